@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"golang.org/x/exp/slices"
+)
+
+// The API runs behind a gateway that authenticates users and forwards their id
+const UserIdHeader = "X-User-Id"
+
+// gin context key holding the id of the current user
+const userIdKey = "auth_user_id"
+
+// request context key holding the id of the current user, read by the models
+// to know who makes a change
+type contextKey struct{}
+
+// Reads the id of the user making the request, anonymous requests are allowed
+func Identify() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIdRaw := c.GetHeader(UserIdHeader)
+		if userIdRaw == "" {
+			c.Next()
+			return
+		}
+
+		userId, err := strconv.ParseUint(userIdRaw, 10, 32)
+		if err != nil || userId == 0 {
+			log.Log.Warn(fmt.Sprintf("Invalid user id: %s", userIdRaw))
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid user id"})
+			c.Abort()
+			return
+		}
+
+		c.Set(userIdKey, uint32(userId))
+		c.Request = c.Request.WithContext(WithUserId(c.Request.Context(), uint32(userId)))
+		c.Next()
+	}
+}
+
+// Rejects anonymous requests and the requests of unknown users
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := UserId(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Authentication required"})
+			c.Abort()
+			return
+		}
+		if !knownUser(c, userId) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Rejects requests that are not made by an admin
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, ok := UserId(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Authentication required"})
+			c.Abort()
+			return
+		}
+		if !IsAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Admin access required"})
+			c.Abort()
+			return
+		}
+		if !knownUser(c, userId) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Whether the user has an account, aborts the request otherwise. The gateway
+// may forward the id of a user deleted since, changes record who made them
+// and can't be made by an unknown user
+func knownUser(c *gin.Context, userId uint32) bool {
+	var count int64
+	err := db.DB.WithContext(c.Request.Context()).Table("users").Where("id = ?", userId).Count(&count).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return false
+	}
+	if count == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unknown user"})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+// Rejects the requests of a user who already has limit requests in flight on
+// the route (ex: exports), anonymous requests share a limit. The limit is per
+// API instance
+func LimitConcurrentRequests(limit int) gin.HandlerFunc {
+	var mutex sync.Mutex
+	inFlight := make(map[uint32]int)
+
+	return func(c *gin.Context) {
+		userId, _ := UserId(c)
+		mutex.Lock()
+		if inFlight[userId] >= limit {
+			mutex.Unlock()
+			c.JSON(http.StatusTooManyRequests, gin.H{"message": "Too many requests", "error": fmt.Sprintf("Limited to %d requests at a time", limit)})
+			c.Abort()
+			return
+		}
+		inFlight[userId]++
+		mutex.Unlock()
+
+		defer func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if inFlight[userId]--; inFlight[userId] == 0 {
+				delete(inFlight, userId)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// Returns the id of the user making the request, if any
+func UserId(c *gin.Context) (uint32, bool) {
+	value, ok := c.Get(userIdKey)
+	if !ok {
+		return 0, false
+	}
+
+	userId, ok := value.(uint32)
+	return userId, ok
+}
+
+// Returns a copy of the context carrying the id of the user
+func WithUserId(ctx context.Context, userId uint32) context.Context {
+	return context.WithValue(ctx, contextKey{}, userId)
+}
+
+// Returns the id of the user carried by the context, if any (ex: not for
+// admin commands)
+func ContextUserId(ctx context.Context) (uint32, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	userId, ok := ctx.Value(contextKey{}).(uint32)
+	return userId, ok
+}
+
+// Returns true when the request is made by one of the configured admins
+func IsAdmin(c *gin.Context) bool {
+	userId, ok := UserId(c)
+	return ok && slices.Contains(config.GetConfig().AdminUserIds, userId)
+}
+
+// Returns true when the request is made by the given user or by an admin
+func IsUserOrAdmin(c *gin.Context, userId uint32) bool {
+	currentUserId, ok := UserId(c)
+	return ok && (currentUserId == userId || IsAdmin(c))
+}
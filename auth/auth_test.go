@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the auth middlewares
+type AuthTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+func setupRouter() *gin.Engine {
+	// don't log API calls
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(Identify())
+
+	router.GET("/public", func(c *gin.Context) {
+		userId, ok := UserId(c)
+		c.JSON(http.StatusOK, gin.H{"user_id": userId, "identified": ok})
+	})
+	router.GET("/context", func(c *gin.Context) {
+		userId, ok := ContextUserId(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"user_id": userId, "identified": ok})
+	})
+	router.GET("/user", RequireUser(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/admin", RequireAdmin(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	return router
+}
+
+func (suite *AuthTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+func (suite *AuthTestSuite) request(path string, userId string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	if userId != "" {
+		req.Header.Set(UserIdHeader, userId)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	return w
+}
+
+func (suite *AuthTestSuite) TestIdentifyAnonymous() {
+	w := suite.request("/public", "")
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.JSONEq(`{"user_id":0,"identified":false}`, w.Body.String())
+	}
+}
+
+func (suite *AuthTestSuite) TestIdentifyUser() {
+	w := suite.request("/public", "3")
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.JSONEq(`{"user_id":3,"identified":true}`, w.Body.String())
+	}
+}
+
+func (suite *AuthTestSuite) TestIdentifyRequestContext() {
+	w := suite.request("/context", "3")
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.JSONEq(`{"user_id":3,"identified":true}`, w.Body.String())
+	}
+
+	w = suite.request("/context", "")
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.JSONEq(`{"user_id":0,"identified":false}`, w.Body.String())
+	}
+}
+
+func (suite *AuthTestSuite) TestIdentifyInvalidUser() {
+	suite.Equal(http.StatusUnauthorized, suite.request("/public", "abc").Code)
+	suite.Equal(http.StatusUnauthorized, suite.request("/public", "0").Code)
+}
+
+func (suite *AuthTestSuite) TestRequireUser() {
+	suite.Equal(http.StatusUnauthorized, suite.request("/user", "").Code)
+	suite.Equal(http.StatusOK, suite.request("/user", "2").Code)
+	// ids of users without an account
+	suite.Equal(http.StatusUnauthorized, suite.request("/user", "999").Code)
+}
+
+func (suite *AuthTestSuite) TestRequireAdmin() {
+	suite.Equal(http.StatusUnauthorized, suite.request("/admin", "").Code)
+	suite.Equal(http.StatusForbidden, suite.request("/admin", "2").Code)
+	// user 1 is the only admin in the test config
+	suite.Equal(http.StatusOK, suite.request("/admin", "1").Code)
+}
+
+func (suite *AuthTestSuite) TestLimitConcurrentRequests() {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	router := gin.New()
+	router.Use(Identify())
+	router.GET("/slow", LimitConcurrentRequests(1), func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	request := func(userId string) int {
+		req, _ := http.NewRequest("GET", "/slow", nil)
+		req.Header.Set(UserIdHeader, userId)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	codes := make(chan int)
+	go func() { codes <- request("2") }()
+	<-started
+
+	// the same user waits for the first request, other users don't
+	suite.Equal(http.StatusTooManyRequests, request("2"))
+	go func() { codes <- request("3") }()
+	<-started
+
+	close(release)
+	suite.Equal(http.StatusOK, <-codes)
+	suite.Equal(http.StatusOK, <-codes)
+	suite.Equal(http.StatusOK, request("2"), "Should allow requests once the first one completed")
+}
+
+func TestAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(AuthTestSuite))
+}
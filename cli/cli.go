@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/images"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/notifications"
+	"github.com/samuelg/rentals/storage"
+)
+
+// Exit codes
+const (
+	exitOk    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// Parts of the application a command needs, config and logging are always set up
+const (
+	needsDb = 1 << iota
+	// storage, images and notifications
+	needsServices
+)
+
+// A subcommand of the rentals binary
+type command struct {
+	name        string
+	args        string
+	description string
+	needs       int
+	run         func(args []string) error
+}
+
+// Returned by commands for invalid arguments, the usage is printed
+var errUsage = errors.New("invalid arguments")
+
+// Where commands write their output
+var stdout io.Writer = os.Stdout
+
+// Global flags and the config values they override
+var globalFlags = []struct {
+	name  string
+	key   string
+	usage string
+}{
+	{"log-level", "log_level", "log level (TRACE, DEBUG, INFO, WARN, ERROR, FATAL)"},
+	{"host", "host", "host the server listens on"},
+	{"port", "port", "port the server listens on"},
+	{"db-driver", "db_driver", "database driver (postgres, sqlite)"},
+	{"db-host", "db_host", "database host"},
+	{"db-port", "db_port", "database port"},
+	{"db-user", "db_user", "database user"},
+	{"db-password", "db_password", "database password"},
+	{"db-name", "db_name", "database name"},
+	{"db-path", "db_path", "sqlite database file"},
+}
+
+func commands() map[string]command {
+	list := []command{
+		{"serve", "", "run the API server (default)", needsDb | needsServices, serve},
+		{"demo", "[-rentals N] [-users N] [-seed N]", "serve synthetic rentals from memory, only the rental reads", 0, demo},
+		{"migrate", "up|down|status [flags]", "manage schema migrations", needsDb, migrate},
+		{"seed", "[-synthetic N [-users N] [-seed N]]", "load the fixtures into an empty database, or synthetic data", needsDb, seed},
+		{"export", "[-query QUERY] [-format F]", "print the rentals matching a /rentals query as NDJSON, CSV or Parquet", needsDb, export},
+		{"import", "-user ID [flags] FILE", "create or update rentals from a CSV or NDJSON file, - for stdin", needsDb, importRentals},
+		{"check-config", "", "print the configuration and check its values", 0, checkConfig},
+		{"backfill-locations", "[-dry-run]", "derive and check the location of rentals", needsDb, backfillLocations},
+		{"normalize-vehicles", "[-dry-run]", "normalize the make and model of rentals", needsDb, normalizeVehicles},
+		{"quality-report", "[-format json|csv]", "check the data quality of rentals", needsDb, qualityReport},
+		{"evaluate-saved-searches", "", "notify users of new rentals matching their saved searches", needsDb | needsServices, evaluateSavedSearches},
+		{"deliver-price-alerts", "", "deliver the queued price drop alerts", needsDb | needsServices, deliverPriceAlerts},
+		{"find-duplicates", "[-min-score S]", "list the rentals that may be listed twice", needsDb, findDuplicates},
+		{"sync-feeds", "[-feed NAME [-force]]", "sync the partner feeds, or a single feed", needsDb | needsServices, syncFeeds},
+	}
+
+	byName := make(map[string]command, len(list))
+	for _, c := range list {
+		byName[c.name] = c
+	}
+	return byName
+}
+
+// Run the command line, returns the exit code
+func Run(args []string) int {
+	flags := flag.NewFlagSet("rentals", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	env := flags.String("env", defaultEnv(), "environment, selects the configuration file")
+	values := make(map[string]*string, len(globalFlags))
+	for _, global := range globalFlags {
+		values[global.name] = flags.String(global.name, "", global.usage+", overrides the configuration")
+	}
+	flags.Usage = func() { usage(flags) }
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	name := "serve"
+	commandArgs := flags.Args()
+	if len(commandArgs) > 0 {
+		name, commandArgs = commandArgs[0], commandArgs[1:]
+	}
+	cmd, ok := commands()[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", name)
+		usage(flags)
+		return exitUsage
+	}
+
+	config.InitWithOverrides(*env, overrides(flags, values))
+	log.Init(config.GetConfig().LogLevel, config.GetConfig().AppVersion)
+	log.Log.Info(fmt.Sprintf("Loaded config for %s environment", *env))
+
+	if cmd.needs&needsDb != 0 {
+		db.Init()
+	}
+	if cmd.needs&needsServices != 0 {
+		storage.Init()
+		images.Init()
+		notifications.Init()
+	}
+
+	if err := cmd.run(commandArgs); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "Usage: rentals [flags] %s %s\n", cmd.name, cmd.args)
+			return exitUsage
+		}
+		log.Log.Error(fmt.Sprintf("%s failed: %v", name, err))
+		return exitError
+	}
+
+	return exitOk
+}
+
+func defaultEnv() string {
+	if value, ok := os.LookupEnv("ENV"); ok {
+		return value
+	}
+	// default to development
+	return "development"
+}
+
+// Config values of the global flags set on the command line
+func overrides(flags *flag.FlagSet, values map[string]*string) map[string]interface{} {
+	keys := make(map[string]string, len(globalFlags))
+	for _, global := range globalFlags {
+		keys[global.name] = global.key
+	}
+
+	result := make(map[string]interface{})
+	flags.Visit(func(f *flag.Flag) {
+		if key, ok := keys[f.Name]; ok {
+			result[key] = *values[f.Name]
+		}
+	})
+
+	return result
+}
+
+func usage(flags *flag.FlagSet) {
+	fmt.Fprintln(os.Stderr, "Usage: rentals [flags] [command] [command flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+
+	byName := commands()
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := byName[name]
+		fmt.Fprintf(os.Stderr, "  %-24s %-24s %s\n", cmd.name, cmd.args, cmd.description)
+	}
+
+	fmt.Fprintln(os.Stderr, "\nFlags:")
+	flags.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "\nConfiguration values can also be set with environment variables, ex: DB_HOST")
+}
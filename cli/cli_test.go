@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the command line
+type CliTestSuite struct {
+	suite.Suite
+	output *bytes.Buffer
+}
+
+func (suite *CliTestSuite) SetupTest() {
+	suite.output = new(bytes.Buffer)
+	stdout = suite.output
+}
+
+func (suite *CliTestSuite) TestUnknownCommand() {
+	suite.Equal(exitUsage, Run([]string{"-env", "test", "nope"}))
+}
+
+func (suite *CliTestSuite) TestUnknownFlag() {
+	suite.Equal(exitUsage, Run([]string{"-nope", "check-config"}))
+}
+
+func (suite *CliTestSuite) TestCheckConfigOverrides() {
+	code := Run([]string{"-env", "test", "-port", "9001", "-db-password", "secret", "check-config"})
+
+	if suite.Equal(exitOk, code) {
+		suite.Equal(uint16(9001), config.GetConfig().Port)
+
+		var values map[string]interface{}
+		if suite.Nil(json.Unmarshal(suite.output.Bytes(), &values), "Should print JSON") {
+			suite.Equal(float64(9001), values["port"])
+			suite.Equal("********", values["db_password"])
+			suite.Equal("testingwithrentals", values["db_name"])
+		}
+	}
+}
+
+func (suite *CliTestSuite) TestCheckConfigInvalid() {
+	suite.Equal(exitError, Run([]string{"-env", "test", "-log-level", "LOUD", "check-config"}))
+}
+
+func (suite *CliTestSuite) TestCommandUsage() {
+	suite.Equal(exitUsage, Run([]string{"-env", "test", "check-config", "extra"}))
+}
+
+func (suite *CliTestSuite) TestDemoUsage() {
+	suite.Equal(exitUsage, Run([]string{"-env", "test", "demo", "-rentals", "0"}))
+}
+
+func TestCliTestSuite(t *testing.T) {
+	suite.Run(t, new(CliTestSuite))
+}
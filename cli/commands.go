@@ -0,0 +1,373 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/samuelg/rentals/notifications"
+	"github.com/samuelg/rentals/server"
+	"github.com/samuelg/rentals/synthetic"
+)
+
+// Number of rentals loaded at once when exporting
+const exportBatchSize = 500
+
+// Print a value as indented JSON
+func printJson(value interface{}) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// Parse the flags of a command, extra arguments are a usage error
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return errUsage
+	}
+	return nil
+}
+
+// Apply pending migrations and run the API server
+func serve(args []string) error {
+	if err := parseFlags(flag.NewFlagSet("serve", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+
+	// instances starting together wait on the migration lock
+	if _, err := db.MigrateUp(); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	return server.Init()
+}
+
+// Run the API server on synthetic rentals held in memory, without a database
+func demo(args []string) error {
+	flags := flag.NewFlagSet("demo", flag.ContinueOnError)
+	rentals := flags.Uint("rentals", 1000, "number of synthetic rentals to generate")
+	users := flags.Int("users", 0, "number of synthetic users owning them, a fifth of the rentals by default")
+	seedValue := flags.Int64("seed", 1, "seed of the synthetic data, the same seed generates the same data")
+	if err := parseFlags(flags, args); err != nil || *users < 0 || *rentals == 0 {
+		return errUsage
+	}
+
+	if *users == 0 {
+		*users = int(max(1, *rentals/5))
+	}
+	repository := models.NewMemoryRentalRepository(synthetic.Generate(*seedValue, *users, *rentals))
+	log.Log.Info(fmt.Sprintf("Generated %d rentals owned by %d users", *rentals, *users))
+
+	return server.Demo(repository)
+}
+
+// Apply or revert schema migrations, or print their status as JSON
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "up":
+		if err := parseFlags(flag.NewFlagSet("migrate up", flag.ContinueOnError), args[1:]); err != nil {
+			return err
+		}
+
+		applied, err := db.MigrateUp()
+		if err != nil {
+			return err
+		}
+		log.Log.Info(fmt.Sprintf("Applied %d migrations", len(applied)))
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := flags.Int("steps", 1, "number of migrations to revert")
+		if err := parseFlags(flags, args[1:]); err != nil || *steps < 1 {
+			return errUsage
+		}
+
+		reverted, err := db.MigrateDown(*steps)
+		if err != nil {
+			return err
+		}
+		log.Log.Info(fmt.Sprintf("Reverted %d migrations", len(reverted)))
+	case "status":
+		statuses, err := db.MigrationStatuses()
+		if err != nil {
+			return err
+		}
+		return printJson(statuses)
+	default:
+		return errUsage
+	}
+
+	return nil
+}
+
+// Load the fixtures into a database without users, or generate synthetic
+// users and rentals
+func seed(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	rentals := flags.Uint("synthetic", 0, "number of synthetic rentals to generate instead of loading the fixtures")
+	users := flags.Int("users", 0, "number of synthetic users owning them, a fifth of the rentals by default")
+	seedValue := flags.Int64("seed", 1, "seed of the synthetic data, the same seed generates the same data")
+	if err := parseFlags(flags, args); err != nil || *users < 0 {
+		return errUsage
+	}
+
+	if *rentals > 0 {
+		if *users == 0 {
+			*users = int(max(1, *rentals/5))
+		}
+		report, err := synthetic.Load(context.Background(), *seedValue, *users, *rentals)
+		if err != nil {
+			return err
+		}
+		return printJson(report)
+	}
+
+	loaded, err := db.LoadFixtures()
+	if err != nil {
+		return err
+	}
+	if !loaded {
+		log.Log.Info("Database already has users, fixtures not loaded")
+	}
+
+	return nil
+}
+
+// Print the rentals matching a /rentals query as NDJSON, CSV or Parquet
+func export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	query := flags.String("query", "", "/rentals query, ex: price_min=9000&amenities=kitchen")
+	format := flags.String("format", models.ExportNdjson, "export format, ndjson, csv or parquet")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	filter, err := models.ParseQueryString(*query)
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	writer, err := models.NewRentalWriter(stdout, *format)
+	if err != nil {
+		return errUsage
+	}
+
+	if err := filter.Stream(context.Background(), exportBatchSize, writer.Write); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// Create or update the rentals of a CSV or NDJSON file, prints a JSON report
+func importRentals(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	userId := flags.Uint("user", 0, "id of the user owning the imported rentals")
+	format := flags.String("format", "", "file format, csv or ndjson, from the file extension by default")
+	transaction := flags.String("transaction", "", "transaction policy, row or all, import_transaction by default")
+	dryRun := flags.Bool("dry-run", false, "report the outcome of every row without saving them")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 || *userId == 0 {
+		return errUsage
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		switch filepath.Ext(path) {
+		case ".csv":
+			*format = models.ImportCsv
+		case ".ndjson", ".jsonl":
+			*format = models.ImportNdjson
+		default:
+			return errUsage
+		}
+	}
+
+	file := os.Stdin
+	if path != "-" {
+		var err error
+		if file, err = os.Open(path); err != nil {
+			return err
+		}
+		defer file.Close()
+	}
+
+	report, err := models.ImportRentals(context.Background(), file, models.ImportOptions{
+		UserId:      uint32(*userId),
+		Format:      *format,
+		Transaction: *transaction,
+		DryRun:      *dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJson(report)
+}
+
+// Print the duplicate candidates as JSON, best scores first
+func findDuplicates(args []string) error {
+	flags := flag.NewFlagSet("find-duplicates", flag.ContinueOnError)
+	minScore := flags.Float64("min-score", config.GetConfig().DuplicateMinScore, "lowest score listed, from 0 to 1")
+	if err := parseFlags(flags, args); err != nil || *minScore < 0 || *minScore > 1 {
+		return errUsage
+	}
+
+	candidates, err := models.FindDuplicates(*minScore)
+	if err != nil {
+		return err
+	}
+
+	return printJson(candidates)
+}
+
+// Sync the partner feeds, or a single feed by name, prints a JSON report
+func syncFeeds(args []string) error {
+	flags := flag.NewFlagSet("sync-feeds", flag.ContinueOnError)
+	name := flags.String("feed", "", "name of the feed to sync, all the feeds by default")
+	force := flags.Bool("force", false, "sync the feed even when its content didn't change")
+	if err := parseFlags(flags, args); err != nil || (*force && *name == "") {
+		return errUsage
+	}
+
+	if *name == "" {
+		report, err := models.SyncFeeds(context.Background())
+		if err != nil {
+			return err
+		}
+		return printJson(report)
+	}
+
+	var feed models.Feed
+	if err := db.DB.Where("name = ?", *name).First(&feed).Error; err != nil {
+		return fmt.Errorf("feed %s: %w", *name, err)
+	}
+	sync, err := models.SyncFeed(context.Background(), &feed, *force)
+	if err != nil {
+		return err
+	}
+	if err := printJson(sync); err != nil {
+		return err
+	}
+	if sync.Status == models.FeedFailed {
+		return errors.New(sync.Error)
+	}
+	return nil
+}
+
+// Print the configuration with secrets masked, fails when values are invalid
+func checkConfig(args []string) error {
+	if err := parseFlags(flag.NewFlagSet("check-config", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+
+	if err := printJson(configValues(config.GetConfig())); err != nil {
+		return err
+	}
+
+	return config.GetConfig().Validate()
+}
+
+// Configuration keyed like the configuration files, secrets are masked
+func configValues(c *config.Config) map[string]interface{} {
+	values := make(map[string]interface{})
+	reflected := reflect.ValueOf(*c)
+	for i := 0; i < reflected.NumField(); i++ {
+		key := reflected.Type().Field(i).Tag.Get("mapstructure")
+		values[key] = reflected.Field(i).Interface()
+		if strings.HasSuffix(key, "_password") && values[key] != "" {
+			values[key] = "********"
+		}
+	}
+
+	return values
+}
+
+// Derive and check the location of existing rentals, prints a JSON report
+func backfillLocations(args []string) error {
+	flags := flag.NewFlagSet("backfill-locations", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report changes without saving them")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	report, err := models.BackfillLocations(*dryRun)
+	if err != nil {
+		return err
+	}
+
+	return printJson(report)
+}
+
+// Normalize the make and model of existing rentals, prints a JSON report
+func normalizeVehicles(args []string) error {
+	flags := flag.NewFlagSet("normalize-vehicles", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report changes without saving them")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	report, err := models.NormalizeVehicles(*dryRun)
+	if err != nil {
+		return err
+	}
+
+	return printJson(report)
+}
+
+// Check the data quality of all rentals, prints the report as JSON or CSV
+func qualityReport(args []string) error {
+	flags := flag.NewFlagSet("quality-report", flag.ContinueOnError)
+	format := flags.String("format", "json", "report format, json or csv")
+	if err := parseFlags(flags, args); err != nil || (*format != "json" && *format != "csv") {
+		return errUsage
+	}
+
+	report, err := models.CheckRentalQuality()
+	if err != nil {
+		return err
+	}
+
+	if *format == "csv" {
+		return report.WriteCsv(stdout)
+	}
+	return printJson(report)
+}
+
+// Notify users of new rentals matching their saved searches, prints a JSON report
+func evaluateSavedSearches(args []string) error {
+	if err := parseFlags(flag.NewFlagSet("evaluate-saved-searches", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+
+	report, err := models.EvaluateSavedSearches(context.Background(), notifications.Default)
+	if err != nil {
+		return err
+	}
+
+	return printJson(report)
+}
+
+// Deliver the queued price drop alerts, prints a JSON report
+func deliverPriceAlerts(args []string) error {
+	if err := parseFlags(flag.NewFlagSet("deliver-price-alerts", flag.ContinueOnError), args); err != nil {
+		return err
+	}
+
+	report, err := models.DeliverPriceAlerts(context.Background(), notifications.Default)
+	if err != nil {
+		return err
+	}
+
+	return printJson(report)
+}
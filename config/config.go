@@ -1,7 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/spf13/viper"
+	"golang.org/x/exp/slices"
 	"log" // logrus won't be initialized yet
 )
 
@@ -10,17 +15,80 @@ type Config struct {
 	Host            string `mapstructure:"host"`
 	Port            uint16 `mapstructure:"port"`
 	LogLevel        string `mapstructure:"log_level"`
+	DbDriver        string `mapstructure:"db_driver"` // postgres, or sqlite for development without PostGIS
 	DbHost          string `mapstructure:"db_host"`
 	DbPort          uint16 `mapstructure:"db_port"`
 	DbUser          string `mapstructure:"db_user"`
 	DbPassword      string `mapstructure:"db_password"`
 	DbName          string `mapstructure:"db_name"`
+	DbPath          string `mapstructure:"db_path"` // file of the sqlite database
 	DefaultApiLimit uint8  `mapstructure:"default_api_limit"`
+	// users allowed to manage catalogs and read any rental data
+	AdminUserIds []uint32 `mapstructure:"admin_user_ids"`
+	// where uploaded images are stored, the local driver stores them in
+	// storage_dir and serves them under storage_base_url
+	StorageDriver  string `mapstructure:"storage_driver"`
+	StorageDir     string `mapstructure:"storage_dir"`
+	StorageBaseUrl string `mapstructure:"storage_base_url"`
+	MaxUploadBytes int64  `mapstructure:"max_upload_bytes"`
+	// images with more pixels (width x height) are rejected before being decoded
+	MaxImagePixels int64 `mapstructure:"max_image_pixels"`
+	// resized variants served by the image proxy are cached on disk
+	ImageCacheDir      string `mapstructure:"image_cache_dir"`
+	ImageCacheMaxBytes int64  `mapstructure:"image_cache_max_bytes"`
+	// in seconds
+	ImageFetchTimeout uint16 `mapstructure:"image_fetch_timeout"`
+	// how notifications are sent: log, file (JSON lines in notifier_file) or smtp
+	Notifier     string `mapstructure:"notifier"`
+	NotifierFile string `mapstructure:"notifier_file"`
+	SmtpHost     string `mapstructure:"smtp_host"`
+	SmtpPort     uint16 `mapstructure:"smtp_port"`
+	SmtpFrom     string `mapstructure:"smtp_from"`
+	SmtpUsername string `mapstructure:"smtp_username"`
+	SmtpPassword string `mapstructure:"smtp_password"`
+	// in seconds, limits emails sent without a deadline (ex: by scheduled jobs)
+	SmtpTimeout uint16 `mapstructure:"smtp_timeout"`
+	// seconds between saved search evaluations, 0 disables them in the server
+	SavedSearchInterval uint32 `mapstructure:"saved_search_interval"`
+	// seconds between price alert deliveries, 0 disables them in the server
+	PriceAlertInterval uint32 `mapstructure:"price_alert_interval"`
+	// transaction policy of imports without one: row saves every valid row,
+	// all saves nothing when a row is rejected
+	ImportTransaction string `mapstructure:"import_transaction"`
+	// largest file accepted by POST /rentals/import
+	MaxImportBytes int64 `mapstructure:"max_import_bytes"`
+	// exports a user can run at once on an API instance
+	MaxConcurrentExports uint8 `mapstructure:"max_concurrent_exports"`
+	// seconds between partner feed syncs, 0 disables them in the server
+	FeedSyncInterval uint32 `mapstructure:"feed_sync_interval"`
+	// in seconds
+	FeedFetchTimeout uint16 `mapstructure:"feed_fetch_timeout"`
+	// largest feed content read from a URL or a drop directory
+	MaxFeedBytes int64 `mapstructure:"max_feed_bytes"`
+	// feed URLs may reach private addresses (ex: local stand-ins), only public
+	// ones otherwise
+	FeedAllowPrivateAddresses bool `mapstructure:"feed_allow_private_addresses"`
+	// lowest score of the duplicate candidates listed for review, from 0 to 1
+	DuplicateMinScore float64 `mapstructure:"duplicate_min_score"`
+	// timeouts of the server connections in seconds, 0 disables one. Exports
+	// are not limited by the write timeout
+	ReadTimeout  uint16 `mapstructure:"read_timeout"`
+	WriteTimeout uint16 `mapstructure:"write_timeout"`
+	IdleTimeout  uint16 `mapstructure:"idle_timeout"`
+	// seconds the requests in flight have to complete once the server is asked
+	// to stop (SIGINT, SIGTERM)
+	ShutdownTimeout uint16 `mapstructure:"shutdown_timeout"`
 }
 
 var parsedConfig Config
 
 func Init(env string) {
+	InitWithOverrides(env, nil)
+}
+
+// Load the configuration of the environment, overrides take precedence over
+// environment variables and the configuration file (ex: command line flags)
+func InitWithOverrides(env string, overrides map[string]interface{}) {
 	v := viper.New()
 	v.SetConfigType("yaml")
 	v.SetConfigName(env)
@@ -31,15 +99,112 @@ func Init(env string) {
 	v.SetDefault("app_version", "1.0.0")
 	v.SetDefault("host", "0.0.0.0")
 	v.SetDefault("port", 8080)
+	v.SetDefault("db_driver", "postgres")
+	v.SetDefault("db_path", "rentals.db")
+	v.SetDefault("admin_user_ids", []uint32{})
+	v.SetDefault("storage_driver", "local")
+	v.SetDefault("storage_dir", "uploads")
+	v.SetDefault("storage_base_url", "/media")
+	v.SetDefault("max_upload_bytes", 10<<20)
+	v.SetDefault("max_image_pixels", 50_000_000)
+	v.SetDefault("image_cache_dir", "cache/images")
+	v.SetDefault("image_cache_max_bytes", 512<<20)
+	v.SetDefault("image_fetch_timeout", 10)
+	v.SetDefault("notifier", "log")
+	v.SetDefault("notifier_file", "notifications.log")
+	v.SetDefault("smtp_host", "localhost")
+	v.SetDefault("smtp_port", 25)
+	v.SetDefault("smtp_from", "rentals@localhost")
+	v.SetDefault("smtp_timeout", 30)
+	v.SetDefault("saved_search_interval", 3600)
+	v.SetDefault("price_alert_interval", 60)
+	v.SetDefault("import_transaction", "row")
+	v.SetDefault("max_import_bytes", 50<<20)
+	v.SetDefault("max_concurrent_exports", 2)
+	v.SetDefault("feed_sync_interval", 3600)
+	v.SetDefault("feed_fetch_timeout", 30)
+	v.SetDefault("max_feed_bytes", 100<<20)
+	v.SetDefault("feed_allow_private_addresses", false)
+	v.SetDefault("duplicate_min_score", 0.8)
+	v.SetDefault("read_timeout", 30)
+	v.SetDefault("write_timeout", 60)
+	v.SetDefault("idle_timeout", 120)
+	v.SetDefault("shutdown_timeout", 30)
 
 	if err := v.ReadInConfig(); err != nil {
 		log.Fatalf("error parsing configuration file, %v", err)
 	}
+	for key, value := range overrides {
+		v.Set(key, value)
+	}
 	if err := v.Unmarshal(&parsedConfig); err != nil {
 		log.Fatalf("unable to decode into struct, %v", err)
 	}
 }
 
+// Check the values that would otherwise only fail once used, returns all the
+// problems found
+func (c *Config) Validate() error {
+	problems := make([]string, 0)
+
+	if !slices.Contains([]string{"", "TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}, c.LogLevel) {
+		problems = append(problems, fmt.Sprintf("unknown log_level %s", c.LogLevel))
+	}
+	if c.Port == 0 {
+		problems = append(problems, "port is required")
+	}
+	switch c.DbDriver {
+	case "postgres":
+		if c.DbHost == "" || c.DbPort == 0 || c.DbName == "" {
+			problems = append(problems, "db_host, db_port and db_name are required")
+		}
+	case "sqlite":
+		if c.DbPath == "" {
+			problems = append(problems, "db_path is required by the sqlite driver")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown db_driver %s", c.DbDriver))
+	}
+	if c.DefaultApiLimit == 0 || c.DefaultApiLimit > 100 {
+		problems = append(problems, "default_api_limit must be between 1 and 100")
+	}
+	if c.StorageDriver != "local" {
+		problems = append(problems, fmt.Sprintf("unknown storage_driver %s", c.StorageDriver))
+	}
+	if c.MaxConcurrentExports == 0 {
+		problems = append(problems, "max_concurrent_exports must be positive")
+	}
+	if c.MaxImagePixels <= 0 {
+		problems = append(problems, "max_image_pixels must be positive")
+	}
+	if !slices.Contains([]string{"log", "file", "smtp"}, c.Notifier) {
+		problems = append(problems, fmt.Sprintf("unknown notifier %s", c.Notifier))
+	}
+	if c.Notifier == "file" && c.NotifierFile == "" {
+		problems = append(problems, "notifier_file is required by the file notifier")
+	}
+	if c.Notifier == "smtp" && (c.SmtpHost == "" || c.SmtpPort == 0 || c.SmtpFrom == "") {
+		problems = append(problems, "smtp_host, smtp_port and smtp_from are required by the smtp notifier")
+	}
+	if !slices.Contains([]string{"row", "all"}, c.ImportTransaction) {
+		problems = append(problems, fmt.Sprintf("unknown import_transaction %s", c.ImportTransaction))
+	}
+	if c.FeedFetchTimeout == 0 || c.MaxFeedBytes <= 0 {
+		problems = append(problems, "feed_fetch_timeout and max_feed_bytes must be positive")
+	}
+	if c.DuplicateMinScore < 0 || c.DuplicateMinScore > 1 {
+		problems = append(problems, "duplicate_min_score must be between 0 and 1")
+	}
+	if c.ShutdownTimeout == 0 {
+		problems = append(problems, "shutdown_timeout must be positive")
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
 func GetConfig() *Config {
 	return &parsedConfig
 }
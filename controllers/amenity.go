@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"gorm.io/gorm"
+)
+
+type AmenityController struct{}
+
+// Request body to create or update an amenity
+type amenityRequest struct {
+	Key  string `json:"key" binding:"required"`
+	Name string `json:"name" binding:"required"`
+}
+
+// GET /amenities
+func (u AmenityController) List(c *gin.Context) {
+	amenities := make([]models.Amenity, 0)
+	if err := db.DB.Order("key").Find(&amenities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": amenities})
+}
+
+// GET /amenities/:amenity_id
+func (u AmenityController) Get(c *gin.Context) {
+	amenity, ok := findAmenity(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, amenity)
+}
+
+// POST /amenities
+func (u AmenityController) Create(c *gin.Context) {
+	request, ok := bindAmenity(c)
+	if !ok {
+		return
+	}
+
+	amenity := models.Amenity{Key: request.Key, Name: request.Name}
+	if err := db.DB.Create(&amenity).Error; err != nil {
+		amenityWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, amenity)
+}
+
+// PUT /amenities/:amenity_id
+func (u AmenityController) Update(c *gin.Context) {
+	amenity, ok := findAmenity(c)
+	if !ok {
+		return
+	}
+	request, ok := bindAmenity(c)
+	if !ok {
+		return
+	}
+
+	amenity.Key = request.Key
+	amenity.Name = request.Name
+	if err := db.DB.Save(amenity).Error; err != nil {
+		amenityWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, amenity)
+}
+
+// DELETE /amenities/:amenity_id
+func (u AmenityController) Delete(c *gin.Context) {
+	amenity, ok := findAmenity(c)
+	if !ok {
+		return
+	}
+
+	// rental associations are removed by the foreign key cascade
+	if err := db.DB.Delete(amenity).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Load the amenity from the route, responds with an error when it can't be found
+func findAmenity(c *gin.Context) (*models.Amenity, bool) {
+	amenityId, err := strconv.ParseInt(c.Param("amenity_id"), 10, 32)
+	if err != nil {
+		log.Log.Warn(fmt.Sprintf("Invalid amenity id: %s", c.Param("amenity_id")))
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid amenity id"})
+		c.Abort()
+		return nil, false
+	}
+
+	var amenity models.Amenity
+	if result := db.DB.First(&amenity, amenityId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Amenity not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": result.Error.Error()})
+		}
+		c.Abort()
+		return nil, false
+	}
+
+	return &amenity, true
+}
+
+// Parse and validate the amenity request body
+func bindAmenity(c *gin.Context) (*amenityRequest, bool) {
+	var request amenityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid amenity", "error": err.Error()})
+		c.Abort()
+		return nil, false
+	}
+	if !models.ValidAmenityKey(request.Key) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid amenity", "error": "Invalid key"})
+		c.Abort()
+		return nil, false
+	}
+
+	return &request, true
+}
+
+func amenityWriteError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"message": "Amenity key already exists"})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+	}
+	c.Abort()
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the Amenity controller
+type AmenityControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+func (suite *AmenityControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+// GET /amenities tests
+func (suite *AmenityControllerTestSuite) TestListAmenitiesSuccess() {
+	req, _ := http.NewRequest("GET", "/amenities/", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response struct {
+			Data []models.Amenity `json:"data"`
+		}
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.GreaterOrEqual(len(response.Data), 8)
+			// sorted on key
+			suite.Equal("4wd", response.Data[0].Key)
+		}
+	}
+}
+
+// GET /amenities/:amenity_id tests
+func (suite *AmenityControllerTestSuite) TestGetAmenitySuccess() {
+	req, _ := http.NewRequest("GET", "/amenities/1", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response models.Amenity
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.Equal("pets", response.Key)
+			suite.Equal("Pet friendly", response.Name)
+		}
+	}
+}
+
+func (suite *AmenityControllerTestSuite) TestGetAmenityNotFound() {
+	req, _ := http.NewRequest("GET", "/amenities/1000", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+// POST /amenities tests
+func (suite *AmenityControllerTestSuite) TestCreateAmenityRequiresAdmin() {
+	req, _ := http.NewRequest("POST", "/amenities/", strings.NewReader(`{"key":"wifi","name":"Wifi"}`))
+	// user 1 is the only admin in the test config
+	req.Header.Set(auth.UserIdHeader, "2")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusForbidden, w.Code)
+}
+
+func (suite *AmenityControllerTestSuite) TestCreateAmenityInvalidKey() {
+	req, _ := http.NewRequest("POST", "/amenities/", strings.NewReader(`{"key":"Wi Fi","name":"Wifi"}`))
+	req.Header.Set(auth.UserIdHeader, "1")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (suite *AmenityControllerTestSuite) TestCreateAmenityDuplicateKey() {
+	req, _ := http.NewRequest("POST", "/amenities/", strings.NewReader(`{"key":"pets","name":"Pets"}`))
+	req.Header.Set(auth.UserIdHeader, "1")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusConflict, w.Code)
+}
+
+func (suite *AmenityControllerTestSuite) TestCreateUpdateDeleteAmenity() {
+	req, _ := http.NewRequest("POST", "/amenities/", strings.NewReader(`{"key":"wifi","name":"Wifi"}`))
+	req.Header.Set(auth.UserIdHeader, "1")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	var amenity models.Amenity
+	if !suite.Equal(http.StatusCreated, w.Code) || !suite.Nil(json.Unmarshal(w.Body.Bytes(), &amenity)) {
+		return
+	}
+	path := fmt.Sprintf("/amenities/%d", amenity.ID)
+
+	req, _ = http.NewRequest("PUT", path, strings.NewReader(`{"key":"wifi","name":"Wireless internet"}`))
+	req.Header.Set(auth.UserIdHeader, "1")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.Nil(json.Unmarshal(w.Body.Bytes(), &amenity))
+		suite.Equal("Wireless internet", amenity.Name)
+	}
+
+	req, _ = http.NewRequest("DELETE", path, nil)
+	req.Header.Set(auth.UserIdHeader, "1")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Equal(http.StatusNoContent, w.Code)
+}
+
+func TestAmenityControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(AmenityControllerTestSuite))
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/models"
+	"gorm.io/gorm"
+)
+
+type DuplicateController struct{}
+
+// Request body to dismiss a duplicate candidate
+type dismissDuplicateRequest struct {
+	RentalIds []uint32 `json:"rental_ids" binding:"required,len=2,unique"`
+}
+
+// Request body to merge a duplicate into the rental kept
+type mergeDuplicateRequest struct {
+	Keep   uint32 `json:"keep" binding:"required"`
+	Remove uint32 `json:"remove" binding:"required,nefield=Keep"`
+}
+
+// Response for the list operation, note that his is only used to marshal results
+type duplicateListResponse struct {
+	Pagigation *PaginationResponse         `json:"pagination"`
+	Data       []models.DuplicateCandidate `json:"data"`
+}
+
+// GET /admin/rentals/duplicates
+func (u DuplicateController) List(c *gin.Context) {
+	minScore := config.GetConfig().DuplicateMinScore
+	if raw, ok := c.GetQuery("min_score"); ok {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid min_score", "error": "Expected a number between 0 and 1"})
+			c.Abort()
+			return
+		}
+		minScore = parsed
+	}
+
+	limit, offset, err := models.ParsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid pagination", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	candidates, err := models.FindDuplicates(minScore)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	count := uint32(len(candidates))
+	page := candidates[min(offset, count):min(offset+uint32(limit), count)]
+	c.JSON(http.StatusOK, &duplicateListResponse{
+		Pagigation: &PaginationResponse{
+			Count:  count,
+			Limit:  limit,
+			Offset: offset,
+		},
+		Data: page,
+	})
+}
+
+// POST /admin/rentals/duplicates/dismiss
+func (u DuplicateController) Dismiss(c *gin.Context) {
+	var request dismissDuplicateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid duplicate", "error": err.Error()})
+		c.Abort()
+		return
+	}
+	if _, ok := loadDuplicates(c, request.RentalIds[0], request.RentalIds[1]); !ok {
+		return
+	}
+
+	if err := models.DismissDuplicate(c.Request.Context(), request.RentalIds[0], request.RentalIds[1]); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// POST /admin/rentals/duplicates/merge
+func (u DuplicateController) Merge(c *gin.Context) {
+	var request mergeDuplicateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid merge", "error": err.Error()})
+		c.Abort()
+		return
+	}
+	rentals, ok := loadDuplicates(c, request.Keep, request.Remove)
+	if !ok {
+		return
+	}
+
+	if err := models.MergeDuplicate(c.Request.Context(), rentals[0], rentals[1]); err != nil {
+		if errors.Is(err, models.ErrMergeOwners) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid merge", "error": err.Error()})
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Rental not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	var kept models.Rental
+	query := db.DB.Joins("User").Preload("Amenities").Preload("Images", models.OrderImages)
+	if err := query.First(&kept, request.Keep).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, kept)
+}
+
+// Load both rentals of a candidate in the given order, responds with an error
+// when one can't be found
+func loadDuplicates(c *gin.Context, rentalId uint32, otherRentalId uint32) ([2]*models.Rental, bool) {
+	var rentals [2]*models.Rental
+	for i, id := range []uint32{rentalId, otherRentalId} {
+		var rental models.Rental
+		if result := db.DB.First(&rental, id); result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Rental not found", "error": fmt.Sprintf("No rental %d", id)})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": result.Error.Error()})
+			}
+			c.Abort()
+			return rentals, false
+		}
+		rentals[i] = &rental
+	}
+
+	return rentals, true
+}
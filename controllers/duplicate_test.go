@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the Duplicate controller
+type DuplicateControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+func (suite *DuplicateControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+func (suite *DuplicateControllerTestSuite) request(method string, path string, body io.Reader, userId string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, body)
+	if userId != "" {
+		req.Header.Set(auth.UserIdHeader, userId)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	return w
+}
+
+// GET /admin/rentals/duplicates tests
+func (suite *DuplicateControllerTestSuite) TestList() {
+	w := suite.request("GET", "/admin/rentals/duplicates?min_score=0&limit=5", nil, "1")
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response duplicateListResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.LessOrEqual(len(response.Data), 5)
+			suite.GreaterOrEqual(response.Pagigation.Count, uint32(len(response.Data)))
+			for i := 1; i < len(response.Data); i++ {
+				suite.GreaterOrEqual(response.Data[i-1].Score, response.Data[i].Score)
+			}
+		}
+	}
+}
+
+func (suite *DuplicateControllerTestSuite) TestListInvalidMinScore() {
+	w := suite.request("GET", "/admin/rentals/duplicates?min_score=2", nil, "1")
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (suite *DuplicateControllerTestSuite) TestAdminOnly() {
+	w := suite.request("GET", "/admin/rentals/duplicates", nil, "3")
+	suite.Equal(http.StatusForbidden, w.Code)
+
+	w = suite.request("POST", "/admin/rentals/duplicates/merge", strings.NewReader(`{"keep":1,"remove":2}`), "")
+	suite.Equal(http.StatusUnauthorized, w.Code)
+}
+
+// POST /admin/rentals/duplicates/dismiss tests
+func (suite *DuplicateControllerTestSuite) TestDismissInvalid() {
+	w := suite.request("POST", "/admin/rentals/duplicates/dismiss", strings.NewReader(`{"rental_ids":[1]}`), "1")
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	w = suite.request("POST", "/admin/rentals/duplicates/dismiss", strings.NewReader(`{"rental_ids":[1,1]}`), "1")
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	w = suite.request("POST", "/admin/rentals/duplicates/dismiss", strings.NewReader(`{"rental_ids":[1,999999]}`), "1")
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+// POST /admin/rentals/duplicates/merge tests
+func (suite *DuplicateControllerTestSuite) TestMergeInvalid() {
+	w := suite.request("POST", "/admin/rentals/duplicates/merge", strings.NewReader(`{"keep":1,"remove":1}`), "1")
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	w = suite.request("POST", "/admin/rentals/duplicates/merge", strings.NewReader(`{"keep":1,"remove":999999}`), "1")
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+func (suite *DuplicateControllerTestSuite) TestMergeDifferentOwners() {
+	// fixture rentals 1 and 2 have different owners
+	w := suite.request("POST", "/admin/rentals/duplicates/merge", strings.NewReader(`{"keep":1,"remove":2}`), "1")
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestDuplicateControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(DuplicateControllerTestSuite))
+}
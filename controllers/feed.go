@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/feeds"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"gorm.io/gorm"
+)
+
+type FeedController struct{}
+
+// Request body to create or update a partner feed
+type feedRequest struct {
+	Name         string        `json:"name" binding:"required,max=100"`
+	UserId       uint32        `json:"user_id" binding:"required"`
+	Source       string        `json:"source" binding:"required"`
+	Format       string        `json:"format" binding:"required,oneof=json xml"`
+	ListingsPath string        `json:"listings_path"`
+	Mapping      feeds.Mapping `json:"mapping" binding:"required"`
+}
+
+// Response for the list of syncs, note that his is only used to marshal results
+type feedSyncListResponse struct {
+	Pagigation *PaginationResponse `json:"pagination"`
+	Data       []models.FeedSync   `json:"data"`
+}
+
+// GET /admin/feeds
+func (u FeedController) List(c *gin.Context) {
+	feedList := make([]models.Feed, 0)
+	if err := db.DB.Order("name").Find(&feedList).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": feedList})
+}
+
+// POST /admin/feeds
+func (u FeedController) Create(c *gin.Context) {
+	request, ok := bindFeed(c)
+	if !ok {
+		return
+	}
+
+	feed := models.Feed{}
+	request.apply(&feed)
+	if err := db.DB.Create(&feed).Error; err != nil {
+		feedWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, feed)
+}
+
+// PUT /admin/feeds/:feed_id
+func (u FeedController) Update(c *gin.Context) {
+	feed, ok := findFeed(c)
+	if !ok {
+		return
+	}
+	request, ok := bindFeed(c)
+	if !ok {
+		return
+	}
+	// the rentals of the items belong to the owner, they would be left behind
+	if request.UserId != feed.UserId {
+		var items int64
+		if err := db.DB.Model(&models.FeedItem{}).Where("feed_id = ?", feed.ID).Count(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+			c.Abort()
+			return
+		}
+		if items > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid feed", "error": "user_id can't change once rentals are synced, create another feed"})
+			c.Abort()
+			return
+		}
+	}
+
+	request.apply(feed)
+	// the same content maps to different rentals
+	feed.ContentHash = ""
+	if err := db.DB.Save(feed).Error; err != nil {
+		feedWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, feed)
+}
+
+// DELETE /admin/feeds/:feed_id
+func (u FeedController) Delete(c *gin.Context) {
+	feed, ok := findFeed(c)
+	if !ok {
+		return
+	}
+
+	// rentals of the feed are kept, items and syncs are removed by the database
+	if err := db.DB.Delete(feed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// POST /admin/feeds/:feed_id/sync
+func (u FeedController) Sync(c *gin.Context) {
+	feed, ok := findFeed(c)
+	if !ok {
+		return
+	}
+	force, err := strconv.ParseBool(c.DefaultQuery("force", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid force", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	sync, err := models.SyncFeed(c.Request.Context(), feed, force)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	// failed syncs are reported in the body, the request itself succeeded
+	c.JSON(http.StatusOK, sync)
+}
+
+// GET /admin/feeds/:feed_id/syncs
+func (u FeedController) Syncs(c *gin.Context) {
+	feed, ok := findFeed(c)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := models.ParsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid pagination", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	syncs, count, err := models.FindFeedSyncs(feed.ID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, &feedSyncListResponse{
+		Pagigation: &PaginationResponse{
+			Count:  count,
+			Limit:  limit,
+			Offset: offset,
+		},
+		Data: syncs,
+	})
+}
+
+// Load the feed from the route, responds with an error when it can't be found
+func findFeed(c *gin.Context) (*models.Feed, bool) {
+	feedId, err := strconv.ParseInt(c.Param("feed_id"), 10, 32)
+	if err != nil {
+		log.Log.Warn(fmt.Sprintf("Invalid feed id: %s", c.Param("feed_id")))
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid feed id"})
+		c.Abort()
+		return nil, false
+	}
+
+	var feed models.Feed
+	if result := db.DB.First(&feed, feedId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Feed not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": result.Error.Error()})
+		}
+		c.Abort()
+		return nil, false
+	}
+
+	return &feed, true
+}
+
+// Parse and validate the feed request body
+func bindFeed(c *gin.Context) (*feedRequest, bool) {
+	var request feedRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid feed", "error": err.Error()})
+		c.Abort()
+		return nil, false
+	}
+	if err := models.ValidateFeedMapping(request.Mapping); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid feed", "error": fmt.Sprintf("Invalid mapping: %v", err)})
+		c.Abort()
+		return nil, false
+	}
+	var count int64
+	if err := db.DB.Model(&models.User{}).Where("id = ?", request.UserId).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return nil, false
+	}
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid feed", "error": "Unknown user"})
+		c.Abort()
+		return nil, false
+	}
+
+	return &request, true
+}
+
+func (request *feedRequest) apply(feed *models.Feed) {
+	feed.Name = request.Name
+	feed.UserId = request.UserId
+	feed.Source = request.Source
+	feed.Format = request.Format
+	feed.ListingsPath = request.ListingsPath
+	feed.Mapping = request.Mapping
+}
+
+func feedWriteError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{"message": "Feed name already exists"})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+	}
+	c.Abort()
+}
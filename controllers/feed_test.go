@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the Feed controller
+type FeedControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+func (suite *FeedControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+func (suite *FeedControllerTestSuite) request(method string, path string, body io.Reader, userId string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, body)
+	if userId != "" {
+		req.Header.Set(auth.UserIdHeader, userId)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	return w
+}
+
+// Body of a feed reading JSON files from the directory
+func feedBody(name string, dir string, mapping string) io.Reader {
+	return strings.NewReader(fmt.Sprintf(`{"name":%q,"user_id":2,"source":%q,"format":"json","listings_path":"vehicles","mapping":%s}`, name, dir, mapping))
+}
+
+const validFeedMapping = `{"external_id":"id","name":"title","type":"kind","sleeps":"berths","price_day":"rate","lat":"lat","lng":"lng"}`
+
+// Admin routes tests
+func (suite *FeedControllerTestSuite) TestAdminOnly() {
+	w := suite.request("GET", "/admin/feeds", nil, "3")
+	suite.Equal(http.StatusForbidden, w.Code)
+
+	w = suite.request("POST", "/admin/feeds/1/sync", nil, "")
+	suite.Equal(http.StatusUnauthorized, w.Code)
+}
+
+// POST /admin/feeds tests
+func (suite *FeedControllerTestSuite) TestCreateInvalidMapping() {
+	w := suite.request("POST", "/admin/feeds", feedBody("invalid", "/tmp", `{"name":"title"}`), "1")
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	w = suite.request("POST", "/admin/feeds", feedBody("invalid", "/tmp", `{"external_id":"id","colour":"paint"}`), "1")
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (suite *FeedControllerTestSuite) TestCreateInvalidFormat() {
+	body := strings.NewReader(`{"name":"invalid","user_id":2,"source":"/tmp","format":"csv","mapping":{"external_id":"id"}}`)
+	w := suite.request("POST", "/admin/feeds", body, "1")
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (suite *FeedControllerTestSuite) TestCreateAndSync() {
+	dir := suite.T().TempDir()
+	externalId := fmt.Sprintf("feed-%d", time.Now().UnixNano())
+	content := fmt.Sprintf(`{"vehicles":[{"id":%q,"title":"Westy","kind":"camper-van","berths":4,"rate":16900,"lat":33.64,"lng":-117.93}]}`, externalId)
+	suite.Require().Nil(os.WriteFile(filepath.Join(dir, "fleet.json"), []byte(content), 0o644))
+
+	name := fmt.Sprintf("partner-%d", time.Now().UnixNano())
+	w := suite.request("POST", "/admin/feeds", feedBody(name, dir, validFeedMapping), "1")
+	if !suite.Equal(http.StatusCreated, w.Code) {
+		return
+	}
+	var feed models.Feed
+	suite.Require().Nil(json.Unmarshal(w.Body.Bytes(), &feed))
+
+	// names are unique
+	w = suite.request("POST", "/admin/feeds", feedBody(name, dir, validFeedMapping), "1")
+	suite.Equal(http.StatusConflict, w.Code)
+
+	w = suite.request("POST", fmt.Sprintf("/admin/feeds/%d/sync", feed.ID), nil, "1")
+	if suite.Equal(http.StatusOK, w.Code) {
+		var sync models.FeedSync
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &sync), "Should be able to unmarshal response") {
+			suite.Equal(models.FeedApplied, sync.Status, sync.Error)
+			suite.Equal(1, sync.Inserted)
+		}
+	}
+
+	w = suite.request("POST", fmt.Sprintf("/admin/feeds/%d/sync", feed.ID), nil, "1")
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.Contains(w.Body.String(), `"status":"unchanged"`)
+	}
+
+	w = suite.request("GET", fmt.Sprintf("/admin/feeds/%d/syncs?limit=10", feed.ID), nil, "1")
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response feedSyncListResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.Equal(uint32(2), response.Pagigation.Count)
+			suite.Equal(models.FeedUnchanged, response.Data[0].Status)
+		}
+	}
+
+	w = suite.request("DELETE", fmt.Sprintf("/admin/feeds/%d", feed.ID), nil, "1")
+	suite.Equal(http.StatusNoContent, w.Code)
+
+	// other suites count the fixture rentals
+	suite.Nil(db.DB.Where("external_id = ?", externalId).Delete(&models.Rental{}).Error)
+}
+
+// PUT /admin/feeds/:feed_id tests
+func (suite *FeedControllerTestSuite) TestUpdateOwner() {
+	dir := suite.T().TempDir()
+	externalId := fmt.Sprintf("feed-%d", time.Now().UnixNano())
+	content := fmt.Sprintf(`{"vehicles":[{"id":%q,"title":"Westy","kind":"camper-van","berths":4,"rate":16900,"lat":33.64,"lng":-117.93}]}`, externalId)
+	suite.Require().Nil(os.WriteFile(filepath.Join(dir, "fleet.json"), []byte(content), 0o644))
+
+	name := fmt.Sprintf("partner-%d", time.Now().UnixNano())
+	w := suite.request("POST", "/admin/feeds", feedBody(name, dir, validFeedMapping), "1")
+	suite.Require().Equal(http.StatusCreated, w.Code)
+	var feed models.Feed
+	suite.Require().Nil(json.Unmarshal(w.Body.Bytes(), &feed))
+	path := fmt.Sprintf("/admin/feeds/%d", feed.ID)
+	otherOwner := func(userId int) io.Reader {
+		return strings.NewReader(fmt.Sprintf(`{"name":%q,"user_id":%d,"source":%q,"format":"json","listings_path":"vehicles","mapping":%s}`, name, userId, dir, validFeedMapping))
+	}
+
+	// nothing synced yet
+	w = suite.request("PUT", path, otherOwner(3), "1")
+	suite.Equal(http.StatusOK, w.Code)
+
+	w = suite.request("POST", path+"/sync", nil, "1")
+	suite.Require().Equal(http.StatusOK, w.Code)
+
+	w = suite.request("PUT", path, otherOwner(2), "1")
+	if suite.Equal(http.StatusBadRequest, w.Code) {
+		suite.Contains(w.Body.String(), "user_id can't change")
+	}
+	w = suite.request("PUT", path, otherOwner(3), "1")
+	suite.Equal(http.StatusOK, w.Code)
+
+	suite.Equal(http.StatusNoContent, suite.request("DELETE", path, nil, "1").Code)
+	suite.Nil(db.DB.Where("external_id = ?", externalId).Delete(&models.Rental{}).Error)
+}
+
+func (suite *FeedControllerTestSuite) TestSyncNotFound() {
+	w := suite.request("POST", "/admin/feeds/999999/sync", nil, "1")
+	suite.Equal(http.StatusNotFound, w.Code)
+
+	w = suite.request("POST", "/admin/feeds/abc/sync", nil, "1")
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestFeedControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(FeedControllerTestSuite))
+}
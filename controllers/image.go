@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/images"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"gorm.io/gorm"
+)
+
+type ImageController struct{}
+
+// Request body to update an image of the gallery
+type imageRequest struct {
+	Caption  string `json:"caption" binding:"max=500"`
+	Position *int32 `json:"position" binding:"required,min=0"`
+	Primary  bool   `json:"primary"`
+}
+
+// GET /rentals/:rental_id/images
+func (u ImageController) List(c *gin.Context) {
+	rental, ok := findRental(c)
+	if !ok {
+		return
+	}
+
+	rentalImages := make([]models.RentalImage, 0)
+	if err := models.OrderImages(db.DB.Where("rental_id = ?", rental.ID)).Find(&rentalImages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rentalImages})
+}
+
+// POST /rentals/:rental_id/images
+func (u ImageController) Create(c *gin.Context) {
+	rental, ok := findOwnedRental(c)
+	if !ok {
+		return
+	}
+
+	// reject large uploads before reading the whole body
+	maxBytes := config.GetConfig().MaxUploadBytes
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid image", "error": err.Error()})
+		c.Abort()
+		return
+	}
+	if file.Size > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "Image too large", "error": fmt.Sprintf("Images are limited to %d bytes", maxBytes)})
+		c.Abort()
+		return
+	}
+
+	opened, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid image", "error": err.Error()})
+		c.Abort()
+		return
+	}
+	defer opened.Close()
+	content, err := io.ReadAll(opened)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid image", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	primary, _ := strconv.ParseBool(c.PostForm("primary"))
+	rentalImage, err := models.CreateRentalImage(c.Request.Context(), rental, content, c.PostForm("caption"), primary)
+	if err != nil {
+		if errors.Is(err, images.ErrUnsupportedImage) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"message": "Unsupported image", "error": "Images must be JPEG, PNG, GIF or WebP"})
+		} else if errors.Is(err, images.ErrImageTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "Image too large", "error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusCreated, rentalImage)
+}
+
+// PUT /rentals/:rental_id/images/:image_id
+func (u ImageController) Update(c *gin.Context) {
+	rental, ok := findOwnedRental(c)
+	if !ok {
+		return
+	}
+	rentalImage, ok := findRentalImage(c, rental)
+	if !ok {
+		return
+	}
+
+	var request imageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid image", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	if err := models.UpdateRentalImage(c.Request.Context(), rentalImage, request.Caption, *request.Position, request.Primary); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, rentalImage)
+}
+
+// DELETE /rentals/:rental_id/images/:image_id
+func (u ImageController) Delete(c *gin.Context) {
+	rental, ok := findOwnedRental(c)
+	if !ok {
+		return
+	}
+	rentalImage, ok := findRentalImage(c, rental)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteRentalImage(c.Request.Context(), rentalImage); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Load the rental from the route, only its owner can change the gallery
+func findOwnedRental(c *gin.Context) (*models.Rental, bool) {
+	rental, ok := findRental(c)
+	if !ok {
+		return nil, false
+	}
+
+	if !auth.IsUserOrAdmin(c, rental.UserId) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Not allowed to update this rental"})
+		c.Abort()
+		return nil, false
+	}
+
+	return rental, true
+}
+
+// Load an image of the rental from the route, responds with an error when it
+// can't be found
+func findRentalImage(c *gin.Context, rental *models.Rental) (*models.RentalImage, bool) {
+	imageId, err := strconv.ParseInt(c.Param("image_id"), 10, 32)
+	if err != nil {
+		log.Log.Warn(fmt.Sprintf("Invalid image id: %s", c.Param("image_id")))
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid image id"})
+		c.Abort()
+		return nil, false
+	}
+
+	var rentalImage models.RentalImage
+	if result := db.DB.Where("rental_id = ?", rental.ID).First(&rentalImage, imageId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Image not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": result.Error.Error()})
+		}
+		c.Abort()
+		return nil, false
+	}
+
+	return &rentalImage, true
+}
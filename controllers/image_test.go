@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the Image controller
+type ImageControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+func (suite *ImageControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+// Multipart request uploading an image to the gallery of the rental
+func uploadRequest(rentalId uint32, userId string, content []byte, caption string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("image", "photo.png")
+	part.Write(content)
+	writer.WriteField("caption", caption)
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/rentals/%d/images", rentalId), &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set(auth.UserIdHeader, userId)
+	return req
+}
+
+func pngContent(width int, height int) []byte {
+	var content bytes.Buffer
+	png.Encode(&content, image.NewRGBA(image.Rect(0, 0, width, height)))
+	return content.Bytes()
+}
+
+// POST /rentals/:rental_id/images tests
+func (suite *ImageControllerTestSuite) TestUploadImageNotOwner() {
+	// rental 6 belongs to user 1
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, uploadRequest(6, "5", pngContent(10, 10), ""))
+
+	suite.Equal(http.StatusForbidden, w.Code)
+}
+
+func (suite *ImageControllerTestSuite) TestUploadImageUnsupported() {
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, uploadRequest(6, "1", []byte("not an image"), ""))
+
+	suite.Equal(http.StatusUnsupportedMediaType, w.Code)
+}
+
+func (suite *ImageControllerTestSuite) TestUploadImageGallery() {
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, uploadRequest(7, "2", pngContent(1600, 1200), "Front"))
+
+	var first models.RentalImageResponse
+	if suite.Equal(http.StatusCreated, w.Code) {
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &first), "Should be able to unmarshal response") {
+			suite.Equal("Front", first.Caption)
+			// the first image becomes the primary image
+			suite.True(first.Primary)
+			suite.Equal(int32(1600), first.Width)
+			suite.Contains(first.Variants["thumb_webp"], "/media/rentals/7/images/")
+			suite.Len(first.Variants, 5)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, uploadRequest(7, "2", pngContent(800, 600), "Back"))
+	var second models.RentalImageResponse
+	if suite.Equal(http.StatusCreated, w.Code) {
+		suite.Nil(json.Unmarshal(w.Body.Bytes(), &second), "Should be able to unmarshal response")
+		suite.False(second.Primary)
+		suite.Equal(first.Position+1, second.Position)
+	}
+
+	// mark the second image as primary and move it first
+	req, _ := http.NewRequest("PUT", fmt.Sprintf("/rentals/7/images/%d", second.ID), strings.NewReader(`{"caption":"Rear","position":0,"primary":true}`))
+	req.Header.Set(auth.UserIdHeader, "2")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Equal(http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("GET", "/rentals/7", nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	var rental models.RentalResponse
+	if suite.Nil(json.Unmarshal(w.Body.Bytes(), &rental), "Should be able to unmarshal response") {
+		if suite.Len(rental.Images, 2) {
+			suite.Equal("Rear", rental.Images[0].Caption)
+			suite.True(rental.Images[0].Primary)
+			suite.False(rental.Images[1].Primary)
+		}
+		suite.Equal(second.Variants["original"], rental.PrimaryImageUrl)
+	}
+
+	// deleting the primary image promotes the next one
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/rentals/7/images/%d", second.ID), nil)
+	req.Header.Set(auth.UserIdHeader, "2")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Equal(http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest("GET", "/rentals/7/images", nil)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	var list struct {
+		Data []models.RentalImageResponse `json:"data"`
+	}
+	if suite.Nil(json.Unmarshal(w.Body.Bytes(), &list), "Should be able to unmarshal response") {
+		if suite.Len(list.Data, 1) {
+			suite.Equal(first.ID, list.Data[0].ID)
+			suite.True(list.Data[0].Primary)
+		}
+	}
+}
+
+// PUT /rentals/:rental_id/images/:image_id tests
+func (suite *ImageControllerTestSuite) TestUpdateImageNotFound() {
+	req, _ := http.NewRequest("PUT", "/rentals/6/images/1000", strings.NewReader(`{"position":0}`))
+	req.Header.Set(auth.UserIdHeader, "1")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestImageControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(ImageControllerTestSuite))
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/models"
+)
+
+// Most months returned by the price trends
+const maxPriceTrendMonths = 60
+
+type PriceController struct{}
+
+// Response for the price trends operation, note that his is only used to marshal results
+type priceTrendsResponse struct {
+	GroupBy string              `json:"group_by"`
+	From    string              `json:"from"`
+	To      string              `json:"to"`
+	Data    []models.PriceTrend `json:"data"`
+}
+
+// GET /rentals/:rental_id/prices
+func (u PriceController) List(c *gin.Context) {
+	rental, ok := findRental(c)
+	if !ok {
+		return
+	}
+
+	prices, err := models.FindRentalPrices(rental.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": prices})
+}
+
+// GET /rentals/stats/price-trends
+func (u PriceController) Trends(c *gin.Context) {
+	filter, err := models.ParseQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid filter", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	groupBy := c.DefaultQuery("group_by", "type")
+	if !models.ValidPriceTrendGroup(groupBy) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid group_by", "error": "Expected type or state"})
+		c.Abort()
+		return
+	}
+
+	// the last 12 months by default
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, -11, 0)
+	if toRaw := c.Query("to"); toRaw != "" {
+		if to, err = time.Parse(models.PriceTrendMonth, toRaw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid to", "error": "Expected a YYYY-MM month"})
+			c.Abort()
+			return
+		}
+		from = to.AddDate(0, -11, 0)
+	}
+	if fromRaw := c.Query("from"); fromRaw != "" {
+		if from, err = time.Parse(models.PriceTrendMonth, fromRaw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid from", "error": "Expected a YYYY-MM month"})
+			c.Abort()
+			return
+		}
+	}
+	if from.After(to) || from.AddDate(0, maxPriceTrendMonths, 0).Before(to.AddDate(0, 1, 0)) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid range", "error": "from must be before to, at most 60 months apart"})
+		c.Abort()
+		return
+	}
+
+	trends, err := models.PriceTrends(filter, groupBy, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, &priceTrendsResponse{
+		GroupBy: groupBy,
+		From:    from.Format(models.PriceTrendMonth),
+		To:      to.Format(models.PriceTrendMonth),
+		Data:    trends,
+	})
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the Price controller
+type PriceControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+// Used to unmarshal price timeline responses
+type priceListResponse struct {
+	Data []models.RentalPriceResponse `json:"data"`
+}
+
+func (suite *PriceControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+func (suite *PriceControllerTestSuite) request(method string, path string, body string, userId string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if userId != "" {
+		req.Header.Set(auth.UserIdHeader, userId)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	return w
+}
+
+// GET /rentals/:rental_id/prices tests
+func (suite *PriceControllerTestSuite) TestListSeedPrices() {
+	w := suite.request("GET", "/rentals/1/prices", "", "")
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response priceListResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") && suite.NotEmpty(response.Data) {
+			// effective since the rental was created
+			suite.Equal(int64(16900), response.Data[0].Price.Day)
+			suite.Equal("2021-11-29", response.Data[0].Effective.UTC().Format("2006-01-02"))
+		}
+	}
+}
+
+func (suite *PriceControllerTestSuite) TestPriceChanges() {
+	body := `{"name":"Priced Van","type":"priced-van","sleeps":2,"price":{"day":10000},"location":{"lat":39.74,"lng":-104.99}}`
+	w := suite.request("POST", "/rentals/", body, "3")
+	suite.Require().Equal(http.StatusCreated, w.Code)
+	var rental models.RentalResponse
+	suite.Require().Nil(json.Unmarshal(w.Body.Bytes(), &rental))
+	path := fmt.Sprintf("/rentals/%d", rental.ID)
+	defer suite.request("DELETE", path, "", "3")
+
+	w = suite.request("PUT", path, `{"price":{"day":12000}}`, "3")
+	suite.Require().Equal(http.StatusOK, w.Code)
+	// other changes don't add a price
+	w = suite.request("PUT", path, `{"sleeps":3}`, "3")
+	suite.Require().Equal(http.StatusOK, w.Code)
+
+	w = suite.request("GET", path+"/prices", "", "")
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response priceListResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response)) && suite.Len(response.Data, 2) {
+			suite.Equal(int64(10000), response.Data[0].Price.Day)
+			suite.Equal(int64(12000), response.Data[1].Price.Day)
+		}
+	}
+
+	// the last price of the day counts for the day
+	month := time.Now().UTC().Format(models.PriceTrendMonth)
+	w = suite.request("GET", fmt.Sprintf("/rentals/stats/price-trends?ids=%d&from=%s&to=%s", rental.ID, month, month), "", "")
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.JSONEq(fmt.Sprintf(`{"group_by":"type","from":"%s","to":"%s","data":[
+			{"month":"%s","group":"priced-van","average_price":{"day":12000},"rentals":1}
+		]}`, month, month, month), w.Body.String())
+	}
+}
+
+// GET /rentals/stats/price-trends tests
+func (suite *PriceControllerTestSuite) TestTrendsByState() {
+	w := suite.request("GET", "/rentals/stats/price-trends?group_by=state&ids=1,2&from=2021-12&to=2022-01", "", "")
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response priceTrendsResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response)) {
+			suite.Equal("state", response.GroupBy)
+			suite.Equal([]models.PriceTrend{
+				{Month: "2021-12", Group: "CA", AveragePrice: models.PriceReponse{Day: 16900}, Rentals: 1},
+				{Month: "2021-12", Group: "OR", AveragePrice: models.PriceReponse{Day: 15000}, Rentals: 1},
+				{Month: "2022-01", Group: "CA", AveragePrice: models.PriceReponse{Day: 16900}, Rentals: 1},
+				{Month: "2022-01", Group: "OR", AveragePrice: models.PriceReponse{Day: 15000}, Rentals: 1},
+			}, response.Data)
+		}
+	}
+}
+
+func (suite *PriceControllerTestSuite) TestTrendsInvalid() {
+	for _, query := range []string{"group_by=make", "from=2024", "to=2024-13", "from=2024-05&to=2024-01", "from=2019-01&to=2024-01", "price_min=abc"} {
+		w := suite.request("GET", "/rentals/stats/price-trends?"+query, "", "")
+		suite.Equal(http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestPriceControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(PriceControllerTestSuite))
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"gorm.io/gorm"
+)
+
+type PriceWatchController struct{}
+
+// Request body to watch the price of a rental, at least one of below and
+// drop_percent is required
+type priceWatchRequest struct {
+	RentalId    uint32 `json:"rental_id" binding:"required"`
+	Below       *int64 `json:"below" binding:"omitempty,min=1"`
+	DropPercent *int32 `json:"drop_percent" binding:"omitempty,min=1,max=99"`
+}
+
+// GET /price-watches
+func (u PriceWatchController) List(c *gin.Context) {
+	userId, _ := auth.UserId(c)
+	watches, err := models.FindPriceWatches(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": watches})
+}
+
+// POST /price-watches
+func (u PriceWatchController) Create(c *gin.Context) {
+	var request priceWatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid price watch", "error": err.Error()})
+		c.Abort()
+		return
+	}
+	if request.Below == nil && request.DropPercent == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid price watch", "error": "One of below or drop_percent is required"})
+		c.Abort()
+		return
+	}
+
+	var rental models.Rental
+	if result := db.DB.First(&rental, request.RentalId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid price watch", "error": fmt.Sprintf("Unknown rental: %d", request.RentalId)})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": result.Error.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	userId, _ := auth.UserId(c)
+	watch := models.PriceWatch{
+		UserId:        userId,
+		RentalId:      rental.ID,
+		Below:         request.Below,
+		DropPercent:   request.DropPercent,
+		BaselinePrice: rental.Price,
+	}
+	if err := db.DB.Create(&watch).Error; err != nil {
+		// one watch per user and rental
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Rental already watched"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusCreated, watch)
+}
+
+// DELETE /price-watches/:price_watch_id
+func (u PriceWatchController) Delete(c *gin.Context) {
+	// id is an integer in the database, only needs int32
+	watchId, err := strconv.ParseInt(c.Param("price_watch_id"), 10, 32)
+	if err != nil {
+		log.Log.Warn(fmt.Sprintf("Invalid price watch id: %s", c.Param("price_watch_id")))
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid price watch id"})
+		c.Abort()
+		return
+	}
+
+	var watch models.PriceWatch
+	if result := db.DB.First(&watch, watchId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Price watch not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": result.Error.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	// don't reveal which price watches exist
+	if !auth.IsUserOrAdmin(c, watch.UserId) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Price watch not found"})
+		c.Abort()
+		return
+	}
+
+	if err := db.DB.Delete(&watch).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the PriceWatch controller
+type PriceWatchControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+func (suite *PriceWatchControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+func (suite *PriceWatchControllerTestSuite) request(method string, path string, body string, userId string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if userId != "" {
+		req.Header.Set(auth.UserIdHeader, userId)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	return w
+}
+
+// POST /price-watches tests
+func (suite *PriceWatchControllerTestSuite) TestCreatePriceWatch() {
+	w := suite.request("POST", "/price-watches/", `{"rental_id":2,"below":14000}`, "3")
+
+	if suite.Equal(http.StatusCreated, w.Code) {
+		var response models.PriceWatchResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.Equal(uint32(2), response.RentalId)
+			suite.Equal(int64(14000), *response.Below)
+			suite.Nil(response.DropPercent)
+			suite.Equal(int64(15000), response.BaselinePrice.Day)
+		}
+
+		// one watch per rental
+		w = suite.request("POST", "/price-watches/", `{"rental_id":2,"drop_percent":10}`, "3")
+		suite.Equal(http.StatusConflict, w.Code)
+
+		w = suite.request("GET", "/price-watches/", "", "3")
+		suite.Contains(w.Body.String(), `"below":14000`)
+
+		// only the owner can remove it
+		w = suite.request("DELETE", fmt.Sprintf("/price-watches/%d", response.ID), "", "4")
+		suite.Equal(http.StatusNotFound, w.Code)
+		w = suite.request("DELETE", fmt.Sprintf("/price-watches/%d", response.ID), "", "3")
+		suite.Equal(http.StatusNoContent, w.Code)
+	}
+}
+
+func (suite *PriceWatchControllerTestSuite) TestCreatePriceWatchInvalid() {
+	w := suite.request("POST", "/price-watches/", `{"rental_id":2}`, "3")
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	w = suite.request("POST", "/price-watches/", `{"rental_id":2,"drop_percent":100}`, "3")
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	w = suite.request("POST", "/price-watches/", `{"rental_id":999999,"below":100}`, "3")
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (suite *PriceWatchControllerTestSuite) TestCreatePriceWatchRequiresUser() {
+	w := suite.request("POST", "/price-watches/", `{"rental_id":2,"below":14000}`, "")
+	suite.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func TestPriceWatchControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(PriceWatchControllerTestSuite))
+}
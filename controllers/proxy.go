@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/images"
+	log "github.com/samuelg/rentals/logging"
+	"golang.org/x/exp/slices"
+)
+
+// How long clients and CDNs can reuse a variant without revalidating it
+const imageMaxAge = 24 * time.Hour
+
+type ImageProxyController struct{}
+
+// GET /images/:rental_id/:variant
+func (u ImageProxyController) Get(c *gin.Context) {
+	// variants are named <size>_<format> (ex: thumb_webp)
+	sizeName, format, _ := strings.Cut(c.Param("variant"), "_")
+	size, ok := images.FindSize(sizeName)
+	if !ok || !slices.Contains(images.Formats, format) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid variant", "error": fmt.Sprintf("Unknown variant: %s", c.Param("variant"))})
+		c.Abort()
+		return
+	}
+
+	rental, ok := findRental(c)
+	if !ok {
+		return
+	}
+	if rental.PrimaryImageUrl == "" {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Rental has no image"})
+		c.Abort()
+		return
+	}
+
+	variant, err := images.DefaultProxy.Variant(c, rental.PrimaryImageUrl, size, format)
+	if err != nil {
+		log.Log.Warn(fmt.Sprintf("Failed to proxy image of rental %d: %v", rental.ID, err))
+		if errors.Is(err, images.ErrFetchFailed) || errors.Is(err, images.ErrUnsupportedImage) || errors.Is(err, images.ErrImageTooLarge) {
+			c.JSON(http.StatusBadGateway, gin.H{"message": "Source image unavailable", "error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	c.Header("Content-Type", variant.ContentType)
+	c.Header("ETag", variant.ETag)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(imageMaxAge.Seconds())))
+	// handles If-None-Match and range requests
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, bytes.NewReader(variant.Content))
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/images"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/storage"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the ImageProxy controller
+type ImageProxyControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+	server *httptest.Server
+}
+
+// Sends every request to the stand-in instead of the real image host
+type standInTransport struct {
+	target *url.URL
+}
+
+func (t *standInTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (suite *ImageProxyControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		png.Encode(w, image.NewRGBA(image.Rect(0, 0, 1280, 960)))
+	}))
+	target, _ := url.Parse(suite.server.URL)
+	images.DefaultProxy.Fetcher = &images.StorageFetcher{
+		Storage:  storage.Default,
+		Fallback: &images.HTTPFetcher{Client: &http.Client{Transport: &standInTransport{target: target}}},
+	}
+}
+
+func (suite *ImageProxyControllerTestSuite) TearDownSuite() {
+	suite.server.Close()
+}
+
+// GET /images/:rental_id/:variant tests
+func (suite *ImageProxyControllerTestSuite) TestGetVariant() {
+	req, _ := http.NewRequest("GET", "/images/1/thumb_jpeg", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.Equal("image/jpeg", w.Header().Get("Content-Type"))
+		suite.Contains(w.Header().Get("Cache-Control"), "max-age=")
+		suite.NotEmpty(w.Header().Get("ETag"))
+
+		img, err := jpeg.Decode(w.Body)
+		if suite.Nil(err) {
+			suite.Equal(320, img.Bounds().Dx())
+			suite.Equal(240, img.Bounds().Dy())
+		}
+
+		// revalidating with the ETag doesn't resend the image
+		req, _ = http.NewRequest("GET", "/images/1/thumb_jpeg", nil)
+		req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+		w = httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		suite.Equal(http.StatusNotModified, w.Code)
+	}
+}
+
+func (suite *ImageProxyControllerTestSuite) TestGetUnknownVariant() {
+	req, _ := http.NewRequest("GET", "/images/1/huge_gif", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (suite *ImageProxyControllerTestSuite) TestGetRentalNotFound() {
+	req, _ := http.NewRequest("GET", "/images/1000/thumb_webp", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestImageProxyControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(ImageProxyControllerTestSuite))
+}
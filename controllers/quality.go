@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/models"
+)
+
+type QualityController struct{}
+
+// GET /admin/quality-report
+func (u QualityController) Report(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid format", "error": "Expected json or csv"})
+		c.Abort()
+		return
+	}
+
+	report, err := models.CheckRentalQuality()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	if format == "csv" {
+		c.Header("Content-Disposition", `attachment; filename="quality-report.csv"`)
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		report.WriteCsv(c.Writer)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
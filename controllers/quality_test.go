@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the Quality controller
+type QualityControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+func (suite *QualityControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+func (suite *QualityControllerTestSuite) request(path string, userId string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	if userId != "" {
+		req.Header.Set(auth.UserIdHeader, userId)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	return w
+}
+
+// GET /admin/quality-report tests
+func (suite *QualityControllerTestSuite) TestReport() {
+	w := suite.request("/admin/quality-report", "1")
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		var report models.QualityReport
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &report), "Should be able to unmarshal response") {
+			suite.Greater(report.Checked, 0)
+			suite.NotEmpty(report.Problems)
+		}
+	}
+}
+
+func (suite *QualityControllerTestSuite) TestReportCsv() {
+	w := suite.request("/admin/quality-report?format=csv", "1")
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.Equal("text/csv", w.Header().Get("Content-Type"))
+		suite.True(strings.HasPrefix(w.Body.String(), "rental_id,user_id,name,check,field,detail\n"))
+	}
+}
+
+func (suite *QualityControllerTestSuite) TestReportInvalidFormat() {
+	w := suite.request("/admin/quality-report?format=xml", "1")
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (suite *QualityControllerTestSuite) TestReportAdminOnly() {
+	w := suite.request("/admin/quality-report", "3")
+	suite.Equal(http.StatusForbidden, w.Code)
+
+	w = suite.request("/admin/quality-report", "")
+	suite.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func TestQualityControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(QualityControllerTestSuite))
+}
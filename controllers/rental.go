@@ -5,15 +5,30 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
 	"github.com/samuelg/rentals/db"
 	log "github.com/samuelg/rentals/logging"
 	"github.com/samuelg/rentals/models"
+	"golang.org/x/exp/slices"
 	"gorm.io/gorm"
 )
 
-type RentalController struct{}
+type RentalController struct {
+	// where List and Get find rentals, the database when nil
+	Rentals models.RentalRepository
+}
+
+// Repository the read endpoints use
+func (u RentalController) repository() models.RentalRepository {
+	if u.Rentals == nil {
+		return models.GormRentalRepository{}
+	}
+	return u.Rentals
+}
 
 // pagination object for front-end logic, can be used to unmarshal responses
 type PaginationResponse struct {
@@ -38,7 +53,7 @@ func (u RentalController) List(c *gin.Context) {
 		return
 	}
 
-	rentals, count, err := filter.Find()
+	rentals, count, err := u.repository().Find(c.Request.Context(), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
 		c.Abort()
@@ -55,6 +70,53 @@ func (u RentalController) List(c *gin.Context) {
 	})
 }
 
+// Number of rentals loaded at once when exporting
+const exportBatchSize = 500
+
+// GET /rentals/export
+func (u RentalController) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", models.ExportNdjson)
+	if !slices.Contains(models.ExportFormats, format) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid format", "error": "Expected csv, ndjson or parquet"})
+		c.Abort()
+		return
+	}
+
+	// exports have every matching rental, pagination doesn't apply
+	query := c.Request.URL.Query()
+	query.Del("limit")
+	query.Del("offset")
+	filter, err := models.ParseQueryString(query.Encode())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid filter", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	// exports take as long as there are rentals to write, the write timeout of
+	// the server doesn't apply. Test recorders don't support deadlines
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	// the Parquet writer starts the file right away
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="rentals.%s"`, format))
+	c.Header("Content-Type", models.ExportContentTypes[format])
+	c.Status(http.StatusOK)
+
+	writer, err := models.NewRentalWriter(c.Writer, format)
+	if err == nil {
+		err = filter.Stream(c.Request.Context(), exportBatchSize, writer.Write)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// the status is sent with the first rows, clients get a truncated file
+		log.Log.Error(fmt.Sprintf("Export failed: %v", err))
+		c.Error(err)
+		c.Abort()
+	}
+}
+
 // GET /rentals/:rental_id
 func (u RentalController) Get(c *gin.Context) {
 	// id is an integer in the database, only needs int32
@@ -66,14 +128,341 @@ func (u RentalController) Get(c *gin.Context) {
 		return
 	}
 
+	asOf, err := models.ParseAsOf(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid as_of", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	rental, err := u.repository().Get(c.Request.Context(), uint32(rentalId), asOf)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Rental not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, rental)
+}
+
+// Request body to set the amenities of a rental
+type rentalAmenitiesRequest struct {
+	Amenities []string `json:"amenities" binding:"required"`
+}
+
+// PUT /rentals/:rental_id/amenities
+func (u RentalController) UpdateAmenities(c *gin.Context) {
+	rental, ok := findRental(c)
+	if !ok {
+		return
+	}
+
+	var request rentalAmenitiesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid amenities", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	// only the owner of the rental can change its amenities
+	if !auth.IsUserOrAdmin(c, rental.UserId) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Not allowed to update this rental"})
+		c.Abort()
+		return
+	}
+
+	amenities, err := models.FindAmenitiesByKey(request.Amenities)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+	for _, key := range request.Amenities {
+		if !slices.ContainsFunc(amenities, func(amenity models.Amenity) bool { return amenity.Key == key }) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid amenities", "error": fmt.Sprintf("Unknown amenity: %s", key)})
+			c.Abort()
+			return
+		}
+	}
+
+	// only the join table changes, skip the rental hooks
+	association := db.DB.Session(&gorm.Session{SkipHooks: true}).Model(rental).Association("Amenities")
+	if err := association.Replace(amenities); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	keys := make([]string, len(amenities))
+	for i, amenity := range amenities {
+		keys[i] = amenity.Key
+	}
+	c.JSON(http.StatusOK, gin.H{"amenities": keys})
+}
+
+// Load the rental from the route, responds with an error when it can't be found
+func findRental(c *gin.Context) (*models.Rental, bool) {
+	// id is an integer in the database, only needs int32
+	rentalId, err := strconv.ParseInt(c.Param("rental_id"), 10, 32)
+	if err != nil {
+		log.Log.Warn(fmt.Sprintf("Invalid rental id: %s", c.Param("rental_id")))
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid rental id"})
+		c.Abort()
+		return nil, false
+	}
+
 	var rental models.Rental
-	if result := db.DB.Joins("User").First(&rental, rentalId); result.Error != nil {
+	if result := db.DB.First(&rental, rentalId); result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"message": "Rental not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": result.Error.Error()})
+		}
+		c.Abort()
+		return nil, false
+	}
+
+	return &rental, true
+}
+
+// POST /rentals
+func (u RentalController) Create(c *gin.Context) {
+	var request models.RentalInput
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid rental", "error": err.Error()})
+		c.Abort()
+		return
+	}
+	if field := request.Missing(); field != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid rental", "error": fmt.Sprintf("Missing field: %s", field)})
+		c.Abort()
+		return
+	}
+
+	// rentals are owned by the user creating them
+	userId, _ := auth.UserId(c)
+	rental := models.Rental{UserId: userId}
+	request.Apply(&rental)
+	if err := models.CreateRental(c.Request.Context(), &rental); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"message": "External id already used"})
 			c.Abort()
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
 	}
 
-	c.JSON(http.StatusOK, rental)
+	respondWithRental(c, http.StatusCreated, rental.ID)
+}
+
+// Formats of the files imported, by content type
+var importContentTypes = map[string]string{
+	"text/csv":             models.ImportCsv,
+	"application/x-ndjson": models.ImportNdjson,
+	"application/jsonl":    models.ImportNdjson,
+}
+
+// POST /rentals/import
+func (u RentalController) Import(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format = importContentTypes[c.ContentType()]
+	}
+	if !slices.Contains(models.ImportFormats, format) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid format", "error": "Expected csv or ndjson"})
+		c.Abort()
+		return
+	}
+	transaction := c.Query("transaction")
+	if transaction != "" && !slices.Contains(models.ImportTransactions, transaction) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid transaction", "error": "Expected row or all"})
+		c.Abort()
+		return
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid dry_run", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	// imports take as long as there are rows to read and save, the read and
+	// write timeouts of the server don't apply. Test recorders don't support
+	// deadlines
+	controller := http.NewResponseController(c.Writer)
+	controller.SetReadDeadline(time.Time{})
+	controller.SetWriteDeadline(time.Time{})
+
+	// rentals are owned by the user importing them
+	userId, _ := auth.UserId(c)
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, config.GetConfig().MaxImportBytes)
+	report, err := models.ImportRentals(c.Request.Context(), body, models.ImportOptions{
+		UserId:      userId,
+		Format:      format,
+		Transaction: transaction,
+		DryRun:      dryRun,
+	})
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		response := gin.H{"message": "File too large", "error": err.Error()}
+		// rows before the limit may have been saved
+		if report != nil {
+			response["report"] = report
+		}
+		c.JSON(http.StatusRequestEntityTooLarge, response)
+		c.Abort()
+		return
+	}
+	if errors.Is(err, models.ErrInvalidImport) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid import", "error": err.Error()})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		response := gin.H{"message": "Something went wrong", "error": err.Error()}
+		// rows before the error are saved by per row imports
+		if report != nil {
+			response["report"] = report
+		}
+		c.JSON(http.StatusInternalServerError, response)
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// PUT /rentals/:rental_id
+func (u RentalController) Update(c *gin.Context) {
+	rental, ok := findRental(c)
+	if !ok {
+		return
+	}
+
+	var request models.RentalInput
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid rental", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	if !auth.IsUserOrAdmin(c, rental.UserId) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Not allowed to update this rental"})
+		c.Abort()
+		return
+	}
+
+	request.Apply(rental)
+	if err := models.UpdateRental(c.Request.Context(), rental); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"message": "External id already used"})
+			c.Abort()
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	respondWithRental(c, http.StatusOK, rental.ID)
+}
+
+// DELETE /rentals/:rental_id
+func (u RentalController) Delete(c *gin.Context) {
+	rental, ok := findRental(c)
+	if !ok {
+		return
+	}
+
+	if !auth.IsUserOrAdmin(c, rental.UserId) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Not allowed to delete this rental"})
+		c.Abort()
+		return
+	}
+
+	if err := models.DeleteRental(c.Request.Context(), rental); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Response for the history operation, note that his is only used to marshal results
+type rentalHistoryResponse struct {
+	Pagigation *PaginationResponse    `json:"pagination"`
+	Data       []models.RentalHistory `json:"data"`
+}
+
+// GET /rentals/:rental_id/history
+func (u RentalController) History(c *gin.Context) {
+	// id is an integer in the database, only needs int32
+	rentalId, err := strconv.ParseInt(c.Param("rental_id"), 10, 32)
+	if err != nil {
+		log.Log.Warn(fmt.Sprintf("Invalid rental id: %s", c.Param("rental_id")))
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid rental id"})
+		c.Abort()
+		return
+	}
+
+	limit, offset, err := models.ParsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid pagination", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	// the history of deleted rentals is kept, only admins can still read it
+	var rental models.Rental
+	result := db.DB.Select("id", "user_id").Limit(1).Find(&rental, rentalId)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": result.Error.Error()})
+		c.Abort()
+		return
+	}
+	if result.RowsAffected == 0 && !auth.IsAdmin(c) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Rental not found"})
+		c.Abort()
+		return
+	}
+	if result.RowsAffected > 0 && !auth.IsUserOrAdmin(c, rental.UserId) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Not allowed to read the history of this rental"})
+		c.Abort()
+		return
+	}
+
+	history, count, err := models.FindRentalHistory(uint32(rentalId), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, &rentalHistoryResponse{
+		Pagigation: &PaginationResponse{
+			Count:  count,
+			Limit:  limit,
+			Offset: offset,
+		},
+		Data: history,
+	})
+}
+
+// Respond with the rental and its associations
+func respondWithRental(c *gin.Context, status int, rentalId uint32) {
+	var rental models.Rental
+	if err := db.DB.Joins("User").Preload("Amenities").Preload("Images", models.OrderImages).First(&rental, rentalId).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(status, rental)
 }
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for rental changes and their history
+type RentalHistoryControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+// Used to unmarshal history responses
+type rentalHistoryListResponse struct {
+	Pagigation *PaginationResponse            `json:"pagination"`
+	Data       []models.RentalHistoryResponse `json:"data"`
+}
+
+func (suite *RentalHistoryControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+func (suite *RentalHistoryControllerTestSuite) request(method string, path string, body string, userId string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if userId != "" {
+		req.Header.Set(auth.UserIdHeader, userId)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	return w
+}
+
+func (suite *RentalHistoryControllerTestSuite) TestRentalHistory() {
+	body := `{"name":"Audit Van","type":"camper-van","sleeps":2,"price":{"day":12000},"location":{"lat":39.74,"lng":-104.99}}`
+	w := suite.request("POST", "/rentals/", body, "3")
+	suite.Require().Equal(http.StatusCreated, w.Code)
+
+	var rental models.RentalResponse
+	suite.Require().Nil(json.Unmarshal(w.Body.Bytes(), &rental), "Should be able to unmarshal response")
+	suite.Equal("Audit Van", rental.Name)
+	suite.Equal(uint32(3), rental.User.Id)
+	suite.Equal(int64(12000), rental.Price.Day)
+	path := fmt.Sprintf("/rentals/%d", rental.ID)
+
+	// only the owner can change it
+	w = suite.request("PUT", path, `{"price":{"day":9900}}`, "4")
+	suite.Equal(http.StatusForbidden, w.Code)
+	w = suite.request("PUT", path, `{"price":{"day":9900}}`, "3")
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.Contains(w.Body.String(), `"name":"Audit Van"`)
+		suite.Contains(w.Body.String(), `"price":{"day":9900}`)
+	}
+
+	// history is restricted to the owner and admins
+	w = suite.request("GET", path+"/history", "", "")
+	suite.Equal(http.StatusUnauthorized, w.Code)
+	w = suite.request("GET", path+"/history", "", "4")
+	suite.Equal(http.StatusForbidden, w.Code)
+
+	w = suite.request("GET", path+"/history?limit=1", "", "3")
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response rentalHistoryListResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.Equal(uint32(2), response.Pagigation.Count)
+			if suite.Len(response.Data, 1) {
+				suite.Equal(models.RentalUpdated, response.Data[0].Action)
+				suite.Equal(uint32(3), *response.Data[0].ActorId)
+				suite.Equal(models.FieldChange{Before: float64(12000), After: float64(9900)}, response.Data[0].Changes["price_per_day"])
+			}
+		}
+	}
+
+	w = suite.request("DELETE", path, "", "4")
+	suite.Equal(http.StatusForbidden, w.Code)
+	w = suite.request("DELETE", path, "", "3")
+	suite.Equal(http.StatusNoContent, w.Code)
+	w = suite.request("GET", path, "", "")
+	suite.Equal(http.StatusNotFound, w.Code)
+
+	// the history outlives the rental for admins
+	w = suite.request("GET", path+"/history", "", "3")
+	suite.Equal(http.StatusNotFound, w.Code)
+	w = suite.request("GET", path+"/history", "", "1")
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.Contains(w.Body.String(), `"action":"delete"`)
+		suite.Contains(w.Body.String(), `"count":3`)
+	}
+}
+
+func (suite *RentalHistoryControllerTestSuite) TestCreateRentalInvalid() {
+	w := suite.request("POST", "/rentals/", `{"name":"Missing Price","type":"camper-van","sleeps":2}`, "3")
+	if suite.Equal(http.StatusBadRequest, w.Code) {
+		suite.Contains(w.Body.String(), "Missing field: price.day")
+	}
+
+	w = suite.request("POST", "/rentals/", `{"name":"Bad Fuel","vehicle":{"fuel_type":"coal"}}`, "3")
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	w = suite.request("POST", "/rentals/", `{"name":"Anonymous"}`, "")
+	suite.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (suite *RentalHistoryControllerTestSuite) TestCreateRentalUnknownUser() {
+	body := `{"name":"Unknown Owner","type":"camper-van","sleeps":2,"price":{"day":9000},"location":{"lat":45.52,"lng":-122.68}}`
+	// the version records its author, who must have an account
+	w := suite.request("POST", "/rentals/", body, "999")
+	suite.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func TestRentalHistoryControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(RentalHistoryControllerTestSuite))
+}
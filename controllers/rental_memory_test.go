@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/config"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the Rental controller read endpoints backed by the in memory
+// repository, no database needed
+type RentalMemoryControllerTestSuite struct {
+	suite.Suite
+	router *gin.Engine
+}
+
+type testListResponse struct {
+	Pagigation *PaginationResponse `json:"pagination"`
+	// after a Rental model is marshaled
+	Data []models.RentalResponse `json:"data"`
+}
+
+// Rentals of the repository, 1, 3 and 4 are around Costa Mesa
+func memoryRentals() []models.Rental {
+	created := time.Date(2021, 11, 29, 0, 0, 0, 0, time.UTC)
+	fourWheelDrive := models.Amenity{ID: 1, Key: "4wd", Name: "4WD"}
+	heater := models.Amenity{ID: 2, Key: "heater", Name: "Heater"}
+	kitchen := models.Amenity{ID: 3, Key: "kitchen", Name: "Kitchen"}
+
+	return []models.Rental{
+		{
+			ID: 1, Name: "'Abaco' VW Bay Window: Westfalia Pop-top", Price: 16900, City: "Costa Mesa", State: "CA",
+			Lat: 33.68, Lng: -117.82, Created: created, User: models.User{ID: 1, FirstName: "Ada"},
+			Amenities: []models.Amenity{fourWheelDrive, heater},
+		},
+		{
+			ID: 2, Name: "Maupin: Vanagon Camper", Price: 15000, City: "Portland", State: "OR",
+			Lat: 45.52, Lng: -122.68, Created: created, Amenities: []models.Amenity{heater},
+		},
+		{
+			ID: 3, Name: "AWESOME 1977 Volkswagen Westfalia camper", Price: 12000, City: "Irvine", State: "CA",
+			Lat: 33.66, Lng: -117.79, Created: created, Amenities: []models.Amenity{kitchen},
+		},
+		{
+			ID: 4, Name: "Sprinter", Price: 9500, City: "Newport Beach", State: "CA",
+			Lat: 33.62, Lng: -117.93, Created: created, Amenities: []models.Amenity{fourWheelDrive},
+		},
+	}
+}
+
+func (suite *RentalMemoryControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+
+	gin.SetMode(gin.ReleaseMode)
+	suite.router = gin.New()
+	rentals := &RentalController{Rentals: models.NewMemoryRentalRepository(memoryRentals())}
+	suite.router.GET("/rentals/", rentals.List)
+	suite.router.GET("/rentals/:rental_id", rentals.Get)
+}
+
+func (suite *RentalMemoryControllerTestSuite) request(path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	return w
+}
+
+func (suite *RentalMemoryControllerTestSuite) list(path string) *testListResponse {
+	w := suite.request(path)
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response testListResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			return &response
+		}
+	}
+
+	return nil
+}
+
+// GET /rentals tests
+func (suite *RentalMemoryControllerTestSuite) TestListSuccess() {
+	if response := suite.list("/rentals/?limit=1"); response != nil {
+		suite.Equal(1, len(response.Data), "Should return a single result")
+		suite.Equal(uint32(4), response.Pagigation.Count)
+		suite.Equal(uint8(1), response.Pagigation.Limit)
+		suite.Equal(uint32(0), response.Pagigation.Offset)
+		suite.Equal(uint32(1), response.Data[0].ID)
+		suite.Equal("'Abaco' VW Bay Window: Westfalia Pop-top", response.Data[0].Name)
+		suite.Equal("Ada", response.Data[0].User.FirstName)
+	}
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListSuccessWithOffset() {
+	if response := suite.list("/rentals/?limit=1&offset=1"); response != nil {
+		suite.Equal(1, len(response.Data), "Should return a single result")
+		suite.Equal(uint32(4), response.Pagigation.Count)
+		suite.Equal(uint32(1), response.Pagigation.Offset)
+		suite.Equal(uint32(2), response.Data[0].ID)
+		suite.Equal("Maupin: Vanagon Camper", response.Data[0].Name)
+	}
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListSort() {
+	if response := suite.list("/rentals/?limit=1&sort=price"); response != nil {
+		suite.Equal(uint32(4), response.Pagigation.Count)
+		if suite.Len(response.Data, 1) {
+			suite.Equal(uint32(4), response.Data[0].ID)
+		}
+	}
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListSuccessAllFilters() {
+	if response := suite.list("/rentals/?near=33.68,-117.82&price_min=9000&price_max=16000&ids=2,3,4&sort=price&limit=1&offset=0"); response != nil {
+		suite.Equal(1, len(response.Data), "Should return a single result")
+		suite.Equal(uint32(2), response.Pagigation.Count)
+		suite.Equal(uint32(4), response.Data[0].ID)
+		suite.Equal("Sprinter", response.Data[0].Name)
+	}
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListNear() {
+	if response := suite.list("/rentals/?limit=10&near=33.64,-117.93"); response != nil {
+		suite.Equal(uint32(3), response.Pagigation.Count)
+	}
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListAllAmenities() {
+	if response := suite.list("/rentals/?limit=10&amenities=4wd,heater"); response != nil {
+		suite.Equal(uint32(1), response.Pagigation.Count)
+		if suite.Len(response.Data, 1) {
+			suite.Equal(uint32(1), response.Data[0].ID)
+			suite.Equal([]string{"4wd", "heater"}, response.Data[0].Amenities)
+		}
+	}
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListAnyAmenities() {
+	if response := suite.list("/rentals/?limit=10&amenities=4wd,heater&amenities_match=any"); response != nil {
+		suite.Equal(uint32(3), response.Pagigation.Count)
+		if suite.Len(response.Data, 3) {
+			suite.Equal(uint32(4), response.Data[2].ID)
+		}
+	}
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListInvalidPriceMin() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/?price_min=a").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListInvalidPriceMax() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/?price_max=a").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListInvalidLimit() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/?limit=50.5").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListLimitTooLarge() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/?limit=1000").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListInvalidOffset() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/?offset=50.5").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListInvalidSort() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/?sort=notafield").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListInvalidIds() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/?ids=1,a").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListInvalidNear() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/?near=1,a").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListNearTooFewValues() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/?near=1").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListNearTooManyValues() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/?near=1,2,3").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestListNearInvalidLatLong() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/?near=100,200").Code)
+}
+
+// GET /rentals/:rental_id tests
+func (suite *RentalMemoryControllerTestSuite) TestGetSuccess() {
+	w := suite.request("/rentals/1")
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response models.RentalResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.Equal(uint32(1), response.ID)
+			suite.Equal(int64(16900), response.Price.Day)
+			suite.Equal("Costa Mesa", response.Location.City)
+		}
+	}
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestGetIdNotFound() {
+	suite.Equal(http.StatusNotFound, suite.request("/rentals/100").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestGetInvalidId() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/invalid").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestGetAsOf() {
+	suite.Equal(http.StatusOK, suite.request("/rentals/1?as_of=2022-01-01T00:00:00Z").Code)
+	suite.Equal(http.StatusNotFound, suite.request("/rentals/1?as_of=2021-01-01T00:00:00Z").Code)
+}
+
+func (suite *RentalMemoryControllerTestSuite) TestGetInvalidAsOf() {
+	suite.Equal(http.StatusBadRequest, suite.request("/rentals/1?as_of=yesterday").Code)
+}
+
+func TestRentalMemoryControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(RentalMemoryControllerTestSuite))
+}
@@ -4,13 +4,19 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
 	"github.com/samuelg/rentals/config"
 	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/images"
 	log "github.com/samuelg/rentals/logging"
 	"github.com/samuelg/rentals/models"
+	"github.com/samuelg/rentals/storage"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -26,12 +32,112 @@ func setupRouter() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 	router.Use(gin.Recovery())
+	router.Use(auth.Identify())
+
+	// keep uploads out of the working tree
+	dir, err := os.MkdirTemp("", "rentals-uploads-")
+	if err != nil {
+		panic(err)
+	}
+	storage.Default, _ = storage.NewLocal(dir, "/media")
+	cache, _ := images.NewCache(filepath.Join(dir, "cache"), 1<<20)
+	images.DefaultProxy = &images.Proxy{
+		Fetcher: &images.StorageFetcher{Storage: storage.Default, Fallback: &images.HTTPFetcher{Client: http.DefaultClient}},
+		Cache:   cache,
+	}
 
 	rentalGroup := router.Group("rentals")
 	{
 		rentals := new(RentalController)
 		rentalGroup.GET("/", rentals.List)
+		rentalGroup.GET("/export", auth.RequireUser(), auth.LimitConcurrentRequests(int(config.GetConfig().MaxConcurrentExports)), rentals.Export)
 		rentalGroup.GET("/:rental_id", rentals.Get)
+		rentalGroup.POST("/", auth.RequireUser(), rentals.Create)
+		rentalGroup.POST("/import", auth.RequireUser(), rentals.Import)
+		rentalGroup.PUT("/:rental_id", auth.RequireUser(), rentals.Update)
+		rentalGroup.DELETE("/:rental_id", auth.RequireUser(), rentals.Delete)
+		rentalGroup.GET("/:rental_id/history", auth.RequireUser(), rentals.History)
+		rentalGroup.PUT("/:rental_id/amenities", auth.RequireUser(), rentals.UpdateAmenities)
+
+		prices := new(PriceController)
+		rentalGroup.GET("/:rental_id/prices", prices.List)
+		rentalGroup.GET("/stats/price-trends", prices.Trends)
+
+		reviews := new(ReviewController)
+		rentalGroup.GET("/:rental_id/reviews", reviews.List)
+		rentalGroup.POST("/:rental_id/reviews", auth.RequireUser(), reviews.Create)
+
+		gallery := new(ImageController)
+		rentalGroup.GET("/:rental_id/images", gallery.List)
+		rentalGroup.POST("/:rental_id/images", auth.RequireUser(), gallery.Create)
+		rentalGroup.PUT("/:rental_id/images/:image_id", auth.RequireUser(), gallery.Update)
+		rentalGroup.DELETE("/:rental_id/images/:image_id", auth.RequireUser(), gallery.Delete)
+	}
+
+	imageGroup := router.Group("images")
+	{
+		proxy := new(ImageProxyController)
+		imageGroup.GET("/:rental_id/:variant", proxy.Get)
+	}
+
+	wishlistGroup := router.Group("wishlists", auth.RequireUser())
+	{
+		wishlists := new(WishlistController)
+		wishlistGroup.GET("/", wishlists.List)
+		wishlistGroup.POST("/", wishlists.Create)
+		wishlistGroup.GET("/:wishlist_id", wishlists.Get)
+		wishlistGroup.PUT("/:wishlist_id", wishlists.Update)
+		wishlistGroup.DELETE("/:wishlist_id", wishlists.Delete)
+		wishlistGroup.POST("/:wishlist_id/share", wishlists.Share)
+		wishlistGroup.DELETE("/:wishlist_id/share", wishlists.Unshare)
+		wishlistGroup.POST("/:wishlist_id/items", wishlists.AddItem)
+		wishlistGroup.DELETE("/:wishlist_id/items/:rental_id", wishlists.RemoveItem)
+	}
+	router.GET("/shared/wishlists/:share_token", new(WishlistController).GetShared)
+
+	savedSearchGroup := router.Group("saved-searches", auth.RequireUser())
+	{
+		savedSearches := new(SavedSearchController)
+		savedSearchGroup.GET("/", savedSearches.List)
+		savedSearchGroup.POST("/", savedSearches.Create)
+		savedSearchGroup.DELETE("/:saved_search_id", savedSearches.Delete)
+	}
+
+	priceWatchGroup := router.Group("price-watches", auth.RequireUser())
+	{
+		priceWatches := new(PriceWatchController)
+		priceWatchGroup.GET("/", priceWatches.List)
+		priceWatchGroup.POST("/", priceWatches.Create)
+		priceWatchGroup.DELETE("/:price_watch_id", priceWatches.Delete)
+	}
+
+	amenityGroup := router.Group("amenities")
+	{
+		amenities := new(AmenityController)
+		amenityGroup.GET("/", amenities.List)
+		amenityGroup.GET("/:amenity_id", amenities.Get)
+		amenityGroup.POST("/", auth.RequireAdmin(), amenities.Create)
+		amenityGroup.PUT("/:amenity_id", auth.RequireAdmin(), amenities.Update)
+		amenityGroup.DELETE("/:amenity_id", auth.RequireAdmin(), amenities.Delete)
+	}
+
+	adminGroup := router.Group("admin", auth.RequireAdmin())
+	{
+		quality := new(QualityController)
+		adminGroup.GET("/quality-report", quality.Report)
+
+		feeds := new(FeedController)
+		adminGroup.GET("/feeds", feeds.List)
+		adminGroup.POST("/feeds", feeds.Create)
+		adminGroup.PUT("/feeds/:feed_id", feeds.Update)
+		adminGroup.DELETE("/feeds/:feed_id", feeds.Delete)
+		adminGroup.POST("/feeds/:feed_id/sync", feeds.Sync)
+		adminGroup.GET("/feeds/:feed_id/syncs", feeds.Syncs)
+
+		duplicates := new(DuplicateController)
+		adminGroup.GET("/rentals/duplicates", duplicates.List)
+		adminGroup.POST("/rentals/duplicates/dismiss", duplicates.Dismiss)
+		adminGroup.POST("/rentals/duplicates/merge", duplicates.Merge)
 	}
 
 	return router
@@ -41,189 +147,201 @@ func (suite *RentalControllerTestSuite) SetupSuite() {
 	config.Init("test")
 	log.Init("FATAL", config.GetConfig().AppVersion)
 	db.Init()
+	db.Prepare()
 	suite.config = config.GetConfig()
 	suite.router = setupRouter()
 }
 
-// GET /rentals tests
-type testListResponse struct {
-	Pagigation *PaginationResponse `json:"pagination"`
-	// after a Rental model is marshaled
-	Data []models.RentalResponse `json:"data"`
-}
-
-func (suite *RentalControllerTestSuite) TestListRentalsSuccess() {
-	req, _ := http.NewRequest("GET", "/rentals/?limit=1", nil)
+// GET /rentals/:rental_id tests, the other read endpoint tests run on the in
+// memory repository in rental_memory_test.go
+func (suite *RentalControllerTestSuite) TestGetRentalAsOf() {
+	// seed rentals were created on 2021-11-29, versions are only in the database
+	req, _ := http.NewRequest("GET", "/rentals/1?as_of=2022-01-01T00:00:00Z", nil)
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
 	if suite.Equal(http.StatusOK, w.Code) {
-		var response testListResponse
+		var response models.RentalResponse
 		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
-			suite.Equal(1, len(response.Data), "Should return a single result")
-			suite.Equal(uint32(30), response.Pagigation.Count)
-			suite.Equal(uint8(1), response.Pagigation.Limit)
-			suite.Equal(uint32(0), response.Pagigation.Offset)
-			suite.Equal(uint32(1), response.Data[0].ID)
-			suite.Equal("'Abaco' VW Bay Window: Westfalia Pop-top", response.Data[0].Name)
+			suite.Equal(uint32(1), response.ID)
+			suite.Equal("Costa Mesa", response.Location.City)
 		}
 	}
-}
 
-func (suite *RentalControllerTestSuite) TestListRentalsSuccessWithOffset() {
-	req, _ := http.NewRequest("GET", "/rentals/?limit=1&offset=1", nil)
-	w := httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/rentals/1?as_of=2021-01-01T00:00:00Z", nil)
+	w = httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	if suite.Equal(http.StatusOK, w.Code) {
-		var response testListResponse
-		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
-			suite.Equal(1, len(response.Data), "Should return a single result")
-			suite.Equal(uint32(30), response.Pagigation.Count)
-			suite.Equal(uint8(1), response.Pagigation.Limit)
-			suite.Equal(uint32(1), response.Pagigation.Offset)
-			suite.Equal(uint32(2), response.Data[0].ID)
-			suite.Equal("Maupin: Vanagon Camper", response.Data[0].Name)
-		}
-	}
+	suite.Equal(http.StatusNotFound, w.Code)
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsSuccessAllFilters() {
-	req, _ := http.NewRequest("GET", "/rentals/?near=33.68,-117.82&price_min=9000&price_max=16000&ids=7,15&sort=price&limit=1&offset=0", nil)
+func (suite *RentalControllerTestSuite) TestUpdateAmenitiesRequiresUser() {
+	req, _ := http.NewRequest("PUT", "/rentals/1/amenities", strings.NewReader(`{"amenities":["kitchen"]}`))
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	if suite.Equal(http.StatusOK, w.Code) {
-		var response testListResponse
-		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
-			suite.Equal(1, len(response.Data), "Should return a single result")
-			suite.Equal(uint32(2), response.Pagigation.Count)
-			suite.Equal(uint8(1), response.Pagigation.Limit)
-			suite.Equal(uint32(0), response.Pagigation.Offset)
-			suite.Equal(uint32(15), response.Data[0].ID)
-			suite.Equal("AWESOME 1977 Volkswagen Westfalia camper", response.Data[0].Name)
-		}
-	}
+	suite.Equal(http.StatusUnauthorized, w.Code)
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsInvalidPriceMin() {
-	req, _ := http.NewRequest("GET", "/rentals/?price_min=a", nil)
+func (suite *RentalControllerTestSuite) TestUpdateAmenitiesNotOwner() {
+	req, _ := http.NewRequest("PUT", "/rentals/1/amenities", strings.NewReader(`{"amenities":["kitchen"]}`))
+	// rental 1 belongs to user 1
+	req.Header.Set(auth.UserIdHeader, "2")
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	suite.Equal(http.StatusBadRequest, w.Code)
+	suite.Equal(http.StatusForbidden, w.Code)
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsInvalidPriceMax() {
-	req, _ := http.NewRequest("GET", "/rentals/?price_max=a", nil)
+func (suite *RentalControllerTestSuite) TestUpdateAmenitiesUnknownAmenity() {
+	req, _ := http.NewRequest("PUT", "/rentals/1/amenities", strings.NewReader(`{"amenities":["hot-tub"]}`))
+	req.Header.Set(auth.UserIdHeader, "1")
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
 	suite.Equal(http.StatusBadRequest, w.Code)
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsInvalidLimit() {
-	req, _ := http.NewRequest("GET", "/rentals/?limit=50.5", nil)
+func (suite *RentalControllerTestSuite) TestUpdateAmenitiesSuccess() {
+	req, _ := http.NewRequest("PUT", "/rentals/1/amenities", strings.NewReader(`{"amenities":["kitchen"]}`))
+	req.Header.Set(auth.UserIdHeader, "1")
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	suite.Equal(http.StatusBadRequest, w.Code)
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.JSONEq(`{"amenities":["kitchen"]}`, w.Body.String())
+	}
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsLimitTooLarge() {
-	req, _ := http.NewRequest("GET", "/rentals/?limit=1000", nil)
+func (suite *RentalControllerTestSuite) TestExportRequiresUser() {
+	req, _ := http.NewRequest("GET", "/rentals/export", nil)
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	suite.Equal(http.StatusBadRequest, w.Code)
+	suite.Equal(http.StatusUnauthorized, w.Code)
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsInvalidOffset() {
-	req, _ := http.NewRequest("GET", "/rentals/?offset=50.5", nil)
+func (suite *RentalControllerTestSuite) TestExportInvalidFormat() {
+	req, _ := http.NewRequest("GET", "/rentals/export?format=xlsx", nil)
+	req.Header.Set(auth.UserIdHeader, "2")
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
 	suite.Equal(http.StatusBadRequest, w.Code)
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsInvalidSort() {
-	req, _ := http.NewRequest("GET", "/rentals/?sort=notafield", nil)
+func (suite *RentalControllerTestSuite) TestExportInvalidFilter() {
+	req, _ := http.NewRequest("GET", "/rentals/export?price_min=cheap", nil)
+	req.Header.Set(auth.UserIdHeader, "2")
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
 	suite.Equal(http.StatusBadRequest, w.Code)
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsInvalidIds() {
-	req, _ := http.NewRequest("GET", "/rentals/?ids=1,a", nil)
+func (suite *RentalControllerTestSuite) TestExportCsv() {
+	// pagination is ignored, limit would be too large for /rentals
+	req, _ := http.NewRequest("GET", "/rentals/export?format=csv&ids=1,2&limit=1000", nil)
+	req.Header.Set(auth.UserIdHeader, "2")
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	suite.Equal(http.StatusBadRequest, w.Code)
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.Equal("text/csv", w.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		if suite.Len(lines, 3) {
+			suite.True(strings.HasPrefix(lines[0], "id,user_id,external_id,name"))
+			suite.True(strings.HasPrefix(lines[1], "1,"))
+		}
+	}
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsInvalidNear() {
-	req, _ := http.NewRequest("GET", "/rentals/?near=1,a", nil)
+func (suite *RentalControllerTestSuite) TestExportNdjson() {
+	req, _ := http.NewRequest("GET", "/rentals/export?ids=2&sort=price", nil)
+	req.Header.Set(auth.UserIdHeader, "2")
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	suite.Equal(http.StatusBadRequest, w.Code)
+	if suite.Equal(http.StatusOK, w.Code) {
+		var rental models.RentalResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &rental), "Should not lead to an error") {
+			suite.Equal(uint32(2), rental.ID)
+		}
+	}
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsNearTooFewValues() {
-	req, _ := http.NewRequest("GET", "/rentals/?near=1", nil)
+func (suite *RentalControllerTestSuite) TestImportRequiresUser() {
+	req, _ := http.NewRequest("POST", "/rentals/import?format=csv", strings.NewReader("name\nWesty\n"))
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	suite.Equal(http.StatusBadRequest, w.Code)
+	suite.Equal(http.StatusUnauthorized, w.Code)
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsNearTooManyValues() {
-	req, _ := http.NewRequest("GET", "/rentals/?near=1,2,3", nil)
+func (suite *RentalControllerTestSuite) TestImportInvalidFormat() {
+	req, _ := http.NewRequest("POST", "/rentals/import", strings.NewReader("name\nWesty\n"))
+	req.Header.Set("Content-Type", "application/vnd.ms-excel")
+	req.Header.Set(auth.UserIdHeader, "2")
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
 	suite.Equal(http.StatusBadRequest, w.Code)
 }
 
-func (suite *RentalControllerTestSuite) TestListRentalsNearInvalidLatLong() {
-	req, _ := http.NewRequest("GET", "/rentals/?near=100,200", nil)
+func (suite *RentalControllerTestSuite) TestImportUnknownColumn() {
+	req, _ := http.NewRequest("POST", "/rentals/import", strings.NewReader("name,colour\nWesty,blue\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set(auth.UserIdHeader, "2")
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
 	suite.Equal(http.StatusBadRequest, w.Code)
 }
 
-// GET /rentals/:rental_id tests
-func (suite *RentalControllerTestSuite) TestGetRentalSuccess() {
-	req, _ := http.NewRequest("GET", "/rentals/1", nil)
+func (suite *RentalControllerTestSuite) TestImportDryRun() {
+	body := `{"name":"Imported Westy","type":"camper-van","sleeps":2,"price":{"day":9000},"location":{"lat":45.52,"lng":-122.68}}
+{"name":"Missing Price","type":"camper-van","sleeps":2}
+`
+	req, _ := http.NewRequest("POST", "/rentals/import?dry_run=true&transaction=all", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set(auth.UserIdHeader, "2")
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
 	if suite.Equal(http.StatusOK, w.Code) {
-		var response models.RentalResponse
-		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
-			suite.Equal(uint32(1), response.ID)
-			suite.Equal(int64(16900), response.Price.Day)
-			suite.Equal("Costa Mesa", response.Location.City)
+		var report models.ImportReport
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &report), "Should not lead to an error") {
+			suite.False(report.Committed)
+			suite.Equal(models.ImportAllOrNothing, report.Transaction)
+			suite.Equal(1, report.Created)
+			suite.Equal(1, report.Rejected)
+			suite.Equal("Missing field: price.day", report.Rows[1].Reason)
 		}
 	}
 }
 
-func (suite *RentalControllerTestSuite) TestGetRentalIdNotFound() {
-	req, _ := http.NewRequest("GET", "/rentals/100", nil)
-	w := httptest.NewRecorder()
-	suite.router.ServeHTTP(w, req)
-
-	suite.Equal(http.StatusNotFound, w.Code)
-}
+func (suite *RentalControllerTestSuite) TestImportTooLarge() {
+	row := `{"name":"Imported Westy","type":"camper-van","sleeps":2,"price":{"day":9000},"location":{"lat":45.52,"lng":-122.68}}` + "\n"
+	body := row + row + strings.Repeat(row, 20)
+	config.InitWithOverrides("test", map[string]interface{}{"max_import_bytes": 2 * len(row)})
+	defer config.Init("test")
 
-func (suite *RentalControllerTestSuite) TestGetRentalInvalidId() {
-	req, _ := http.NewRequest("GET", "/rentals/invalid", nil)
+	req, _ := http.NewRequest("POST", "/rentals/import?dry_run=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set(auth.UserIdHeader, "2")
 	w := httptest.NewRecorder()
 	suite.router.ServeHTTP(w, req)
 
-	suite.Equal(http.StatusBadRequest, w.Code)
+	if suite.Equal(http.StatusRequestEntityTooLarge, w.Code) {
+		var response struct {
+			Report *models.ImportReport `json:"report"`
+		}
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should not lead to an error") && suite.NotNil(response.Report) {
+			// the rows before the limit
+			suite.Equal(2, response.Report.Created)
+			suite.False(response.Report.Committed)
+		}
+	}
 }
 
 // test for invalid route handling
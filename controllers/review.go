@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/models"
+	"gorm.io/gorm"
+)
+
+type ReviewController struct{}
+
+// Request body to review a rental
+type reviewRequest struct {
+	Cleanliness   int16  `json:"cleanliness" binding:"required,min=1,max=5"`
+	Accuracy      int16  `json:"accuracy" binding:"required,min=1,max=5"`
+	Communication int16  `json:"communication" binding:"required,min=1,max=5"`
+	Text          string `json:"text" binding:"max=5000"`
+}
+
+// Response for the list operation, note that his is only used to marshal results
+type reviewListResponse struct {
+	Pagigation *PaginationResponse `json:"pagination"`
+	Data       []models.Review     `json:"data"`
+}
+
+// GET /rentals/:rental_id/reviews
+func (u ReviewController) List(c *gin.Context) {
+	rental, ok := findRental(c)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := models.ParsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid pagination", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	reviews, count, err := models.FindReviews(rental.ID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, &reviewListResponse{
+		Pagigation: &PaginationResponse{
+			Count:  count,
+			Limit:  limit,
+			Offset: offset,
+		},
+		Data: reviews,
+	})
+}
+
+// POST /rentals/:rental_id/reviews
+func (u ReviewController) Create(c *gin.Context) {
+	rental, ok := findRental(c)
+	if !ok {
+		return
+	}
+
+	var request reviewRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid review", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	userId, _ := auth.UserId(c)
+	if userId == rental.UserId {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Not allowed to review your own rental"})
+		c.Abort()
+		return
+	}
+
+	review := models.Review{
+		RentalId:      rental.ID,
+		UserId:        userId,
+		Cleanliness:   request.Cleanliness,
+		Accuracy:      request.Accuracy,
+		Communication: request.Communication,
+		Text:          request.Text,
+	}
+	if err := models.CreateReview(&review); err != nil {
+		// users can only review a rental once
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Rental already reviewed"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusCreated, review)
+}
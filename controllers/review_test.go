@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the Review controller
+type ReviewControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+func (suite *ReviewControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+type testReviewListResponse struct {
+	Pagigation *PaginationResponse `json:"pagination"`
+	// after a Review model is marshaled
+	Data []models.ReviewResponse `json:"data"`
+}
+
+// GET /rentals/:rental_id/reviews tests
+func (suite *ReviewControllerTestSuite) TestListReviewsSuccess() {
+	req, _ := http.NewRequest("GET", "/rentals/1/reviews?limit=10", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response testReviewListResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.Equal(uint32(2), response.Pagigation.Count)
+			// most recent first
+			suite.Equal("Barry", response.Data[0].User.FirstName)
+			suite.InDelta(4.33, response.Data[0].Rating, 0.01)
+		}
+	}
+}
+
+func (suite *ReviewControllerTestSuite) TestListReviewsRentalNotFound() {
+	req, _ := http.NewRequest("GET", "/rentals/1000/reviews", nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+// POST /rentals/:rental_id/reviews tests
+func (suite *ReviewControllerTestSuite) TestCreateReviewRequiresUser() {
+	req, _ := http.NewRequest("POST", "/rentals/3/reviews", strings.NewReader(`{"cleanliness":5,"accuracy":5,"communication":5}`))
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (suite *ReviewControllerTestSuite) TestCreateReviewInvalidScore() {
+	req, _ := http.NewRequest("POST", "/rentals/3/reviews", strings.NewReader(`{"cleanliness":6,"accuracy":5,"communication":5}`))
+	req.Header.Set(auth.UserIdHeader, "1")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (suite *ReviewControllerTestSuite) TestCreateReviewOwnRental() {
+	// rental 3 belongs to user 3
+	req, _ := http.NewRequest("POST", "/rentals/3/reviews", strings.NewReader(`{"cleanliness":5,"accuracy":5,"communication":5}`))
+	req.Header.Set(auth.UserIdHeader, "3")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusForbidden, w.Code)
+}
+
+func (suite *ReviewControllerTestSuite) TestCreateReviewUpdatesAggregates() {
+	req, _ := http.NewRequest("POST", "/rentals/4/reviews", strings.NewReader(`{"cleanliness":4,"accuracy":4,"communication":4,"text":"Nice"}`))
+	req.Header.Set(auth.UserIdHeader, "5")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	if suite.Equal(http.StatusCreated, w.Code) {
+		var response models.ReviewResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.Equal(uint32(5), response.User.Id)
+			suite.Equal(float64(4), response.Rating)
+		}
+
+		req, _ = http.NewRequest("GET", "/rentals/4", nil)
+		w = httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+
+		var rental models.RentalResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &rental), "Should be able to unmarshal response") {
+			suite.Equal(int32(1), rental.ReviewCount)
+			suite.Equal(float64(4), *rental.RatingAverage)
+		}
+	}
+
+	// a second review by the same user is rejected
+	req, _ = http.NewRequest("POST", "/rentals/4/reviews", strings.NewReader(`{"cleanliness":1,"accuracy":1,"communication":1}`))
+	req.Header.Set(auth.UserIdHeader, "5")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusConflict, w.Code)
+}
+
+func TestReviewControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(ReviewControllerTestSuite))
+}
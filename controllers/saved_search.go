@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"gorm.io/gorm"
+)
+
+type SavedSearchController struct{}
+
+// Request body to save a search, the filter uses the /rentals query params
+type savedSearchRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// GET /saved-searches
+func (u SavedSearchController) List(c *gin.Context) {
+	userId, _ := auth.UserId(c)
+	searches, err := models.FindSavedSearches(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": searches})
+}
+
+// POST /saved-searches
+func (u SavedSearchController) Create(c *gin.Context) {
+	filter, err := models.ParseQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid filter", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	var request savedSearchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid saved search", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	userId, _ := auth.UserId(c)
+	search := models.SavedSearch{UserId: userId, Name: request.Name, Filter: *filter}
+	if err := models.CreateSavedSearch(&search); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusCreated, search)
+}
+
+// DELETE /saved-searches/:saved_search_id
+func (u SavedSearchController) Delete(c *gin.Context) {
+	// id is an integer in the database, only needs int32
+	searchId, err := strconv.ParseInt(c.Param("saved_search_id"), 10, 32)
+	if err != nil {
+		log.Log.Warn(fmt.Sprintf("Invalid saved search id: %s", c.Param("saved_search_id")))
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid saved search id"})
+		c.Abort()
+		return
+	}
+
+	var search models.SavedSearch
+	if result := db.DB.First(&search, searchId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Saved search not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": result.Error.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	// don't reveal which saved searches exist
+	if !auth.IsUserOrAdmin(c, search.UserId) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Saved search not found"})
+		c.Abort()
+		return
+	}
+
+	if err := db.DB.Delete(&search).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the SavedSearch controller
+type SavedSearchControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+func (suite *SavedSearchControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+func (suite *SavedSearchControllerTestSuite) request(method string, path string, body string, userId string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if userId != "" {
+		req.Header.Set(auth.UserIdHeader, userId)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	return w
+}
+
+// POST /saved-searches tests
+func (suite *SavedSearchControllerTestSuite) TestCreateSavedSearch() {
+	w := suite.request("POST", "/saved-searches/?near=39.74,-104.99&sleeps_min=4&price_max=15000&limit=5", `{"name":"Denver"}`, "3")
+
+	if suite.Equal(http.StatusCreated, w.Code) {
+		var response models.SavedSearchResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.Equal("Denver", response.Name)
+			suite.Equal([]float32{39.74, -104.99}, response.Filter.Near)
+			suite.Equal(int32(4), *response.Filter.SleepsMin)
+			suite.Equal(int64(15000), *response.Filter.PriceMax)
+			// pagination is not saved
+			suite.Equal(uint8(0), response.Filter.Limit)
+		}
+
+		w = suite.request("GET", "/saved-searches/", "", "3")
+		suite.Contains(w.Body.String(), `"name":"Denver"`)
+
+		// only the owner can remove it
+		w = suite.request("DELETE", fmt.Sprintf("/saved-searches/%d", response.ID), "", "4")
+		suite.Equal(http.StatusNotFound, w.Code)
+		w = suite.request("DELETE", fmt.Sprintf("/saved-searches/%d", response.ID), "", "3")
+		suite.Equal(http.StatusNoContent, w.Code)
+	}
+}
+
+func (suite *SavedSearchControllerTestSuite) TestCreateSavedSearchInvalidFilter() {
+	w := suite.request("POST", "/saved-searches/?sleeps_min=many", `{"name":"Invalid"}`, "3")
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (suite *SavedSearchControllerTestSuite) TestCreateSavedSearchRequiresUser() {
+	w := suite.request("POST", "/saved-searches/?sleeps_min=4", `{"name":"Anonymous"}`, "")
+	suite.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func TestSavedSearchControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(SavedSearchControllerTestSuite))
+}
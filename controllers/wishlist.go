@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"gorm.io/gorm"
+)
+
+type WishlistController struct{}
+
+// Request body to create or rename a wishlist
+type wishlistRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// Request body to save a rental in a wishlist
+type wishlistItemRequest struct {
+	RentalId uint32 `json:"rental_id" binding:"required"`
+}
+
+// GET /wishlists
+func (u WishlistController) List(c *gin.Context) {
+	userId, _ := auth.UserId(c)
+	wishlists, err := models.FindWishlists(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": wishlists})
+}
+
+// GET /wishlists/:wishlist_id
+func (u WishlistController) Get(c *gin.Context) {
+	wishlist, ok := findWishlist(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, wishlist)
+}
+
+// GET /shared/wishlists/:share_token
+func (u WishlistController) GetShared(c *gin.Context) {
+	wishlist, err := models.FindSharedWishlist(c.Param("share_token"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Wishlist not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	// the token is a secret of the owner
+	wishlist.ShareToken = nil
+	c.JSON(http.StatusOK, wishlist)
+}
+
+// POST /wishlists
+func (u WishlistController) Create(c *gin.Context) {
+	var request wishlistRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid wishlist", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	userId, _ := auth.UserId(c)
+	wishlist := models.Wishlist{UserId: userId, Name: request.Name}
+	if err := db.DB.Create(&wishlist).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusCreated, wishlist)
+}
+
+// PUT /wishlists/:wishlist_id
+func (u WishlistController) Update(c *gin.Context) {
+	wishlist, ok := findWishlist(c)
+	if !ok {
+		return
+	}
+
+	var request wishlistRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid wishlist", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	wishlist.Name = request.Name
+	if err := db.DB.Model(wishlist).Update("name", wishlist.Name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, wishlist)
+}
+
+// DELETE /wishlists/:wishlist_id
+func (u WishlistController) Delete(c *gin.Context) {
+	wishlist, ok := findWishlist(c)
+	if !ok {
+		return
+	}
+
+	// items are removed by the database
+	if err := db.DB.Delete(wishlist).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// POST /wishlists/:wishlist_id/share
+func (u WishlistController) Share(c *gin.Context) {
+	wishlist, ok := findWishlist(c)
+	if !ok {
+		return
+	}
+
+	if err := wishlist.Share(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, wishlist)
+}
+
+// DELETE /wishlists/:wishlist_id/share
+func (u WishlistController) Unshare(c *gin.Context) {
+	wishlist, ok := findWishlist(c)
+	if !ok {
+		return
+	}
+
+	if err := wishlist.Unshare(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusOK, wishlist)
+}
+
+// POST /wishlists/:wishlist_id/items
+func (u WishlistController) AddItem(c *gin.Context) {
+	wishlist, ok := findWishlist(c)
+	if !ok {
+		return
+	}
+
+	var request wishlistItemRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid wishlist item", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	var rental models.Rental
+	if result := db.DB.First(&rental, request.RentalId); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid wishlist item", "error": fmt.Sprintf("Unknown rental: %d", request.RentalId)})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": result.Error.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	if _, err := wishlist.AddItem(&rental); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Rental already in wishlist"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		}
+		c.Abort()
+		return
+	}
+
+	// respond with the whole list so clients don't have to reload it
+	wishlist, err := models.FindWishlist(wishlist.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.JSON(http.StatusCreated, wishlist)
+}
+
+// DELETE /wishlists/:wishlist_id/items/:rental_id
+func (u WishlistController) RemoveItem(c *gin.Context) {
+	wishlist, ok := findWishlist(c)
+	if !ok {
+		return
+	}
+
+	// id is an integer in the database, only needs int32
+	rentalId, err := strconv.ParseInt(c.Param("rental_id"), 10, 32)
+	if err != nil {
+		log.Log.Warn(fmt.Sprintf("Invalid rental id: %s", c.Param("rental_id")))
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid rental id"})
+		c.Abort()
+		return
+	}
+
+	removed, err := wishlist.RemoveItem(uint32(rentalId))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		c.Abort()
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Rental not in wishlist"})
+		c.Abort()
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Load the wishlist from the route, only its owner can see or change it
+func findWishlist(c *gin.Context) (*models.Wishlist, bool) {
+	// id is an integer in the database, only needs int32
+	wishlistId, err := strconv.ParseInt(c.Param("wishlist_id"), 10, 32)
+	if err != nil {
+		log.Log.Warn(fmt.Sprintf("Invalid wishlist id: %s", c.Param("wishlist_id")))
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid wishlist id"})
+		c.Abort()
+		return nil, false
+	}
+
+	wishlist, err := models.FindWishlist(uint32(wishlistId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Wishlist not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Something went wrong", "error": err.Error()})
+		}
+		c.Abort()
+		return nil, false
+	}
+
+	// don't reveal which wishlists exist
+	if !auth.IsUserOrAdmin(c, wishlist.UserId) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Wishlist not found"})
+		c.Abort()
+		return nil, false
+	}
+
+	return wishlist, true
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the Wishlist controller
+type WishlistControllerTestSuite struct {
+	suite.Suite
+	config *config.Config
+	router *gin.Engine
+}
+
+func (suite *WishlistControllerTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.router = setupRouter()
+}
+
+func (suite *WishlistControllerTestSuite) request(method string, path string, body string, userId string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if userId != "" {
+		req.Header.Set(auth.UserIdHeader, userId)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	return w
+}
+
+func (suite *WishlistControllerTestSuite) createWishlist(name string, userId string) models.WishlistResponse {
+	w := suite.request("POST", "/wishlists/", fmt.Sprintf(`{"name":%q}`, name), userId)
+	suite.Require().Equal(http.StatusCreated, w.Code)
+
+	var wishlist models.WishlistResponse
+	suite.Require().Nil(json.Unmarshal(w.Body.Bytes(), &wishlist))
+	return wishlist
+}
+
+// POST /wishlists tests
+func (suite *WishlistControllerTestSuite) TestCreateWishlistRequiresUser() {
+	w := suite.request("POST", "/wishlists/", `{"name":"Summer"}`, "")
+	suite.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (suite *WishlistControllerTestSuite) TestCreateWishlistInvalid() {
+	w := suite.request("POST", "/wishlists/", `{}`, "4")
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+// Items tests
+func (suite *WishlistControllerTestSuite) TestWishlistItems() {
+	wishlist := suite.createWishlist("Summer", "4")
+	suite.Equal("Summer", wishlist.Name)
+	suite.Empty(wishlist.Items)
+
+	path := fmt.Sprintf("/wishlists/%d", wishlist.ID)
+	w := suite.request("POST", path+"/items", `{"rental_id":3}`, "4")
+	if suite.Equal(http.StatusCreated, w.Code) {
+		var response models.WishlistResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			if suite.Len(response.Items, 1) {
+				item := response.Items[0]
+				suite.Equal(uint32(3), item.RentalId)
+				suite.True(item.Active)
+				suite.Equal(item.SavedPrice.Day, item.CurrentPrice.Day)
+				suite.Equal(int64(0), *item.PriceChange)
+			}
+		}
+	}
+
+	// saving a rental twice is rejected
+	w = suite.request("POST", path+"/items", `{"rental_id":3}`, "4")
+	suite.Equal(http.StatusConflict, w.Code)
+
+	w = suite.request("POST", path+"/items", `{"rental_id":1000}`, "4")
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	w = suite.request("DELETE", path+"/items/3", "", "4")
+	suite.Equal(http.StatusNoContent, w.Code)
+	w = suite.request("DELETE", path+"/items/3", "", "4")
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+func (suite *WishlistControllerTestSuite) TestWishlistOtherUser() {
+	wishlist := suite.createWishlist("Private", "4")
+	path := fmt.Sprintf("/wishlists/%d", wishlist.ID)
+
+	w := suite.request("GET", path, "", "5")
+	suite.Equal(http.StatusNotFound, w.Code)
+	w = suite.request("PUT", path, `{"name":"Mine"}`, "5")
+	suite.Equal(http.StatusNotFound, w.Code)
+
+	// not listed for other users
+	w = suite.request("GET", "/wishlists/", "", "5")
+	suite.NotContains(w.Body.String(), `"Private"`)
+}
+
+func (suite *WishlistControllerTestSuite) TestRenameWishlist() {
+	wishlist := suite.createWishlist("Road trip", "4")
+
+	w := suite.request("PUT", fmt.Sprintf("/wishlists/%d", wishlist.ID), `{"name":"Fall road trip"}`, "4")
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response models.WishlistResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.Equal("Fall road trip", response.Name)
+		}
+	}
+}
+
+// Sharing tests
+func (suite *WishlistControllerTestSuite) TestShareWishlist() {
+	wishlist := suite.createWishlist("Shared", "4")
+	path := fmt.Sprintf("/wishlists/%d", wishlist.ID)
+	suite.request("POST", path+"/items", `{"rental_id":5}`, "4")
+
+	w := suite.request("POST", path+"/share", "", "4")
+	var shared models.WishlistResponse
+	if suite.Equal(http.StatusOK, w.Code) {
+		suite.Nil(json.Unmarshal(w.Body.Bytes(), &shared), "Should be able to unmarshal response")
+		suite.NotNil(shared.ShareToken)
+	}
+	if shared.ShareToken == nil {
+		return
+	}
+
+	// anyone with the link can read the list but not the token
+	w = suite.request("GET", "/shared/wishlists/"+*shared.ShareToken, "", "")
+	if suite.Equal(http.StatusOK, w.Code) {
+		var response models.WishlistResponse
+		if suite.Nil(json.Unmarshal(w.Body.Bytes(), &response), "Should be able to unmarshal response") {
+			suite.Nil(response.ShareToken)
+			suite.Len(response.Items, 1)
+		}
+	}
+
+	// links stop working once the list is unshared
+	w = suite.request("DELETE", path+"/share", "", "4")
+	suite.Equal(http.StatusOK, w.Code)
+	w = suite.request("GET", "/shared/wishlists/"+*shared.ShareToken, "", "")
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestWishlistControllerTestSuite(t *testing.T) {
+	suite.Run(t, new(WishlistControllerTestSuite))
+}
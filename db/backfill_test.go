@@ -0,0 +1,102 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samuelg/rentals/config"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the migrations filling tables from existing rows, runs on a
+// sqlite database of its own since it reverts migrations
+type BackfillTestSuite struct {
+	suite.Suite
+}
+
+func (suite *BackfillTestSuite) SetupTest() {
+	config.InitWithOverrides("test", map[string]interface{}{
+		"db_driver": "sqlite",
+		"db_path":   filepath.Join(suite.T().TempDir(), "backfill.db"),
+	})
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	Init()
+	Prepare()
+}
+
+func (suite *BackfillTestSuite) TearDownTest() {
+	Close()
+	config.Init("test")
+}
+
+// Revert the migrations from the version on, the rows stay in the tables
+// created before
+func (suite *BackfillTestSuite) revertFrom(version uint32) {
+	steps := 0
+	for _, migration := range Migrations() {
+		if migration.Version >= version {
+			steps++
+		}
+	}
+	_, err := MigrateDown(steps)
+	suite.Require().Nil(err)
+}
+
+func (suite *BackfillTestSuite) TestRentalPrices() {
+	// rentals created before rental_prices existed
+	suite.revertFrom(10)
+	var rentals int64
+	suite.Require().Nil(DB.Table("rentals").Count(&rentals).Error)
+	suite.Require().Greater(rentals, int64(0))
+
+	_, err := MigrateUp()
+	suite.Require().Nil(err)
+
+	var missing int64
+	err = DB.Table("rentals").
+		Where("NOT EXISTS (SELECT 1 FROM rental_prices WHERE rental_prices.rental_id = rentals.id AND rental_prices.price_per_day IS rentals.price_per_day)").
+		Count(&missing).Error
+	if suite.Nil(err) {
+		suite.Equal(int64(0), missing, "Every rental should have its current price")
+	}
+	var prices int64
+	suite.Nil(DB.Table("rental_prices").Count(&prices).Error)
+	suite.Equal(rentals, prices)
+}
+
+func (suite *BackfillTestSuite) TestRentalVersions() {
+	// rentals created before rental_versions existed
+	suite.revertFrom(9)
+	var rentals int64
+	suite.Require().Nil(DB.Table("rentals").Count(&rentals).Error)
+	suite.Require().Greater(rentals, int64(0))
+
+	_, err := MigrateUp()
+	suite.Require().Nil(err)
+
+	// the as_of condition of models.RentalsAsOf
+	asOf := func(at time.Time) int64 {
+		var count int64
+		err := DB.Table("rental_versions").
+			Where("valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", at, at).
+			Count(&count).Error
+		suite.Nil(err)
+		return count
+	}
+	suite.Equal(rentals, asOf(time.Now()), "Every rental should have a current version")
+	suite.Equal(int64(0), asOf(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)), "Rentals should not exist before their creation")
+
+	var missing int64
+	err = DB.Table("rentals").
+		Where("NOT EXISTS (SELECT 1 FROM rental_versions WHERE rental_versions.id = rentals.id AND rental_versions.name = rentals.name)").
+		Count(&missing).Error
+	if suite.Nil(err) {
+		suite.Equal(int64(0), missing)
+	}
+}
+
+func TestBackfillTestSuite(t *testing.T) {
+	suite.Run(t, new(BackfillTestSuite))
+}
@@ -1,25 +1,44 @@
 package db
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/samuelg/rentals/config"
 	log "github.com/samuelg/rentals/logging"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 var DB *gorm.DB
 
 func Init() {
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
-		config.GetConfig().DbHost,
-		config.GetConfig().DbUser,
-		config.GetConfig().DbPassword,
-		config.GetConfig().DbName,
-		config.GetConfig().DbPort,
-	)
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	var dialector gorm.Dialector
+	if config.GetConfig().DbDriver == "sqlite" {
+		dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite",
+			config.GetConfig().DbPath)
+		dialector = sqlite.Dialector{DriverName: sqliteDriverName, DSN: dsn}
+	} else {
+		dsn := fmt.Sprintf(
+			"host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
+			config.GetConfig().DbHost,
+			config.GetConfig().DbUser,
+			config.GetConfig().DbPassword,
+			config.GetConfig().DbName,
+			config.GetConfig().DbPort,
+		)
+		dialector = postgres.Open(dsn)
+	}
+
+	database, err := gorm.Open(dialector, &gorm.Config{
+		// return gorm errors (ex: gorm.ErrDuplicatedKey) instead of driver errors
+		TranslateError: true,
+	})
 	if err != nil {
 		panic("Failed to connect to database")
 	}
@@ -27,3 +46,41 @@ func Init() {
 	log.Log.Info("Connected to database")
 	DB = database
 }
+
+// Returns true when the database is sqlite, queries relying on Postgres or
+// PostGIS have a sqlite variant
+func IsSQLite() bool {
+	return DB.Dialector.Name() == "sqlite"
+}
+
+// Returns true when the database refused the values of a query: data
+// exceptions and integrity constraint violations, with either driver. Other
+// errors, ex: a lost connection, mean the query couldn't run
+func IsDataError(err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) || errors.Is(err, gorm.ErrForeignKeyViolated) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")
+	}
+	var sqliteErr *gosqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// primary result code, without the extended code of the constraint
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_CONSTRAINT, sqlite3.SQLITE_MISMATCH, sqlite3.SQLITE_TOOBIG:
+			return true
+		}
+	}
+	return false
+}
+
+// Close the connections of the pool once the queries using them complete,
+// queries fail afterwards
+func Close() error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
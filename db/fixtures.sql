@@ -1,39 +1,10 @@
-CREATE TABLE IF NOT EXISTS users (
-    id SERIAL PRIMARY KEY,
-    first_name text,
-    last_name text
-);
-
-CREATE TABLE IF NOT EXISTS rentals (
-    id SERIAL PRIMARY KEY,
-    user_id integer,
-    name text,
-    type text,
-    description text,
-    sleeps integer,
-    price_per_day bigint,
-    home_city text,
-    home_state text,
-    home_zip text,
-    home_country text,
-    vehicle_make text,
-    vehicle_model text,
-    vehicle_year integer,
-    vehicle_length numeric(4,2),
-    created timestamp with time zone,
-    updated timestamp with time zone,
-    lat double precision,
-    lng double precision,
-    primary_image_url text
-);
-
-INSERT INTO "users"("id", "first_name", "last_name")
+INSERT INTO "users"("id", "first_name", "last_name", "email")
 VALUES
-    (1, 'John', 'Smith'),
-    (2, 'Jane', 'Doe'),
-    (3, 'Barry', 'Martin'),
-    (4, 'Todd', 'Edison'),
-    (5, 'Ben', 'Reynard')
+    (1, 'John', 'Smith', 'john.smith@example.com'),
+    (2, 'Jane', 'Doe', 'jane.doe@example.com'),
+    (3, 'Barry', 'Martin', 'barry.martin@example.com'),
+    (4, 'Todd', 'Edison', 'todd.edison@example.com'),
+    (5, 'Ben', 'Reynard', NULL)
 ;
 
 INSERT INTO "rentals"("user_id", "name","type","description","sleeps","price_per_day","home_city","home_state","home_zip","home_country","vehicle_make","vehicle_model","vehicle_year","vehicle_length","created","updated","lat","lng","primary_image_url")
@@ -67,4 +38,62 @@ VALUES
 (2, E'Coya | Van-gelina Jolie',E'camper-van',E'lacus cras molestie nam dapibus ullamcorper massa ultricies bibendum lectus auctor nisi ridiculus ultricies tristique curabitur diam feugiat erat inceptos sapien vivamus parturient sem nibh',2,20000,E'Seattle',E'WA',E'98116',E'US',E'Ford',E'Transit',2019,20,E'2021-11-29 22:42:06.478595+00',E'2021-11-29 22:42:06.478595+00',47.56,-122.39,E'https://res.cloudinary.com/outdoorsy/image/upload/v1582091293/p/rentals/153401/images/kaqt2b6n6sm1xnmvbi5w.jpg'),
 (3, E'sCAMPer X',E'camper-van',E'ac tellus phasellus ultrices nostra eros aenean metus ridiculus adipiscing habitant nulla cubilia tortor rhoncus quisque sem ultrices varius massa mollis congue praesent nam ante',4,17500,E'Atlanta',E'GA',E'30310',E'US',E'Ram',E'Promaster',2020,19,E'2021-11-29 22:42:06.478595+00',E'2021-11-29 22:42:06.478595+00',33.73,-84.41,E'https://res.cloudinary.com/outdoorsy/image/upload/v1589910541/p/rentals/156152/images/jvyvtqoeljadoizjjzag.jpg'),
 (4, E'2015 Dodge Sprinter Van',E'camper-van',E'pretium non litora lobortis pharetra elit sociosqu platea nostra interdum odio vestibulum tincidunt mi blandit convallis pellentesque tempor viverra fermentum ultricies nunc egestas id arcu',2,17000,E'Silverthorne',E'CO',E'80498',E'US',E'Dodge',E'Sprinter Van',2015,20,E'2021-11-29 22:42:06.478595+00',E'2021-11-29 22:42:06.478595+00',39.62,-106.09,E'https://res.cloudinary.com/outdoorsy/image/upload/v1588550855/p/rentals/162781/images/az0xp8wbdto4pjzlkyh3.jpg'),
-(5, E'The New Adventures of Pearl - 2014 Nissan NV2500 High Top',E'camper-van',E'malesuada eget conubia porta sollicitudin urna ad aenean lacus vulputate parturient vulputate suspendisse sit parturient ante mauris maecenas dignissim donec eget adipiscing dui luctus eget',2,18900,E'Denver',E'CO',E'80222',E'US',E'Nissan',E'NV2500',2014,20,E'2021-11-29 22:42:06.478595+00',E'2021-11-29 22:42:06.478595+00',39.67,-104.92,E'https://res.cloudinary.com/outdoorsy/image/upload/v1590500837/undefined/rentals/164961/images/t3nkxdl0ua8g6gp1idcm.jpg');
\ No newline at end of file
+(5, E'The New Adventures of Pearl - 2014 Nissan NV2500 High Top',E'camper-van',E'malesuada eget conubia porta sollicitudin urna ad aenean lacus vulputate parturient vulputate suspendisse sit parturient ante mauris maecenas dignissim donec eget adipiscing dui luctus eget',2,18900,E'Denver',E'CO',E'80222',E'US',E'Nissan',E'NV2500',2014,20,E'2021-11-29 22:42:06.478595+00',E'2021-11-29 22:42:06.478595+00',39.67,-104.92,E'https://res.cloudinary.com/outdoorsy/image/upload/v1590500837/undefined/rentals/164961/images/t3nkxdl0ua8g6gp1idcm.jpg');
+
+INSERT INTO "amenities"("id", "key", "name")
+VALUES
+    (1, 'pets', 'Pet friendly'),
+    (2, 'kitchen', 'Kitchen'),
+    (3, 'shower', 'Shower'),
+    (4, 'toilet', 'Toilet'),
+    (5, '4wd', '4WD'),
+    (6, 'solar', 'Solar power'),
+    (7, 'ac', 'Air conditioning'),
+    (8, 'heater', 'Heater')
+;
+SELECT setval('amenities_id_seq', (SELECT MAX(id) FROM amenities));
+
+INSERT INTO "rental_amenities"("rental_id", "amenity_id")
+VALUES
+    (1, 2),
+    (2, 1),
+    (2, 2),
+    (3, 2),
+    (9, 5),
+    (9, 7),
+    (10, 2),
+    (17, 5),
+    (18, 5),
+    (18, 8),
+    (26, 2),
+    (26, 3),
+    (26, 4),
+    (26, 6)
+;
+
+UPDATE "rentals" SET "fuel_type" = 'gasoline', "transmission" = 'manual', "drivetrain" = 'rwd', "seatbelts" = 4, "fresh_water_capacity" = 13, "generator" = false WHERE "id" IN (1, 2, 3, 10, 15);
+UPDATE "rentals" SET "fuel_type" = 'gasoline', "transmission" = 'automatic', "drivetrain" = 'rwd', "seatbelts" = 2, "tow_capacity" = 5000, "fresh_water_capacity" = 20, "generator" = true WHERE "id" IN (4, 14, 16, 19, 24, 27);
+UPDATE "rentals" SET "fuel_type" = 'diesel', "transmission" = 'automatic', "drivetrain" = 'rwd', "seatbelts" = 2, "tow_capacity" = 5000, "fresh_water_capacity" = 25, "generator" = false WHERE "id" IN (5, 22, 29);
+UPDATE "rentals" SET "fuel_type" = 'gasoline', "transmission" = 'automatic', "drivetrain" = '4wd', "seatbelts" = 5, "tow_capacity" = 5000 WHERE "id" IN (17, 18);
+UPDATE "rentals" SET "fuel_type" = 'gasoline', "transmission" = 'automatic', "drivetrain" = 'awd', "seatbelts" = 5 WHERE "id" = 9;
+UPDATE "rentals" SET "fuel_type" = 'electric', "transmission" = 'automatic', "drivetrain" = 'fwd', "seatbelts" = 4, "ev_range" = 120 WHERE "id" = 28;
+
+INSERT INTO "reviews"("rental_id", "user_id", "cleanliness", "accuracy", "communication", "text", "created")
+VALUES
+    (1, 2, 5, 5, 4, E'Great van, John was very helpful.', E'2022-06-12 18:00:00+00'),
+    (1, 3, 4, 4, 5, E'Clean and exactly as described.', E'2022-07-03 18:00:00+00'),
+    (2, 1, 3, 4, 3, E'Fun trip but the pop-top was hard to open.', E'2022-08-21 18:00:00+00'),
+    (5, 4, 5, 5, 5, E'Perfect weekend in San Diego!', E'2022-09-10 18:00:00+00')
+;
+
+-- aggregates are normally maintained as reviews are created
+UPDATE "rentals" SET
+    "review_count" = "aggregates"."count",
+    "rating_sum" = "aggregates"."sum",
+    "rating_average" = "aggregates"."sum" / "aggregates"."count"
+FROM (
+    SELECT "rental_id", COUNT(*) AS "count", SUM(("cleanliness" + "accuracy" + "communication") / 3.0) AS "sum"
+    FROM "reviews"
+    GROUP BY "rental_id"
+) AS "aggregates"
+WHERE "rentals"."id" = "aggregates"."rental_id";
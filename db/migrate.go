@@ -0,0 +1,321 @@
+package db
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/samuelg/rentals/logging"
+	"gorm.io/gorm"
+)
+
+// Key of the advisory lock held while migrating, only one instance migrates at
+// a time
+const migrationLockKey = 7_365_120_411
+
+//go:embed migrations/*.sql migrations_sqlite/*.sql
+var migrationFiles embed.FS
+
+//go:embed fixtures.sql
+var fixtures string
+
+// The same rows in sqlite syntax, fixtures.sql uses Postgres escape strings
+// and sets sequences
+//
+//go:embed fixtures_sqlite.sql
+var sqliteFixtures string
+
+// Migration file names, ex: 0001_create_rentals.up.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// A versioned schema change and how to revert it
+type Migration struct {
+	Version uint32
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Version of the schema recorded in schema_migrations when a migration is applied
+type SchemaMigration struct {
+	Version uint32    `gorm:"primary_key;column:version"`
+	Name    string    `gorm:"column:name"`
+	Applied time.Time `gorm:"column:applied;autoCreateTime"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// State of a migration
+type MigrationStatus struct {
+	Version uint32     `json:"version"`
+	Name    string     `json:"name"`
+	Applied *time.Time `json:"applied"`
+}
+
+// Load the migrations of a directory ordered by version, every version needs
+// both an up and a down file
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint32]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration version %s", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[uint32(version)]
+		if !ok {
+			migration = &Migration{Version: uint32(version), Name: match[2]}
+			byVersion[uint32(version)] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrations embedded in the binary for the database, sqlite has its own
+func Migrations() []Migration {
+	dir := "migrations"
+	if DB != nil && IsSQLite() {
+		dir = "migrations_sqlite"
+	}
+	migrations, err := LoadMigrations(migrationFiles, dir)
+	if err != nil {
+		// the embedded migrations are part of the build, they should always load
+		panic(fmt.Sprintf("invalid embedded migrations: %v", err))
+	}
+
+	return migrations
+}
+
+// Apply the pending migrations in order, returns the ones applied
+func MigrateUp() ([]Migration, error) {
+	applied := make([]Migration, 0)
+
+	err := withMigrationLock(func(tx *gorm.DB) error {
+		versions, err := appliedVersions(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range Migrations() {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				if err := execScript(tx, migration.Up); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			log.Log.Info(fmt.Sprintf("Applied migration %d_%s", migration.Version, migration.Name))
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Revert the last steps applied migrations, most recent first, returns the
+// ones reverted
+func MigrateDown(steps int) ([]Migration, error) {
+	reverted := make([]Migration, 0)
+
+	err := withMigrationLock(func(tx *gorm.DB) error {
+		versions, err := appliedVersions(tx)
+		if err != nil {
+			return err
+		}
+
+		migrations := Migrations()
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				if err := execScript(tx, migration.Down); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{Version: migration.Version}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			log.Log.Info(fmt.Sprintf("Reverted migration %d_%s", migration.Version, migration.Name))
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// State of every migration, ordered by version
+func MigrationStatuses() ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+
+	err := withMigrationLock(func(tx *gorm.DB) error {
+		versions, err := appliedVersions(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range Migrations() {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if applied, ok := versions[migration.Version]; ok {
+				status.Applied = &applied
+			}
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Insert the fixtures used by tests and development when there are no users yet
+func LoadFixtures() (bool, error) {
+	loaded := false
+
+	err := withMigrationLock(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Table("users").Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		loaded = true
+		script := fixtures
+		if IsSQLite() {
+			script = sqliteFixtures
+		}
+		return tx.Transaction(func(tx *gorm.DB) error {
+			return execScript(tx, script)
+		})
+	})
+
+	return loaded, err
+}
+
+// Run migrations and load the fixtures, used to set up the test database
+func Prepare() {
+	if _, err := MigrateUp(); err != nil {
+		panic(fmt.Sprintf("Failed to migrate the database: %v", err))
+	}
+	if _, err := LoadFixtures(); err != nil {
+		panic(fmt.Sprintf("Failed to load fixtures: %v", err))
+	}
+}
+
+// Run the function on a single connection holding the migration lock, the
+// schema_migrations table is created first if needed
+func withMigrationLock(f func(tx *gorm.DB) error) error {
+	if DB == nil {
+		return errors.New("database not initialized")
+	}
+
+	return DB.Connection(func(tx *gorm.DB) error {
+		// every query starts from a new statement on the locked connection
+		tx = tx.Session(&gorm.Session{NewDB: true})
+		if IsSQLite() {
+			// a single process uses the file, sqlite locks it while writing
+			err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+				version integer PRIMARY KEY,
+				name text NOT NULL,
+				applied timestamp NOT NULL
+			)`).Error
+			if err != nil {
+				return err
+			}
+			return f(tx)
+		}
+
+		if err := tx.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+			return err
+		}
+		defer tx.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey)
+
+		err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version integer PRIMARY KEY,
+			name text NOT NULL,
+			applied timestamp with time zone NOT NULL DEFAULT now()
+		)`).Error
+		if err != nil {
+			return err
+		}
+
+		return f(tx)
+	})
+}
+
+// Applied versions with the time they were applied
+func appliedVersions(tx *gorm.DB) (map[uint32]time.Time, error) {
+	var rows []SchemaMigration
+	if err := tx.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	versions := make(map[uint32]time.Time, len(rows))
+	for _, row := range rows {
+		versions[row.Version] = row.Applied
+	}
+
+	return versions, nil
+}
+
+// Run a sql script on the connection as is, gorm would otherwise parse it for
+// ? and @ placeholders
+func execScript(tx *gorm.DB, script string) error {
+	_, err := tx.Statement.ConnPool.ExecContext(tx.Statement.Context, script)
+	return err
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/samuelg/rentals/config"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for loading migrations
+type MigrateTestSuite struct {
+	suite.Suite
+}
+
+func (suite *MigrateTestSuite) TestLoadMigrations() {
+	fsys := fstest.MapFS{
+		"sql/0002_add_reviews.up.sql":      {Data: []byte("CREATE TABLE reviews ();")},
+		"sql/0002_add_reviews.down.sql":    {Data: []byte("DROP TABLE reviews;")},
+		"sql/0001_create_rentals.up.sql":   {Data: []byte("CREATE TABLE rentals ();")},
+		"sql/0001_create_rentals.down.sql": {Data: []byte("DROP TABLE rentals;")},
+	}
+
+	migrations, err := LoadMigrations(fsys, "sql")
+
+	if suite.Nil(err, "Should not lead to an error") && suite.Len(migrations, 2) {
+		suite.Equal(uint32(1), migrations[0].Version)
+		suite.Equal("create_rentals", migrations[0].Name)
+		suite.Equal("CREATE TABLE rentals ();", migrations[0].Up)
+		suite.Equal("DROP TABLE rentals;", migrations[0].Down)
+		suite.Equal(uint32(2), migrations[1].Version)
+	}
+}
+
+func (suite *MigrateTestSuite) TestLoadMigrationsMissingDown() {
+	fsys := fstest.MapFS{
+		"sql/0001_create_rentals.up.sql": {Data: []byte("CREATE TABLE rentals ();")},
+	}
+
+	_, err := LoadMigrations(fsys, "sql")
+
+	if suite.NotNil(err, "Should result in an error") {
+		suite.Equal("migration 1_create_rentals needs an up and a down file", err.Error())
+	}
+}
+
+func (suite *MigrateTestSuite) TestLoadMigrationsInvalidName() {
+	fsys := fstest.MapFS{
+		"sql/create_rentals.sql": {Data: []byte("CREATE TABLE rentals ();")},
+	}
+
+	_, err := LoadMigrations(fsys, "sql")
+	suite.NotNil(err, "Should result in an error")
+}
+
+func (suite *MigrateTestSuite) TestLoadMigrationsConflictingNames() {
+	fsys := fstest.MapFS{
+		"sql/0001_create_rentals.up.sql": {Data: []byte("CREATE TABLE rentals ();")},
+		"sql/0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	}
+
+	_, err := LoadMigrations(fsys, "sql")
+	suite.NotNil(err, "Should result in an error")
+}
+
+func (suite *MigrateTestSuite) TestEmbeddedMigrations() {
+	migrations := Migrations()
+
+	if suite.NotEmpty(migrations) {
+		suite.Equal("create_rentals", migrations[0].Name)
+		for i := 1; i < len(migrations); i++ {
+			suite.Less(migrations[i-1].Version, migrations[i].Version)
+		}
+	}
+}
+
+func (suite *MigrateTestSuite) TestSqliteMigrations() {
+	postgres, err := LoadMigrations(migrationFiles, "migrations")
+	suite.Require().Nil(err)
+	sqlite, err := LoadMigrations(migrationFiles, "migrations_sqlite")
+	suite.Require().Nil(err)
+
+	if suite.Len(sqlite, len(postgres)) {
+		for i := range postgres {
+			suite.Equal(postgres[i].Version, sqlite[i].Version)
+			suite.Equal(postgres[i].Name, sqlite[i].Name)
+		}
+	}
+}
+
+// Rows inserted in every table by a fixtures script, one row per line
+func fixtureRows(script string) map[string]int {
+	rows := make(map[string]int)
+	table := ""
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if match := insertTable.FindStringSubmatch(line); match != nil {
+			table = match[1]
+		} else if table != "" && strings.HasPrefix(line, "(") {
+			rows[table]++
+		} else if strings.HasPrefix(line, ";") {
+			table = ""
+		}
+	}
+	return rows
+}
+
+var insertTable = regexp.MustCompile(`^INSERT INTO "?(\w+)"?`)
+
+func (suite *MigrateTestSuite) TestSqliteFixtures() {
+	rows := fixtureRows(fixtures)
+
+	suite.Equal(30, rows["rentals"])
+	suite.Equal(rows, fixtureRows(sqliteFixtures), "Should insert the same rows as fixtures.sql")
+}
+
+func TestMigrateTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrateTestSuite))
+}
+
+// Database of its own created by the suite, next to the Postgres test database
+const legacyDbName = "testingwithrentals_legacy"
+
+// Tables and a row as created by the former sql-init.sql
+const legacySchema = `
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    first_name text,
+    last_name text
+);
+
+CREATE TABLE rentals (
+    id SERIAL PRIMARY KEY,
+    user_id integer,
+    name text,
+    type text,
+    description text,
+    sleeps integer,
+    price_per_day bigint,
+    home_city text,
+    home_state text,
+    home_zip text,
+    home_country text,
+    vehicle_make text,
+    vehicle_model text,
+    vehicle_year integer,
+    vehicle_length numeric(4,2),
+    created timestamp with time zone,
+    updated timestamp with time zone,
+    lat double precision,
+    lng double precision,
+    primary_image_url text
+);
+
+INSERT INTO users (id, first_name, last_name) VALUES (1, 'John', 'Smith');
+
+INSERT INTO rentals (user_id, name, type, sleeps, price_per_day, home_city, home_state, vehicle_make, vehicle_model, vehicle_year, created, updated, lat, lng)
+VALUES (1, 'Maupin: Vanagon Camper', 'camper-van', 4, 15000, 'Portland', 'OR', 'Volkswagen', 'Vanagon Camper', 1989,
+    '2021-11-29 22:42:06+00', '2021-11-29 22:42:06+00', 45.51, -122.68);
+`
+
+// Test suite migrating a database created by sql-init.sql, needs the Postgres
+// test database to create a database of its own
+type LegacySchemaTestSuite struct {
+	suite.Suite
+}
+
+func (suite *LegacySchemaTestSuite) SetupTest() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	if config.GetConfig().DbDriver == "sqlite" {
+		suite.T().Skip("sql-init.sql only created Postgres databases")
+	}
+	Init()
+	suite.Require().Nil(DB.Exec("DROP DATABASE IF EXISTS " + legacyDbName).Error)
+	suite.Require().Nil(DB.Exec("CREATE DATABASE " + legacyDbName).Error)
+	Close()
+
+	config.InitWithOverrides("test", map[string]interface{}{"db_name": legacyDbName})
+	Init()
+}
+
+func (suite *LegacySchemaTestSuite) TearDownTest() {
+	if config.GetConfig().DbDriver == "sqlite" {
+		return
+	}
+	Close()
+	config.Init("test")
+	Init()
+	DB.Exec("DROP DATABASE IF EXISTS " + legacyDbName)
+	Close()
+}
+
+func (suite *LegacySchemaTestSuite) TestMigrateLegacySchema() {
+	suite.Require().Nil(execScript(DB, legacySchema))
+
+	_, err := MigrateUp()
+	suite.Require().Nil(err, "Should add the missing columns to the existing tables")
+
+	var rental struct {
+		Name        string
+		ReviewCount int
+		Located     bool
+		Versions    int
+		Prices      int
+	}
+	err = DB.Raw(`SELECT name, review_count, location IS NOT NULL AS located,
+			(SELECT count(*) FROM rental_versions WHERE rental_versions.id = rentals.id) AS versions,
+			(SELECT count(*) FROM rental_prices WHERE rental_prices.rental_id = rentals.id) AS prices
+		FROM rentals`).Scan(&rental).Error
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal("Maupin: Vanagon Camper", rental.Name)
+		suite.Equal(0, rental.ReviewCount)
+		suite.True(rental.Located, "Should fill the location from lat and lng")
+		suite.Equal(1, rental.Versions)
+		suite.Equal(1, rental.Prices)
+	}
+
+	var emails int64
+	suite.Nil(DB.Table("users").Where("email IS NULL").Count(&emails).Error)
+	suite.Equal(int64(1), emails)
+}
+
+func TestLegacySchemaTestSuite(t *testing.T) {
+	suite.Run(t, new(LegacySchemaTestSuite))
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS rentals;
+DROP FUNCTION IF EXISTS rentals_sync_location();
+DROP TABLE IF EXISTS users;
+-- the postgis extension is left installed
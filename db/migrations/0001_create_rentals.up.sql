@@ -0,0 +1,81 @@
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+-- the schema of the former sql-init.sql, databases created with it get the
+-- columns added since by the ALTER TABLE statements below
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    first_name text,
+    last_name text
+);
+
+CREATE TABLE IF NOT EXISTS rentals (
+    id SERIAL PRIMARY KEY,
+    user_id integer,
+    name text,
+    type text,
+    description text,
+    sleeps integer,
+    price_per_day bigint,
+    home_city text,
+    home_state text,
+    home_zip text,
+    home_country text,
+    vehicle_make text,
+    vehicle_model text,
+    vehicle_year integer,
+    vehicle_length numeric(4,2),
+    created timestamp with time zone,
+    updated timestamp with time zone,
+    lat double precision,
+    lng double precision,
+    primary_image_url text
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email text;
+
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS location_issues text;
+-- kept in sync with lat / lng by the rentals_sync_location trigger
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS location geography(Point, 4326);
+
+-- canonical names from the vehicles catalog are in vehicle_make and
+-- vehicle_model, derived from the make and model as entered
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS vehicle_make_input text;
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS vehicle_model_input text;
+
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS fuel_type text CHECK (fuel_type IN ('gasoline', 'diesel', 'electric', 'hybrid', 'propane'));
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS transmission text CHECK (transmission IN ('automatic', 'manual'));
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS drivetrain text CHECK (drivetrain IN ('fwd', 'rwd', 'awd', '4wd'));
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS seatbelts integer CHECK (seatbelts >= 0);
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS tow_capacity integer CHECK (tow_capacity >= 0);
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS fresh_water_capacity integer CHECK (fresh_water_capacity >= 0);
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS generator boolean;
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS ev_range integer CHECK (ev_range >= 0);
+
+-- maintained incrementally when reviews are created
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS review_count integer NOT NULL DEFAULT 0;
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS rating_sum double precision NOT NULL DEFAULT 0;
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS rating_average numeric(3,2);
+
+CREATE OR REPLACE FUNCTION rentals_sync_location() RETURNS trigger AS $$
+BEGIN
+    IF NEW.lat IS NULL OR NEW.lng IS NULL THEN
+        NEW.location := NULL;
+    ELSE
+        NEW.location := ST_SetSRID(ST_MakePoint(NEW.lng, NEW.lat), 4326)::geography;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS rentals_sync_location ON rentals;
+CREATE TRIGGER rentals_sync_location
+    BEFORE INSERT OR UPDATE OF lat, lng, location ON rentals
+    FOR EACH ROW EXECUTE PROCEDURE rentals_sync_location();
+
+-- rows of sql-init.sql databases, ex: the example rentals
+UPDATE rentals SET location = ST_SetSRID(ST_MakePoint(lng, lat), 4326)::geography
+WHERE location IS NULL AND lat IS NOT NULL AND lng IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS rentals_location_idx ON rentals USING GIST (location);
+
+CREATE INDEX IF NOT EXISTS rentals_vehicle_make_idx ON rentals (vehicle_make);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS rentals_rating_average_idx;
+DROP TABLE IF EXISTS reviews;
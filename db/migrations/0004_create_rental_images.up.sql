@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS rental_images (
+    id SERIAL PRIMARY KEY,
+    rental_id integer NOT NULL REFERENCES rentals(id) ON DELETE CASCADE,
+    position integer NOT NULL DEFAULT 0,
+    caption text NOT NULL DEFAULT '',
+    is_primary boolean NOT NULL DEFAULT false,
+    -- prefix of the storage keys of the original and its variants
+    storage_key text NOT NULL UNIQUE,
+    format text NOT NULL,
+    width integer NOT NULL,
+    height integer NOT NULL,
+    created timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS rental_images_rental_id_idx ON rental_images (rental_id, position);
+-- at most one primary image per rental
+CREATE UNIQUE INDEX IF NOT EXISTS rental_images_primary_idx ON rental_images (rental_id) WHERE is_primary;
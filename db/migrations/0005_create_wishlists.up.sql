@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS wishlists (
+    id SERIAL PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id),
+    name text NOT NULL,
+    share_token text UNIQUE,
+    created timestamp with time zone NOT NULL DEFAULT now(),
+    updated timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS wishlists_user_id_idx ON wishlists (user_id);
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    id SERIAL PRIMARY KEY,
+    wishlist_id integer NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+    -- not a foreign key, items are kept when the rental is removed
+    rental_id integer NOT NULL,
+    name text NOT NULL,
+    saved_price_per_day bigint NOT NULL,
+    created timestamp with time zone NOT NULL DEFAULT now(),
+    UNIQUE (wishlist_id, rental_id)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id SERIAL PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id),
+    name text NOT NULL,
+    -- serialized models.Filter
+    filter jsonb NOT NULL,
+    last_run timestamp with time zone NOT NULL,
+    created timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS saved_searches_user_id_idx ON saved_searches (user_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS price_alerts;
+DROP TABLE IF EXISTS price_watches;
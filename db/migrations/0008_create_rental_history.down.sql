@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS rental_history;
+DROP FUNCTION IF EXISTS rental_history_append_only();
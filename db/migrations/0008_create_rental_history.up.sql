@@ -0,0 +1,22 @@
+-- append only, rental_id is not a foreign key so the history outlives the rental
+CREATE TABLE IF NOT EXISTS rental_history (
+    id SERIAL PRIMARY KEY,
+    rental_id integer NOT NULL,
+    actor_id integer REFERENCES users(id),
+    action text NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    changes jsonb NOT NULL DEFAULT '{}',
+    created timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS rental_history_rental_id_idx ON rental_history (rental_id, created DESC);
+
+CREATE OR REPLACE FUNCTION rental_history_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'rental_history is append only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS rental_history_append_only ON rental_history;
+CREATE TRIGGER rental_history_append_only
+    BEFORE UPDATE OR DELETE ON rental_history
+    FOR EACH ROW EXECUTE PROCEDURE rental_history_append_only();
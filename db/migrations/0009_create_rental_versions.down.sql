@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS rentals_version ON rentals;
+DROP FUNCTION IF EXISTS rentals_version();
+DROP TABLE IF EXISTS rental_versions;
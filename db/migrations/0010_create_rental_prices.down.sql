@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS rentals_price ON rentals;
+DROP FUNCTION IF EXISTS rentals_price();
+DROP TABLE IF EXISTS rental_prices;
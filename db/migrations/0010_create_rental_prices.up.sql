@@ -0,0 +1,33 @@
+-- every price of every rental with the time it took effect
+CREATE TABLE IF NOT EXISTS rental_prices (
+    id SERIAL PRIMARY KEY,
+    rental_id integer NOT NULL REFERENCES rentals(id) ON DELETE CASCADE,
+    price_per_day bigint,
+    effective timestamp with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rental_prices_rental_id_idx ON rental_prices (rental_id, effective);
+
+-- existing rentals have their current price from their creation
+INSERT INTO rental_prices (rental_id, price_per_day, effective)
+SELECT id, price_per_day, LEAST(COALESCE(created, now()), now()) FROM rentals
+WHERE NOT EXISTS (SELECT 1 FROM rental_prices WHERE rental_prices.rental_id = rentals.id);
+
+CREATE OR REPLACE FUNCTION rentals_price() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        -- rentals have their price from their creation, ex: seed data
+        INSERT INTO rental_prices (rental_id, price_per_day, effective)
+        VALUES (NEW.id, NEW.price_per_day, LEAST(COALESCE(NEW.created, now()), now()));
+    ELSIF NEW.price_per_day IS DISTINCT FROM OLD.price_per_day THEN
+        INSERT INTO rental_prices (rental_id, price_per_day, effective)
+        VALUES (NEW.id, NEW.price_per_day, now());
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS rentals_price ON rentals;
+CREATE TRIGGER rentals_price
+    AFTER INSERT OR UPDATE OF price_per_day ON rentals
+    FOR EACH ROW EXECUTE PROCEDURE rentals_price();
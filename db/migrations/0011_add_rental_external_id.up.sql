@@ -0,0 +1,47 @@
+-- id of the rental in the system of the partner importing it, unique per owner
+-- so imports can update the rentals they created before
+ALTER TABLE rentals ADD COLUMN IF NOT EXISTS external_id text;
+ALTER TABLE rental_versions ADD COLUMN IF NOT EXISTS external_id text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS rentals_external_id_idx ON rentals (user_id, external_id) WHERE external_id IS NOT NULL;
+
+-- versions copy the columns by name, external_id is added to them. Columns
+-- added to rentals by later migrations must be added to rental_versions and to
+-- this list
+CREATE OR REPLACE FUNCTION rentals_version() RETURNS trigger AS $$
+DECLARE
+    version_from timestamp with time zone;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        UPDATE rental_versions SET valid_to = now() WHERE id = OLD.id AND valid_to IS NULL;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        -- rentals exist from their creation, ex: seed data
+        version_from := LEAST(COALESCE(NEW.created, now()), now());
+    ELSIF TG_OP = 'UPDATE' THEN
+        version_from := now();
+    ELSE
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO rental_versions (
+        id, user_id, name, type, description, sleeps, price_per_day,
+        home_city, home_state, home_zip, home_country,
+        vehicle_make, vehicle_model, vehicle_make_input, vehicle_model_input, vehicle_year, vehicle_length,
+        fuel_type, transmission, drivetrain, seatbelts, tow_capacity, fresh_water_capacity, generator, ev_range,
+        review_count, rating_sum, rating_average, created, updated, lat, lng,
+        primary_image_url, location_issues, location, external_id,
+        valid_from, valid_to
+    ) VALUES (
+        NEW.id, NEW.user_id, NEW.name, NEW.type, NEW.description, NEW.sleeps, NEW.price_per_day,
+        NEW.home_city, NEW.home_state, NEW.home_zip, NEW.home_country,
+        NEW.vehicle_make, NEW.vehicle_model, NEW.vehicle_make_input, NEW.vehicle_model_input, NEW.vehicle_year, NEW.vehicle_length,
+        NEW.fuel_type, NEW.transmission, NEW.drivetrain, NEW.seatbelts, NEW.tow_capacity, NEW.fresh_water_capacity, NEW.generator, NEW.ev_range,
+        NEW.review_count, NEW.rating_sum, NEW.rating_average, NEW.created, NEW.updated, NEW.lat, NEW.lng,
+        NEW.primary_image_url, NEW.location_issues, NEW.location, NEW.external_id,
+        version_from, NULL
+    );
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
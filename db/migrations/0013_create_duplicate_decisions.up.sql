@@ -0,0 +1,13 @@
+-- reviewed duplicate candidates, the lower rental id first. Rental ids are not
+-- foreign keys so merges are still recorded once the duplicate is removed
+CREATE TABLE IF NOT EXISTS duplicate_decisions (
+    rental_id integer NOT NULL,
+    other_rental_id integer NOT NULL,
+    decision text NOT NULL CHECK (decision IN ('dismissed', 'merged')),
+    -- rental kept by a merge
+    kept_rental_id integer,
+    actor_id integer REFERENCES users(id),
+    created timestamp with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (rental_id, other_rental_id),
+    CHECK (rental_id < other_rental_id)
+);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS price_alerts_rental_price_idx;
+
+-- keep the first alert of every price
+DELETE FROM price_alerts USING price_alerts AS first
+WHERE first.price_watch_id = price_alerts.price_watch_id
+    AND first.new_price_per_day = price_alerts.new_price_per_day
+    AND first.id < price_alerts.id;
+
+ALTER TABLE price_alerts ADD UNIQUE (price_watch_id, new_price_per_day);
+ALTER TABLE price_alerts DROP COLUMN IF EXISTS rental_price_id;
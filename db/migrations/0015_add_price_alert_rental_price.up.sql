@@ -0,0 +1,16 @@
+-- alerts are unique per watch and price change instead of price, a later drop to
+-- a price that was notified before is notified again
+ALTER TABLE price_alerts ADD COLUMN IF NOT EXISTS rental_price_id integer;
+
+-- the price change of existing alerts is the last one to their price before them
+UPDATE price_alerts SET rental_price_id = (
+    SELECT rental_prices.id FROM rental_prices
+    WHERE rental_prices.rental_id = price_alerts.rental_id
+        AND rental_prices.price_per_day = price_alerts.new_price_per_day
+        AND rental_prices.effective <= price_alerts.created
+    ORDER BY rental_prices.effective DESC, rental_prices.id DESC
+    LIMIT 1
+);
+
+ALTER TABLE price_alerts DROP CONSTRAINT IF EXISTS price_alerts_price_watch_id_new_price_per_day_key;
+CREATE UNIQUE INDEX IF NOT EXISTS price_alerts_rental_price_idx ON price_alerts (price_watch_id, rental_price_id);
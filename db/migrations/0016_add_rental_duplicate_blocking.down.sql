@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS rentals_user_id_idx;
+-- the pg_trgm extension is left installed
@@ -0,0 +1,6 @@
+-- similarity of rental names, duplicate candidates are paired in SQL before
+-- they are scored
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- candidates are rentals of the same owner
+CREATE INDEX IF NOT EXISTS rentals_user_id_idx ON rentals (user_id);
@@ -0,0 +1,53 @@
+-- sqlite variant of the migrations in db/migrations for local development.
+-- Timestamps are text in UTC, written as now_utc()
+-- by the schema so they compare with the ones written by the application. There
+-- is no location column, the near filter computes distances with distance_miles
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    first_name text,
+    last_name text,
+    email text
+);
+
+CREATE TABLE IF NOT EXISTS rentals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id integer,
+    name text,
+    type text,
+    description text,
+    sleeps integer,
+    price_per_day bigint,
+    home_city text,
+    home_state text,
+    home_zip text,
+    home_country text,
+    -- canonical names from the vehicles catalog, derived from the make and model as entered
+    vehicle_make text,
+    vehicle_model text,
+    vehicle_make_input text,
+    vehicle_model_input text,
+    vehicle_year integer,
+    vehicle_length real,
+    fuel_type text CHECK (fuel_type IN ('gasoline', 'diesel', 'electric', 'hybrid', 'propane')),
+    transmission text CHECK (transmission IN ('automatic', 'manual')),
+    drivetrain text CHECK (drivetrain IN ('fwd', 'rwd', 'awd', '4wd')),
+    seatbelts integer CHECK (seatbelts >= 0),
+    tow_capacity integer CHECK (tow_capacity >= 0),
+    fresh_water_capacity integer CHECK (fresh_water_capacity >= 0),
+    generator boolean,
+    ev_range integer CHECK (ev_range >= 0),
+    -- maintained incrementally when reviews are created
+    review_count integer NOT NULL DEFAULT 0,
+    rating_sum real NOT NULL DEFAULT 0,
+    rating_average real,
+    created timestamp,
+    updated timestamp,
+    lat real,
+    lng real,
+    primary_image_url text,
+    location_issues text
+);
+
+CREATE INDEX IF NOT EXISTS rentals_lat_idx ON rentals (lat);
+
+CREATE INDEX IF NOT EXISTS rentals_vehicle_make_idx ON rentals (vehicle_make);
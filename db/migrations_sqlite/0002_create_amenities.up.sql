@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS amenities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    key text NOT NULL UNIQUE,
+    name text
+);
+
+CREATE TABLE IF NOT EXISTS rental_amenities (
+    rental_id integer REFERENCES rentals(id) ON DELETE CASCADE,
+    amenity_id integer REFERENCES amenities(id) ON DELETE CASCADE,
+    PRIMARY KEY (rental_id, amenity_id)
+);
+
+CREATE INDEX IF NOT EXISTS rental_amenities_amenity_id_idx ON rental_amenities (amenity_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS reviews (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    rental_id integer NOT NULL REFERENCES rentals(id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES users(id),
+    cleanliness smallint NOT NULL CHECK (cleanliness BETWEEN 1 AND 5),
+    accuracy smallint NOT NULL CHECK (accuracy BETWEEN 1 AND 5),
+    communication smallint NOT NULL CHECK (communication BETWEEN 1 AND 5),
+    text text,
+    created timestamp NOT NULL DEFAULT (now_utc()),
+    UNIQUE (rental_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS rentals_rating_average_idx ON rentals (rating_average DESC);
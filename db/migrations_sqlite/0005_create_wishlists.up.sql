@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS wishlists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id integer NOT NULL REFERENCES users(id),
+    name text NOT NULL,
+    share_token text UNIQUE,
+    created timestamp NOT NULL DEFAULT (now_utc()),
+    updated timestamp NOT NULL DEFAULT (now_utc())
+);
+
+CREATE INDEX IF NOT EXISTS wishlists_user_id_idx ON wishlists (user_id);
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    wishlist_id integer NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+    -- not a foreign key, items are kept when the rental is removed
+    rental_id integer NOT NULL,
+    name text NOT NULL,
+    saved_price_per_day bigint NOT NULL,
+    created timestamp NOT NULL DEFAULT (now_utc()),
+    UNIQUE (wishlist_id, rental_id)
+);
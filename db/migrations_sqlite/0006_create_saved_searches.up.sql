@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id integer NOT NULL REFERENCES users(id),
+    name text NOT NULL,
+    -- serialized models.Filter
+    filter text NOT NULL,
+    last_run timestamp NOT NULL,
+    created timestamp NOT NULL DEFAULT (now_utc())
+);
+
+CREATE INDEX IF NOT EXISTS saved_searches_user_id_idx ON saved_searches (user_id);
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS price_watches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id integer NOT NULL REFERENCES users(id),
+    rental_id integer NOT NULL REFERENCES rentals(id) ON DELETE CASCADE,
+    below bigint CHECK (below > 0),
+    drop_percent integer CHECK (drop_percent BETWEEN 1 AND 99),
+    baseline_price_per_day bigint NOT NULL,
+    created timestamp NOT NULL DEFAULT (now_utc()),
+    UNIQUE (user_id, rental_id),
+    CHECK (below IS NOT NULL OR drop_percent IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS price_watches_rental_id_idx ON price_watches (rental_id);
+
+-- outbox of price drops to notify, unique per watch and price to deduplicate
+CREATE TABLE IF NOT EXISTS price_alerts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    price_watch_id integer NOT NULL REFERENCES price_watches(id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES users(id),
+    rental_id integer NOT NULL,
+    old_price_per_day bigint NOT NULL,
+    new_price_per_day bigint NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt timestamp NOT NULL DEFAULT (now_utc()),
+    last_error text NOT NULL DEFAULT '',
+    delivered timestamp,
+    created timestamp NOT NULL DEFAULT (now_utc()),
+    UNIQUE (price_watch_id, new_price_per_day)
+);
+
+CREATE INDEX IF NOT EXISTS price_alerts_pending_idx ON price_alerts (next_attempt) WHERE delivered IS NULL;
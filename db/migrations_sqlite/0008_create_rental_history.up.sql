@@ -0,0 +1,23 @@
+-- append only, rental_id is not a foreign key so the history outlives the rental
+CREATE TABLE IF NOT EXISTS rental_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    rental_id integer NOT NULL,
+    actor_id integer REFERENCES users(id),
+    action text NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    changes text NOT NULL DEFAULT '{}',
+    created timestamp NOT NULL DEFAULT (now_utc())
+);
+
+CREATE INDEX IF NOT EXISTS rental_history_rental_id_idx ON rental_history (rental_id, created DESC);
+
+CREATE TRIGGER IF NOT EXISTS rental_history_append_only_update
+    BEFORE UPDATE ON rental_history
+BEGIN
+    SELECT RAISE(ABORT, 'rental_history is append only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS rental_history_append_only_delete
+    BEFORE DELETE ON rental_history
+BEGIN
+    SELECT RAISE(ABORT, 'rental_history is append only');
+END;
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS rentals_version_insert;
+DROP TRIGGER IF EXISTS rentals_version_update;
+DROP TRIGGER IF EXISTS rentals_version_delete;
+DROP TABLE IF EXISTS rental_versions;
@@ -0,0 +1,118 @@
+-- every version of every rental, valid from valid_from until valid_to (NULL for
+-- the current version), read for point in time queries. Columns added to rentals
+-- by later migrations must be added here and to the rentals_version triggers
+CREATE TABLE IF NOT EXISTS rental_versions (
+    id integer,
+    user_id integer,
+    name text,
+    type text,
+    description text,
+    sleeps integer,
+    price_per_day bigint,
+    home_city text,
+    home_state text,
+    home_zip text,
+    home_country text,
+    vehicle_make text,
+    vehicle_model text,
+    vehicle_make_input text,
+    vehicle_model_input text,
+    vehicle_year integer,
+    vehicle_length real,
+    fuel_type text,
+    transmission text,
+    drivetrain text,
+    seatbelts integer,
+    tow_capacity integer,
+    fresh_water_capacity integer,
+    generator boolean,
+    ev_range integer,
+    review_count integer NOT NULL DEFAULT 0,
+    rating_sum real NOT NULL DEFAULT 0,
+    rating_average real,
+    created timestamp,
+    updated timestamp,
+    lat real,
+    lng real,
+    primary_image_url text,
+    location_issues text,
+    valid_from timestamp NOT NULL,
+    valid_to timestamp,
+    version_id INTEGER PRIMARY KEY AUTOINCREMENT
+);
+
+CREATE INDEX IF NOT EXISTS rental_versions_id_idx ON rental_versions (id, valid_from);
+CREATE INDEX IF NOT EXISTS rental_versions_valid_idx ON rental_versions (valid_from, valid_to);
+
+-- existing rentals have their current version from their creation
+INSERT INTO rental_versions (
+    id, user_id, name, type, description, sleeps, price_per_day,
+    home_city, home_state, home_zip, home_country,
+    vehicle_make, vehicle_model, vehicle_make_input, vehicle_model_input, vehicle_year, vehicle_length,
+    fuel_type, transmission, drivetrain, seatbelts, tow_capacity, fresh_water_capacity, generator, ev_range,
+    review_count, rating_sum, rating_average, created, updated, lat, lng,
+    primary_image_url, location_issues,
+    valid_from
+)
+SELECT
+    id, user_id, name, type, description, sleeps, price_per_day,
+    home_city, home_state, home_zip, home_country,
+    vehicle_make, vehicle_model, vehicle_make_input, vehicle_model_input, vehicle_year, vehicle_length,
+    fuel_type, transmission, drivetrain, seatbelts, tow_capacity, fresh_water_capacity, generator, ev_range,
+    review_count, rating_sum, rating_average, created, updated, lat, lng,
+    primary_image_url, location_issues,
+    min(COALESCE(created, now_utc()), now_utc())
+FROM rentals
+WHERE NOT EXISTS (SELECT 1 FROM rental_versions WHERE rental_versions.id = rentals.id);
+
+-- rentals exist from their creation, ex: seed data
+CREATE TRIGGER IF NOT EXISTS rentals_version_insert
+    AFTER INSERT ON rentals
+BEGIN
+    INSERT INTO rental_versions (
+        id, user_id, name, type, description, sleeps, price_per_day,
+        home_city, home_state, home_zip, home_country,
+        vehicle_make, vehicle_model, vehicle_make_input, vehicle_model_input, vehicle_year, vehicle_length,
+        fuel_type, transmission, drivetrain, seatbelts, tow_capacity, fresh_water_capacity, generator, ev_range,
+        review_count, rating_sum, rating_average, created, updated, lat, lng,
+        primary_image_url, location_issues,
+        valid_from
+    ) VALUES (
+        NEW.id, NEW.user_id, NEW.name, NEW.type, NEW.description, NEW.sleeps, NEW.price_per_day,
+        NEW.home_city, NEW.home_state, NEW.home_zip, NEW.home_country,
+        NEW.vehicle_make, NEW.vehicle_model, NEW.vehicle_make_input, NEW.vehicle_model_input, NEW.vehicle_year, NEW.vehicle_length,
+        NEW.fuel_type, NEW.transmission, NEW.drivetrain, NEW.seatbelts, NEW.tow_capacity, NEW.fresh_water_capacity, NEW.generator, NEW.ev_range,
+        NEW.review_count, NEW.rating_sum, NEW.rating_average, NEW.created, NEW.updated, NEW.lat, NEW.lng,
+        NEW.primary_image_url, NEW.location_issues,
+        min(COALESCE(NEW.created, now_utc()), now_utc())
+    );
+END;
+
+CREATE TRIGGER IF NOT EXISTS rentals_version_update
+    AFTER UPDATE ON rentals
+BEGIN
+    UPDATE rental_versions SET valid_to = now_utc() WHERE id = OLD.id AND valid_to IS NULL;
+    INSERT INTO rental_versions (
+        id, user_id, name, type, description, sleeps, price_per_day,
+        home_city, home_state, home_zip, home_country,
+        vehicle_make, vehicle_model, vehicle_make_input, vehicle_model_input, vehicle_year, vehicle_length,
+        fuel_type, transmission, drivetrain, seatbelts, tow_capacity, fresh_water_capacity, generator, ev_range,
+        review_count, rating_sum, rating_average, created, updated, lat, lng,
+        primary_image_url, location_issues,
+        valid_from
+    ) VALUES (
+        NEW.id, NEW.user_id, NEW.name, NEW.type, NEW.description, NEW.sleeps, NEW.price_per_day,
+        NEW.home_city, NEW.home_state, NEW.home_zip, NEW.home_country,
+        NEW.vehicle_make, NEW.vehicle_model, NEW.vehicle_make_input, NEW.vehicle_model_input, NEW.vehicle_year, NEW.vehicle_length,
+        NEW.fuel_type, NEW.transmission, NEW.drivetrain, NEW.seatbelts, NEW.tow_capacity, NEW.fresh_water_capacity, NEW.generator, NEW.ev_range,
+        NEW.review_count, NEW.rating_sum, NEW.rating_average, NEW.created, NEW.updated, NEW.lat, NEW.lng,
+        NEW.primary_image_url, NEW.location_issues,
+        now_utc()
+    );
+END;
+
+CREATE TRIGGER IF NOT EXISTS rentals_version_delete
+    AFTER DELETE ON rentals
+BEGIN
+    UPDATE rental_versions SET valid_to = now_utc() WHERE id = OLD.id AND valid_to IS NULL;
+END;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS rentals_price_insert;
+DROP TRIGGER IF EXISTS rentals_price_update;
+DROP TABLE IF EXISTS rental_prices;
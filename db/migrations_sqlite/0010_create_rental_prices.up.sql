@@ -0,0 +1,31 @@
+-- every price of every rental with the time it took effect
+CREATE TABLE IF NOT EXISTS rental_prices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    rental_id integer NOT NULL REFERENCES rentals(id) ON DELETE CASCADE,
+    price_per_day bigint,
+    effective timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rental_prices_rental_id_idx ON rental_prices (rental_id, effective);
+
+-- existing rentals have their current price from their creation
+INSERT INTO rental_prices (rental_id, price_per_day, effective)
+SELECT id, price_per_day, min(COALESCE(created, now_utc()), now_utc()) FROM rentals
+WHERE NOT EXISTS (SELECT 1 FROM rental_prices WHERE rental_prices.rental_id = rentals.id);
+
+-- rentals have their price from their creation, ex: seed data
+CREATE TRIGGER IF NOT EXISTS rentals_price_insert
+    AFTER INSERT ON rentals
+BEGIN
+    INSERT INTO rental_prices (rental_id, price_per_day, effective)
+    VALUES (NEW.id, NEW.price_per_day,
+        min(COALESCE(NEW.created, now_utc()), now_utc()));
+END;
+
+CREATE TRIGGER IF NOT EXISTS rentals_price_update
+    AFTER UPDATE OF price_per_day ON rentals
+    WHEN NEW.price_per_day IS NOT OLD.price_per_day
+BEGIN
+    INSERT INTO rental_prices (rental_id, price_per_day, effective)
+    VALUES (NEW.id, NEW.price_per_day, now_utc());
+END;
@@ -0,0 +1,47 @@
+-- partner feeds, listings are created as rentals of the feed user
+CREATE TABLE IF NOT EXISTS feeds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name text NOT NULL UNIQUE,
+    user_id integer NOT NULL REFERENCES users(id),
+    -- http(s) URL or drop directory
+    source text NOT NULL,
+    format text NOT NULL CHECK (format IN ('json', 'xml')),
+    listings_path text NOT NULL DEFAULT '',
+    -- listing paths by import column
+    mapping text NOT NULL,
+    -- content of the last applied sync, unchanged content is not synced again
+    content_hash text NOT NULL DEFAULT '',
+    last_synced timestamp,
+    created timestamp NOT NULL DEFAULT (now_utc())
+);
+
+-- listings of the last applied sync, hashes of the mapped values detect updates
+CREATE TABLE IF NOT EXISTS feed_items (
+    feed_id integer NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    external_id text NOT NULL,
+    rental_id integer NOT NULL REFERENCES rentals(id) ON DELETE CASCADE,
+    hash text NOT NULL,
+    PRIMARY KEY (feed_id, external_id)
+);
+
+CREATE INDEX IF NOT EXISTS feed_items_rental_id_idx ON feed_items (rental_id);
+
+-- report of every sync
+CREATE TABLE IF NOT EXISTS feed_syncs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    feed_id integer NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    status text NOT NULL,
+    source text NOT NULL DEFAULT '',
+    content_hash text NOT NULL DEFAULT '',
+    inserted integer NOT NULL DEFAULT 0,
+    updated integer NOT NULL DEFAULT 0,
+    removed integer NOT NULL DEFAULT 0,
+    unchanged integer NOT NULL DEFAULT 0,
+    rejected integer NOT NULL DEFAULT 0,
+    changes text NOT NULL DEFAULT '[]',
+    error text NOT NULL DEFAULT '',
+    started timestamp NOT NULL,
+    finished timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS feed_syncs_feed_id_idx ON feed_syncs (feed_id, started);
@@ -0,0 +1,3 @@
+-- time a delivery claimed the alert, alerts are sent outside of any transaction
+-- so claims of runs that stopped while sending expire
+ALTER TABLE price_alerts ADD COLUMN sending timestamp;
@@ -0,0 +1,29 @@
+CREATE TABLE price_alerts_copy (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    price_watch_id integer NOT NULL REFERENCES price_watches(id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES users(id),
+    rental_id integer NOT NULL,
+    old_price_per_day bigint NOT NULL,
+    new_price_per_day bigint NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt timestamp NOT NULL DEFAULT (now_utc()),
+    last_error text NOT NULL DEFAULT '',
+    sending timestamp,
+    delivered timestamp,
+    created timestamp NOT NULL DEFAULT (now_utc()),
+    UNIQUE (price_watch_id, new_price_per_day)
+);
+
+-- keep the first alert of every price
+INSERT OR IGNORE INTO price_alerts_copy (
+    id, price_watch_id, user_id, rental_id, old_price_per_day, new_price_per_day,
+    attempts, next_attempt, last_error, sending, delivered, created
+) SELECT
+    id, price_watch_id, user_id, rental_id, old_price_per_day, new_price_per_day,
+    attempts, next_attempt, last_error, sending, delivered, created
+FROM price_alerts ORDER BY id;
+
+DROP TABLE price_alerts;
+ALTER TABLE price_alerts_copy RENAME TO price_alerts;
+
+CREATE INDEX IF NOT EXISTS price_alerts_pending_idx ON price_alerts (next_attempt) WHERE delivered IS NULL;
@@ -0,0 +1,40 @@
+-- alerts are unique per watch and price change instead of price, a later drop to
+-- a price that was notified before is notified again. sqlite can't drop the
+-- unique constraint so the table is copied
+CREATE TABLE price_alerts_copy (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    price_watch_id integer NOT NULL REFERENCES price_watches(id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES users(id),
+    rental_id integer NOT NULL,
+    rental_price_id integer,
+    old_price_per_day bigint NOT NULL,
+    new_price_per_day bigint NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt timestamp NOT NULL DEFAULT (now_utc()),
+    last_error text NOT NULL DEFAULT '',
+    sending timestamp,
+    delivered timestamp,
+    created timestamp NOT NULL DEFAULT (now_utc())
+);
+
+-- the price change of existing alerts is the last one to their price before them
+INSERT INTO price_alerts_copy (
+    id, price_watch_id, user_id, rental_id, rental_price_id, old_price_per_day, new_price_per_day,
+    attempts, next_attempt, last_error, sending, delivered, created
+) SELECT
+    id, price_watch_id, user_id, rental_id, (
+        SELECT rental_prices.id FROM rental_prices
+        WHERE rental_prices.rental_id = price_alerts.rental_id
+            AND rental_prices.price_per_day = price_alerts.new_price_per_day
+            AND rental_prices.effective <= price_alerts.created
+        ORDER BY rental_prices.effective DESC, rental_prices.id DESC
+        LIMIT 1
+    ), old_price_per_day, new_price_per_day,
+    attempts, next_attempt, last_error, sending, delivered, created
+FROM price_alerts;
+
+DROP TABLE price_alerts;
+ALTER TABLE price_alerts_copy RENAME TO price_alerts;
+
+CREATE INDEX IF NOT EXISTS price_alerts_pending_idx ON price_alerts (next_attempt) WHERE delivered IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS price_alerts_rental_price_idx ON price_alerts (price_watch_id, rental_price_id);
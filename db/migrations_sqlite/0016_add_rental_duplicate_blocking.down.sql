@@ -0,0 +1 @@
+DROP INDEX IF EXISTS rentals_user_id_idx;
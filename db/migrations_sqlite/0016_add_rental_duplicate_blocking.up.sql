@@ -0,0 +1,3 @@
+-- candidates are rentals of the same owner in a band of latitude, similarity
+-- is registered in Go like the functions of pg_trgm it replaces
+CREATE INDEX IF NOT EXISTS rentals_user_id_idx ON rentals (user_id, lat);
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/samuelg/rentals/config"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Columns only Postgres has, PostGIS types have no sqlite equivalent
+var postgisColumns = map[string][]string{
+	"rentals":         {"location"},
+	"rental_versions": {"location"},
+}
+
+// Test suite comparing the schema of both migration sets, needs the Postgres
+// test database
+type SchemaTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SchemaTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+}
+
+// Tables of the migrated database with their columns, sorted by name
+func (suite *SchemaTestSuite) migratedColumns() map[string][]string {
+	Init()
+	defer Close()
+	_, err := MigrateUp()
+	suite.Require().Nil(err)
+
+	query := `SELECT c.table_name, c.column_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = current_schema() AND t.table_type = 'BASE TABLE' AND c.table_name <> 'spatial_ref_sys'
+		ORDER BY 1, 2`
+	if IsSQLite() {
+		query = `SELECT m.name AS table_name, p.name AS column_name
+			FROM sqlite_master m, pragma_table_info(m.name) p
+			WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+			ORDER BY 1, 2`
+	}
+	var rows []struct {
+		TableName  string
+		ColumnName string
+	}
+	suite.Require().Nil(DB.Raw(query).Scan(&rows).Error)
+
+	columns := make(map[string][]string)
+	for _, row := range rows {
+		columns[row.TableName] = append(columns[row.TableName], row.ColumnName)
+	}
+	return columns
+}
+
+func (suite *SchemaTestSuite) TestSqliteMatchesPostgres() {
+	postgres := suite.migratedColumns()
+	for table, names := range postgisColumns {
+		for _, name := range names {
+			columns := postgres[table]
+			for i := range columns {
+				if columns[i] == name {
+					postgres[table] = append(columns[:i], columns[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+
+	config.InitWithOverrides("test", map[string]interface{}{
+		"db_driver": "sqlite",
+		"db_path":   filepath.Join(suite.T().TempDir(), "schema.db"),
+	})
+	defer config.Init("test")
+	sqlite := suite.migratedColumns()
+
+	suite.NotEmpty(postgres["rentals"])
+	suite.Equal(postgres, sqlite, "db/migrations_sqlite should create the tables and columns of db/migrations")
+}
+
+func TestSchemaTestSuite(t *testing.T) {
+	suite.Run(t, new(SchemaTestSuite))
+}
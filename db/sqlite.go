@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	sqlite "github.com/glebarez/go-sqlite"
+	"github.com/samuelg/rentals/geo"
+	"github.com/samuelg/rentals/trigrams"
+)
+
+// Name of the sqlite driver with the functions PostGIS would otherwise provide
+const sqliteDriverName = "sqlite_rentals"
+
+// Format of the times written to sqlite. Timestamps are compared as text so
+// they are all written in UTC, scanned times are in UTC
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+func init() {
+	// great circle distance in miles, used by the near filter
+	sqlite.MustRegisterDeterministicScalarFunction("distance_miles", 4, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		var values [4]float64
+		for i, arg := range args {
+			switch value := arg.(type) {
+			case float64:
+				values[i] = value
+			case int64:
+				values[i] = float64(value)
+			case nil:
+				return nil, nil
+			default:
+				return nil, fmt.Errorf("distance_miles: unexpected %T", arg)
+			}
+		}
+		return geo.DistanceMiles(values[0], values[1], values[2], values[3]), nil
+	})
+	// trigram similarity of two texts like pg_trgm, used to find duplicates
+	sqlite.MustRegisterDeterministicScalarFunction("similarity", 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		var values [2]string
+		for i, arg := range args {
+			switch value := arg.(type) {
+			case string:
+				values[i] = value
+			case nil:
+				return nil, nil
+			default:
+				return nil, fmt.Errorf("similarity: unexpected %T", arg)
+			}
+		}
+		return trigrams.Similarity(trigrams.Of(values[0]), trigrams.Of(values[1])), nil
+	})
+	// current time in the format of bound times, 'now' only has milliseconds
+	sqlite.MustRegisterScalarFunction("now_utc", 0, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format(sqliteTimeFormat), nil
+	})
+
+	// the functions are registered on the driver of the sqlite package, it is
+	// only reachable through a handle
+	handle, err := sql.Open("sqlite", "")
+	if err != nil {
+		panic(fmt.Sprintf("sqlite driver: %v", err))
+	}
+	sql.Register(sqliteDriverName, utcDriver{handle.Driver()})
+}
+
+// Driver binding times in UTC, the sqlite driver writes them with the offset
+// of their location
+type utcDriver struct {
+	driver driver.Driver
+}
+
+func (d utcDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return utcConn{conn}, nil
+}
+
+// Connection of the sqlite driver, database/sql checks every argument with
+// CheckNamedValue before calling it
+type utcConn struct {
+	driver.Conn
+}
+
+func (c utcConn) CheckNamedValue(value *driver.NamedValue) error {
+	if t, ok := value.Value.(time.Time); ok {
+		value.Value = t.UTC()
+		return nil
+	}
+	// default conversion
+	return driver.ErrSkip
+}
+
+func (c utcConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c utcConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+}
+
+func (c utcConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c utcConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c utcConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the sqlite driver, runs on an in memory database
+type SqliteTestSuite struct {
+	suite.Suite
+	handle *sql.DB
+}
+
+func (suite *SqliteTestSuite) SetupTest() {
+	handle, err := sql.Open(sqliteDriverName, "file::memory:?_time_format=sqlite")
+	suite.Require().Nil(err)
+	suite.handle = handle
+}
+
+func (suite *SqliteTestSuite) TearDownTest() {
+	suite.handle.Close()
+}
+
+func (suite *SqliteTestSuite) TestTimesBoundInUTC() {
+	portland := time.FixedZone("PDT", -7*60*60)
+	at := time.Date(2024, time.March, 1, 17, 30, 0, 0, portland)
+
+	var value string
+	err := suite.handle.QueryRow("SELECT ?", at).Scan(&value)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal("2024-03-02 00:30:00+00:00", value)
+	}
+}
+
+func (suite *SqliteTestSuite) TestFunctions() {
+	var distance float64
+	var now string
+	var similarity float64
+	err := suite.handle.QueryRow("SELECT distance_miles(33.68, -117.82, 32.72, -117.16), now_utc(), similarity('word', 'two words')").
+		Scan(&distance, &now, &similarity)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.InDelta(76, distance, 5)
+		suite.Contains(now, "+00:00")
+		// the value pg_trgm gives
+		suite.InDelta(0.3636, similarity, 0.0001)
+	}
+}
+
+func (suite *SqliteTestSuite) TestIsDataError() {
+	_, err := suite.handle.Exec("CREATE TABLE vans (name text NOT NULL UNIQUE, seatbelts integer CHECK (seatbelts >= 0))")
+	suite.Require().Nil(err)
+	_, err = suite.handle.Exec("INSERT INTO vans (name, seatbelts) VALUES ('Westy', 4)")
+	suite.Require().Nil(err)
+
+	for _, query := range []string{
+		"INSERT INTO vans (name, seatbelts) VALUES ('Westy', 2)",
+		"INSERT INTO vans (name, seatbelts) VALUES (NULL, 2)",
+		"INSERT INTO vans (name, seatbelts) VALUES ('Vanagon', -1)",
+	} {
+		_, err = suite.handle.Exec(query)
+		suite.True(IsDataError(err), query)
+	}
+
+	_, err = suite.handle.Exec("INSERT INTO trucks (name) VALUES ('Tacoma')")
+	suite.NotNil(err)
+	suite.False(IsDataError(err), "A missing table should not be a data error")
+}
+
+func TestSqliteTestSuite(t *testing.T) {
+	suite.Run(t, new(SqliteTestSuite))
+}
@@ -0,0 +1,55 @@
+package feeds
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Where the fields of a rental are found in the listings of a partner, paths by
+// import column (ex: "price_day": "rates.daily")
+type Mapping map[string]string
+
+// Values of the mapped columns of a listing, paths missing from the listing
+// and null values are left out
+func (mapping Mapping) Values(listing Listing) (map[string]string, error) {
+	values := make(map[string]string, len(mapping))
+	for column, path := range mapping {
+		value, ok := lookup(map[string]interface{}(listing), path)
+		if !ok {
+			continue
+		}
+		formatted, err := formatValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s: %s is %v", column, path, err)
+		}
+		if formatted != "" {
+			values[column] = formatted
+		}
+	}
+
+	return values, nil
+}
+
+// Text of a single value, as it would be written in a CSV import
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case map[string]interface{}:
+		// XML element with attributes
+		if text, ok := v["#text"].(string); ok {
+			return text, nil
+		}
+		return "", errors.New("an object")
+	}
+
+	return "", errors.New("not a single value")
+}
@@ -0,0 +1,176 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats of a feed
+const (
+	FormatJSON = "json"
+	FormatXML  = "xml"
+)
+
+var Formats = []string{FormatJSON, FormatXML}
+
+// Returned when the content of a feed can't be parsed or has no listings
+var ErrInvalidFeed = errors.New("invalid feed")
+
+// A listing of a partner as found in the feed. XML elements are objects keyed
+// by child element name, attributes are prefixed with @ and the text of
+// elements that also have attributes or children is under #text. Repeated
+// elements are arrays
+type Listing map[string]interface{}
+
+// Parse the listings of a feed, found at the dot separated path (ex:
+// "fleet.vehicle"). XML paths start with the root element
+func Parse(content []byte, format string, listingsPath string) ([]Listing, error) {
+	var document interface{}
+	var err error
+	switch format {
+	case FormatJSON:
+		document, err = parseJSON(content)
+	case FormatXML:
+		document, err = parseXML(content)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %s", ErrInvalidFeed, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFeed, err)
+	}
+
+	found, ok := lookup(document, listingsPath)
+	if !ok {
+		return nil, fmt.Errorf("%w: no listings at %q", ErrInvalidFeed, listingsPath)
+	}
+	// a single XML element isn't an array
+	elements, ok := found.([]interface{})
+	if !ok {
+		elements = []interface{}{found}
+	}
+
+	listings := make([]Listing, len(elements))
+	for i, element := range elements {
+		object, ok := element.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: listing %d is not an object", ErrInvalidFeed, i+1)
+		}
+		listings[i] = object
+	}
+	return listings, nil
+}
+
+func parseJSON(content []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	// keep numbers as written, ex: ids and prices in cents
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected content after the document")
+	}
+	return document, nil
+}
+
+func parseXML(content []byte) (interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("no root element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			root, err := parseXMLElement(decoder, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{start.Name.Local: root}, nil
+		}
+	}
+}
+
+// Object of an element, or its text when it only has text
+func parseXMLElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	fields := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		fields["@"+attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := parseXMLElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			addXMLChild(fields, t.Name.Local, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			if len(fields) == 0 {
+				return value, nil
+			}
+			if value != "" {
+				fields["#text"] = value
+			}
+			return fields, nil
+		}
+	}
+}
+
+func addXMLChild(fields map[string]interface{}, name string, child interface{}) {
+	switch existing := fields[name].(type) {
+	case nil:
+		fields[name] = child
+	case []interface{}:
+		fields[name] = append(existing, child)
+	default:
+		fields[name] = []interface{}{existing, child}
+	}
+}
+
+// Value at the dot separated path, array elements are selected by index (ex:
+// "photos.0.url")
+func lookup(value interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return value, true
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
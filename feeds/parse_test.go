@@ -0,0 +1,125 @@
+package feeds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for parsing feeds and mapping listings
+type ParseTestSuite struct {
+	suite.Suite
+}
+
+var jsonFeed = `{
+  "fleet": {
+    "vehicles": [
+      {"id": 1017, "title": "Westy", "rates": {"daily": 16900}, "geo": {"lat": 33.64, "lng": -117.93}, "generator": true, "notes": null},
+      {"id": "b-2", "title": "Eurovan", "photos": [{"url": "a.jpg"}, {"url": "b.jpg"}]}
+    ]
+  }
+}`
+
+var xmlFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<fleet partner="acme">
+  <vehicle id="x-1">
+    <title>Westy</title>
+    <rate currency="USD">16900</rate>
+  </vehicle>
+  <vehicle id="x-2">
+    <title> Eurovan </title>
+    <photo>a.jpg</photo>
+    <photo>b.jpg</photo>
+  </vehicle>
+</fleet>`
+
+func (suite *ParseTestSuite) TestParseJSON() {
+	listings, err := Parse([]byte(jsonFeed), FormatJSON, "fleet.vehicles")
+
+	if suite.Nil(err, "Should not lead to an error") && suite.Len(listings, 2) {
+		values, err := Mapping{
+			"external_id": "id",
+			"name":        "title",
+			"price_day":   "rates.daily",
+			"lat":         "geo.lat",
+			"generator":   "generator",
+			"description": "notes",
+		}.Values(listings[0])
+		if suite.Nil(err, "Should not lead to an error") {
+			suite.Equal(map[string]string{
+				"external_id": "1017",
+				"name":        "Westy",
+				"price_day":   "16900",
+				"lat":         "33.64",
+				"generator":   "true",
+			}, values)
+		}
+
+		values, err = Mapping{"external_id": "id", "description": "photos.1.url", "price_day": "rates.daily"}.Values(listings[1])
+		if suite.Nil(err, "Should not lead to an error") {
+			suite.Equal(map[string]string{"external_id": "b-2", "description": "b.jpg"}, values)
+		}
+	}
+}
+
+func (suite *ParseTestSuite) TestParseJSONTopLevelArray() {
+	listings, err := Parse([]byte(`[{"id": 1}, {"id": 2}, {"id": 3}]`), FormatJSON, "")
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Len(listings, 3)
+	}
+}
+
+func (suite *ParseTestSuite) TestParseXML() {
+	listings, err := Parse([]byte(xmlFeed), FormatXML, "fleet.vehicle")
+
+	if suite.Nil(err, "Should not lead to an error") && suite.Len(listings, 2) {
+		mapping := Mapping{"external_id": "@id", "name": "title", "price_day": "rate", "description": "photo.1"}
+		values, err := mapping.Values(listings[0])
+		if suite.Nil(err, "Should not lead to an error") {
+			suite.Equal(map[string]string{"external_id": "x-1", "name": "Westy", "price_day": "16900"}, values)
+		}
+
+		values, err = mapping.Values(listings[1])
+		if suite.Nil(err, "Should not lead to an error") {
+			suite.Equal(map[string]string{"external_id": "x-2", "name": "Eurovan", "description": "b.jpg"}, values)
+		}
+	}
+}
+
+func (suite *ParseTestSuite) TestParseXMLSingleListing() {
+	listings, err := Parse([]byte(`<fleet><vehicle id="x-1"><title>Westy</title></vehicle></fleet>`), FormatXML, "fleet.vehicle")
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Len(listings, 1)
+	}
+}
+
+func (suite *ParseTestSuite) TestParseInvalid() {
+	_, err := Parse([]byte(`{"fleet": [`), FormatJSON, "fleet")
+	suite.ErrorIs(err, ErrInvalidFeed)
+
+	_, err = Parse([]byte(`{"fleet": []}`), FormatJSON, "vehicles")
+	suite.ErrorIs(err, ErrInvalidFeed)
+
+	_, err = Parse([]byte(`["a", "b"]`), FormatJSON, "")
+	suite.ErrorIs(err, ErrInvalidFeed)
+
+	_, err = Parse([]byte(`<fleet><vehicle>`), FormatXML, "fleet.vehicle")
+	suite.ErrorIs(err, ErrInvalidFeed)
+
+	_, err = Parse([]byte(`id,name`), "csv", "")
+	suite.ErrorIs(err, ErrInvalidFeed)
+}
+
+func (suite *ParseTestSuite) TestValuesNotSingleValue() {
+	listings, _ := Parse([]byte(jsonFeed), FormatJSON, "fleet.vehicles")
+
+	_, err := Mapping{"name": "rates"}.Values(listings[0])
+
+	suite.EqualError(err, "Invalid name: rates is an object")
+}
+
+func TestParseTestSuite(t *testing.T) {
+	suite.Run(t, new(ParseTestSuite))
+}
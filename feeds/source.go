@@ -0,0 +1,117 @@
+package feeds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/outbound"
+)
+
+// Returned when the content of a feed can't be read
+var ErrFetchFailed = errors.New("failed to fetch feed")
+
+// Returns true for the sources fetched over HTTP(S), other sources are drop
+// directories
+func IsURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// Read the content of a feed: URLs are fetched, drop directories are read from
+// their newest file with the extension of the format. Also returns where the
+// content was read from
+func Fetch(ctx context.Context, source string, format string) ([]byte, string, error) {
+	if IsURL(source) {
+		content, err := fetchURL(ctx, source)
+		return content, source, err
+	}
+
+	path, err := newestFile(source, format)
+	if err != nil {
+		return nil, "", err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, path, fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+	defer file.Close()
+
+	content, err := readLimited(file)
+	return content, path, err
+}
+
+func fetchURL(ctx context.Context, url string) ([]byte, error) {
+	timeout := time.Duration(config.GetConfig().FeedFetchTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+
+	// feed URLs are set by admins, redirects must not reach internal services either
+	client := outbound.NewClient(timeout, config.GetConfig().FeedAllowPrivateAddresses)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrFetchFailed, resp.StatusCode)
+	}
+
+	return readLimited(resp.Body)
+}
+
+// Newest file of the format in a drop directory, hidden files are skipped as
+// partners may upload under a temporary name
+func newestFile(dir string, format string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+
+	var newest string
+	var newestTime time.Time
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.EqualFold(filepath.Ext(name), "."+format) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrFetchFailed, err)
+		}
+		// ties are broken by name so the same file is always picked
+		if newest == "" || info.ModTime().After(newestTime) || (info.ModTime().Equal(newestTime) && name > filepath.Base(newest)) {
+			newest, newestTime = filepath.Join(dir, name), info.ModTime()
+		}
+	}
+
+	if newest == "" {
+		return "", fmt.Errorf("%w: no %s file in %s", ErrFetchFailed, format, dir)
+	}
+	return newest, nil
+}
+
+// Read the whole content, failing when larger than max_feed_bytes
+func readLimited(r io.Reader) ([]byte, error) {
+	maxBytes := config.GetConfig().MaxFeedBytes
+	content, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+	if int64(len(content)) > maxBytes {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrFetchFailed, maxBytes)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,114 @@
+package feeds
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/outbound"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for feed sources, URLs are served by a local HTTP stand-in
+type SourceTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+func (suite *SourceTestSuite) SetupSuite() {
+	// the stand-in listens on a loopback address
+	config.InitWithOverrides("test", map[string]interface{}{"feed_allow_private_addresses": true})
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fleet.json":
+			w.Write([]byte(`[{"id": 1}]`))
+		case "/redirect.json":
+			http.Redirect(w, r, "/fleet.json", http.StatusFound)
+		case "/large.json":
+			w.Write(make([]byte, config.GetConfig().MaxFeedBytes+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func (suite *SourceTestSuite) TearDownSuite() {
+	suite.server.Close()
+	config.Init("test")
+}
+
+func (suite *SourceTestSuite) TestFetchURL() {
+	content, source, err := Fetch(context.Background(), suite.server.URL+"/fleet.json", FormatJSON)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal(`[{"id": 1}]`, string(content))
+		suite.Equal(suite.server.URL+"/fleet.json", source)
+	}
+}
+
+func (suite *SourceTestSuite) TestFetchURLPrivateAddress() {
+	config.Init("test")
+	defer config.InitWithOverrides("test", map[string]interface{}{"feed_allow_private_addresses": true})
+
+	_, _, err := Fetch(context.Background(), suite.server.URL+"/fleet.json", FormatJSON)
+
+	suite.ErrorIs(err, ErrFetchFailed)
+	suite.ErrorIs(err, outbound.ErrForbiddenAddress)
+}
+
+func (suite *SourceTestSuite) TestFetchURLRedirect() {
+	content, _, err := Fetch(context.Background(), suite.server.URL+"/redirect.json", FormatJSON)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal(`[{"id": 1}]`, string(content))
+	}
+}
+
+func (suite *SourceTestSuite) TestFetchURLNotFound() {
+	_, _, err := Fetch(context.Background(), suite.server.URL+"/missing.json", FormatJSON)
+
+	suite.ErrorIs(err, ErrFetchFailed)
+}
+
+func (suite *SourceTestSuite) TestFetchURLTooLarge() {
+	_, _, err := Fetch(context.Background(), suite.server.URL+"/large.json", FormatJSON)
+
+	suite.ErrorIs(err, ErrFetchFailed)
+}
+
+func (suite *SourceTestSuite) TestFetchDirectoryNewestFile() {
+	dir := suite.T().TempDir()
+	write := func(name string, content string, age time.Duration) {
+		path := filepath.Join(dir, name)
+		suite.Require().Nil(os.WriteFile(path, []byte(content), 0o644))
+		modified := time.Now().Add(-age)
+		suite.Require().Nil(os.Chtimes(path, modified, modified))
+	}
+	write("monday.json", "old", 48*time.Hour)
+	write("tuesday.json", "new", 24*time.Hour)
+	// still uploading and other formats are skipped
+	write(".wednesday.json", "partial", 0)
+	write("wednesday.xml", "<fleet/>", 0)
+
+	content, source, err := Fetch(context.Background(), dir, FormatJSON)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal("new", string(content))
+		suite.Equal(filepath.Join(dir, "tuesday.json"), source)
+	}
+}
+
+func (suite *SourceTestSuite) TestFetchEmptyDirectory() {
+	_, _, err := Fetch(context.Background(), suite.T().TempDir(), FormatXML)
+
+	suite.ErrorIs(err, ErrFetchFailed)
+}
+
+func TestSourceTestSuite(t *testing.T) {
+	suite.Run(t, new(SourceTestSuite))
+}
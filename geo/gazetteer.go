@@ -0,0 +1,212 @@
+package geo
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// mean radius of the earth in miles
+const earthRadiusMiles = 3958.8
+
+//go:embed data/gazetteer.csv
+var gazetteerData []byte
+
+//go:embed data/countries.csv
+var countriesData []byte
+
+// A known place from the gazetteer
+type Place struct {
+	City    string
+	State   string
+	Zip     string
+	Country string
+	Lat     float64
+	Lng     float64
+}
+
+// Bounding box for (part of) a country
+type Bounds struct {
+	Country string
+	MinLat  float64
+	MinLng  float64
+	MaxLat  float64
+	MaxLng  float64
+}
+
+// Returns true when the point is inside the bounding box
+func (b Bounds) Contains(lat float64, lng float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lng >= b.MinLng && lng <= b.MaxLng
+}
+
+// Local places and country boundaries used to reverse geocode coordinates
+type Gazetteer struct {
+	places []Place
+	// countries can have several boxes (ex: Alaska and Hawaii for the US)
+	bounds map[string][]Bounds
+}
+
+var (
+	defaultGazetteer *Gazetteer
+	defaultOnce      sync.Once
+)
+
+// Returns the gazetteer built from the dataset embedded in the binary
+func Default() *Gazetteer {
+	defaultOnce.Do(func() {
+		gazetteer, err := Load(bytes.NewReader(gazetteerData), bytes.NewReader(countriesData))
+		if err != nil {
+			// the embedded dataset is part of the build, it should always parse
+			panic(fmt.Sprintf("invalid embedded gazetteer: %v", err))
+		}
+		defaultGazetteer = gazetteer
+	})
+
+	return defaultGazetteer
+}
+
+// Load a gazetteer from csv places and country bounds
+func Load(places io.Reader, countries io.Reader) (*Gazetteer, error) {
+	gazetteer := &Gazetteer{bounds: make(map[string][]Bounds)}
+
+	placeRows, err := readCsv(places, 6)
+	if err != nil {
+		return nil, fmt.Errorf("places: %w", err)
+	}
+	for _, row := range placeRows {
+		lat, lng, err := parseLatLng(row[4], row[5])
+		if err != nil {
+			return nil, fmt.Errorf("places: %w", err)
+		}
+		gazetteer.places = append(gazetteer.places, Place{
+			City:    row[0],
+			State:   row[1],
+			Zip:     row[2],
+			Country: row[3],
+			Lat:     lat,
+			Lng:     lng,
+		})
+	}
+
+	countryRows, err := readCsv(countries, 5)
+	if err != nil {
+		return nil, fmt.Errorf("countries: %w", err)
+	}
+	for _, row := range countryRows {
+		minLat, minLng, err := parseLatLng(row[1], row[2])
+		if err != nil {
+			return nil, fmt.Errorf("countries: %w", err)
+		}
+		maxLat, maxLng, err := parseLatLng(row[3], row[4])
+		if err != nil {
+			return nil, fmt.Errorf("countries: %w", err)
+		}
+		gazetteer.bounds[row[0]] = append(gazetteer.bounds[row[0]], Bounds{
+			Country: row[0],
+			MinLat:  minLat,
+			MinLng:  minLng,
+			MaxLat:  maxLat,
+			MaxLng:  maxLng,
+		})
+	}
+
+	return gazetteer, nil
+}
+
+// Returns the places within the given distance (in miles), closest first
+func (g *Gazetteer) Within(lat float64, lng float64, miles float64) []Place {
+	type candidate struct {
+		place    Place
+		distance float64
+	}
+	candidates := make([]candidate, 0)
+	for _, place := range g.places {
+		distance := DistanceMiles(lat, lng, place.Lat, place.Lng)
+		if distance <= miles {
+			candidates = append(candidates, candidate{place: place, distance: distance})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	places := make([]Place, len(candidates))
+	for i, c := range candidates {
+		places[i] = c.place
+	}
+
+	return places
+}
+
+// Known places, shared with the gazetteer and not to be modified
+func (g *Gazetteer) Places() []Place {
+	return g.places
+}
+
+// Returns true when the point is within the known bounds of the country, and
+// whether the country has any known bounds at all
+func (g *Gazetteer) InCountry(country string, lat float64, lng float64) (bool, bool) {
+	bounds, known := g.bounds[country]
+	for _, b := range bounds {
+		if b.Contains(lat, lng) {
+			return true, known
+		}
+	}
+
+	return false, known
+}
+
+// Great circle distance between two points in miles (haversine formula)
+func DistanceMiles(lat1 float64, lng1 float64, lat2 float64, lng2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusMiles * math.Asin(math.Sqrt(a))
+}
+
+// Read all csv rows, skipping the header and checking the column count
+func readCsv(r io.Reader, columns int) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = columns
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("missing header")
+	}
+
+	for _, row := range rows {
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+	}
+
+	return rows[1:], nil
+}
+
+func parseLatLng(latRaw string, lngRaw string) (float64, float64, error) {
+	lat, err := strconv.ParseFloat(latRaw, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid latitude %q", latRaw)
+	}
+	lng, err := strconv.ParseFloat(lngRaw, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid longitude %q", lngRaw)
+	}
+
+	return lat, lng, nil
+}
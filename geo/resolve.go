@@ -0,0 +1,184 @@
+package geo
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// places this close to the coordinates are considered part of the same area
+	// (suburbs often use the name of the nearest city)
+	cityRadiusMiles = 30
+	// beyond this distance we don't have enough data to check the location
+	matchRadiusMiles = 100
+)
+
+// Problem found when checking a location against the gazetteer
+type Issue string
+
+const (
+	IssueNoMatch         Issue = "no_match"
+	IssueCountryMismatch Issue = "country_mismatch"
+	IssueStateMismatch   Issue = "state_mismatch"
+	IssueCityMismatch    Issue = "city_mismatch"
+	IssueZipMismatch     Issue = "zip_mismatch"
+)
+
+// Location fields of a rental
+type Location struct {
+	City    string
+	State   string
+	Zip     string
+	Country string
+	Lat     float64
+	Lng     float64
+}
+
+// Result of resolving a location
+type Result struct {
+	// normalized location, blank fields are derived from the closest place
+	Location Location
+	// closest known place, nil when nothing is close enough
+	Place *Place
+	// names of the fields that were derived
+	Filled []string
+	// mismatches between the provided fields and the coordinates
+	Issues []Issue
+}
+
+// Returns the issues as a sorted comma separated list, empty when valid
+func (result *Result) IssuesString() string {
+	issues := make([]string, len(result.Issues))
+	for i, issue := range result.Issues {
+		issues[i] = string(issue)
+	}
+	sort.Strings(issues)
+
+	return strings.Join(issues, ",")
+}
+
+// Derive blank location fields from the coordinates and flag provided fields
+// that don't agree with them
+func (g *Gazetteer) Resolve(location Location) *Result {
+	result := &Result{Location: normalize(location)}
+	loc := &result.Location
+
+	nearby := g.Within(loc.Lat, loc.Lng, matchRadiusMiles)
+
+	// coordinates must be within the stated country when we know its bounds
+	if loc.Country != "" {
+		if inCountry, known := g.InCountry(loc.Country, loc.Lat, loc.Lng); known && !inCountry {
+			result.Issues = append(result.Issues, IssueCountryMismatch)
+		}
+	}
+
+	if len(nearby) == 0 {
+		result.Issues = append(result.Issues, IssueNoMatch)
+		return result
+	}
+
+	closest := nearby[0]
+	result.Place = &closest
+
+	if loc.Country == "" {
+		loc.Country = closest.Country
+		result.Filled = append(result.Filled, "country")
+	} else if loc.Country != closest.Country && !hasIssue(result.Issues, IssueCountryMismatch) {
+		result.Issues = append(result.Issues, IssueCountryMismatch)
+	}
+
+	if loc.State == "" {
+		loc.State = closest.State
+		result.Filled = append(result.Filled, "state")
+	} else if loc.State != closest.State {
+		result.Issues = append(result.Issues, IssueStateMismatch)
+	}
+
+	// city and zip only need to match one of the places in the area
+	area := make([]Place, 0)
+	for _, place := range nearby {
+		if DistanceMiles(loc.Lat, loc.Lng, place.Lat, place.Lng) <= cityRadiusMiles {
+			area = append(area, place)
+		}
+	}
+
+	if loc.City == "" {
+		loc.City = closest.City
+		result.Filled = append(result.Filled, "city")
+	} else if !anyPlace(area, func(place Place) bool { return strings.EqualFold(place.City, loc.City) }) {
+		result.Issues = append(result.Issues, IssueCityMismatch)
+	}
+
+	if loc.Zip == "" {
+		// only derive a zip when the closest place is in the area
+		if len(area) > 0 && area[0].Zip != "" {
+			loc.Zip = area[0].Zip
+			result.Filled = append(result.Filled, "zip")
+		}
+	} else if !anyPlace(area, func(place Place) bool {
+		return zipRegion(place.Country, place.Zip) == zipRegion(place.Country, loc.Zip)
+	}) {
+		result.Issues = append(result.Issues, IssueZipMismatch)
+	}
+
+	return result
+}
+
+// Trim whitespace and use upper case codes
+func normalize(location Location) Location {
+	location.City = strings.Join(strings.Fields(location.City), " ")
+	location.State = strings.ToUpper(strings.TrimSpace(location.State))
+	location.Zip = strings.ToUpper(strings.Join(strings.Fields(location.Zip), " "))
+	location.Country = strings.ToUpper(strings.TrimSpace(location.Country))
+
+	return location
+}
+
+// Returns the part of a postal code that identifies a region, the gazetteer
+// can't hold every postal code so we compare regions instead
+func zipRegion(country string, zip string) string {
+	switch country {
+	case "US":
+		// sectional center facility (first 3 digits)
+		if len(zip) >= 3 {
+			return zip[:3]
+		}
+	case "CA", "IE":
+		// forward sortation area and routing key
+		if len(zip) >= 3 {
+			return zip[:3]
+		}
+	case "GB":
+		// outward code, only keep the area letters (ex: CA for CA11 9TE)
+		if parts := strings.Fields(zip); len(parts) > 0 {
+			return strings.TrimRight(parts[0], "0123456789")
+		}
+	case "AU":
+		// first two digits identify the area of a state
+		if len(zip) >= 2 {
+			return zip[:2]
+		}
+	}
+
+	return zip
+}
+
+func anyPlace(places []Place, predicate func(Place) bool) bool {
+	for _, place := range places {
+		if predicate(place) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasIssue(issues []Issue, issue Issue) bool {
+	for _, i := range issues {
+		if i == issue {
+			return true
+		}
+	}
+
+	return false
+}
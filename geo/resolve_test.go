@@ -0,0 +1,110 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for location resolution
+type ResolveTestSuite struct {
+	suite.Suite
+	gazetteer *Gazetteer
+}
+
+func (suite *ResolveTestSuite) SetupSuite() {
+	suite.gazetteer = Default()
+}
+
+func (suite *ResolveTestSuite) TestResolveValidLocation() {
+	result := suite.gazetteer.Resolve(Location{
+		City: "Costa Mesa", State: "CA", Zip: "92627", Country: "US", Lat: 33.64, Lng: -117.93,
+	})
+
+	suite.Empty(result.Issues)
+	suite.Empty(result.Filled)
+	suite.Equal("", result.IssuesString())
+}
+
+func (suite *ResolveTestSuite) TestResolveTrimsWhitespace() {
+	result := suite.gazetteer.Resolve(Location{
+		City: "Missoula ", State: "MT", Zip: "59808", Country: "US", Lat: 46.92, Lng: -114.09,
+	})
+
+	suite.Empty(result.Issues)
+	suite.Equal("Missoula", result.Location.City)
+}
+
+func (suite *ResolveTestSuite) TestResolveFillsBlankZip() {
+	result := suite.gazetteer.Resolve(Location{
+		City: "Rancho Mission Viejo", State: "CA", Zip: "", Country: "US", Lat: 33.53, Lng: -117.63,
+	})
+
+	suite.Empty(result.Issues)
+	suite.Equal([]string{"zip"}, result.Filled)
+	suite.Equal("92694", result.Location.Zip)
+}
+
+func (suite *ResolveTestSuite) TestResolveFillsBlankFields() {
+	result := suite.gazetteer.Resolve(Location{Lat: 45.51, Lng: -122.68})
+
+	suite.Empty(result.Issues)
+	suite.Equal("Portland", result.Location.City)
+	suite.Equal("OR", result.Location.State)
+	suite.Equal("US", result.Location.Country)
+}
+
+func (suite *ResolveTestSuite) TestResolveInvalidState() {
+	result := suite.gazetteer.Resolve(Location{
+		City: "Cumbria", State: "CMA", Zip: "CA11 9TE", Country: "GB", Lat: 54.72, Lng: -2.88,
+	})
+
+	suite.Equal("city_mismatch,state_mismatch", result.IssuesString())
+}
+
+func (suite *ResolveTestSuite) TestResolveWrongCountry() {
+	result := suite.gazetteer.Resolve(Location{
+		City: "Bangor", State: "", Zip: "BT23 7XE", Country: "IE", Lat: 54.63, Lng: -5.67,
+	})
+
+	suite.Equal("country_mismatch", result.IssuesString())
+	// the state is still derived from the closest place
+	suite.Equal("NIR", result.Location.State)
+}
+
+func (suite *ResolveTestSuite) TestResolveZipMismatch() {
+	result := suite.gazetteer.Resolve(Location{
+		City: "Denver", State: "CO", Zip: "10001", Country: "US", Lat: 39.8, Lng: -104.89,
+	})
+
+	suite.Equal("zip_mismatch", result.IssuesString())
+}
+
+func (suite *ResolveTestSuite) TestResolveNoMatch() {
+	// middle of the Pacific ocean
+	result := suite.gazetteer.Resolve(Location{Country: "US", Lat: 30, Lng: -140})
+
+	suite.Equal("country_mismatch,no_match", result.IssuesString())
+	suite.Nil(result.Place)
+}
+
+func (suite *ResolveTestSuite) TestDistanceMiles() {
+	// Costa Mesa to San Diego
+	suite.InDelta(78, DistanceMiles(33.64, -117.93, 32.72, -117.16), 5)
+	suite.Equal(float64(0), DistanceMiles(33.64, -117.93, 33.64, -117.93))
+}
+
+func (suite *ResolveTestSuite) TestValidZip() {
+	suite.True(ValidZip("US", "92627"))
+	suite.True(ValidZip("US", "92627-1234"))
+	suite.True(ValidZip("CA", "v6b 1a1"))
+	suite.True(ValidZip("GB", "CA11 9TE"))
+	suite.True(ValidZip("ZZ", "anything"))
+	suite.False(ValidZip("US", ""))
+	suite.False(ValidZip("US", "9262"))
+	suite.False(ValidZip("AU", "ABCD"))
+}
+
+func TestResolveTestSuite(t *testing.T) {
+	suite.Run(t, new(ResolveTestSuite))
+}
@@ -0,0 +1,31 @@
+package geo
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Postal code formats of the countries we know about
+var zipFormats = map[string]*regexp.Regexp{
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	// eircodes, the routing key alone is accepted
+	"IE": regexp.MustCompile(`^[A-Z]\d[\dW]( ?[A-Z\d]{4})?$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"NZ": regexp.MustCompile(`^\d{4}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"MX": regexp.MustCompile(`^\d{5}$`),
+}
+
+// Returns true when the postal code matches the format of the country, codes
+// of countries without a known format are always valid
+func ValidZip(country string, zip string) bool {
+	format, ok := zipFormats[strings.ToUpper(strings.TrimSpace(country))]
+	if !ok {
+		return true
+	}
+
+	return format.MatchString(strings.ToUpper(strings.TrimSpace(zip)))
+}
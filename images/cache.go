@@ -0,0 +1,193 @@
+package images
+
+import (
+	"container/list"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Disk cache of encoded variants, the least recently used entries are evicted
+// once the total size goes over the budget
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mutex   sync.Mutex
+	size    int64
+	order   *list.List // most recently used first
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+// Open the cache in the directory, entries left by a previous run are kept
+func NewCache(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	cache := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+
+	// the modification time is bumped on reads so it orders existing entries
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type existing struct {
+		key      string
+		size     int64
+		modified time.Time
+	}
+	found := make([]existing, 0, len(files))
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || !info.Mode().IsRegular() || !validCacheKey(file.Name()) {
+			continue
+		}
+		found = append(found, existing{key: file.Name(), size: info.Size(), modified: info.ModTime()})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].modified.After(found[j].modified) })
+	for _, entry := range found {
+		cache.entries[entry.key] = cache.order.PushBack(&cacheEntry{key: entry.key, size: entry.size})
+		cache.size += entry.size
+	}
+	cache.evict()
+
+	return cache, nil
+}
+
+// Returns the cached content for the key, false when it is not cached
+func (cache *Cache) Get(key string) ([]byte, bool) {
+	if !validCacheKey(key) {
+		return nil, false
+	}
+
+	cache.mutex.Lock()
+	element, ok := cache.entries[key]
+	if ok {
+		cache.order.MoveToFront(element)
+	}
+	cache.mutex.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	content, err := os.ReadFile(cache.path(key))
+	if err != nil {
+		// removed behind our back, forget about it
+		cache.remove(key)
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(cache.path(key), now, now)
+	return content, true
+}
+
+// Cache the content under the key, evicting old entries to stay in budget
+func (cache *Cache) Put(key string, content []byte) error {
+	if !validCacheKey(key) {
+		return errors.New("invalid cache key")
+	}
+	size := int64(len(content))
+	if size > cache.maxBytes {
+		// would evict everything else and itself
+		return nil
+	}
+
+	// write to a temporary file first so readers never see partial content
+	tmp, err := os.CreateTemp(cache.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if err := os.Rename(tmp.Name(), cache.path(key)); err != nil {
+		return err
+	}
+	if element, ok := cache.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		cache.size += size - entry.size
+		entry.size = size
+		cache.order.MoveToFront(element)
+	} else {
+		cache.entries[key] = cache.order.PushFront(&cacheEntry{key: key, size: size})
+		cache.size += size
+	}
+	cache.evict()
+
+	return nil
+}
+
+// Total size of the cached content
+func (cache *Cache) Size() int64 {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return cache.size
+}
+
+// Remove least recently used entries until the cache fits its budget, the
+// mutex must be held
+func (cache *Cache) evict() {
+	for cache.size > cache.maxBytes {
+		element := cache.order.Back()
+		entry := element.Value.(*cacheEntry)
+		cache.order.Remove(element)
+		delete(cache.entries, entry.key)
+		cache.size -= entry.size
+		// a file that can't be removed is picked up again on the next start
+		os.Remove(cache.path(entry.key))
+	}
+}
+
+func (cache *Cache) remove(key string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+		cache.size -= element.Value.(*cacheEntry).size
+	}
+}
+
+func (cache *Cache) path(key string) string {
+	return filepath.Join(cache.dir, key)
+}
+
+// Keys are file names made of hex digits (ex: a SHA-256 sum)
+func validCacheKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, char := range key {
+		if !(char >= '0' && char <= '9' || char >= 'a' && char <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
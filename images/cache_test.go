@@ -0,0 +1,75 @@
+package images
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the disk cache
+type CacheTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *CacheTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+}
+
+func (suite *CacheTestSuite) TestPutAndGet() {
+	cache, _ := NewCache(suite.dir, 100)
+	suite.Nil(cache.Put("aa", []byte("content")))
+
+	content, ok := cache.Get("aa")
+	suite.True(ok)
+	suite.Equal("content", string(content))
+
+	_, ok = cache.Get("bb")
+	suite.False(ok)
+}
+
+func (suite *CacheTestSuite) TestEvictsLeastRecentlyUsed() {
+	cache, _ := NewCache(suite.dir, 100)
+	suite.Nil(cache.Put("aa", bytes.Repeat([]byte("a"), 40)))
+	suite.Nil(cache.Put("bb", bytes.Repeat([]byte("b"), 40)))
+	// reading aa makes bb the least recently used
+	cache.Get("aa")
+	suite.Nil(cache.Put("cc", bytes.Repeat([]byte("c"), 40)))
+
+	_, ok := cache.Get("bb")
+	suite.False(ok)
+	_, ok = cache.Get("aa")
+	suite.True(ok)
+	_, ok = cache.Get("cc")
+	suite.True(ok)
+	suite.Equal(int64(80), cache.Size())
+}
+
+func (suite *CacheTestSuite) TestSkipsContentOverBudget() {
+	cache, _ := NewCache(suite.dir, 10)
+	suite.Nil(cache.Put("aa", bytes.Repeat([]byte("a"), 20)))
+
+	_, ok := cache.Get("aa")
+	suite.False(ok)
+}
+
+func (suite *CacheTestSuite) TestInvalidKey() {
+	cache, _ := NewCache(suite.dir, 100)
+	suite.NotNil(cache.Put("../escape", []byte("content")))
+}
+
+func (suite *CacheTestSuite) TestKeepsEntriesAcrossRestarts() {
+	cache, _ := NewCache(suite.dir, 100)
+	suite.Nil(cache.Put("aa", []byte("content")))
+
+	reopened, _ := NewCache(suite.dir, 100)
+	content, ok := reopened.Get("aa")
+	suite.True(ok)
+	suite.Equal("content", string(content))
+	suite.Equal(int64(7), reopened.Size())
+}
+
+func TestCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
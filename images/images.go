@@ -0,0 +1,132 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Formats variants are encoded to
+const (
+	FormatJpeg = "jpeg"
+	FormatWebp = "webp"
+)
+
+var Formats = []string{FormatJpeg, FormatWebp}
+
+// quality of the encoded JPEG variants
+const jpegQuality = 85
+
+var (
+	// Returned when the content is not an image we can decode
+	ErrUnsupportedImage = errors.New("unsupported image")
+	// Returned when the image has more pixels than allowed
+	ErrImageTooLarge = errors.New("image too large")
+)
+
+// Size of an image variant, images are scaled down to fit the box and keep
+// their aspect ratio, they are never scaled up
+type Size struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+}
+
+var Sizes = []Size{
+	{Name: "thumb", MaxWidth: 320, MaxHeight: 320},
+	{Name: "medium", MaxWidth: 1024, MaxHeight: 1024},
+}
+
+// Find a size by name
+func FindSize(name string) (Size, bool) {
+	for _, size := range Sizes {
+		if size.Name == name {
+			return size, true
+		}
+	}
+
+	return Size{}, false
+}
+
+// Decode a JPEG, PNG, GIF or WebP image, returns the name of its format. The
+// dimensions are read first, images with more than maxPixels pixels are
+// rejected before allocating them (0 for no limit)
+func Decode(r io.Reader, maxPixels int64) (image.Image, string, error) {
+	// the header read for the dimensions is decoded again with the rest
+	var header bytes.Buffer
+	imageConfig, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, "", ErrUnsupportedImage
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if pixels := int64(imageConfig.Width) * int64(imageConfig.Height); maxPixels > 0 && pixels > maxPixels {
+		return nil, "", fmt.Errorf("%w: %dx%d is over %d pixels", ErrImageTooLarge, imageConfig.Width, imageConfig.Height, maxPixels)
+	}
+
+	img, format, err := image.Decode(io.MultiReader(&header, r))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, "", ErrUnsupportedImage
+	}
+
+	return img, format, err
+}
+
+// Scale the image down to fit the size
+func Resize(img image.Image, size Size) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size.MaxWidth && height <= size.MaxHeight {
+		return img
+	}
+
+	// keep the aspect ratio using the most constrained side
+	scale := float64(size.MaxWidth) / float64(width)
+	if heightScale := float64(size.MaxHeight) / float64(height); heightScale < scale {
+		scale = heightScale
+	}
+	resizedWidth := max(1, int(float64(width)*scale+0.5))
+	resizedHeight := max(1, int(float64(height)*scale+0.5))
+
+	resized := image.NewRGBA(image.Rect(0, 0, resizedWidth, resizedHeight))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Src, nil)
+
+	return resized
+}
+
+// Encode the image in one of the supported formats
+func Encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case FormatJpeg:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	case FormatWebp:
+		// lossless, we don't have a pure Go lossy WebP encoder
+		return nativewebp.Encode(w, img, nil)
+	default:
+		return fmt.Errorf("unknown image format %q", format)
+	}
+}
+
+// Content type of the format
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+// File extension of the format
+func Extension(format string) string {
+	if format == FormatJpeg {
+		return "jpg"
+	}
+
+	return format
+}
@@ -0,0 +1,102 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for image processing
+type ImagesTestSuite struct {
+	suite.Suite
+}
+
+func newImage(width int, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	return img
+}
+
+func (suite *ImagesTestSuite) TestDecodePng() {
+	var content bytes.Buffer
+	suite.Nil(png.Encode(&content, newImage(40, 30)))
+
+	img, format, err := Decode(&content, 0)
+	if suite.Nil(err) {
+		suite.Equal("png", format)
+		suite.Equal(40, img.Bounds().Dx())
+	}
+}
+
+func (suite *ImagesTestSuite) TestDecodeUnsupported() {
+	_, _, err := Decode(strings.NewReader("not an image"), 0)
+	suite.ErrorIs(err, ErrUnsupportedImage)
+}
+
+func (suite *ImagesTestSuite) TestDecodeTooLarge() {
+	var content bytes.Buffer
+	suite.Nil(png.Encode(&content, newImage(40, 30)))
+
+	_, _, err := Decode(bytes.NewReader(content.Bytes()), 1199)
+	suite.ErrorIs(err, ErrImageTooLarge)
+	_, _, err = Decode(bytes.NewReader(content.Bytes()), 1200)
+	suite.Nil(err)
+
+	// only the header is read, a small file can claim a huge image
+	header := content.Bytes()[:33]
+	binary.BigEndian.PutUint32(header[16:20], 100_000)
+	binary.BigEndian.PutUint32(header[20:24], 100_000)
+	binary.BigEndian.PutUint32(header[29:33], crc32.ChecksumIEEE(header[12:29]))
+	_, _, err = Decode(bytes.NewReader(header), 50_000_000)
+	suite.ErrorIs(err, ErrImageTooLarge)
+}
+
+func (suite *ImagesTestSuite) TestResizeKeepsAspectRatio() {
+	size, _ := FindSize("thumb")
+	resized := Resize(newImage(1280, 640), size)
+
+	suite.Equal(320, resized.Bounds().Dx())
+	suite.Equal(160, resized.Bounds().Dy())
+}
+
+func (suite *ImagesTestSuite) TestResizeNeverUpscales() {
+	size, _ := FindSize("medium")
+	resized := Resize(newImage(200, 100), size)
+
+	suite.Equal(200, resized.Bounds().Dx())
+	suite.Equal(100, resized.Bounds().Dy())
+}
+
+func (suite *ImagesTestSuite) TestEncodeRoundTrip() {
+	for _, format := range Formats {
+		var encoded bytes.Buffer
+		if suite.Nil(Encode(&encoded, newImage(64, 48), format)) {
+			img, decodedFormat, err := Decode(&encoded, 0)
+			if suite.Nil(err) {
+				suite.Equal(format, decodedFormat)
+				suite.Equal(64, img.Bounds().Dx())
+			}
+		}
+	}
+}
+
+func (suite *ImagesTestSuite) TestEncodeUnknownFormat() {
+	var encoded bytes.Buffer
+	suite.NotNil(Encode(&encoded, newImage(8, 8), "bmp"))
+}
+
+func TestImagesTestSuite(t *testing.T) {
+	suite.Run(t, new(ImagesTestSuite))
+}
@@ -0,0 +1,168 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/samuelg/rentals/config"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/outbound"
+	"github.com/samuelg/rentals/storage"
+)
+
+// Source images larger than this are not processed
+const maxSourceBytes = 32 << 20
+
+var (
+	// Returned when the source image can't be fetched
+	ErrFetchFailed = errors.New("failed to fetch source image")
+	// Returned when the source image isn't on a public HTTP(S) address
+	ErrForbiddenSource = outbound.ErrForbiddenAddress
+)
+
+// Fetches source images by URL
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (io.ReadCloser, error)
+}
+
+// Fetches source images over HTTP(S)
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+// Fetcher only connecting to public addresses, source URLs come from users so
+// they must not reach the internal network
+func NewHTTPFetcher(timeout time.Duration) *HTTPFetcher {
+	return &HTTPFetcher{Client: outbound.NewClient(timeout, false)}
+}
+
+func (fetcher *HTTPFetcher) Fetch(ctx context.Context, source string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+	if err := outbound.CheckScheme(req.URL); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+
+	resp, err := fetcher.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: status %d", ErrFetchFailed, resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+// Reads uploaded images from the storage, other URLs are fetched with the
+// fallback
+type StorageFetcher struct {
+	Storage  storage.Storage
+	Fallback Fetcher
+}
+
+func (fetcher *StorageFetcher) Fetch(ctx context.Context, url string) (io.ReadCloser, error) {
+	key, ok := fetcher.Storage.Key(url)
+	if !ok {
+		return fetcher.Fallback.Fetch(ctx, url)
+	}
+
+	file, err := fetcher.Storage.Open(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+
+	return file, nil
+}
+
+// A resized and re-encoded image
+type Variant struct {
+	Content     []byte
+	ContentType string
+	// changes whenever the source or the variant changes
+	ETag string
+}
+
+// Serves resized variants of source images, variants are cached on disk
+type Proxy struct {
+	Fetcher Fetcher
+	Cache   *Cache
+	// source images with more pixels are not decoded, 0 for no limit
+	MaxPixels int64
+}
+
+// Proxy configured for the app
+var DefaultProxy *Proxy
+
+func Init() {
+	cache, err := NewCache(config.GetConfig().ImageCacheDir, config.GetConfig().ImageCacheMaxBytes)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize image cache: %v", err))
+	}
+
+	DefaultProxy = &Proxy{
+		Fetcher: &StorageFetcher{
+			Storage:  storage.Default,
+			Fallback: NewHTTPFetcher(time.Duration(config.GetConfig().ImageFetchTimeout) * time.Second),
+		},
+		Cache:     cache,
+		MaxPixels: config.GetConfig().MaxImagePixels,
+	}
+
+	log.Log.Info(fmt.Sprintf("Caching image variants in %s", config.GetConfig().ImageCacheDir))
+}
+
+// Returns the source image scaled down to the size and encoded in the format
+func (proxy *Proxy) Variant(ctx context.Context, source string, size Size, format string) (*Variant, error) {
+	// the source URL is part of the key so changing the image busts the cache
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%s", source, size.Name, format)))
+	key := hex.EncodeToString(sum[:])
+	variant := &Variant{ContentType: ContentType(format), ETag: `"` + key[:32] + `"`}
+
+	if content, ok := proxy.Cache.Get(key); ok {
+		variant.Content = content
+		return variant, nil
+	}
+
+	reader, err := proxy.Fetcher.Fetch(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, maxSourceBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFetchFailed, err)
+	}
+	if len(content) > maxSourceBytes {
+		return nil, fmt.Errorf("%w: source larger than %d bytes", ErrFetchFailed, maxSourceBytes)
+	}
+
+	img, _, err := Decode(bytes.NewReader(content), proxy.MaxPixels)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded bytes.Buffer
+	if err := Encode(&encoded, Resize(img, size), format); err != nil {
+		return nil, err
+	}
+	variant.Content = encoded.Bytes()
+
+	// serving the variant doesn't depend on caching it
+	if err := proxy.Cache.Put(key, variant.Content); err != nil {
+		log.Log.Warn(fmt.Sprintf("Failed to cache image variant: %v", err))
+	}
+
+	return variant, nil
+}
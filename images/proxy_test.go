@@ -0,0 +1,132 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/samuelg/rentals/outbound"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the image proxy, sources are served by a local HTTP stand-in
+type ProxyTestSuite struct {
+	suite.Suite
+	server   *httptest.Server
+	requests atomic.Int32
+	proxy    *Proxy
+}
+
+func (suite *ProxyTestSuite) SetupSuite() {
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.requests.Add(1)
+		switch r.URL.Path {
+		case "/large.png":
+			png.Encode(w, newImage(1280, 960))
+		case "/text":
+			w.Write([]byte("not an image"))
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func (suite *ProxyTestSuite) TearDownSuite() {
+	suite.server.Close()
+}
+
+func (suite *ProxyTestSuite) SetupTest() {
+	cache, err := NewCache(suite.T().TempDir(), 1<<20)
+	suite.Require().Nil(err)
+	suite.proxy = &Proxy{Fetcher: &HTTPFetcher{Client: suite.server.Client()}, Cache: cache}
+	suite.requests.Store(0)
+}
+
+func (suite *ProxyTestSuite) TestVariantResizesAndCaches() {
+	size, _ := FindSize("thumb")
+	variant, err := suite.proxy.Variant(context.Background(), suite.server.URL+"/large.png", size, FormatWebp)
+	if suite.Nil(err) {
+		suite.Equal("image/webp", variant.ContentType)
+		img, format, err := Decode(bytes.NewReader(variant.Content), 0)
+		if suite.Nil(err) {
+			suite.Equal(FormatWebp, format)
+			suite.Equal(320, img.Bounds().Dx())
+			suite.Equal(240, img.Bounds().Dy())
+		}
+	}
+
+	cached, err := suite.proxy.Variant(context.Background(), suite.server.URL+"/large.png", size, FormatWebp)
+	if suite.Nil(err) {
+		suite.Equal(variant.ETag, cached.ETag)
+		suite.Equal(variant.Content, cached.Content)
+	}
+	suite.Equal(int32(1), suite.requests.Load())
+
+	// another format is another variant
+	other, err := suite.proxy.Variant(context.Background(), suite.server.URL+"/large.png", size, FormatJpeg)
+	if suite.Nil(err) {
+		suite.NotEqual(variant.ETag, other.ETag)
+	}
+	suite.Equal(int32(2), suite.requests.Load())
+}
+
+func (suite *ProxyTestSuite) TestVariantMissingSource() {
+	size, _ := FindSize("thumb")
+	_, err := suite.proxy.Variant(context.Background(), suite.server.URL+"/missing.png", size, FormatJpeg)
+	suite.ErrorIs(err, ErrFetchFailed)
+}
+
+func (suite *ProxyTestSuite) TestVariantUnsupportedSource() {
+	size, _ := FindSize("thumb")
+	_, err := suite.proxy.Variant(context.Background(), suite.server.URL+"/text", size, FormatJpeg)
+	suite.ErrorIs(err, ErrUnsupportedImage)
+}
+
+func (suite *ProxyTestSuite) TestVariantTooLargeSource() {
+	suite.proxy.MaxPixels = 1000 * 1000
+	size, _ := FindSize("thumb")
+	_, err := suite.proxy.Variant(context.Background(), suite.server.URL+"/large.png", size, FormatJpeg)
+	suite.ErrorIs(err, ErrImageTooLarge)
+}
+
+func (suite *ProxyTestSuite) TestFetchInternalAddress() {
+	fetcher := NewHTTPFetcher(time.Second)
+
+	// the stand-in listens on a loopback address
+	_, err := fetcher.Fetch(context.Background(), suite.server.URL+"/large.png")
+	suite.ErrorIs(err, ErrFetchFailed)
+	suite.ErrorIs(err, ErrForbiddenSource)
+
+	// checked once the host name is resolved
+	_, port, _ := net.SplitHostPort(suite.server.Listener.Addr().String())
+	_, err = fetcher.Fetch(context.Background(), "http://localhost:"+port+"/large.png")
+	suite.ErrorIs(err, ErrForbiddenSource)
+	suite.Equal(int32(0), suite.requests.Load())
+}
+
+func (suite *ProxyTestSuite) TestFetchScheme() {
+	_, err := NewHTTPFetcher(time.Second).Fetch(context.Background(), "file:///etc/passwd")
+	suite.ErrorIs(err, ErrForbiddenSource)
+}
+
+func (suite *ProxyTestSuite) TestFetchRedirects() {
+	client := suite.server.Client()
+	client.CheckRedirect = outbound.CheckRedirect
+	fetcher := &HTTPFetcher{Client: client}
+
+	_, err := fetcher.Fetch(context.Background(), suite.server.URL+"/loop")
+	suite.ErrorIs(err, ErrFetchFailed)
+	suite.Equal(int32(outbound.MaxRedirects), suite.requests.Load())
+}
+
+func TestProxyTestSuite(t *testing.T) {
+	suite.Run(t, new(ProxyTestSuite))
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"regexp"
+
+	"github.com/samuelg/rentals/db"
+)
+
+// amenity keys are used in query strings (ex: amenities=kitchen,pets)
+var amenityKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// Amenity model, features renters can filter on (ex: pet friendly, kitchen, 4WD)
+type Amenity struct {
+	// uses serial integer column in the database which will use 32 bits at most
+	ID   uint32 `gorm:"primary_key;autoincrement;column:id" json:"id"`
+	Key  string `gorm:"column:key" json:"key"`
+	Name string `gorm:"column:name" json:"name"`
+}
+
+// Returns true when the key can be used for an amenity
+func ValidAmenityKey(key string) bool {
+	return amenityKeyPattern.MatchString(key)
+}
+
+// Find amenities by key, unknown keys are ignored
+func FindAmenitiesByKey(keys []string) ([]Amenity, error) {
+	amenities := make([]Amenity, 0)
+	if len(keys) == 0 {
+		return amenities, nil
+	}
+
+	err := db.DB.Where("key IN ?", keys).Order("key").Find(&amenities).Error
+	return amenities, err
+}
+
+// Returns the keys of the amenities, never nil so it is marshaled to an array
+func amenityKeys(amenities []Amenity) []string {
+	keys := make([]string, len(amenities))
+	for i, amenity := range amenities {
+		keys[i] = amenity.Key
+	}
+
+	return keys
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the backfills rewriting every rental, runs on a sqlite
+// database of its own so the fixture rentals of the other suites don't change
+type BackfillModelTestSuite struct {
+	suite.Suite
+}
+
+func (suite *BackfillModelTestSuite) SetupTest() {
+	config.InitWithOverrides("test", map[string]interface{}{
+		"db_driver": "sqlite",
+		"db_path":   filepath.Join(suite.T().TempDir(), "backfill.db"),
+	})
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+}
+
+func (suite *BackfillModelTestSuite) TearDownTest() {
+	db.Close()
+	config.Init("test")
+	db.Init()
+}
+
+// Clear the city and state of a rental without the hooks resolving them again
+func (suite *BackfillModelTestSuite) clearLocation(id uint32) Rental {
+	err := db.DB.Model(&Rental{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{"home_city": "", "home_state": ""}).Error
+	suite.Require().Nil(err)
+
+	var rental Rental
+	suite.Require().Nil(db.DB.First(&rental, id).Error)
+	return rental
+}
+
+// Add rentals so the backfills have more than one batch, returns the id of
+// the last one
+func (suite *BackfillModelTestSuite) addRentals() uint32 {
+	rentals := make([]Rental, backfillBatchSize)
+	for i := range rentals {
+		rentals[i] = Rental{UserId: 2, Name: "Batch van", Type: "camper-van", Sleeps: 2, Price: 9000, Lat: 45.52, Lng: -122.68}
+	}
+	suite.Require().Nil(db.DB.Omit("Amenities", "Images", "User").CreateInBatches(rentals, 50).Error)
+
+	return rentals[len(rentals)-1].ID
+}
+
+func (suite *BackfillModelTestSuite) TestBackfillLocationsMissing() {
+	// rentals of the first and of the last batch
+	first := suite.clearLocation(2)
+	rental := suite.clearLocation(suite.addRentals())
+	suite.Require().Greater(rental.ID, uint32(backfillBatchSize))
+	resolved := rental.ResolveLocation()
+	suite.Require().NotEmpty(resolved.Location.City)
+
+	report, err := BackfillLocations(false)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.GreaterOrEqual(report.Updated, 2)
+		var saved Rental
+		if suite.Nil(db.DB.First(&saved, first.ID).Error) {
+			suite.NotEmpty(saved.City)
+		}
+		saved = Rental{}
+		if suite.Nil(db.DB.First(&saved, rental.ID).Error) {
+			suite.Equal(resolved.Location.City, saved.City)
+			suite.Equal(resolved.Location.State, saved.State)
+			suite.Equal(resolved.IssuesString(), saved.LocationIssues)
+		}
+	}
+
+	// nothing is left to update
+	report, err = BackfillLocations(false)
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Zero(report.Updated)
+	}
+}
+
+func (suite *BackfillModelTestSuite) TestBackfillLocationsDryRun() {
+	rental := suite.clearLocation(2)
+
+	report, err := BackfillLocations(true)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.GreaterOrEqual(report.Updated, 1)
+		var saved Rental
+		if suite.Nil(db.DB.First(&saved, rental.ID).Error) {
+			suite.Empty(saved.City)
+			suite.Empty(saved.State)
+			suite.Equal(rental.Updated, saved.Updated)
+		}
+	}
+}
+
+// Set the make and model of a rental saved before the catalog, without the
+// hooks normalizing them
+func (suite *BackfillModelTestSuite) setVehicle(id uint32, vehicleMake string, vehicleModel string) {
+	err := db.DB.Model(&Rental{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"vehicle_make": vehicleMake, "vehicle_model": vehicleModel, "vehicle_make_input": "", "vehicle_model_input": "",
+	}).Error
+	suite.Require().Nil(err)
+}
+
+func (suite *BackfillModelTestSuite) TestNormalizeVehicles() {
+	last := suite.addRentals()
+	suite.setVehicle(2, "VW", "Eurovan Weekender Westfalia")
+	suite.setVehicle(last, " toyota ", "4RUNNER")
+
+	report, err := NormalizeVehicles(false)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.GreaterOrEqual(report.Updated, 2)
+		var rental Rental
+		if suite.Nil(db.DB.First(&rental, 2).Error) {
+			suite.Equal("Volkswagen", rental.VehicleMake)
+			suite.Equal("Eurovan Weekender Westfalia", rental.VehicleModel)
+			// the make and model as entered are kept
+			suite.Equal("VW", rental.VehicleMakeInput)
+			suite.Equal("Eurovan Weekender Westfalia", rental.VehicleModelInput)
+		}
+		rental = Rental{}
+		if suite.Nil(db.DB.First(&rental, last).Error) {
+			suite.Equal("Toyota", rental.VehicleMake)
+			suite.Equal("4Runner", rental.VehicleModel)
+			suite.Equal(" toyota ", rental.VehicleMakeInput)
+			suite.Equal("4RUNNER", rental.VehicleModelInput)
+		}
+	}
+
+	// nothing is left to update
+	report, err = NormalizeVehicles(false)
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Zero(report.Updated)
+	}
+}
+
+func (suite *BackfillModelTestSuite) TestNormalizeVehiclesDryRun() {
+	suite.setVehicle(2, "VW", "Eurovan Weekender Westfalia")
+
+	report, err := NormalizeVehicles(true)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.GreaterOrEqual(report.Updated, 1)
+		var rental Rental
+		if suite.Nil(db.DB.First(&rental, 2).Error) {
+			suite.Equal("VW", rental.VehicleMake)
+			suite.Equal("Eurovan Weekender Westfalia", rental.VehicleModel)
+			suite.Empty(rental.VehicleMakeInput)
+			suite.Empty(rental.VehicleModelInput)
+		}
+	}
+}
+
+func TestBackfillModelTestSuite(t *testing.T) {
+	suite.Run(t, new(BackfillModelTestSuite))
+}
@@ -0,0 +1,427 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/geo"
+	"github.com/samuelg/rentals/trigrams"
+	"golang.org/x/exp/slices"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Rentals further apart are never duplicate candidates
+const maxDuplicateMiles = 15
+
+// About maxDuplicateMiles in degrees of latitude, the band of latitude rentals
+// are paired in on sqlite
+const maxDuplicateLatDelta = maxDuplicateMiles / milesPerDegree
+
+// Weights of the similarities in the score of a candidate, they add up to 1
+const (
+	duplicateProximityWeight   = 0.25
+	duplicateVehicleWeight     = 0.25
+	duplicateNameWeight        = 0.3
+	duplicateDescriptionWeight = 0.2
+)
+
+// Decisions on a duplicate candidate
+const (
+	DuplicateDismissed = "dismissed"
+	DuplicateMerged    = "merged"
+)
+
+// Returned when merging rentals of different owners
+var ErrMergeOwners = errors.New("rentals have different owners")
+
+// Similarity of a pair of rentals on every signal, from 0 to 1. Signals unknown
+// on either rental (ex: no description) are 0.5
+type DuplicateScores struct {
+	Proximity   float64 `json:"proximity"`
+	Vehicle     float64 `json:"vehicle"`
+	Name        float64 `json:"name"`
+	Description float64 `json:"description"`
+}
+
+// A pair of rentals of the same owner that may be the same vehicle, the oldest
+// rental first
+type DuplicateCandidate struct {
+	RentalIds     [2]uint32       `json:"rental_ids"`
+	UserId        uint32          `json:"user_id"`
+	Names         [2]string       `json:"names"`
+	DistanceMiles float64         `json:"distance_miles"`
+	Score         float64         `json:"score"`
+	Scores        DuplicateScores `json:"scores"`
+}
+
+// DuplicateDecision model, a candidate dismissed or merged by an admin
+type DuplicateDecision struct {
+	RentalId      uint32  `gorm:"primaryKey;column:rental_id"`
+	OtherRentalId uint32  `gorm:"primaryKey;column:other_rental_id"`
+	Decision      string  `gorm:"column:decision"`
+	KeptRentalId  *uint32 `gorm:"column:kept_rental_id"`
+	ActorId       *uint32 `gorm:"column:actor_id"`
+}
+
+// A rental with the fields compared, text is split in trigrams once
+type duplicateRental struct {
+	rental       *Rental
+	name         trigrams.Set
+	description  trigrams.Set
+	vehicleMake  string
+	vehicleModel string
+}
+
+// A pair of rentals that may be duplicates, the oldest rental first
+type duplicatePair struct {
+	RentalId      uint32
+	OtherRentalId uint32
+}
+
+// Pairs of rentals of the same owner close to each other, in SQL so only the
+// pairs that can score enough are compared in Go
+const duplicatePairsQuery = `SELECT a.id AS rental_id, b.id AS other_rental_id
+	FROM rentals a
+	JOIN rentals b ON b.user_id = a.user_id AND b.id > a.id
+	WHERE %s AND (%s)
+	AND NOT EXISTS (
+		SELECT 1 FROM duplicate_decisions d
+		WHERE d.rental_id = a.id AND d.other_rental_id = b.id AND d.decision = ?
+	)
+	ORDER BY a.id, b.id`
+
+// Pairs close enough, uses the GIST index on the location column
+const duplicateNearCondition = "ST_DWITHIN(a.location, b.location, ?)"
+
+// Pairs close enough in sqlite, rentals outside the latitude band are skipped
+// first like the near filter
+const sqliteDuplicateNearCondition = "b.lat BETWEEN a.lat - ? AND a.lat + ? AND distance_miles(a.lat, a.lng, b.lat, b.lng) <= ?"
+
+// Pairs with a vehicle similarity above 0: the make, model or year is the same,
+// or none of them is set on both rentals. Mirrors vehicleSimilarity
+const duplicateVehicleCondition = `(
+	(TRIM(LOWER(COALESCE(a.vehicle_make, ''))) <> '' AND TRIM(LOWER(COALESCE(a.vehicle_make, ''))) = TRIM(LOWER(COALESCE(b.vehicle_make, ''))))
+	OR (TRIM(LOWER(COALESCE(a.vehicle_model, ''))) <> '' AND TRIM(LOWER(COALESCE(a.vehicle_model, ''))) = TRIM(LOWER(COALESCE(b.vehicle_model, ''))))
+	OR (COALESCE(a.vehicle_year, 0) <> 0 AND a.vehicle_year = b.vehicle_year)
+	OR NOT (
+		(TRIM(COALESCE(a.vehicle_make, '')) <> '' AND TRIM(COALESCE(b.vehicle_make, '')) <> '')
+		OR (TRIM(COALESCE(a.vehicle_model, '')) <> '' AND TRIM(COALESCE(b.vehicle_model, '')) <> '')
+		OR (COALESCE(a.vehicle_year, 0) <> 0 AND COALESCE(b.vehicle_year, 0) <> 0)
+	)
+)`
+
+// Number of rentals of the pairs loaded at once
+const duplicateBatchSize = 1000
+
+// Find the pairs of rentals scoring at least the minimum, best scores first.
+// Dismissed pairs are left out. Pairs are blocked in SQL: rentals of the same
+// owner close to each other with a similar vehicle, or similar names when the
+// other signals can't reach the minimum without it
+func FindDuplicates(minScore float64) ([]DuplicateCandidate, error) {
+	near := duplicateNearCondition
+	vars := []interface{}{maxDuplicateMiles * metersPerMile}
+	if db.IsSQLite() {
+		near = sqliteDuplicateNearCondition
+		vars = []interface{}{maxDuplicateLatDelta, maxDuplicateLatDelta, maxDuplicateMiles}
+	}
+
+	// a pair without vehicle similarity scores at most the proximity and
+	// description weights and its name similarity
+	blocking := duplicateVehicleCondition
+	nameMin := (minScore - duplicateProximityWeight - duplicateDescriptionWeight) / duplicateNameWeight
+	if nameMin <= 0 {
+		blocking = "1 = 1"
+	} else if nameMin <= 1 {
+		// scores are rounded
+		blocking += " OR similarity(a.name, b.name) >= ?"
+		vars = append(vars, nameMin-0.001)
+	}
+	vars = append(vars, DuplicateDismissed)
+
+	var pairs []duplicatePair
+	if err := db.DB.Raw(fmt.Sprintf(duplicatePairsQuery, near, blocking), vars...).Scan(&pairs).Error; err != nil {
+		return nil, err
+	}
+
+	rentals, err := duplicateRentals(pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]DuplicateCandidate, 0)
+	for _, pair := range pairs {
+		a, b := rentals[pair.RentalId], rentals[pair.OtherRentalId]
+		// removed since the pairs were found
+		if a == nil || b == nil {
+			continue
+		}
+		candidate, ok := compareRentals(a, b)
+		if ok && candidate.Score >= minScore {
+			candidates = append(candidates, candidate)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].RentalIds[0] < candidates[j].RentalIds[0] ||
+			(candidates[i].RentalIds[0] == candidates[j].RentalIds[0] && candidates[i].RentalIds[1] < candidates[j].RentalIds[1])
+	})
+
+	return candidates, nil
+}
+
+// The rentals of the pairs by id with the fields compared
+func duplicateRentals(pairs []duplicatePair) (map[uint32]*duplicateRental, error) {
+	ids := make([]uint32, 0, 2*len(pairs))
+	for _, pair := range pairs {
+		ids = append(ids, pair.RentalId, pair.OtherRentalId)
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	result := make(map[uint32]*duplicateRental, len(ids))
+	for start := 0; start < len(ids); start += duplicateBatchSize {
+		var rentals []Rental
+		err := db.DB.Select("id", "user_id", "name", "description", "lat", "lng", "vehicle_make", "vehicle_model", "vehicle_year").
+			Where("id IN ?", ids[start:min(start+duplicateBatchSize, len(ids))]).
+			Find(&rentals).Error
+		if err != nil {
+			return nil, err
+		}
+		for i := range rentals {
+			result[rentals[i].ID] = newDuplicateRental(&rentals[i])
+		}
+	}
+
+	return result, nil
+}
+
+func newDuplicateRental(rental *Rental) *duplicateRental {
+	return &duplicateRental{
+		rental:       rental,
+		name:         trigrams.Of(rental.Name),
+		description:  trigrams.Of(rental.Description),
+		vehicleMake:  strings.ToLower(strings.TrimSpace(rental.VehicleMake)),
+		vehicleModel: strings.ToLower(strings.TrimSpace(rental.VehicleModel)),
+	}
+}
+
+// Score a pair of rentals, false when they are too far apart
+func compareRentals(a *duplicateRental, b *duplicateRental) (DuplicateCandidate, bool) {
+	if a.rental.ID > b.rental.ID {
+		a, b = b, a
+	}
+	distance := geo.DistanceMiles(float64(a.rental.Lat), float64(a.rental.Lng), float64(b.rental.Lat), float64(b.rental.Lng))
+	if distance > maxDuplicateMiles {
+		return DuplicateCandidate{}, false
+	}
+
+	scores := DuplicateScores{
+		Proximity:   roundScore(1 - distance/maxDuplicateMiles),
+		Vehicle:     roundScore(vehicleSimilarity(a, b)),
+		Name:        roundScore(textSimilarity(a.name, b.name)),
+		Description: roundScore(textSimilarity(a.description, b.description)),
+	}
+	score := duplicateProximityWeight*scores.Proximity +
+		duplicateVehicleWeight*scores.Vehicle +
+		duplicateNameWeight*scores.Name +
+		duplicateDescriptionWeight*scores.Description
+
+	return DuplicateCandidate{
+		RentalIds:     [2]uint32{a.rental.ID, b.rental.ID},
+		UserId:        a.rental.UserId,
+		Names:         [2]string{a.rental.Name, b.rental.Name},
+		DistanceMiles: math.Round(distance*100) / 100,
+		Score:         roundScore(score),
+		Scores:        scores,
+	}, true
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*1000) / 1000
+}
+
+// Share of the make, model and year set on both rentals that are the same
+func vehicleSimilarity(a *duplicateRental, b *duplicateRental) float64 {
+	compared, matched := 0, 0
+	compare := func(set bool, same bool) {
+		if set {
+			compared++
+			if same {
+				matched++
+			}
+		}
+	}
+	compare(a.vehicleMake != "" && b.vehicleMake != "", a.vehicleMake == b.vehicleMake)
+	compare(a.vehicleModel != "" && b.vehicleModel != "", a.vehicleModel == b.vehicleModel)
+	compare(a.rental.VehicleYear != 0 && b.rental.VehicleYear != 0, a.rental.VehicleYear == b.rental.VehicleYear)
+
+	if compared == 0 {
+		return 0.5
+	}
+	return float64(matched) / float64(compared)
+}
+
+// Similarity of the trigrams of two texts, 0.5 when neither has words
+func textSimilarity(a trigrams.Set, b trigrams.Set) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0.5
+	}
+	return trigrams.Similarity(a, b)
+}
+
+// Keep the pair out of the candidates
+func DismissDuplicate(ctx context.Context, rentalId uint32, otherRentalId uint32) error {
+	decision := newDuplicateDecision(ctx, rentalId, otherRentalId, DuplicateDismissed)
+	return db.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(decision).Error
+}
+
+// Merge the duplicate into the kept rental and remove it. Reviews, photos,
+// amenities, wishlist items and price watches move to the kept rental unless
+// it already has the same one (ex: a review by the same user)
+func MergeDuplicate(ctx context.Context, kept *Rental, duplicate *Rental) error {
+	if kept.UserId != duplicate.UserId {
+		return ErrMergeOwners
+	}
+
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// no other change to the pair while merging
+		var locked []Rental
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []uint32{kept.ID, duplicate.ID}).
+			Order("id").
+			Find(&locked).Error
+		if err != nil {
+			return err
+		}
+		if len(locked) != 2 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := mergeReviews(tx, kept.ID, duplicate.ID); err != nil {
+			return err
+		}
+		if err := mergeImages(tx, kept.ID, duplicate.ID); err != nil {
+			return err
+		}
+
+		statements := []struct {
+			sql  string
+			vars []interface{}
+		}{
+			{`INSERT INTO rental_amenities (rental_id, amenity_id)
+				SELECT ?, amenity_id FROM rental_amenities WHERE rental_id = ?
+				ON CONFLICT DO NOTHING`, []interface{}{kept.ID, duplicate.ID}},
+			{`UPDATE wishlist_items SET rental_id = ?
+				WHERE rental_id = ? AND wishlist_id NOT IN (SELECT wishlist_id FROM wishlist_items WHERE rental_id = ?)`,
+				[]interface{}{kept.ID, duplicate.ID, kept.ID}},
+			// items are not removed with the rental, the rest are already in the wishlist
+			{`DELETE FROM wishlist_items WHERE rental_id = ?`, []interface{}{duplicate.ID}},
+			// watches compare with the price of the kept rental from now on
+			{`UPDATE price_watches SET rental_id = ?, baseline_price_per_day = (SELECT price_per_day FROM rentals WHERE id = ?)
+				WHERE rental_id = ? AND user_id NOT IN (SELECT user_id FROM price_watches WHERE rental_id = ?)`,
+				[]interface{}{kept.ID, kept.ID, duplicate.ID, kept.ID}},
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement.sql, statement.vars...).Error; err != nil {
+				return err
+			}
+		}
+
+		// photos were moved, no files to remove
+		if _, err := deleteRental(tx, duplicate); err != nil {
+			return err
+		}
+		// feeds and imports keep matching the kept rental
+		if kept.ExternalId == nil && duplicate.ExternalId != nil {
+			kept.ExternalId = duplicate.ExternalId
+			if err := tx.Model(kept).Update("external_id", *kept.ExternalId).Error; err != nil {
+				return err
+			}
+		}
+
+		decision := newDuplicateDecision(ctx, kept.ID, duplicate.ID, DuplicateMerged)
+		decision.KeptRentalId = &kept.ID
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(decision).Error
+	})
+}
+
+// Move the reviews of users who didn't review the kept rental and update its
+// rating aggregates
+func mergeReviews(tx *gorm.DB, keptId uint32, duplicateId uint32) error {
+	err := tx.Exec(
+		`UPDATE reviews SET rental_id = ?
+		WHERE rental_id = ? AND user_id NOT IN (SELECT user_id FROM reviews WHERE rental_id = ?)`,
+		keptId, duplicateId, keptId,
+	).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Exec(
+		`UPDATE rentals SET
+			review_count = aggregates.review_count,
+			rating_sum = aggregates.rating_sum,
+			rating_average = CASE WHEN aggregates.review_count > 0 THEN aggregates.rating_sum / aggregates.review_count END
+		FROM (
+			SELECT count(*) AS review_count, COALESCE(sum((cleanliness + accuracy + communication) / 3.0), 0) AS rating_sum
+			FROM reviews WHERE rental_id = ?
+		) AS aggregates
+		WHERE id = ?`,
+		keptId, keptId,
+	).Error
+}
+
+// Append the photos of the duplicate to the gallery of the kept rental, its
+// primary photo stays primary when the kept rental has none
+func mergeImages(tx *gorm.DB, keptId uint32, duplicateId uint32) error {
+	var gallery []RentalImage
+	if err := tx.Where("rental_id = ?", keptId).Find(&gallery).Error; err != nil {
+		return err
+	}
+	var nextPosition int32
+	hasPrimary := false
+	for _, rentalImage := range gallery {
+		if rentalImage.Position >= nextPosition {
+			nextPosition = rentalImage.Position + 1
+		}
+		hasPrimary = hasPrimary || rentalImage.Primary
+	}
+
+	err := tx.Model(&RentalImage{}).Where("rental_id = ?", duplicateId).Updates(map[string]interface{}{
+		"rental_id":  keptId,
+		"position":   gorm.Expr("position + ?", nextPosition),
+		"is_primary": gorm.Expr("is_primary AND ?", !hasPrimary),
+	}).Error
+	if err != nil || hasPrimary {
+		return err
+	}
+
+	var primary RentalImage
+	result := tx.Where("rental_id = ? AND is_primary", keptId).Limit(1).Find(&primary)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	return setPrimaryImage(tx, &primary)
+}
+
+func newDuplicateDecision(ctx context.Context, rentalId uint32, otherRentalId uint32, decision string) *DuplicateDecision {
+	if rentalId > otherRentalId {
+		rentalId, otherRentalId = otherRentalId, rentalId
+	}
+	return &DuplicateDecision{
+		RentalId:      rentalId,
+		OtherRentalId: otherRentalId,
+		Decision:      decision,
+		ActorId:       actorId(ctx),
+	}
+}
@@ -0,0 +1,229 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/trigrams"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for duplicate detection
+type DuplicateModelTestSuite struct {
+	suite.Suite
+	config       *config.Config
+	lastRentalId uint32
+}
+
+func (suite *DuplicateModelTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.lastRentalId = maxRentalId()
+}
+
+func (suite *DuplicateModelTestSuite) TearDownSuite() {
+	db.DB.Where("id > ?", suite.lastRentalId).Delete(&Rental{})
+}
+
+func duplicateOf(id uint32, name string, description string, lat float32, lng float32) *duplicateRental {
+	return newDuplicateRental(&Rental{
+		ID: id, UserId: 4, Name: name, Description: description, Lat: lat, Lng: lng,
+		VehicleMake: "Volkswagen", VehicleModel: "Vanagon", VehicleYear: 1984,
+	})
+}
+
+// Van listed twice in Portland, the second copy slightly edited
+func (suite *DuplicateModelTestSuite) createPair() (*Rental, *Rental) {
+	ctx := context.Background()
+	create := func(name string, description string, lat float32) *Rental {
+		rental := &Rental{
+			UserId: 4, Name: name, Description: description, Type: "camper-van", Sleeps: 4, Price: 15000,
+			City: "Portland", State: "OR", Zip: "97214", Country: "US", Lat: lat, Lng: -122.64,
+			VehicleMakeInput: "Volkswagen", VehicleModelInput: "Vanagon", VehicleYear: 1984,
+		}
+		suite.Require().Nil(CreateRental(ctx, rental))
+		return rental
+	}
+
+	return create("Westy Vanagon pop-top", "Classic 1984 Westfalia with a kitchen and a pop-top.", 45.515),
+		create("Westy Vanagon pop top!", "Classic 1984 Westfalia with kitchen and pop-top", 45.516)
+}
+
+func containsPair(candidates []DuplicateCandidate, a *Rental, b *Rental) bool {
+	for _, candidate := range candidates {
+		if candidate.RentalIds == [2]uint32{a.ID, b.ID} {
+			return true
+		}
+	}
+	return false
+}
+
+func (suite *DuplicateModelTestSuite) TestTextSimilarity() {
+	suite.Equal(1.0, textSimilarity(trigrams.Of("Westy, the van"), trigrams.Of("westy the VAN!")))
+	suite.Greater(textSimilarity(trigrams.Of("Westy the van"), trigrams.Of("Westy the vans")), 0.7)
+	suite.Less(textSimilarity(trigrams.Of("Westy the van"), trigrams.Of("Airstream Bambi")), 0.1)
+	suite.Equal(0.5, textSimilarity(trigrams.Of(""), trigrams.Of(" ")))
+	suite.Equal(0.0, textSimilarity(trigrams.Of("Westy"), trigrams.Of("")))
+}
+
+func (suite *DuplicateModelTestSuite) TestCompareRentals() {
+	a := duplicateOf(2, "Westy pop-top", "Classic Westfalia", 45.52, -122.68)
+	b := duplicateOf(1, "Westy pop top", "Classic Westfalia!", 45.52, -122.68)
+
+	candidate, ok := compareRentals(a, b)
+
+	if suite.True(ok) {
+		suite.Equal([2]uint32{1, 2}, candidate.RentalIds)
+		suite.Equal(1.0, candidate.Scores.Proximity)
+		suite.Equal(1.0, candidate.Scores.Vehicle)
+		suite.Equal(1.0, candidate.Scores.Description)
+		suite.Greater(candidate.Score, 0.9)
+	}
+
+	b.rental.VehicleYear = 1990
+	candidate, _ = compareRentals(a, b)
+	suite.InDelta(2.0/3, candidate.Scores.Vehicle, 0.001)
+}
+
+// Rentals of user 5 around Portland, with the vehicle and name of the van or
+// of a different one
+func (suite *DuplicateModelTestSuite) createNeighbors() []*Rental {
+	ctx := context.Background()
+	create := func(name string, vehicleMake string, vehicleModel string, vehicleYear int32, lat float32, lng float32) *Rental {
+		rental := &Rental{
+			UserId: 5, Name: name, Type: "camper-van", Sleeps: 4, Price: 12000, Lat: lat, Lng: lng,
+			VehicleMakeInput: vehicleMake, VehicleModelInput: vehicleModel, VehicleYear: vehicleYear,
+		}
+		suite.Require().Nil(CreateRental(ctx, rental))
+		return rental
+	}
+
+	return []*Rental{
+		create("Syncro camper", "Volkswagen", "Vanagon", 1987, 45.52, -122.68),
+		// a few miles east
+		create("Syncro camper", "Volkswagen", "Vanagon", 1987, 45.52, -122.60),
+		// Seattle
+		create("Syncro camper", "Volkswagen", "Vanagon", 1987, 47.61, -122.33),
+		// same name, another vehicle
+		create("Syncro camper!", "Ford", "Transit", 2019, 45.52, -122.68),
+		// another name and vehicle
+		create("Airstream Bambi", "Airstream", "Bambi", 1964, 45.52, -122.68),
+	}
+}
+
+// Pairs of the rentals scoring at least the minimum, every pair compared
+func allDuplicatePairs(rentals []*Rental, minScore float64) [][2]uint32 {
+	pairs := make([][2]uint32, 0)
+	for i, a := range rentals {
+		for _, b := range rentals[i+1:] {
+			candidate, ok := compareRentals(newDuplicateRental(a), newDuplicateRental(b))
+			if ok && candidate.Score >= minScore {
+				pairs = append(pairs, candidate.RentalIds)
+			}
+		}
+	}
+	return pairs
+}
+
+func (suite *DuplicateModelTestSuite) TestFindDuplicatesDistance() {
+	rentals := suite.createNeighbors()
+
+	candidates, err := FindDuplicates(0)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.True(containsPair(candidates, rentals[0], rentals[1]))
+		suite.False(containsPair(candidates, rentals[0], rentals[2]), "Should not pair rentals in Portland and Seattle")
+		for _, candidate := range candidates {
+			if candidate.RentalIds == [2]uint32{rentals[0].ID, rentals[1].ID} {
+				suite.Greater(candidate.DistanceMiles, 3.0)
+				suite.Less(candidate.Scores.Proximity, 1.0)
+			}
+		}
+	}
+}
+
+func (suite *DuplicateModelTestSuite) TestFindDuplicatesBlocking() {
+	rentals := suite.createNeighbors()
+
+	for _, minScore := range []float64{0, 0.4, 0.6, 0.7, 0.8} {
+		candidates, err := FindDuplicates(minScore)
+		if !suite.Nil(err, "Should not lead to an error") {
+			continue
+		}
+		found := make([][2]uint32, 0)
+		for _, candidate := range candidates {
+			if candidate.UserId == 5 && candidate.RentalIds[0] >= rentals[0].ID {
+				found = append(found, candidate.RentalIds)
+			}
+		}
+		// the pairs left out in SQL could not reach the minimum
+		suite.ElementsMatch(allDuplicatePairs(rentals, minScore), found, "min score %v", minScore)
+	}
+
+	// the name alone makes up for another vehicle only with a low minimum
+	candidates, _ := FindDuplicates(0.6)
+	suite.True(containsPair(candidates, rentals[0], rentals[3]))
+	candidates, _ = FindDuplicates(0.7)
+	suite.False(containsPair(candidates, rentals[0], rentals[3]))
+}
+
+func (suite *DuplicateModelTestSuite) TestFindAndDismissDuplicates() {
+	a, b := suite.createPair()
+
+	candidates, err := FindDuplicates(suite.config.DuplicateMinScore)
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.True(containsPair(candidates, a, b))
+	}
+
+	if suite.Nil(DismissDuplicate(context.Background(), b.ID, a.ID), "Should not lead to an error") {
+		candidates, _ = FindDuplicates(suite.config.DuplicateMinScore)
+		suite.False(containsPair(candidates, a, b))
+	}
+}
+
+func (suite *DuplicateModelTestSuite) TestMergeDuplicate() {
+	kept, duplicate := suite.createPair()
+	externalId := uniqueExternalId("merged")
+	suite.Require().Nil(db.DB.Model(duplicate).Update("external_id", externalId).Error)
+	duplicate.ExternalId = &externalId
+	suite.Require().Nil(CreateReview(&Review{RentalId: duplicate.ID, UserId: 3, Cleanliness: 5, Accuracy: 4, Communication: 3}))
+	suite.Require().Nil(db.DB.Exec("INSERT INTO rental_amenities (rental_id, amenity_id) SELECT ?, id FROM amenities LIMIT 1", duplicate.ID).Error)
+
+	err := MergeDuplicate(context.Background(), kept, duplicate)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		var count int64
+		db.DB.Model(&Rental{}).Where("id = ?", duplicate.ID).Count(&count)
+		suite.Zero(count)
+
+		var merged Rental
+		if suite.Nil(db.DB.Preload("Amenities").First(&merged, kept.ID).Error, "Should not lead to an error") {
+			suite.Equal(int32(1), merged.ReviewCount)
+			suite.InDelta(4.0, *merged.RatingAverage, 0.001)
+			suite.Len(merged.Amenities, 1)
+			suite.Equal(externalId, *merged.ExternalId)
+		}
+
+		var decision DuplicateDecision
+		if suite.Nil(db.DB.Where("rental_id = ? AND other_rental_id = ?", kept.ID, duplicate.ID).First(&decision).Error, "Should not lead to an error") {
+			suite.Equal(DuplicateMerged, decision.Decision)
+			suite.Equal(kept.ID, *decision.KeptRentalId)
+		}
+	}
+}
+
+func (suite *DuplicateModelTestSuite) TestMergeDuplicateOwners() {
+	err := MergeDuplicate(context.Background(), &Rental{ID: 1, UserId: 1}, &Rental{ID: 2, UserId: 2})
+
+	suite.ErrorIs(err, ErrMergeOwners)
+}
+
+func TestDuplicateModelTestSuite(t *testing.T) {
+	suite.Run(t, new(DuplicateModelTestSuite))
+}
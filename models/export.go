@@ -0,0 +1,333 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samuelg/rentals/db"
+	"github.com/xitongsys/parquet-go/writer"
+	"gorm.io/gorm"
+)
+
+// Formats of an export
+const (
+	ExportCsv     = "csv"
+	ExportNdjson  = "ndjson"
+	ExportParquet = "parquet"
+)
+
+var ExportFormats = []string{ExportCsv, ExportNdjson, ExportParquet}
+
+// Content type of the exports by format
+var ExportContentTypes = map[string]string{
+	ExportCsv:     "text/csv",
+	ExportNdjson:  "application/x-ndjson",
+	ExportParquet: "application/vnd.apache.parquet",
+}
+
+// Rows buffered by the Parquet writer before a row group is written, in bytes
+const parquetRowGroupBytes = 8 << 20
+
+// Call fn with every rental matching the filter in the order of its sort.
+// Matching ids are read from a server-side cursor a batch at a time in a read
+// only snapshot so memory stays constant, pagination is ignored. SQLite has no
+// cursors, the ids are read at once and only the rentals are batched
+func (filter *Filter) Stream(ctx context.Context, batchSize int, fn func(rental *Rental) error) error {
+	options := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ids := rentalsAsOf(tx, filter.AsOf).Session(&gorm.Session{DryRun: true}).
+			Model(&Rental{}).
+			Select("rentals.id").
+			Scopes(filter.Conditions).
+			Order(getSort(filter)).
+			Find(&[]uint32{}).Statement
+
+		var next func() ([]uint32, error)
+		if db.IsSQLite() {
+			var all []uint32
+			if err := tx.Raw(ids.SQL.String(), ids.Vars...).Scan(&all).Error; err != nil {
+				return err
+			}
+			next = func() ([]uint32, error) {
+				batch := all[:min(batchSize, len(all))]
+				all = all[len(batch):]
+				return batch, nil
+			}
+		} else {
+			// run as is, gorm would parse the placeholders of the query again
+			_, err := tx.Statement.ConnPool.ExecContext(ctx, "DECLARE rentals_export NO SCROLL CURSOR FOR "+ids.SQL.String(), ids.Vars...)
+			if err != nil {
+				return err
+			}
+
+			fetch := fmt.Sprintf("FETCH FORWARD %d FROM rentals_export", batchSize)
+			next = func() ([]uint32, error) {
+				var batch []uint32
+				err := tx.Raw(fetch).Scan(&batch).Error
+				return batch, err
+			}
+		}
+
+		for {
+			batch, err := next()
+			if err != nil {
+				return err
+			}
+			if len(batch) == 0 {
+				return nil
+			}
+
+			var rentals []Rental
+			err = rentalsAsOf(tx, filter.AsOf).Joins("User").Preload("Amenities").Preload("Images", OrderImages).
+				Where("rentals.id IN ?", batch).
+				Find(&rentals).Error
+			if err != nil {
+				return err
+			}
+
+			// keep the order of the cursor
+			byId := make(map[uint32]*Rental, len(rentals))
+			for i := range rentals {
+				byId[rentals[i].ID] = &rentals[i]
+			}
+			for _, id := range batch {
+				if rental, ok := byId[id]; ok {
+					if err := fn(rental); err != nil {
+						return err
+					}
+				}
+			}
+			if len(batch) < batchSize {
+				return nil
+			}
+		}
+	}, options)
+}
+
+// Writes rentals in an export format, Close writes what is still buffered
+type RentalWriter interface {
+	Write(rental *Rental) error
+	Close() error
+}
+
+func NewRentalWriter(w io.Writer, format string) (RentalWriter, error) {
+	switch format {
+	case ExportCsv:
+		return &csvRentalWriter{writer: csv.NewWriter(w)}, nil
+	case ExportNdjson:
+		return &ndjsonRentalWriter{encoder: json.NewEncoder(w)}, nil
+	case ExportParquet:
+		parquetWriter, err := writer.NewParquetWriterFromWriter(w, new(rentalRecord), 1)
+		if err != nil {
+			return nil, err
+		}
+		parquetWriter.RowGroupSize = parquetRowGroupBytes
+		return &parquetRentalWriter{writer: parquetWriter}, nil
+	}
+
+	return nil, fmt.Errorf("unknown export format %s", format)
+}
+
+// One rental per line in the format of the responses
+type ndjsonRentalWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonRentalWriter) Write(rental *Rental) error {
+	return w.encoder.Encode(rental)
+}
+
+func (w *ndjsonRentalWriter) Close() error {
+	return nil
+}
+
+// A rental flattened for CSV and Parquet, the columns shared with imports have
+// the same names
+type rentalRecord struct {
+	Id                 int32   `parquet:"name=id, type=INT32"`
+	UserId             int32   `parquet:"name=user_id, type=INT32"`
+	ExternalId         *string `parquet:"name=external_id, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Name               string  `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Description        string  `parquet:"name=description, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Type               string  `parquet:"name=type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Make               string  `parquet:"name=make, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Model              string  `parquet:"name=model, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Year               int32   `parquet:"name=year, type=INT32"`
+	Length             float32 `parquet:"name=length, type=FLOAT"`
+	Sleeps             int32   `parquet:"name=sleeps, type=INT32"`
+	PriceDay           int64   `parquet:"name=price_day, type=INT64"`
+	City               string  `parquet:"name=city, type=BYTE_ARRAY, convertedtype=UTF8"`
+	State              string  `parquet:"name=state, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Zip                string  `parquet:"name=zip, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Country            string  `parquet:"name=country, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Lat                float32 `parquet:"name=lat, type=FLOAT"`
+	Lng                float32 `parquet:"name=lng, type=FLOAT"`
+	FuelType           *string `parquet:"name=fuel_type, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Transmission       *string `parquet:"name=transmission, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Drivetrain         *string `parquet:"name=drivetrain, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Seatbelts          *int32  `parquet:"name=seatbelts, type=INT32, repetitiontype=OPTIONAL"`
+	TowCapacity        *int32  `parquet:"name=tow_capacity, type=INT32, repetitiontype=OPTIONAL"`
+	FreshWaterCapacity *int32  `parquet:"name=fresh_water_capacity, type=INT32, repetitiontype=OPTIONAL"`
+	Generator          *bool   `parquet:"name=generator, type=BOOLEAN, repetitiontype=OPTIONAL"`
+	EvRange            *int32  `parquet:"name=ev_range, type=INT32, repetitiontype=OPTIONAL"`
+	// comma separated keys, like the amenities filter
+	Amenities       string   `parquet:"name=amenities, type=BYTE_ARRAY, convertedtype=UTF8"`
+	RatingAverage   *float64 `parquet:"name=rating_average, type=DOUBLE, repetitiontype=OPTIONAL"`
+	ReviewCount     int32    `parquet:"name=review_count, type=INT32"`
+	PrimaryImageUrl string   `parquet:"name=primary_image_url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Created         int64    `parquet:"name=created, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Updated         int64    `parquet:"name=updated, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+}
+
+var csvExportHeader = []string{
+	"id", "user_id", "external_id", "name", "description", "type", "make", "model", "year", "length", "sleeps",
+	"price_day", "city", "state", "zip", "country", "lat", "lng",
+	"fuel_type", "transmission", "drivetrain", "seatbelts", "tow_capacity", "fresh_water_capacity", "generator", "ev_range",
+	"amenities", "rating_average", "review_count", "primary_image_url", "created", "updated",
+}
+
+func (rental *Rental) record() rentalRecord {
+	return rentalRecord{
+		Id:                 int32(rental.ID),
+		UserId:             int32(rental.UserId),
+		ExternalId:         rental.ExternalId,
+		Name:               rental.Name,
+		Description:        rental.Description,
+		Type:               rental.Type,
+		Make:               rental.VehicleMake,
+		Model:              rental.VehicleModel,
+		Year:               rental.VehicleYear,
+		Length:             rental.VehicleLength,
+		Sleeps:             rental.Sleeps,
+		PriceDay:           rental.Price,
+		City:               rental.City,
+		State:              rental.State,
+		Zip:                rental.Zip,
+		Country:            rental.Country,
+		Lat:                rental.Lat,
+		Lng:                rental.Lng,
+		FuelType:           rental.FuelType,
+		Transmission:       rental.Transmission,
+		Drivetrain:         rental.Drivetrain,
+		Seatbelts:          rental.Seatbelts,
+		TowCapacity:        rental.TowCapacity,
+		FreshWaterCapacity: rental.FreshWaterCapacity,
+		Generator:          rental.Generator,
+		EvRange:            rental.EvRange,
+		Amenities:          strings.Join(amenityKeys(rental.Amenities), ","),
+		RatingAverage:      rental.RatingAverage,
+		ReviewCount:        rental.ReviewCount,
+		PrimaryImageUrl:    rental.PrimaryImageUrl,
+		Created:            rental.Created.UnixMilli(),
+		Updated:            rental.Updated.UnixMilli(),
+	}
+}
+
+// Values of the record in the order of the CSV header, empty for NULL
+func (record *rentalRecord) csvValues() []string {
+	return []string{
+		strconv.Itoa(int(record.Id)),
+		strconv.Itoa(int(record.UserId)),
+		csvValue(record.ExternalId, formatString),
+		record.Name,
+		record.Description,
+		record.Type,
+		record.Make,
+		record.Model,
+		strconv.Itoa(int(record.Year)),
+		formatFloat32(record.Length),
+		strconv.Itoa(int(record.Sleeps)),
+		strconv.FormatInt(record.PriceDay, 10),
+		record.City,
+		record.State,
+		record.Zip,
+		record.Country,
+		formatFloat32(record.Lat),
+		formatFloat32(record.Lng),
+		csvValue(record.FuelType, formatString),
+		csvValue(record.Transmission, formatString),
+		csvValue(record.Drivetrain, formatString),
+		csvValue(record.Seatbelts, formatInt32),
+		csvValue(record.TowCapacity, formatInt32),
+		csvValue(record.FreshWaterCapacity, formatInt32),
+		csvValue(record.Generator, strconv.FormatBool),
+		csvValue(record.EvRange, formatInt32),
+		record.Amenities,
+		csvValue(record.RatingAverage, func(value float64) string { return strconv.FormatFloat(value, 'f', -1, 64) }),
+		strconv.Itoa(int(record.ReviewCount)),
+		record.PrimaryImageUrl,
+		time.UnixMilli(record.Created).UTC().Format(time.RFC3339),
+		time.UnixMilli(record.Updated).UTC().Format(time.RFC3339),
+	}
+}
+
+func csvValue[T any](value *T, format func(T) string) string {
+	if value == nil {
+		return ""
+	}
+	return format(*value)
+}
+
+func formatString(value string) string {
+	return value
+}
+
+func formatInt32(value int32) string {
+	return strconv.Itoa(int(value))
+}
+
+func formatFloat32(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', -1, 32)
+}
+
+// A header row then a row per rental
+type csvRentalWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvRentalWriter) Write(rental *Rental) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	record := rental.record()
+	return w.writer.Write(record.csvValues())
+}
+
+func (w *csvRentalWriter) Close() error {
+	// exports without rentals still have a header
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvRentalWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.writer.Write(csvExportHeader)
+}
+
+// Row groups of at most parquetRowGroupBytes, the footer is written on Close
+type parquetRentalWriter struct {
+	writer *writer.ParquetWriter
+}
+
+func (w *parquetRentalWriter) Write(rental *Rental) error {
+	return w.writer.Write(rental.record())
+}
+
+func (w *parquetRentalWriter) Close() error {
+	return w.writer.WriteStop()
+}
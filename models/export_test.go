@@ -0,0 +1,156 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// Test suite for rental exports
+type ExportModelTestSuite struct {
+	suite.Suite
+	config *config.Config
+}
+
+func (suite *ExportModelTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+}
+
+func exportedRental() *Rental {
+	fuel := "diesel"
+	externalId := "fleet-7"
+	rating := 4.5
+	return &Rental{
+		ID: 7, UserId: 2, ExternalId: &externalId, Name: "Westy, the van", Type: "camper-van", Sleeps: 4, Price: 16900,
+		City: "Costa Mesa", State: "CA", Zip: "92627", Country: "US", Lat: 33.64, Lng: -117.93,
+		VehicleMake: "Volkswagen", VehicleModel: "Vanagon", VehicleYear: 1984, VehicleLength: 15.5,
+		VehicleSpecs:  VehicleSpecs{FuelType: &fuel},
+		Amenities:     []Amenity{{Key: "kitchen"}, {Key: "pets"}},
+		RatingAverage: &rating, ReviewCount: 2,
+		Created: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC),
+		Updated: time.Date(2024, time.April, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func (suite *ExportModelTestSuite) TestCsvWriter() {
+	var out bytes.Buffer
+	writer, err := NewRentalWriter(&out, ExportCsv)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Nil(writer.Write(exportedRental()))
+		suite.Nil(writer.Close())
+
+		rows, err := csv.NewReader(&out).ReadAll()
+		if suite.Nil(err, "Should not lead to an error") && suite.Len(rows, 2) {
+			suite.Equal(csvExportHeader, rows[0])
+			values := make(map[string]string)
+			for i, column := range rows[0] {
+				values[column] = rows[1][i]
+			}
+			suite.Equal("fleet-7", values["external_id"])
+			suite.Equal("Westy, the van", values["name"])
+			suite.Equal("15.5", values["length"])
+			suite.Equal("diesel", values["fuel_type"])
+			suite.Equal("", values["transmission"])
+			suite.Equal("kitchen,pets", values["amenities"])
+			suite.Equal("4.5", values["rating_average"])
+			suite.Equal("2024-03-01T12:00:00Z", values["created"])
+		}
+	}
+}
+
+func (suite *ExportModelTestSuite) TestCsvWriterEmpty() {
+	var out bytes.Buffer
+	writer, err := NewRentalWriter(&out, ExportCsv)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Nil(writer.Close())
+		suite.Equal(strings.Join(csvExportHeader, ",")+"\n", out.String())
+	}
+}
+
+func (suite *ExportModelTestSuite) TestParquetWriter() {
+	var out bytes.Buffer
+	writer, err := NewRentalWriter(&out, ExportParquet)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Nil(writer.Write(exportedRental()))
+		suite.Nil(writer.Close())
+
+		file, _ := buffer.NewBufferFile(out.Bytes())
+		parquetReader, err := reader.NewParquetReader(file, new(rentalRecord), 1)
+		if suite.Nil(err, "Should not lead to an error") {
+			defer parquetReader.ReadStop()
+			suite.Equal(int64(1), parquetReader.GetNumRows())
+
+			records := make([]rentalRecord, 1)
+			if suite.Nil(parquetReader.Read(&records), "Should not lead to an error") {
+				suite.Equal(int32(7), records[0].Id)
+				suite.Equal("fleet-7", *records[0].ExternalId)
+				suite.Equal(int64(16900), records[0].PriceDay)
+				suite.Nil(records[0].Transmission)
+				suite.Equal(exportedRental().Created.UnixMilli(), records[0].Created)
+			}
+		}
+	}
+}
+
+func (suite *ExportModelTestSuite) TestNewRentalWriterUnknownFormat() {
+	_, err := NewRentalWriter(new(bytes.Buffer), "xlsx")
+
+	suite.NotNil(err)
+}
+
+func (suite *ExportModelTestSuite) TestStream() {
+	filter, _ := ParseQueryString("sort=price")
+	var prices []int64
+
+	// batches smaller than the fixtures
+	err := filter.Stream(context.Background(), 2, func(rental *Rental) error {
+		prices = append(prices, rental.Price)
+		return nil
+	})
+
+	if suite.Nil(err, "Should not lead to an error") {
+		var count int64
+		db.DB.Model(&Rental{}).Count(&count)
+		suite.Len(prices, int(count))
+		suite.IsNonDecreasing(prices)
+	}
+}
+
+func (suite *ExportModelTestSuite) TestStreamFilter() {
+	filter, _ := ParseQueryString("ids=1,2")
+	var out bytes.Buffer
+	writer, _ := NewRentalWriter(&out, ExportNdjson)
+
+	err := filter.Stream(context.Background(), 500, writer.Write)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if suite.Len(lines, 2) {
+			var rental RentalResponse
+			suite.Nil(json.Unmarshal([]byte(lines[0]), &rental))
+			suite.Equal(uint32(1), rental.ID)
+		}
+	}
+}
+
+func TestExportModelTestSuite(t *testing.T) {
+	suite.Run(t, new(ExportModelTestSuite))
+}
@@ -0,0 +1,395 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/feeds"
+	log "github.com/samuelg/rentals/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Outcomes of a feed sync
+const (
+	FeedApplied = "applied"
+	// same content as the last applied sync, nothing to do
+	FeedUnchanged = "unchanged"
+	// nothing was changed, see the error
+	FeedFailed = "failed"
+)
+
+// Changes made by a feed sync
+const (
+	FeedInserted = "inserted"
+	FeedUpdated  = "updated"
+	FeedRemoved  = "removed"
+	FeedRejected = "rejected"
+)
+
+// Feed model, the listings of a partner fleet synced as rentals of a user
+type Feed struct {
+	// uses serial integer column in the database which will use 32 bits at most
+	ID   uint32 `gorm:"primary_key;autoincrement;column:id" json:"id"`
+	Name string `gorm:"column:name" json:"name"`
+	// owner of the rentals, listings are matched to rentals by external id
+	UserId uint32 `gorm:"column:user_id" json:"user_id"`
+	// http(s) URL or drop directory
+	Source       string        `gorm:"column:source" json:"source"`
+	Format       string        `gorm:"column:format" json:"format"`
+	ListingsPath string        `gorm:"column:listings_path" json:"listings_path"`
+	Mapping      feeds.Mapping `gorm:"column:mapping;serializer:json" json:"mapping"`
+	// hash of the content of the last applied sync
+	ContentHash string     `gorm:"column:content_hash" json:"-"`
+	LastSynced  *time.Time `gorm:"column:last_synced" json:"last_synced"`
+	Created     time.Time  `gorm:"column:created;autoCreateTime" json:"created"`
+}
+
+// A listing of the last applied sync of a feed
+type FeedItem struct {
+	FeedId     uint32 `gorm:"primaryKey;column:feed_id"`
+	ExternalId string `gorm:"primaryKey;column:external_id"`
+	RentalId   uint32 `gorm:"column:rental_id"`
+	// hash of the mapped values of the listing
+	Hash string `gorm:"column:hash"`
+}
+
+// A change made by a feed sync, unchanged listings aren't listed
+type FeedChange struct {
+	// position of the listing in the feed, zero for removals
+	Listing    int    `json:"listing,omitempty"`
+	ExternalId string `json:"external_id"`
+	Action     string `json:"action"`
+	RentalId   uint32 `json:"rental_id,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// FeedSync model, the report of a sync of a feed
+type FeedSync struct {
+	// uses serial integer column in the database which will use 32 bits at most
+	ID     uint32 `gorm:"primary_key;autoincrement;column:id" json:"id"`
+	FeedId uint32 `gorm:"column:feed_id" json:"feed_id"`
+	Status string `gorm:"column:status" json:"status"`
+	// URL or file the content was read from
+	Source      string       `gorm:"column:source" json:"source"`
+	ContentHash string       `gorm:"column:content_hash" json:"content_hash"`
+	Inserted    int          `gorm:"column:inserted" json:"inserted"`
+	Updated     int          `gorm:"column:updated" json:"updated"`
+	Removed     int          `gorm:"column:removed" json:"removed"`
+	Unchanged   int          `gorm:"column:unchanged" json:"unchanged"`
+	Rejected    int          `gorm:"column:rejected" json:"rejected"`
+	Changes     []FeedChange `gorm:"column:changes;serializer:json" json:"changes"`
+	Error       string       `gorm:"column:error" json:"error,omitempty"`
+	Started     time.Time    `gorm:"column:started" json:"started"`
+	Finished    time.Time    `gorm:"column:finished" json:"finished"`
+}
+
+// Summary of the sync of all the feeds
+type FeedsReport struct {
+	Applied   int `json:"applied"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+}
+
+// Check the mapping of a feed: columns must be import columns and the external
+// id is required to match listings between syncs
+func ValidateFeedMapping(mapping feeds.Mapping) error {
+	columns := make([]string, 0, len(mapping))
+	for column := range mapping {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	for _, column := range columns {
+		if _, ok := importColumns[column]; !ok {
+			return fmt.Errorf("unknown column %s", column)
+		}
+		if mapping[column] == "" {
+			return fmt.Errorf("empty path for %s", column)
+		}
+	}
+	if _, ok := mapping["external_id"]; !ok {
+		return errors.New("external_id must be mapped")
+	}
+	return nil
+}
+
+// Find the syncs of a feed, most recent first
+func FindFeedSyncs(feedId uint32, limit uint8, offset uint32) ([]FeedSync, uint32, error) {
+	syncs := make([]FeedSync, 0)
+	var count int64
+
+	query := db.DB.Model(&FeedSync{}).Where("feed_id = ?", feedId)
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := db.DB.Where("feed_id = ?", feedId).
+		Order("started DESC, id DESC").
+		Limit(int(limit)).
+		Offset(int(offset)).
+		Find(&syncs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return syncs, uint32(count), nil
+}
+
+// Sync every feed, failed syncs don't stop the others
+func SyncFeeds(ctx context.Context) (*FeedsReport, error) {
+	report := &FeedsReport{}
+	var feedList []Feed
+	if err := db.DB.WithContext(ctx).Order("id").Find(&feedList).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range feedList {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		sync, err := SyncFeed(ctx, &feedList[i], false)
+		if err != nil {
+			return report, err
+		}
+		switch sync.Status {
+		case FeedApplied:
+			report.Applied++
+		case FeedUnchanged:
+			report.Unchanged++
+		case FeedFailed:
+			report.Failed++
+			log.Log.Warn(fmt.Sprintf("Failed to sync feed %s: %s", feedList[i].Name, sync.Error))
+		}
+	}
+
+	return report, nil
+}
+
+// Fetch the listings of the feed and apply the inserts, updates and removals
+// since the last sync in a single transaction. Invalid listings are rejected
+// without failing the sync. The report is saved and returned, failing to fetch
+// or parse the feed is a failed sync and not an error. Content identical to the
+// last applied sync is skipped unless forced, ex: to restore deleted rentals
+func SyncFeed(ctx context.Context, feed *Feed, force bool) (*FeedSync, error) {
+	sync := &FeedSync{FeedId: feed.ID, Started: time.Now(), Changes: make([]FeedChange, 0)}
+	if err := feed.sync(ctx, sync, force); err != nil {
+		// nothing was applied
+		*sync = FeedSync{
+			FeedId:      feed.ID,
+			Status:      FeedFailed,
+			Source:      sync.Source,
+			ContentHash: sync.ContentHash,
+			Changes:     make([]FeedChange, 0),
+			Error:       err.Error(),
+			Started:     sync.Started,
+		}
+	}
+	sync.Finished = time.Now()
+
+	if err := db.DB.WithContext(ctx).Create(sync).Error; err != nil {
+		return nil, err
+	}
+	return sync, nil
+}
+
+func (feed *Feed) sync(ctx context.Context, sync *FeedSync, force bool) error {
+	content, source, err := feeds.Fetch(ctx, feed.Source, feed.Format)
+	sync.Source = source
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(content)
+	sync.ContentHash = hex.EncodeToString(sum[:])
+
+	now := time.Now()
+	if !force && sync.ContentHash == feed.ContentHash {
+		sync.Status = FeedUnchanged
+		feed.LastSynced = &now
+		return db.DB.WithContext(ctx).Model(feed).Update("last_synced", now).Error
+	}
+
+	listings, err := feeds.Parse(content, feed.Format, feed.ListingsPath)
+	if err != nil {
+		return err
+	}
+	// an empty feed is more likely broken than a fleet with no vehicles
+	if len(listings) == 0 {
+		return fmt.Errorf("%w: no listings, nothing was removed", feeds.ErrInvalidFeed)
+	}
+
+	var removedImages []RentalImage
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// syncs of a feed run one at a time
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&Feed{}, feed.ID).Error; err != nil {
+			return err
+		}
+		var err error
+		removedImages, err = feed.apply(tx, listings, sync)
+		if err != nil {
+			return err
+		}
+		return tx.Model(feed).Updates(map[string]interface{}{"content_hash": sync.ContentHash, "last_synced": now}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	sync.Status = FeedApplied
+	feed.ContentHash = sync.ContentHash
+	feed.LastSynced = &now
+	for i := range removedImages {
+		deleteImageFiles(ctx, &removedImages[i])
+	}
+	return nil
+}
+
+// Diff the listings with the items of the last sync and apply the changes,
+// returns the images of the removed rentals
+func (feed *Feed) apply(tx *gorm.DB, listings []feeds.Listing, sync *FeedSync) ([]RentalImage, error) {
+	var items []FeedItem
+	if err := tx.Where("feed_id = ?", feed.ID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	itemsById := make(map[string]*FeedItem, len(items))
+	for i := range items {
+		itemsById[items[i].ExternalId] = &items[i]
+	}
+
+	seen := make(map[string]bool, len(listings))
+	for i, listing := range listings {
+		change := FeedChange{Listing: i + 1}
+		values, err := feed.Mapping.Values(listing)
+		if err != nil {
+			sync.add(change.reject(err.Error()))
+			continue
+		}
+		change.ExternalId = values["external_id"]
+		if change.ExternalId == "" {
+			sync.add(change.reject("Missing field: external_id"))
+			continue
+		}
+		if seen[change.ExternalId] {
+			sync.add(change.reject("Duplicate external id"))
+			continue
+		}
+		// rejected listings are kept, a listing that turns invalid isn't removed
+		seen[change.ExternalId] = true
+
+		hash := hashFeedValues(values)
+		item, ok := itemsById[change.ExternalId]
+		if ok && item.Hash == hash {
+			sync.Unchanged++
+			continue
+		}
+
+		input, err := feedInput(values)
+		if err != nil {
+			sync.add(change.reject(err.Error()))
+			continue
+		}
+		row := ImportRow{}
+		err = tx.Transaction(func(tx *gorm.DB) error {
+			return importRow(tx, feed.UserId, input, &row)
+		})
+		var rowErr *importRowError
+		if errors.As(err, &rowErr) {
+			sync.add(change.reject(row.Reason))
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("listing %d: %w", change.Listing, err)
+		}
+
+		change.Action, change.RentalId = FeedUpdated, row.RentalId
+		if row.Status == ImportCreated {
+			change.Action = FeedInserted
+		}
+		err = tx.Clauses(clause.OnConflict{UpdateAll: true}).
+			Create(&FeedItem{FeedId: feed.ID, ExternalId: change.ExternalId, RentalId: row.RentalId, Hash: hash}).Error
+		if err != nil {
+			return nil, err
+		}
+		sync.add(change)
+	}
+
+	// removals in a stable order for the report
+	removedIds := make([]string, 0)
+	for externalId := range itemsById {
+		if !seen[externalId] {
+			removedIds = append(removedIds, externalId)
+		}
+	}
+	sort.Strings(removedIds)
+
+	var removedImages []RentalImage
+	for _, externalId := range removedIds {
+		item := itemsById[externalId]
+		rental := Rental{ID: item.RentalId}
+		rentalImages, err := deleteRental(tx, &rental)
+		if err != nil {
+			return nil, err
+		}
+		// the item is removed along with the rental
+		removedImages = append(removedImages, rentalImages...)
+		sync.add(FeedChange{ExternalId: externalId, Action: FeedRemoved, RentalId: item.RentalId})
+	}
+
+	return removedImages, nil
+}
+
+func (change FeedChange) reject(reason string) FeedChange {
+	change.Action, change.Reason = FeedRejected, reason
+	return change
+}
+
+func (sync *FeedSync) add(change FeedChange) {
+	switch change.Action {
+	case FeedInserted:
+		sync.Inserted++
+	case FeedUpdated:
+		sync.Updated++
+	case FeedRemoved:
+		sync.Removed++
+	case FeedRejected:
+		sync.Rejected++
+	}
+	sync.Changes = append(sync.Changes, change)
+}
+
+// Input of the mapped values of a listing, parsed as CSV cells
+func feedInput(values map[string]string) (*RentalInput, error) {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	// the first invalid column in a stable order
+	sort.Strings(columns)
+
+	var input RentalInput
+	for _, column := range columns {
+		value := values[column]
+		setter, ok := importColumns[column]
+		if !ok {
+			return nil, fmt.Errorf("Unknown column: %s", column)
+		}
+		if err := setter(&input, value); err != nil {
+			return nil, fmt.Errorf("Invalid %s: %s", column, value)
+		}
+	}
+	return &input, nil
+}
+
+// Changes whenever a mapped value changes, keys are sorted when marshaled
+func hashFeedValues(values map[string]string) string {
+	content, _ := json.Marshal(values)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
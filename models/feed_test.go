@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/feeds"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for partner feeds, feeds are read from a temporary drop directory
+type FeedModelTestSuite struct {
+	suite.Suite
+	config       *config.Config
+	lastRentalId uint32
+}
+
+func (suite *FeedModelTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.lastRentalId = maxRentalId()
+}
+
+func (suite *FeedModelTestSuite) TearDownSuite() {
+	db.DB.Where("id > ?", suite.lastRentalId).Delete(&Rental{})
+}
+
+var feedMapping = feeds.Mapping{
+	"external_id": "id",
+	"name":        "title",
+	"type":        "kind",
+	"sleeps":      "berths",
+	"price_day":   "rate",
+	"lat":         "position.lat",
+	"lng":         "position.lng",
+}
+
+// Feed owned by user 2 reading from a new drop directory
+func (suite *FeedModelTestSuite) createFeed() (*Feed, string) {
+	dir := suite.T().TempDir()
+	feed := &Feed{Name: uniqueExternalId("feed"), UserId: 2, Source: dir, Format: feeds.FormatJSON, ListingsPath: "vehicles", Mapping: feedMapping}
+	suite.Require().Nil(db.DB.Create(feed).Error)
+	return feed, dir
+}
+
+// Listing ids of the content (v-1, v-2...) are made unique to the test run
+func (suite *FeedModelTestSuite) writeFeed(dir string, prefix string, content string) {
+	content = strings.ReplaceAll(content, `"v-`, `"`+prefix+"-")
+	suite.Require().Nil(os.WriteFile(filepath.Join(dir, "fleet.json"), []byte(content), 0o644))
+}
+
+// Action of every change of the sync
+func actions(sync *FeedSync) []string {
+	result := make([]string, len(sync.Changes))
+	for i, change := range sync.Changes {
+		result[i] = change.Action
+	}
+	return result
+}
+
+func (suite *FeedModelTestSuite) TestValidateFeedMapping() {
+	suite.Nil(ValidateFeedMapping(feedMapping))
+	suite.EqualError(ValidateFeedMapping(feeds.Mapping{"external_id": "id", "colour": "paint"}), "unknown column colour")
+	suite.EqualError(ValidateFeedMapping(feeds.Mapping{"external_id": ""}), "empty path for external_id")
+	suite.EqualError(ValidateFeedMapping(feeds.Mapping{"name": "title"}), "external_id must be mapped")
+}
+
+func (suite *FeedModelTestSuite) TestSyncFeed() {
+	feed, dir := suite.createFeed()
+	ctx := context.Background()
+	prefix := uniqueExternalId("v")
+	suite.writeFeed(dir, prefix, `{"vehicles": [
+		{"id": "v-1", "title": "Westy", "kind": "camper-van", "berths": 4, "rate": 16900, "position": {"lat": 33.64, "lng": -117.93}},
+		{"id": "v-2", "title": "Eurovan", "kind": "camper-van", "berths": 2, "rate": 12000, "position": {"lat": 45.52, "lng": -122.68}},
+		{"id": "v-3", "title": "Trailer", "kind": "travel-trailer", "berths": 4, "rate": 9000, "position": {"lat": 45.52, "lng": -122.68}}
+	]}`)
+
+	sync, err := SyncFeed(ctx, feed, false)
+	if !suite.Nil(err, "Should not lead to an error") || !suite.Equal(FeedApplied, sync.Status, sync.Error) {
+		return
+	}
+	suite.Equal(3, sync.Inserted)
+	suite.Equal(filepath.Join(dir, "fleet.json"), sync.Source)
+	removedRentalId := sync.Changes[2].RentalId
+
+	// same content
+	sync, err = SyncFeed(ctx, feed, false)
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal(FeedUnchanged, sync.Status)
+	}
+
+	// v-1 unchanged, v-2 updated, v-3 removed, v-4 inserted and an invalid listing
+	suite.writeFeed(dir, prefix, `{"vehicles": [
+		{"id": "v-1", "title": "Westy", "kind": "camper-van", "berths": 4, "rate": 16900, "position": {"lat": 33.64, "lng": -117.93}},
+		{"id": "v-2", "title": "Eurovan", "kind": "camper-van", "berths": 2, "rate": 13500, "position": {"lat": 45.52, "lng": -122.68}},
+		{"id": "v-4", "title": "Sprinter", "kind": "camper-van", "berths": 2, "rate": 21000, "position": {"lat": 45.52, "lng": -122.68}},
+		{"id": "v-5", "title": "No price", "kind": "camper-van", "berths": 2, "position": {"lat": 45.52, "lng": -122.68}}
+	]}`)
+
+	sync, err = SyncFeed(ctx, feed, false)
+	if suite.Nil(err, "Should not lead to an error") && suite.Equal(FeedApplied, sync.Status, sync.Error) {
+		suite.Equal([]string{FeedUpdated, FeedInserted, FeedRejected, FeedRemoved}, actions(sync))
+		suite.Equal(1, sync.Unchanged)
+		suite.Equal("Missing field: price.day", sync.Changes[2].Reason)
+		suite.Equal(4, sync.Changes[2].Listing)
+		suite.Equal(prefix+"-3", sync.Changes[3].ExternalId)
+
+		var rental Rental
+		if suite.Nil(db.DB.First(&rental, sync.Changes[0].RentalId).Error, "Should not lead to an error") {
+			suite.Equal(int64(13500), rental.Price)
+			suite.Equal(uint32(2), rental.UserId)
+		}
+		var count int64
+		db.DB.Model(&Rental{}).Where("id = ?", removedRentalId).Count(&count)
+		suite.Zero(count)
+	}
+
+	syncs, count, err := FindFeedSyncs(feed.ID, 10, 0)
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal(uint32(3), count)
+		suite.Equal(FeedApplied, syncs[0].Status)
+		suite.Len(syncs[0].Changes, 4)
+	}
+}
+
+func (suite *FeedModelTestSuite) TestSyncFeedFailed() {
+	feed, dir := suite.createFeed()
+	suite.writeFeed(dir, "", `{"vehicles": []}`)
+
+	sync, err := SyncFeed(context.Background(), feed, false)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal(FeedFailed, sync.Status)
+		suite.Contains(sync.Error, "no listings")
+		suite.NotZero(sync.ID)
+	}
+}
+
+func TestFeedModelTestSuite(t *testing.T) {
+	suite.Run(t, new(FeedModelTestSuite))
+}
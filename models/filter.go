@@ -1,29 +1,77 @@
 package models
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"golang.org/x/exp/slices"
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/samuelg/rentals/config"
 	"github.com/samuelg/rentals/db"
 	log "github.com/samuelg/rentals/logging"
+	"gorm.io/gorm"
 )
 
-// Represents a filter on a list of rentals
+const (
+	// distance used by the near filter
+	nearRadiusMiles = 100
+	metersPerMile   = 1609.34
+	// in a degree of latitude, the shortest so the band is never too narrow
+	milesPerDegree = 68.7
+)
+
+// Rentals within a distance of a point, uses the GIST index on the location column
+const nearCondition = "ST_DWITHIN(location, ST_SETSRID(ST_MAKEPOINT(?, ?), 4326)::geography, ?)"
+
+// Rentals within a distance of a point in sqlite, distance_miles is computed in
+// Go (see db.Init) so rentals outside the latitude band are skipped first
+const sqliteNearCondition = "lat BETWEEN ? AND ? AND distance_miles(lat, lng, ?, ?) <= ?"
+
+// Represents a filter on a list of rentals, serialized with the names of the
+// query params when saving searches
 type Filter struct {
 	// All query params are optional
-	PriceMin *int64
-	PriceMax *int64
-	Limit    uint8 // don't allow a large limit value
-	Offset   uint32
-	Ids      []uint32
-	Near     []float32
-	Sort     string
+	PriceMin *int64    `json:"price_min,omitempty"`
+	PriceMax *int64    `json:"price_max,omitempty"`
+	Limit    uint8     `json:"limit,omitempty"` // don't allow a large limit value
+	Offset   uint32    `json:"offset,omitempty"`
+	Ids      []uint32  `json:"ids,omitempty"`
+	Near     []float32 `json:"near,omitempty"`
+	Sort     string    `json:"sort,omitempty"`
+	// minimum number of people the rental sleeps
+	SleepsMin *int32 `json:"sleeps_min,omitempty"`
+	// amenity keys, rentals must have all of them unless AmenitiesMatch is "any"
+	Amenities      []string `json:"amenities,omitempty"`
+	AmenitiesMatch string   `json:"amenities_match,omitempty"`
+	// vehicle specifications, enums match any of the values and ranges are
+	// inclusive like the price range
+	FuelTypes             []string `json:"fuel_type,omitempty"`
+	Transmissions         []string `json:"transmission,omitempty"`
+	Drivetrains           []string `json:"drivetrain,omitempty"`
+	Makes                 []string `json:"make,omitempty"` // canonical makes, or as entered when missing from the catalog
+	SeatbeltsMin          *int32   `json:"seatbelts_min,omitempty"`
+	SeatbeltsMax          *int32   `json:"seatbelts_max,omitempty"`
+	TowCapacityMin        *int32   `json:"tow_capacity_min,omitempty"`
+	TowCapacityMax        *int32   `json:"tow_capacity_max,omitempty"`
+	FreshWaterCapacityMin *int32   `json:"fresh_water_capacity_min,omitempty"`
+	FreshWaterCapacityMax *int32   `json:"fresh_water_capacity_max,omitempty"`
+	EvRangeMin            *int32   `json:"ev_range_min,omitempty"`
+	EvRangeMax            *int32   `json:"ev_range_max,omitempty"`
+	Generator             *bool    `json:"generator,omitempty"`
+	// minimum average rating, rentals without reviews never match
+	RatingMin *float64 `json:"rating_min,omitempty"`
+	// creation window used when evaluating saved searches, not a query param
+	CreatedAfter  *time.Time `json:"-"`
+	CreatedBefore *time.Time `json:"-"`
+	// match rentals as they were at this time, not kept in saved searches
+	AsOf *time.Time `json:"-"`
 }
 
 // Parse a gin query into a rentals filter
@@ -54,39 +102,9 @@ func ParseQuery(c *gin.Context) (*Filter, error) {
 		}
 	}
 
-	limitRaw := c.Query("limit")
-	if limitRaw != "" {
-		limit, err := strconv.ParseInt(limitRaw, 10, 8)
-		if err != nil {
-			log.Log.Trace(fmt.Sprintf("Invalid limit: %s", limitRaw))
-			validationErrors = append(validationErrors, "Invalid limit")
-		} else {
-			// don't allow a large value for limit
-			if limit > 100 {
-				log.Log.Trace(fmt.Sprintf("Limit is too large: %d", limit))
-				validationErrors = append(validationErrors, "Limit is too large")
-			} else {
-				filter.Limit = uint8(limit)
-			}
-		}
-	}
-	// use default limit if not provided (offset will default to 0)
-	if filter.Limit == 0 {
-		filter.Limit = config.GetConfig().DefaultApiLimit
-	}
+	filter.Limit, filter.Offset = parsePagination(c, &validationErrors)
 
-	offsetRaw := c.Query("offset")
-	if offsetRaw != "" {
-		offset, err := strconv.ParseInt(offsetRaw, 10, 32)
-		if err != nil {
-			log.Log.Trace(fmt.Sprintf("Invalid offset: %s", offsetRaw))
-			validationErrors = append(validationErrors, "Invalid offset")
-		} else {
-			filter.Offset = uint32(offset)
-		}
-	}
-
-	validSorts := []string{"", "name", "type", "sleeps", "price", "city", "state", "country", "make", "model", "year", "length", "created", "updated"}
+	validSorts := []string{"", "name", "type", "sleeps", "price", "city", "state", "country", "make", "model", "year", "length", "created", "updated", "rating"}
 	sort := c.Query("sort")
 	if !slices.Contains(validSorts, sort) {
 		validationErrors = append(validationErrors, "Invalid sort")
@@ -149,6 +167,46 @@ func ParseQuery(c *gin.Context) (*Filter, error) {
 		}
 	}
 
+	filter.SleepsMin = parseInt32Query(c, "sleeps_min", &validationErrors)
+
+	amenitiesRaw := c.Query("amenities")
+	// parse csv amenity keys
+	if amenitiesRaw != "" {
+		for _, key := range strings.Split(amenitiesRaw, ",") {
+			if !ValidAmenityKey(key) {
+				log.Log.Trace(fmt.Sprintf("Invalid amenity: %s", key))
+				validationErrors = append(validationErrors, "Invalid amenity in amenities")
+				break
+			}
+			// duplicates would break the all-of match which counts amenities
+			if !slices.Contains(filter.Amenities, key) {
+				filter.Amenities = append(filter.Amenities, key)
+			}
+		}
+	}
+
+	amenitiesMatch := c.DefaultQuery("amenities_match", "all")
+	if amenitiesMatch != "all" && amenitiesMatch != "any" {
+		validationErrors = append(validationErrors, "Invalid amenities_match")
+	} else {
+		filter.AmenitiesMatch = amenitiesMatch
+	}
+
+	ratingMinRaw := c.Query("rating_min")
+	if ratingMinRaw != "" {
+		rating, err := strconv.ParseFloat(ratingMinRaw, 64)
+		if err != nil || rating < 1 || rating > 5 {
+			log.Log.Trace(fmt.Sprintf("Invalid rating_min: %s", ratingMinRaw))
+			validationErrors = append(validationErrors, "Invalid rating_min")
+		} else {
+			filter.RatingMin = &rating
+		}
+	}
+
+	validationErrors = append(validationErrors, filter.parseVehicleQuery(c)...)
+
+	filter.AsOf = parseAsOf(c, &validationErrors)
+
 	if len(validationErrors) > 0 {
 		return nil, errors.New(strings.Join(validationErrors, "\n"))
 	}
@@ -156,8 +214,21 @@ func ParseQuery(c *gin.Context) (*Filter, error) {
 	return filter, nil
 }
 
-// Find rentals using the provided filter
-func (filter *Filter) Find() ([]Rental, uint32, error) {
+// Parse a url query (ex: "price_min=9000&sort=price") into a rentals filter,
+// used outside of requests
+func ParseQueryString(query string) (*Filter, error) {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &gin.Context{Request: &http.Request{URL: &url.URL{RawQuery: values.Encode()}}}
+	return ParseQuery(c)
+}
+
+// Find rentals using the provided filter, both queries are cancelled with the
+// context (ex: the client of a request disconnects)
+func (filter *Filter) Find(ctx context.Context) ([]Rental, uint32, error) {
 	var rentals []Rental
 	// we will return a uint32 as the serial id column cannot go above this
 	var count int64
@@ -173,45 +244,9 @@ func (filter *Filter) Find() ([]Rental, uint32, error) {
 	var countErr error
 
 	// Default query
-	query := db.DB.Joins("User")
+	query := RentalsAsOf(ctx, filter.AsOf).Joins("User").Preload("Amenities").Preload("Images", OrderImages).Scopes(filter.Conditions)
 	// Count query
-	countQuery := db.DB.Model(&Rental{})
-
-	// Minimum price
-	if filter.PriceMin != nil {
-		query = query.Where("price_per_day >= ?", *filter.PriceMin)
-		countQuery = countQuery.Where("price_per_day >= ?", *filter.PriceMin)
-	}
-
-	// Maximum price
-	if filter.PriceMax != nil {
-		query = query.Where("price_per_day <= ?", *filter.PriceMax)
-		countQuery = countQuery.Where("price_per_day <= ?", *filter.PriceMax)
-	}
-
-	// IDs
-	if len(filter.Ids) != 0 {
-		// IN clause
-		query = query.Where(filter.Ids)
-		countQuery = countQuery.Where(filter.Ids)
-	}
-
-	// Near
-	if len(filter.Near) == 2 {
-		lat := filter.Near[0]
-		lng := filter.Near[1]
-		// Use geography to calculate in meters, then convert from miles
-		query = query.Where(
-			"ST_DWITHIN(ST_SETSRID(st_makepoint(lng, lat), 4326)::geography, st_setsrid(st_makepoint(?, ?), 4326)::geography, 100 * 1609.34)",
-			lng,
-			lat,
-		)
-		countQuery = countQuery.Where(
-			"ST_DWITHIN(ST_SETSRID(st_makepoint(lng, lat), 4326)::geography, st_setsrid(st_makepoint(?, ?), 4326)::geography, 100 * 1609.34)",
-			lng,
-			lat,
-		)
-	}
+	countQuery := RentalsAsOf(ctx, filter.AsOf).Model(&Rental{}).Scopes(filter.Conditions)
 
 	// Limit sort to known values
 	sort := getSort(filter)
@@ -245,40 +280,256 @@ func (filter *Filter) Find() ([]Rental, uint32, error) {
 	return rentals, uint32(count), nil
 }
 
+// Apply the filter conditions to a rentals query, pagination and sort are left
+// to the caller. Used as a gorm scope
+func (filter *Filter) Conditions(tx *gorm.DB) *gorm.DB {
+	// Minimum price
+	if filter.PriceMin != nil {
+		tx = tx.Where("price_per_day >= ?", *filter.PriceMin)
+	}
+
+	// Maximum price
+	if filter.PriceMax != nil {
+		tx = tx.Where("price_per_day <= ?", *filter.PriceMax)
+	}
+
+	// IDs
+	if len(filter.Ids) != 0 {
+		// IN clause
+		tx = tx.Where(filter.Ids)
+	}
+
+	// Near
+	if len(filter.Near) == 2 {
+		lat := filter.Near[0]
+		lng := filter.Near[1]
+		if db.IsSQLite() {
+			band := float32(nearRadiusMiles / milesPerDegree)
+			tx = tx.Where(sqliteNearCondition, lat-band, lat+band, lat, lng, nearRadiusMiles)
+		} else {
+			// Use the indexed geography column to calculate in meters
+			tx = tx.Where(nearCondition, lng, lat, nearRadiusMiles*metersPerMile)
+		}
+	}
+
+	// Sleeps
+	if filter.SleepsMin != nil {
+		tx = tx.Where("sleeps >= ?", *filter.SleepsMin)
+	}
+
+	// Creation window
+	if filter.CreatedAfter != nil {
+		tx = tx.Where("rentals.created > ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		tx = tx.Where("rentals.created <= ?", *filter.CreatedBefore)
+	}
+
+	// Minimum rating
+	if filter.RatingMin != nil {
+		tx = tx.Where("rating_average >= ?", *filter.RatingMin)
+	}
+
+	// Vehicle specifications
+	for _, condition := range filter.vehicleConditions() {
+		tx = tx.Where(condition.query, condition.args...)
+	}
+
+	// Amenities
+	if len(filter.Amenities) != 0 {
+		rentalIds := db.DB.Table("rental_amenities").
+			Select("rental_amenities.rental_id").
+			Joins("JOIN amenities ON amenities.id = rental_amenities.amenity_id").
+			Where("amenities.key IN ?", filter.Amenities).
+			Group("rental_amenities.rental_id")
+		// all-of matches need every amenity, keys are unique after parsing
+		if filter.AmenitiesMatch != "any" {
+			rentalIds = rentalIds.Having("COUNT(DISTINCT amenities.key) = ?", len(filter.Amenities))
+		}
+		tx = tx.Where("rentals.id IN (?)", rentalIds)
+	}
+
+	return tx
+}
+
 // Returns the sort given a filter
 func getSort(filter *Filter) string {
 	var sort string
 	switch filter.Sort {
 	case "name":
-		sort = "name"
+		sort = "rentals.name"
 	case "type":
-		sort = "type"
+		sort = "rentals.type"
 	case "sleeps":
-		sort = "sleeps"
+		sort = "rentals.sleeps"
 	case "price":
-		sort = "price_per_day"
+		sort = "rentals.price_per_day"
 	case "city":
-		sort = "home_city"
+		sort = "rentals.home_city"
 	case "state":
-		sort = "home_state"
+		sort = "rentals.home_state"
 	case "country":
-		sort = "home_country"
+		sort = "rentals.home_country"
 	case "make":
-		sort = "vehicle_make"
+		sort = "rentals.vehicle_make"
 	case "model":
-		sort = "vehicle_model"
+		sort = "rentals.vehicle_model"
 	case "year":
-		sort = "vehicle_year"
+		sort = "rentals.vehicle_year"
 	case "length":
-		sort = "vehicle_length"
+		sort = "rentals.vehicle_length"
 	case "created":
-		sort = "created"
+		sort = "rentals.created"
 	case "updated":
-		sort = "updated"
+		sort = "rentals.updated"
+	case "rating":
+		// best rated first, then the most reviewed
+		sort = "rentals.rating_average DESC NULLS LAST, rentals.review_count DESC"
 	default:
 		// Default sort
-		sort = "id"
+		return "rentals.id"
+	}
+
+	// ids break ties so pages are stable, like the in memory repository
+	return sort + ", rentals.id"
+}
+
+// Parse the limit and offset query params used by all list endpoints
+func ParsePagination(c *gin.Context) (uint8, uint32, error) {
+	validationErrors := make([]string, 0)
+	limit, offset := parsePagination(c, &validationErrors)
+	if len(validationErrors) > 0 {
+		return 0, 0, errors.New(strings.Join(validationErrors, "\n"))
+	}
+
+	return limit, offset, nil
+}
+
+// Parse the as_of query param, nil when not set
+func ParseAsOf(c *gin.Context) (*time.Time, error) {
+	validationErrors := make([]string, 0)
+	asOf := parseAsOf(c, &validationErrors)
+	if len(validationErrors) > 0 {
+		return nil, errors.New(strings.Join(validationErrors, "\n"))
+	}
+
+	return asOf, nil
+}
+
+func parseAsOf(c *gin.Context, validationErrors *[]string) *time.Time {
+	asOfRaw := c.Query("as_of")
+	if asOfRaw == "" {
+		return nil
+	}
+
+	asOf, err := time.Parse(time.RFC3339, asOfRaw)
+	if err != nil {
+		log.Log.Trace(fmt.Sprintf("Invalid as_of: %s", asOfRaw))
+		*validationErrors = append(*validationErrors, "Invalid as_of, expected an RFC 3339 timestamp")
+		return nil
+	}
+
+	return &asOf
+}
+
+// Query rentals as they were at a time, or as they are now when asOf is nil.
+// Past versions are read from the rental_versions table aliased as rentals so
+// the filter conditions apply unchanged, amenities and images are not versioned
+func RentalsAsOf(ctx context.Context, asOf *time.Time) *gorm.DB {
+	return rentalsAsOf(db.DB.WithContext(ctx), asOf)
+}
+
+func rentalsAsOf(tx *gorm.DB, asOf *time.Time) *gorm.DB {
+	if asOf == nil {
+		return tx
+	}
+
+	versions := tx.Table("rental_versions").
+		Where("valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", *asOf, *asOf)
+	return tx.Table("(?) AS rentals", versions)
+}
+
+func parsePagination(c *gin.Context, validationErrors *[]string) (uint8, uint32) {
+	var limitValue uint8
+	var offsetValue uint32
+
+	limitRaw := c.Query("limit")
+	if limitRaw != "" {
+		limit, err := strconv.ParseInt(limitRaw, 10, 8)
+		if err != nil {
+			log.Log.Trace(fmt.Sprintf("Invalid limit: %s", limitRaw))
+			*validationErrors = append(*validationErrors, "Invalid limit")
+		} else {
+			// don't allow a large value for limit
+			if limit > 100 {
+				log.Log.Trace(fmt.Sprintf("Limit is too large: %d", limit))
+				*validationErrors = append(*validationErrors, "Limit is too large")
+			} else {
+				limitValue = uint8(limit)
+			}
+		}
+	}
+	// use default limit if not provided (offset will default to 0)
+	if limitValue == 0 {
+		limitValue = config.GetConfig().DefaultApiLimit
+	}
+
+	offsetRaw := c.Query("offset")
+	if offsetRaw != "" {
+		offset, err := strconv.ParseInt(offsetRaw, 10, 32)
+		if err != nil {
+			log.Log.Trace(fmt.Sprintf("Invalid offset: %s", offsetRaw))
+			*validationErrors = append(*validationErrors, "Invalid offset")
+		} else {
+			offsetValue = uint32(offset)
+		}
+	}
+
+	return limitValue, offsetValue
+}
+
+// SQL condition with its arguments
+type condition struct {
+	query string
+	args  []interface{}
+}
+
+// Parse an optional integer query param
+func parseInt32Query(c *gin.Context, name string, validationErrors *[]string) *int32 {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil
+	}
+
+	value, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil {
+		log.Log.Trace(fmt.Sprintf("Invalid %s: %s", name, raw))
+		*validationErrors = append(*validationErrors, fmt.Sprintf("Invalid %s", name))
+		return nil
+	}
+
+	result := int32(value)
+	return &result
+}
+
+// Parse an optional csv query param where each value must be one of the valid values
+func parseEnumQuery(c *gin.Context, name string, valid []string, validationErrors *[]string) []string {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil
+	}
+
+	values := make([]string, 0)
+	for _, value := range strings.Split(raw, ",") {
+		if !slices.Contains(valid, value) {
+			log.Log.Trace(fmt.Sprintf("Invalid %s: %s", name, value))
+			*validationErrors = append(*validationErrors, fmt.Sprintf("Invalid %s", name))
+			return nil
+		}
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
 	}
 
-	return sort
+	return values
 }
@@ -1,16 +1,22 @@
 package models
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/samuelg/rentals/config"
 	"github.com/samuelg/rentals/db"
 	log "github.com/samuelg/rentals/logging"
 	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
 )
 
 // Test suite for the Rental controller
@@ -23,6 +29,7 @@ func (suite *FilterModelTestSuite) SetupSuite() {
 	config.Init("test")
 	log.Init("FATAL", config.GetConfig().AppVersion)
 	db.Init()
+	db.Prepare()
 	suite.config = config.GetConfig()
 }
 
@@ -255,6 +262,196 @@ func (suite *FilterModelTestSuite) TestParseQueryLongitudeTooSmall() {
 	}
 }
 
+func (suite *FilterModelTestSuite) TestParseQueryAmenities() {
+	q := url.Values{}
+	q.Set("amenities", "kitchen,pets,kitchen")
+	q.Set("amenities_match", "any")
+	c := mockQuery(q)
+
+	filter, err := ParseQuery(c)
+
+	if suite.Nil(err, "Should not result in an error") {
+		// duplicates are removed
+		suite.Equal([]string{"kitchen", "pets"}, filter.Amenities)
+		suite.Equal("any", filter.AmenitiesMatch)
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryAmenitiesDefaultMatch() {
+	q := url.Values{}
+	q.Set("amenities", "kitchen")
+	c := mockQuery(q)
+
+	filter, err := ParseQuery(c)
+
+	if suite.Nil(err, "Should not result in an error") {
+		suite.Equal("all", filter.AmenitiesMatch)
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryInvalidAmenities() {
+	q := url.Values{}
+	q.Set("amenities", "kitchen,Hot Tub")
+	c := mockQuery(q)
+
+	_, err := ParseQuery(c)
+
+	if suite.NotNil(err, "Should result in an error") {
+		suite.Equal("Invalid amenity in amenities", err.Error())
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryInvalidAmenitiesMatch() {
+	q := url.Values{}
+	q.Set("amenities_match", "some")
+	c := mockQuery(q)
+
+	_, err := ParseQuery(c)
+
+	if suite.NotNil(err, "Should result in an error") {
+		suite.Equal("Invalid amenities_match", err.Error())
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryVehicleSpecs() {
+	q := url.Values{}
+	q.Set("fuel_type", "diesel,gasoline")
+	q.Set("transmission", "automatic")
+	q.Set("drivetrain", "4wd")
+	q.Set("seatbelts_min", "2")
+	q.Set("seatbelts_max", "5")
+	q.Set("tow_capacity_min", "3500")
+	q.Set("fresh_water_capacity_max", "30")
+	q.Set("ev_range_min", "100")
+	q.Set("generator", "true")
+	c := mockQuery(q)
+
+	filter, err := ParseQuery(c)
+
+	if suite.Nil(err, "Should not result in an error") {
+		suite.Equal([]string{"diesel", "gasoline"}, filter.FuelTypes)
+		suite.Equal([]string{"automatic"}, filter.Transmissions)
+		suite.Equal([]string{"4wd"}, filter.Drivetrains)
+		suite.Equal(int32(2), *filter.SeatbeltsMin)
+		suite.Equal(int32(5), *filter.SeatbeltsMax)
+		suite.Equal(int32(3500), *filter.TowCapacityMin)
+		suite.Nil(filter.TowCapacityMax)
+		suite.Equal(int32(30), *filter.FreshWaterCapacityMax)
+		suite.Equal(int32(100), *filter.EvRangeMin)
+		suite.True(*filter.Generator)
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryMakes() {
+	q := url.Values{}
+	q.Set("make", "vw, toyota,Airstream Inc")
+	c := mockQuery(q)
+
+	filter, err := ParseQuery(c)
+
+	if suite.Nil(err, "Should not result in an error") {
+		suite.Equal([]string{"Volkswagen", "Toyota", "Airstream Inc"}, filter.Makes)
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryInvalidMake() {
+	q := url.Values{}
+	q.Set("make", "vw,,toyota")
+	c := mockQuery(q)
+
+	_, err := ParseQuery(c)
+
+	if suite.NotNil(err, "Should result in an error") {
+		suite.Equal("Invalid make", err.Error())
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryInvalidFuelType() {
+	q := url.Values{}
+	q.Set("fuel_type", "diesel,coal")
+	c := mockQuery(q)
+
+	_, err := ParseQuery(c)
+
+	if suite.NotNil(err, "Should result in an error") {
+		suite.Equal("Invalid fuel_type", err.Error())
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryInvalidSeatbeltsMin() {
+	q := url.Values{}
+	q.Set("seatbelts_min", "abc")
+	c := mockQuery(q)
+
+	_, err := ParseQuery(c)
+
+	if suite.NotNil(err, "Should result in an error") {
+		suite.Equal("Invalid seatbelts_min", err.Error())
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryInvalidGenerator() {
+	q := url.Values{}
+	q.Set("generator", "maybe")
+	c := mockQuery(q)
+
+	_, err := ParseQuery(c)
+
+	if suite.NotNil(err, "Should result in an error") {
+		suite.Equal("Invalid generator", err.Error())
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryRatingMin() {
+	q := url.Values{}
+	q.Set("rating_min", "4.5")
+	q.Set("sort", "rating")
+	c := mockQuery(q)
+
+	filter, err := ParseQuery(c)
+
+	if suite.Nil(err, "Should not result in an error") {
+		suite.Equal(4.5, *filter.RatingMin)
+		suite.Equal("rating", filter.Sort)
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryInvalidRatingMin() {
+	q := url.Values{}
+	q.Set("rating_min", "6")
+	c := mockQuery(q)
+
+	_, err := ParseQuery(c)
+
+	if suite.NotNil(err, "Should result in an error") {
+		suite.Equal("Invalid rating_min", err.Error())
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryAsOf() {
+	q := url.Values{}
+	q.Set("as_of", "2024-05-01T00:00:00Z")
+	c := mockQuery(q)
+
+	filter, err := ParseQuery(c)
+
+	if suite.Nil(err, "Should not result in an error") {
+		suite.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), *filter.AsOf)
+	}
+}
+
+func (suite *FilterModelTestSuite) TestParseQueryInvalidAsOf() {
+	q := url.Values{}
+	q.Set("as_of", "2024-05-01")
+	c := mockQuery(q)
+
+	_, err := ParseQuery(c)
+
+	if suite.NotNil(err, "Should result in an error") {
+		suite.Equal("Invalid as_of, expected an RFC 3339 timestamp", err.Error())
+	}
+}
+
 // filter.Find tests
 func (suite *FilterModelTestSuite) TestFindSuccessAllFilters() {
 	priceMin := int64(9000)
@@ -272,7 +469,7 @@ func (suite *FilterModelTestSuite) TestFindSuccessAllFilters() {
 		Sort: "price",
 	}
 
-	rentals, count, err := filter.Find()
+	rentals, count, err := filter.Find(context.Background())
 
 	if suite.Nil(err, "Should not lead to an error") {
 		suite.Equal(uint32(2), count)
@@ -287,7 +484,7 @@ func (suite *FilterModelTestSuite) TestFindSuccessNoFilters() {
 	// ParseQuery will set these by default for tests due to default test limit
 	filter := &Filter{Limit: 1, Offset: 0, Sort: "id"}
 
-	rentals, count, err := filter.Find()
+	rentals, count, err := filter.Find(context.Background())
 
 	if suite.Nil(err, "Should not lead to an error") {
 		suite.Equal(uint32(30), count)
@@ -298,6 +495,184 @@ func (suite *FilterModelTestSuite) TestFindSuccessNoFilters() {
 	}
 }
 
+func (suite *FilterModelTestSuite) TestFindSuccessAmenities() {
+	filter := &Filter{Limit: 10, Offset: 0, Amenities: []string{"kitchen", "pets"}, AmenitiesMatch: "all"}
+
+	rentals, count, err := filter.Find(context.Background())
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal(uint32(1), count)
+		suite.Equal(uint32(2), rentals[0].ID)
+		suite.Equal(2, len(rentals[0].Amenities))
+	}
+}
+
+func (suite *FilterModelTestSuite) TestFindSuccessVehicleSpecs() {
+	seatbeltsMin := int32(5)
+	filter := &Filter{Limit: 10, Offset: 0, FuelTypes: []string{"gasoline"}, SeatbeltsMin: &seatbeltsMin}
+
+	rentals, count, err := filter.Find(context.Background())
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal(uint32(3), count)
+		suite.Equal(uint32(9), rentals[0].ID)
+		suite.Equal("awd", *rentals[0].Drivetrain)
+	}
+}
+
+func (suite *FilterModelTestSuite) TestFindSuccessMakes() {
+	filter := &Filter{Limit: 10, Offset: 0, Makes: []string{"Ford"}, Sort: "make"}
+
+	rentals, _, err := filter.Find(context.Background())
+
+	if suite.Nil(err, "Should not lead to an error") && suite.NotEmpty(rentals) {
+		for _, rental := range rentals {
+			suite.Equal("Ford", rental.VehicleMake)
+		}
+	}
+}
+
+func (suite *FilterModelTestSuite) TestFindSuccessUnknownMake() {
+	// Peugeot is missing from the catalog, the make is kept as entered
+	filter := &Filter{Limit: 10, Offset: 0, Makes: []string{"peugeot", "Nissan"}, Sort: "make"}
+
+	rentals, count, err := filter.Find(context.Background())
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal(uint32(2), count)
+		if suite.Len(rentals, 2) {
+			suite.Equal("Nissan", rentals[0].VehicleMake)
+			suite.Equal("Peugeot", rentals[1].VehicleMake)
+		}
+	}
+}
+
+func (suite *FilterModelTestSuite) TestFindSuccessRating() {
+	ratingMin := 4.5
+	filter := &Filter{Limit: 10, Offset: 0, RatingMin: &ratingMin, Sort: "rating"}
+
+	rentals, count, err := filter.Find(context.Background())
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal(uint32(2), count)
+		// best rated first
+		suite.Equal(uint32(5), rentals[0].ID)
+		suite.Equal(uint32(1), rentals[1].ID)
+		suite.Equal(int32(2), rentals[1].ReviewCount)
+	}
+}
+
+func (suite *FilterModelTestSuite) TestFindAsOf() {
+	beforeCreate := time.Now().Add(-time.Millisecond)
+	rental := Rental{UserId: 3, Name: "Versioned Van", Type: "camper-van", Sleeps: 2, Price: 12000, Lat: 39.74, Lng: -104.99}
+	suite.Require().Nil(CreateRental(context.Background(), &rental))
+	created := time.Now()
+
+	rental.Price = 9000
+	suite.Require().Nil(UpdateRental(context.Background(), &rental))
+	updated := time.Now()
+	suite.Require().Nil(DeleteRental(context.Background(), &rental))
+
+	// the filter applies to the values at the time
+	priceMin := int64(10000)
+	near := []float32{39.74, -104.99}
+	find := func(asOf time.Time) []Rental {
+		filter := &Filter{Limit: 10, Ids: []uint32{rental.ID}, PriceMin: &priceMin, Near: near, AsOf: &asOf}
+		rentals, count, err := filter.Find(context.Background())
+		suite.Require().Nil(err, "Should not lead to an error")
+		suite.Equal(uint32(len(rentals)), count)
+		return rentals
+	}
+
+	suite.Empty(find(beforeCreate))
+	if rentals := find(created); suite.Len(rentals, 1) {
+		suite.Equal(int64(12000), rentals[0].Price)
+		suite.Equal("Denver", rentals[0].City)
+	}
+	suite.Empty(find(updated), "Should apply the price range to the updated price")
+	suite.Empty(find(time.Now()), "Should not find the deleted rental")
+
+	priceMin = 0
+	if rentals := find(updated); suite.Len(rentals, 1) {
+		suite.Equal(int64(9000), rentals[0].Price)
+	}
+}
+
+func (suite *FilterModelTestSuite) TestFindCancelled() {
+	filter := &Filter{Limit: 10, Offset: 0, Sort: "id"}
+
+	// ex: the client disconnected
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := filter.Find(ctx)
+
+	suite.ErrorIs(err, context.Canceled)
+}
+
 func TestFilterModelTestSuite(t *testing.T) {
 	suite.Run(t, new(FilterModelTestSuite))
 }
+
+// Benchmark the near filter on a large synthetic dataset, compares the indexed
+// location column with the per row geography cast it replaced. The number of rows
+// can be changed with the BENCH_ROWS environment variable:
+//
+//	BENCH_ROWS=1000000 go test -run '^$' -bench Near ./models
+func BenchmarkNear(b *testing.B) {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+
+	rows := 200000
+	if value, err := strconv.Atoi(os.Getenv("BENCH_ROWS")); err == nil {
+		rows = value
+	}
+
+	// synthetic rentals spread across the continental US, setseed keeps the
+	// dataset identical between runs
+	setup := []string{
+		"DROP TABLE IF EXISTS rentals_bench",
+		"CREATE TABLE rentals_bench (id serial PRIMARY KEY, lat double precision, lng double precision, location geography(Point, 4326))",
+		"SELECT setseed(0.42)",
+		fmt.Sprintf("INSERT INTO rentals_bench (lat, lng) SELECT 25 + random() * 24, -124 + random() * 57 FROM generate_series(1, %d)", rows),
+		"UPDATE rentals_bench SET location = ST_SETSRID(ST_MAKEPOINT(lng, lat), 4326)::geography",
+		"CREATE INDEX rentals_bench_location_idx ON rentals_bench USING GIST (location)",
+		"ANALYZE rentals_bench",
+	}
+	// setseed only applies to the session, run the setup on a single connection
+	err := db.DB.Connection(func(tx *gorm.DB) error {
+		for _, statement := range setup {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("Failed to create synthetic dataset: %v", err)
+	}
+	defer db.DB.Exec("DROP TABLE IF EXISTS rentals_bench")
+
+	// near Denver
+	lat, lng := float32(39.74), float32(-104.99)
+	conditions := map[string]string{
+		"expression": "ST_DWITHIN(ST_SETSRID(ST_MAKEPOINT(lng, lat), 4326)::geography, ST_SETSRID(ST_MAKEPOINT(?, ?), 4326)::geography, ?)",
+		"indexed":    nearCondition,
+	}
+
+	for _, name := range []string{"expression", "indexed"} {
+		condition := conditions[name]
+		b.Run(fmt.Sprintf("%s/%d", name, rows), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var count int64
+				err := db.DB.Table("rentals_bench").
+					Where(condition, lng, lat, nearRadiusMiles*metersPerMile).
+					Count(&count).Error
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/images"
+	"github.com/samuelg/rentals/storage"
+	"gorm.io/gorm"
+)
+
+// RentalImage model, a photo in the gallery of a rental
+type RentalImage struct {
+	// uses serial integer column in the database which will use 32 bits at most
+	ID       uint32 `gorm:"primary_key;autoincrement;column:id"`
+	RentalId uint32 `gorm:"column:rental_id"`
+	// gallery order, lowest first
+	Position int32  `gorm:"column:position"`
+	Caption  string `gorm:"column:caption"`
+	Primary  bool   `gorm:"column:is_primary"`
+	// prefix of the storage keys of the original and its variants
+	StorageKey string `gorm:"column:storage_key"`
+	// format of the original (ex: jpeg, png)
+	Format  string    `gorm:"column:format"`
+	Width   int32     `gorm:"column:width"`
+	Height  int32     `gorm:"column:height"`
+	Created time.Time `gorm:"column:created;autoCreateTime"`
+}
+
+type RentalImageResponse struct {
+	ID       uint32 `json:"id"`
+	Position int32  `json:"position"`
+	Caption  string `json:"caption"`
+	Primary  bool   `json:"primary"`
+	Width    int32  `json:"width"`
+	Height   int32  `json:"height"`
+	// variant name (ex: original, thumb_webp) to its URL
+	Variants map[string]string `json:"variants"`
+}
+
+// Storage key of the original image
+func (rentalImage *RentalImage) OriginalKey() string {
+	return fmt.Sprintf("%s/original.%s", rentalImage.StorageKey, images.Extension(rentalImage.Format))
+}
+
+// Storage key of a resized variant
+func (rentalImage *RentalImage) VariantKey(size images.Size, format string) string {
+	return fmt.Sprintf("%s/%s.%s", rentalImage.StorageKey, size.Name, images.Extension(format))
+}
+
+// Storage keys of the original and all of its variants
+func (rentalImage *RentalImage) Keys() []string {
+	keys := []string{rentalImage.OriginalKey()}
+	for _, size := range images.Sizes {
+		for _, format := range images.Formats {
+			keys = append(keys, rentalImage.VariantKey(size, format))
+		}
+	}
+
+	return keys
+}
+
+func (rentalImage RentalImage) response() RentalImageResponse {
+	variants := map[string]string{"original": storage.Default.URL(rentalImage.OriginalKey())}
+	for _, size := range images.Sizes {
+		for _, format := range images.Formats {
+			variants[size.Name+"_"+format] = storage.Default.URL(rentalImage.VariantKey(size, format))
+		}
+	}
+
+	return RentalImageResponse{
+		ID:       rentalImage.ID,
+		Position: rentalImage.Position,
+		Caption:  rentalImage.Caption,
+		Primary:  rentalImage.Primary,
+		Width:    rentalImage.Width,
+		Height:   rentalImage.Height,
+		Variants: variants,
+	}
+}
+
+// Custom JSON format for the response
+func (rentalImage RentalImage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rentalImage.response())
+}
+
+// Returns the responses for the images, never nil so it is marshaled to an array
+func rentalImageResponses(rentalImages []RentalImage) []RentalImageResponse {
+	responses := make([]RentalImageResponse, len(rentalImages))
+	for i, rentalImage := range rentalImages {
+		responses[i] = rentalImage.response()
+	}
+
+	return responses
+}
+
+// Orders the gallery when preloading images
+func OrderImages(tx *gorm.DB) *gorm.DB {
+	return tx.Order("position, id")
+}
+
+// Store an uploaded image with its resized variants and add it to the gallery
+// of the rental, the first image of a gallery becomes its primary image
+func CreateRentalImage(ctx context.Context, rental *Rental, content []byte, caption string, primary bool) (*RentalImage, error) {
+	img, format, err := images.Decode(bytes.NewReader(content), config.GetConfig().MaxImagePixels)
+	if err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, 12)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	rentalImage := &RentalImage{
+		RentalId:   rental.ID,
+		Caption:    caption,
+		StorageKey: fmt.Sprintf("rentals/%d/images/%s", rental.ID, hex.EncodeToString(token)),
+		Format:     format,
+		Width:      int32(img.Bounds().Dx()),
+		Height:     int32(img.Bounds().Dy()),
+	}
+
+	// store the files first, they are removed if saving the record fails
+	if err := storeImageFiles(ctx, rentalImage, content, img); err != nil {
+		deleteImageFiles(ctx, rentalImage)
+		return nil, err
+	}
+
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stats struct {
+			Count       int64
+			MaxPosition int32
+		}
+		err := tx.Model(&RentalImage{}).
+			Select("COUNT(*) AS count, COALESCE(MAX(position), -1) AS max_position").
+			Where("rental_id = ?", rental.ID).
+			Scan(&stats).Error
+		if err != nil {
+			return err
+		}
+
+		rentalImage.Position = stats.MaxPosition + 1
+		if err := tx.Create(rentalImage).Error; err != nil {
+			return err
+		}
+
+		if primary || stats.Count == 0 {
+			return setPrimaryImage(tx, rentalImage)
+		}
+		return nil
+	})
+	if err != nil {
+		deleteImageFiles(ctx, rentalImage)
+		return nil, err
+	}
+
+	return rentalImage, nil
+}
+
+// Update the caption, position and primary flag of an image, the images from
+// the new position on move one further
+func UpdateRentalImage(ctx context.Context, rentalImage *RentalImage, caption string, position int32, primary bool) error {
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if position != rentalImage.Position {
+			err := tx.Model(&RentalImage{}).
+				Where("rental_id = ? AND id <> ? AND position >= ?", rentalImage.RentalId, rentalImage.ID, position).
+				Update("position", gorm.Expr("position + 1")).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		rentalImage.Caption = caption
+		rentalImage.Position = position
+		if err := tx.Select("caption", "position").Updates(rentalImage).Error; err != nil {
+			return err
+		}
+
+		if primary && !rentalImage.Primary {
+			return setPrimaryImage(tx, rentalImage)
+		}
+		return nil
+	})
+}
+
+// Remove an image from the gallery along with its files
+func DeleteRentalImage(ctx context.Context, rentalImage *RentalImage) error {
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(rentalImage).Error; err != nil {
+			return err
+		}
+
+		// promote the next image when removing the primary image
+		if !rentalImage.Primary {
+			return nil
+		}
+		var next RentalImage
+		result := tx.Where("rental_id = ?", rentalImage.RentalId).Order("position, id").Limit(1).Find(&next)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return tx.Model(&Rental{ID: rentalImage.RentalId}).Update("primary_image_url", "").Error
+		}
+		return setPrimaryImage(tx, &next)
+	})
+	if err != nil {
+		return err
+	}
+
+	deleteImageFiles(ctx, rentalImage)
+	return nil
+}
+
+// Mark the image as the primary image of its rental, the rental primary image
+// URL points to the original
+func setPrimaryImage(tx *gorm.DB, rentalImage *RentalImage) error {
+	err := tx.Model(&RentalImage{}).
+		Where("rental_id = ? AND id <> ?", rentalImage.RentalId, rentalImage.ID).
+		Update("is_primary", false).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(rentalImage).Update("is_primary", true).Error; err != nil {
+		return err
+	}
+	rentalImage.Primary = true
+
+	return tx.Model(&Rental{ID: rentalImage.RentalId}).
+		Update("primary_image_url", storage.Default.URL(rentalImage.OriginalKey())).Error
+}
+
+func storeImageFiles(ctx context.Context, rentalImage *RentalImage, content []byte, img image.Image) error {
+	if err := storage.Default.Put(ctx, rentalImage.OriginalKey(), bytes.NewReader(content)); err != nil {
+		return err
+	}
+
+	for _, size := range images.Sizes {
+		resized := images.Resize(img, size)
+		for _, format := range images.Formats {
+			var encoded bytes.Buffer
+			if err := images.Encode(&encoded, resized, format); err != nil {
+				return err
+			}
+			if err := storage.Default.Put(ctx, rentalImage.VariantKey(size, format), &encoded); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Best effort, files left behind don't break anything
+func deleteImageFiles(ctx context.Context, rentalImage *RentalImage) {
+	for _, key := range rentalImage.Keys() {
+		storage.Default.Delete(ctx, key)
+	}
+}
@@ -0,0 +1,428 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	"golang.org/x/exp/slices"
+	"gorm.io/gorm"
+)
+
+// Formats of an import
+const (
+	ImportCsv    = "csv"
+	ImportNdjson = "ndjson"
+)
+
+// Transaction policies of an import
+const (
+	// every row is saved on its own, rejected rows are skipped
+	ImportPerRow = "row"
+	// nothing is saved when a row is rejected
+	ImportAllOrNothing = "all"
+)
+
+// Outcomes of an imported row
+const (
+	ImportCreated  = "created"
+	ImportUpdated  = "updated"
+	ImportRejected = "rejected"
+)
+
+var ImportFormats = []string{ImportCsv, ImportNdjson}
+var ImportTransactions = []string{ImportPerRow, ImportAllOrNothing}
+
+// Returned when the file can't be imported at all, ex: unknown CSV columns
+var ErrInvalidImport = errors.New("invalid import")
+
+// Rolls back the transaction of dry runs and of failed all or nothing imports
+var errImportRollback = errors.New("import rolled back")
+
+// How rentals are imported
+type ImportOptions struct {
+	// owner of the imported rentals, existing rentals are matched by external
+	// id among the rentals of this user
+	UserId uint32
+	Format string
+	// transaction policy, the import_transaction configuration when empty
+	Transaction string
+	// report what would be imported without saving anything
+	DryRun bool
+}
+
+// Outcome of a row of an import
+type ImportRow struct {
+	// line of the row in the file
+	Line       int     `json:"line"`
+	ExternalId *string `json:"external_id"`
+	Status     string  `json:"status"`
+	// zero for rejected rows and rentals created by a dry run
+	RentalId uint32 `json:"rental_id,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Summary of an import with the outcome of every row
+type ImportReport struct {
+	Format      string `json:"format"`
+	Transaction string `json:"transaction"`
+	DryRun      bool   `json:"dry_run"`
+	Created     int    `json:"created"`
+	Updated     int    `json:"updated"`
+	Rejected    int    `json:"rejected"`
+	// false for dry runs and all or nothing imports with rejected rows
+	Committed bool        `json:"committed"`
+	Rows      []ImportRow `json:"rows"`
+}
+
+// Create or update rentals from a CSV or NDJSON file read as a stream. Rows
+// are checked with the rules of single creates and updates, rows with the
+// external id of an existing rental of the user update it. When reading the
+// file fails after its header, ex: the body is too large, or a row fails in a
+// per row import, ex: the context is cancelled, the report of the rows before
+// is returned with the error
+func ImportRentals(ctx context.Context, r io.Reader, options ImportOptions) (*ImportReport, error) {
+	if options.Transaction == "" {
+		options.Transaction = config.GetConfig().ImportTransaction
+	}
+	if !slices.Contains(ImportTransactions, options.Transaction) {
+		return nil, fmt.Errorf("%w: unknown transaction policy %s", ErrInvalidImport, options.Transaction)
+	}
+	reader, err := newImportReader(r, options.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{
+		Format:      options.Format,
+		Transaction: options.Transaction,
+		DryRun:      options.DryRun,
+		Rows:        make([]ImportRow, 0),
+	}
+	var readErr error
+	run := func(tx *gorm.DB) error {
+		for {
+			line, input, err := reader.next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			row := ImportRow{Line: line}
+			var rowErr *importRowError
+			if errors.As(err, &rowErr) {
+				row.Status, row.Reason = ImportRejected, rowErr.Error()
+			} else if err != nil {
+				readErr = err
+				return err
+			} else {
+				row.ExternalId = input.ExternalId
+				// every row has its own savepoint, or transaction for per row imports
+				err = tx.Transaction(func(tx *gorm.DB) error {
+					return importRow(tx, options.UserId, input, &row)
+				})
+				if err != nil && !errors.As(err, &rowErr) {
+					return fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+
+			report.add(row)
+		}
+
+		if options.DryRun || (options.Transaction == ImportAllOrNothing && report.Rejected > 0) {
+			return errImportRollback
+		}
+		return nil
+	}
+
+	tx := db.DB.WithContext(ctx)
+	perRow := options.Transaction == ImportPerRow && !options.DryRun
+	if perRow {
+		err = run(tx)
+	} else {
+		err = tx.Transaction(run)
+	}
+	switch {
+	case perRow:
+		// the rows before an error were saved in transactions of their own
+		report.Committed = true
+	case errors.Is(err, errImportRollback):
+		err = nil
+	case err != nil && readErr == nil:
+		return nil, err
+	default:
+		report.Committed = err == nil
+	}
+
+	if !report.Committed {
+		// nothing was saved, created rentals don't exist
+		for i := range report.Rows {
+			if report.Rows[i].Status == ImportCreated {
+				report.Rows[i].RentalId = 0
+			}
+		}
+	}
+	return report, err
+}
+
+func (report *ImportReport) add(row ImportRow) {
+	switch row.Status {
+	case ImportCreated:
+		report.Created++
+	case ImportUpdated:
+		report.Updated++
+	case ImportRejected:
+		report.Rejected++
+	}
+	report.Rows = append(report.Rows, row)
+}
+
+// Create or update the rental of a row, the row is rejected with an
+// *importRowError (rolling back its savepoint) when the input is invalid
+func importRow(tx *gorm.DB, userId uint32, input *RentalInput, row *ImportRow) error {
+	reject := func(reason string) error {
+		row.Status, row.Reason = ImportRejected, reason
+		return &importRowError{reason}
+	}
+	if err := input.Validate(); err != nil {
+		return reject(err.Error())
+	}
+
+	var rental Rental
+	if input.ExternalId != nil {
+		err := tx.Where("user_id = ? AND external_id = ?", userId, *input.ExternalId).Limit(1).Find(&rental).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if rental.ID == 0 {
+		if field := input.Missing(); field != "" {
+			return reject(fmt.Sprintf("Missing field: %s", field))
+		}
+		rental = Rental{UserId: userId}
+		input.Apply(&rental)
+		row.Status = ImportCreated
+		err = createRental(tx, &rental)
+	} else {
+		input.Apply(&rental)
+		row.Status = ImportUpdated
+		err = updateRental(tx, &rental)
+	}
+	if err != nil {
+		// values the database refuses only reject the row
+		if db.IsDataError(err) {
+			return reject(err.Error())
+		}
+		return err
+	}
+
+	row.RentalId = rental.ID
+	return nil
+}
+
+// Reason a row is rejected, the rows after it are still imported
+type importRowError struct {
+	reason string
+}
+
+func (err *importRowError) Error() string {
+	return err.reason
+}
+
+// Reads the rows of an import one at a time
+type importReader interface {
+	// line and input of the next row, io.EOF after the last row. Rows that
+	// can't be parsed return an *importRowError
+	next() (int, *RentalInput, error)
+}
+
+func newImportReader(r io.Reader, format string) (importReader, error) {
+	switch format {
+	case ImportCsv:
+		return newCsvImportReader(r)
+	case ImportNdjson:
+		return &ndjsonImportReader{reader: bufio.NewReader(r)}, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown format %s", ErrInvalidImport, format)
+}
+
+// One JSON object per line, in the format of the create requests
+type ndjsonImportReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func (r *ndjsonImportReader) next() (int, *RentalInput, error) {
+	for {
+		content, err := r.reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, nil, err
+		}
+		if len(content) == 0 && err != nil {
+			return 0, nil, io.EOF
+		}
+		r.line++
+
+		content = bytes.TrimSpace(content)
+		if len(content) == 0 {
+			continue
+		}
+		var input RentalInput
+		if jsonErr := json.Unmarshal(content, &input); jsonErr != nil {
+			return r.line, nil, &importRowError{fmt.Sprintf("Invalid JSON: %v", jsonErr)}
+		}
+		return r.line, &input, nil
+	}
+}
+
+// Sets the value of a CSV cell on the input
+type csvSetter func(input *RentalInput, value string) error
+
+// CSV columns, the fields of the create requests with nested fields flattened.
+// Also the columns partner feeds are mapped to. Empty cells leave the field unset
+var importColumns = map[string]csvSetter{
+	"external_id":          csvColumn(parseCsvString, func(i *RentalInput) **string { return &i.ExternalId }),
+	"name":                 csvColumn(parseCsvString, func(i *RentalInput) **string { return &i.Name }),
+	"description":          csvColumn(parseCsvString, func(i *RentalInput) **string { return &i.Description }),
+	"type":                 csvColumn(parseCsvString, func(i *RentalInput) **string { return &i.Type }),
+	"make":                 csvColumn(parseCsvString, func(i *RentalInput) **string { return &i.Make }),
+	"model":                csvColumn(parseCsvString, func(i *RentalInput) **string { return &i.Model }),
+	"year":                 csvColumn(parseCsvInt32, func(i *RentalInput) **int32 { return &i.Year }),
+	"length":               csvColumn(parseCsvFloat32, func(i *RentalInput) **float32 { return &i.Length }),
+	"sleeps":               csvColumn(parseCsvInt32, func(i *RentalInput) **int32 { return &i.Sleeps }),
+	"price_day":            csvColumn(parseCsvInt64, func(i *RentalInput) **int64 { return &inputPrice(i).Day }),
+	"city":                 csvColumn(parseCsvString, func(i *RentalInput) **string { return &inputLocation(i).City }),
+	"state":                csvColumn(parseCsvString, func(i *RentalInput) **string { return &inputLocation(i).State }),
+	"zip":                  csvColumn(parseCsvString, func(i *RentalInput) **string { return &inputLocation(i).Zip }),
+	"country":              csvColumn(parseCsvString, func(i *RentalInput) **string { return &inputLocation(i).Country }),
+	"lat":                  csvColumn(parseCsvFloat32, func(i *RentalInput) **float32 { return &inputLocation(i).Lat }),
+	"lng":                  csvColumn(parseCsvFloat32, func(i *RentalInput) **float32 { return &inputLocation(i).Lng }),
+	"fuel_type":            csvColumn(parseCsvString, func(i *RentalInput) **string { return &inputVehicle(i).FuelType }),
+	"transmission":         csvColumn(parseCsvString, func(i *RentalInput) **string { return &inputVehicle(i).Transmission }),
+	"drivetrain":           csvColumn(parseCsvString, func(i *RentalInput) **string { return &inputVehicle(i).Drivetrain }),
+	"seatbelts":            csvColumn(parseCsvInt32, func(i *RentalInput) **int32 { return &inputVehicle(i).Seatbelts }),
+	"tow_capacity":         csvColumn(parseCsvInt32, func(i *RentalInput) **int32 { return &inputVehicle(i).TowCapacity }),
+	"fresh_water_capacity": csvColumn(parseCsvInt32, func(i *RentalInput) **int32 { return &inputVehicle(i).FreshWaterCapacity }),
+	"generator":            csvColumn(strconv.ParseBool, func(i *RentalInput) **bool { return &inputVehicle(i).Generator }),
+	"ev_range":             csvColumn(parseCsvInt32, func(i *RentalInput) **int32 { return &inputVehicle(i).EvRange }),
+}
+
+func csvColumn[T any](parse func(string) (T, error), field func(input *RentalInput) **T) csvSetter {
+	return func(input *RentalInput, value string) error {
+		parsed, err := parse(value)
+		if err != nil {
+			return err
+		}
+		*field(input) = &parsed
+		return nil
+	}
+}
+
+func parseCsvString(value string) (string, error) {
+	return value, nil
+}
+
+func parseCsvInt32(value string) (int32, error) {
+	parsed, err := strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+	return int32(parsed), err
+}
+
+func parseCsvInt64(value string) (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+}
+
+func parseCsvFloat32(value string) (float32, error) {
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 32)
+	return float32(parsed), err
+}
+
+func inputPrice(input *RentalInput) *RentalPriceInput {
+	if input.Price == nil {
+		input.Price = &RentalPriceInput{}
+	}
+	return input.Price
+}
+
+func inputLocation(input *RentalInput) *RentalLocationInput {
+	if input.Location == nil {
+		input.Location = &RentalLocationInput{}
+	}
+	return input.Location
+}
+
+func inputVehicle(input *RentalInput) *RentalVehicleInput {
+	if input.Vehicle == nil {
+		input.Vehicle = &RentalVehicleInput{}
+	}
+	return input.Vehicle
+}
+
+// A header with the columns used followed by a row per rental
+type csvImportReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCsvImportReader(r io.Reader) (*csvImportReader, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading the CSV header: %w", ErrInvalidImport, err)
+	}
+
+	columns := make([]string, len(header))
+	for i, column := range header {
+		// spreadsheets often save with a byte order mark
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := importColumns[column]; !ok {
+			return nil, fmt.Errorf("%w: unknown CSV column %s", ErrInvalidImport, column)
+		}
+		if slices.Contains(columns[:i], column) {
+			return nil, fmt.Errorf("%w: duplicate CSV column %s", ErrInvalidImport, column)
+		}
+		columns[i] = column
+	}
+
+	return &csvImportReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvImportReader) next() (int, *RentalInput, error) {
+	record, err := r.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return 0, nil, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine, nil, &importRowError{fmt.Sprintf("Invalid CSV: %v", parseErr.Err)}
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	line, _ := r.reader.FieldPos(0)
+
+	var input RentalInput
+	for i, value := range record {
+		if value == "" {
+			continue
+		}
+		if err := importColumns[r.columns[i]](&input, value); err != nil {
+			return line, nil, &importRowError{fmt.Sprintf("Invalid %s: %s", r.columns[i], value)}
+		}
+	}
+	return line, &input, nil
+}
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for rental imports
+type ImportModelTestSuite struct {
+	suite.Suite
+	config       *config.Config
+	lastRentalId uint32
+}
+
+func (suite *ImportModelTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+	suite.lastRentalId = maxRentalId()
+}
+
+func (suite *ImportModelTestSuite) TearDownSuite() {
+	db.DB.Where("id > ?", suite.lastRentalId).Delete(&Rental{})
+}
+
+// External id not used by previous runs of the tests
+func uniqueExternalId(name string) string {
+	return fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+}
+
+// Id of the last rental, suites creating rentals remove the ones after it when
+// they end since other suites count the fixture rentals
+func maxRentalId() uint32 {
+	var id uint32
+	db.DB.Model(&Rental{}).Select("COALESCE(MAX(id), 0)").Scan(&id)
+	return id
+}
+
+// Status of every row of the import
+func statuses(report *ImportReport) []string {
+	result := make([]string, len(report.Rows))
+	for i, row := range report.Rows {
+		result[i] = row.Status
+	}
+	return result
+}
+
+func (suite *ImportModelTestSuite) TestCsvReader() {
+	content := "\ufeffexternal_id,Name,type,sleeps,price_day,lat,lng,generator\n" +
+		"a-1,\"Westy, the van\",camper-van,4,16900,33.64,-117.93,true\n" +
+		"a-2,Trailer,travel-trailer,four,9000,,,\n" +
+		"a-3,Short\n"
+	reader, err := newImportReader(strings.NewReader(content), ImportCsv)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		line, input, err := reader.next()
+		if suite.Nil(err, "Should not lead to an error") {
+			suite.Equal(2, line)
+			suite.Equal("a-1", *input.ExternalId)
+			suite.Equal("Westy, the van", *input.Name)
+			suite.Equal(int32(4), *input.Sleeps)
+			suite.Equal(int64(16900), *input.Price.Day)
+			suite.Equal(float32(-117.93), *input.Location.Lng)
+			suite.True(*input.Vehicle.Generator)
+			suite.Nil(input.Description)
+		}
+
+		line, _, err = reader.next()
+		suite.Equal(3, line)
+		suite.EqualError(err, "Invalid sleeps: four")
+
+		line, _, err = reader.next()
+		suite.Equal(4, line)
+		var rowErr *importRowError
+		suite.ErrorAs(err, &rowErr)
+
+		_, _, err = reader.next()
+		suite.ErrorIs(err, io.EOF)
+	}
+}
+
+func (suite *ImportModelTestSuite) TestCsvReaderUnknownColumn() {
+	_, err := newImportReader(strings.NewReader("name,colour\nWesty,blue\n"), ImportCsv)
+
+	suite.ErrorIs(err, ErrInvalidImport)
+}
+
+func (suite *ImportModelTestSuite) TestNdjsonReader() {
+	content := `{"external_id":"b-1","name":"Westy","price":{"day":16900},"location":{"lat":33.64,"lng":-117.93}}
+
+{"name":
+{"name":"Eurovan"}`
+	reader, err := newImportReader(strings.NewReader(content), ImportNdjson)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		line, input, err := reader.next()
+		if suite.Nil(err, "Should not lead to an error") {
+			suite.Equal(1, line)
+			suite.Equal("b-1", *input.ExternalId)
+			suite.Equal(int64(16900), *input.Price.Day)
+		}
+
+		// blank lines are skipped
+		line, _, err = reader.next()
+		suite.Equal(3, line)
+		var rowErr *importRowError
+		suite.ErrorAs(err, &rowErr)
+
+		line, input, err = reader.next()
+		if suite.Nil(err, "Should not lead to an error") {
+			suite.Equal(4, line)
+			suite.Equal("Eurovan", *input.Name)
+		}
+
+		_, _, err = reader.next()
+		suite.ErrorIs(err, io.EOF)
+	}
+}
+
+func (suite *ImportModelTestSuite) TestImportUnknownFormat() {
+	_, err := ImportRentals(context.Background(), strings.NewReader(""), ImportOptions{UserId: 2, Format: "xlsx"})
+
+	suite.ErrorIs(err, ErrInvalidImport)
+}
+
+func (suite *ImportModelTestSuite) TestImportCreateAndUpdate() {
+	externalId := uniqueExternalId("upsert")
+	content := "external_id,name,type,sleeps,price_day,lat,lng\n" +
+		externalId + ",Imported van,camper-van,2,12000,45.52,-122.68\n" +
+		externalId + ",,,,13000,,\n" +
+		",Missing price,camper-van,2,,45.52,-122.68\n" +
+		",Too many sleeps,camper-van,200,9000,45.52,-122.68\n"
+
+	report, err := ImportRentals(context.Background(), strings.NewReader(content), ImportOptions{UserId: 2, Format: ImportCsv, Transaction: ImportPerRow})
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.True(report.Committed)
+		suite.Equal([]string{ImportCreated, ImportUpdated, ImportRejected, ImportRejected}, statuses(report))
+		suite.Equal(1, report.Created)
+		suite.Equal(1, report.Updated)
+		suite.Equal(2, report.Rejected)
+		suite.Equal("Missing field: price.day", report.Rows[2].Reason)
+		suite.Equal(report.Rows[0].RentalId, report.Rows[1].RentalId)
+
+		var rental Rental
+		if suite.Nil(db.DB.First(&rental, report.Rows[0].RentalId).Error, "Should not lead to an error") {
+			suite.Equal("Imported van", rental.Name)
+			suite.Equal(int64(13000), rental.Price)
+			suite.Equal(uint32(2), rental.UserId)
+			suite.Equal(externalId, *rental.ExternalId)
+		}
+	}
+}
+
+func (suite *ImportModelTestSuite) TestImportAllOrNothing() {
+	externalId := uniqueExternalId("all")
+	content := fmt.Sprintf(`{"external_id":%q,"name":"Rolled back","type":"camper-van","sleeps":2,"price":{"day":9000},"location":{"lat":45.52,"lng":-122.68}}
+{"name":"No location","type":"camper-van","sleeps":2,"price":{"day":9000}}
+`, externalId)
+
+	report, err := ImportRentals(context.Background(), strings.NewReader(content), ImportOptions{UserId: 2, Format: ImportNdjson, Transaction: ImportAllOrNothing})
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.False(report.Committed)
+		suite.Equal([]string{ImportCreated, ImportRejected}, statuses(report))
+		suite.Zero(report.Rows[0].RentalId)
+
+		var count int64
+		db.DB.Model(&Rental{}).Where("external_id = ?", externalId).Count(&count)
+		suite.Zero(count)
+	}
+}
+
+func (suite *ImportModelTestSuite) TestImportDryRun() {
+	externalId := uniqueExternalId("dry-run")
+	content := "external_id,name,type,sleeps,price_day,lat,lng\n" +
+		externalId + ",Not saved,camper-van,2,12000,45.52,-122.68\n"
+
+	report, err := ImportRentals(context.Background(), strings.NewReader(content), ImportOptions{UserId: 2, Format: ImportCsv, DryRun: true})
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.False(report.Committed)
+		suite.Equal(ImportPerRow, report.Transaction)
+		suite.Equal([]string{ImportCreated}, statuses(report))
+
+		var count int64
+		db.DB.Model(&Rental{}).Where("external_id = ?", externalId).Count(&count)
+		suite.Zero(count)
+	}
+}
+
+func (suite *ImportModelTestSuite) TestImportReadFailed() {
+	readErr := errors.New("body too large")
+
+	for _, transaction := range ImportTransactions {
+		externalId := uniqueExternalId("truncated")
+		content := fmt.Sprintf(`{"external_id":%q,"name":"Read before","type":"camper-van","sleeps":2,"price":{"day":9000},"location":{"lat":45.52,"lng":-122.68}}
+{"name":"Cut`, externalId)
+		r := io.MultiReader(strings.NewReader(content), iotest.ErrReader(readErr))
+
+		report, err := ImportRentals(context.Background(), r, ImportOptions{UserId: 2, Format: ImportNdjson, Transaction: transaction})
+
+		suite.ErrorIs(err, readErr)
+		if suite.NotNil(report, "Should report the rows read before") {
+			suite.Equal([]string{ImportCreated}, statuses(report))
+			// rows have their own transaction or none is saved
+			suite.Equal(transaction == ImportPerRow, report.Committed)
+			suite.Equal(transaction == ImportPerRow, report.Rows[0].RentalId != 0)
+		}
+
+		var count int64
+		db.DB.Model(&Rental{}).Where("external_id = ?", externalId).Count(&count)
+		suite.Equal(report != nil && report.Committed, count == 1, transaction)
+	}
+}
+
+// Cancels the context when the row after the first one is read
+type cancelingReader struct {
+	cancel context.CancelFunc
+	reader io.Reader
+}
+
+func (r cancelingReader) Read(p []byte) (int, error) {
+	r.cancel()
+	return r.reader.Read(p)
+}
+
+func (suite *ImportModelTestSuite) TestImportCancelled() {
+	externalId := uniqueExternalId("cancelled")
+	first := fmt.Sprintf(`{"external_id":%q,"name":"Saved before","type":"camper-van","sleeps":2,"price":{"day":9000},"location":{"lat":45.52,"lng":-122.68}}
+`, externalId)
+	second := `{"name":"Cancelled","type":"camper-van","sleeps":2,"price":{"day":9000},"location":{"lat":45.52,"lng":-122.68}}
+`
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := io.MultiReader(strings.NewReader(first), cancelingReader{cancel, strings.NewReader(second)})
+
+	report, err := ImportRentals(ctx, r, ImportOptions{UserId: 2, Format: ImportNdjson, Transaction: ImportPerRow})
+
+	suite.ErrorIs(err, context.Canceled)
+	if suite.NotNil(report, "Should report the rows saved before") {
+		suite.True(report.Committed)
+		suite.Equal([]string{ImportCreated}, statuses(report))
+		suite.NotZero(report.Rows[0].RentalId)
+	}
+
+	var count int64
+	db.DB.Model(&Rental{}).Where("external_id = ?", externalId).Count(&count)
+	suite.Equal(int64(1), count)
+}
+
+func TestImportModelTestSuite(t *testing.T) {
+	suite.Run(t, new(ImportModelTestSuite))
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/geo"
+	log "github.com/samuelg/rentals/logging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Number of rentals loaded at once when backfilling locations
+const backfillBatchSize = 100
+
+// Summary of a location backfill
+type BackfillReport struct {
+	Checked int `json:"checked"`
+	Updated int `json:"updated"`
+	Flagged int `json:"flagged"`
+	// rental id to the issues found for it
+	Issues map[uint32]string `json:"issues"`
+}
+
+// Derive or check the location fields whenever a rental is created or updated
+func (rental *Rental) saveLocation(tx *gorm.DB) {
+	// nothing to check against without coordinates (ex: partial updates)
+	if rental.Lat == 0 && rental.Lng == 0 {
+		return
+	}
+
+	result := rental.ResolveLocation()
+	// set columns on the statement so partial updates also pick up the changes
+	tx.Statement.SetColumn("City", result.Location.City)
+	tx.Statement.SetColumn("State", result.Location.State)
+	tx.Statement.SetColumn("Zip", result.Location.Zip)
+	tx.Statement.SetColumn("Country", result.Location.Country)
+	tx.Statement.SetColumn("LocationIssues", result.IssuesString())
+}
+
+// Resolve the rental location against the gazetteer
+func (rental *Rental) ResolveLocation() *geo.Result {
+	return geo.Default().Resolve(geo.Location{
+		City:    rental.City,
+		State:   rental.State,
+		Zip:     rental.Zip,
+		Country: rental.Country,
+		Lat:     float64(rental.Lat),
+		Lng:     float64(rental.Lng),
+	})
+}
+
+// Resolve the location of all existing rentals, derived fields and issues are
+// only saved when dryRun is false
+func BackfillLocations(dryRun bool) (*BackfillReport, error) {
+	report := &BackfillReport{Issues: make(map[uint32]string)}
+	var rentals []Rental
+
+	result := db.DB.Order("id").FindInBatches(&rentals, backfillBatchSize, func(tx *gorm.DB, batch int) error {
+		for i := range rentals {
+			rental := &rentals[i]
+			report.Checked++
+
+			resolved := rental.ResolveLocation()
+			issues := resolved.IssuesString()
+			if issues != "" {
+				report.Flagged++
+				report.Issues[rental.ID] = issues
+				log.Log.Debug(fmt.Sprintf("Rental %d location issues: %s", rental.ID, issues))
+			}
+
+			changed := resolved.Location.City != rental.City ||
+				resolved.Location.State != rental.State ||
+				resolved.Location.Zip != rental.Zip ||
+				resolved.Location.Country != rental.Country ||
+				issues != rental.LocationIssues
+			if !changed {
+				continue
+			}
+
+			report.Updated++
+			if dryRun {
+				continue
+			}
+			// the BeforeSave hook applies the resolved location
+			if err := tx.Omit(clause.Associations).Save(rental).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return report, nil
+}
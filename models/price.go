@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/samuelg/rentals/db"
+)
+
+// Month format of the price trends
+const PriceTrendMonth = "2006-01"
+
+// Columns the price trends can be grouped by
+var priceTrendGroups = map[string]string{
+	"type":  "rentals.type",
+	"state": "rentals.home_state",
+}
+
+// RentalPrice model, a price of a rental and the time it took effect. Recorded
+// by the rentals_price trigger whenever the price changes
+type RentalPrice struct {
+	ID        uint32    `gorm:"primary_key;autoincrement;column:id"`
+	RentalId  uint32    `gorm:"column:rental_id"`
+	Price     int64     `gorm:"column:price_per_day"`
+	Effective time.Time `gorm:"column:effective"`
+}
+
+type RentalPriceResponse struct {
+	Price     PriceReponse `json:"price"`
+	Effective time.Time    `json:"effective"`
+}
+
+// Custom JSON format for the response
+func (price RentalPrice) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&RentalPriceResponse{
+		Price:     PriceReponse{Day: price.Price},
+		Effective: price.Effective,
+	})
+}
+
+// Average daily price of a group of rentals for a month
+type PriceTrend struct {
+	Month        string       `json:"month"`
+	Group        string       `json:"group"`
+	AveragePrice PriceReponse `json:"average_price"`
+	// rentals with a price during the month
+	Rentals uint32 `json:"rentals"`
+}
+
+// Find the prices of a rental, oldest first
+func FindRentalPrices(rentalId uint32) ([]RentalPrice, error) {
+	prices := make([]RentalPrice, 0)
+	err := db.DB.Where("rental_id = ?", rentalId).Order("effective, id").Find(&prices).Error
+
+	return prices, err
+}
+
+// Returns true when the price trends can be grouped by the value
+func ValidPriceTrendGroup(groupBy string) bool {
+	_, ok := priceTrendGroups[groupBy]
+	return ok
+}
+
+// Average daily price by month of the rentals matching the filter, from the
+// first day of the from month to the end of the to month (or now). Every day a
+// rental has a price counts once in the average of its month
+func PriceTrends(filter *Filter, groupBy string, from time.Time, to time.Time) ([]PriceTrend, error) {
+	start := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year(), to.Month()+1, 1, 0, 0, 0, 0, time.UTC).Add(-24 * time.Hour)
+	if now := time.Now().UTC(); end.After(now) {
+		end = now
+	}
+
+	rentalIds := db.DB.Model(&Rental{}).Select("rentals.id").Scopes(filter.Conditions)
+	// the price of a rental is effective until the next one
+	prices := db.DB.Table("rental_prices").
+		Select("rental_id, price_per_day, effective, LEAD(effective) OVER (PARTITION BY rental_id ORDER BY effective, id) AS until").
+		Where("rental_id IN (?)", rentalIds)
+	days := db.DB.Raw("SELECT generate_series(?::timestamptz, ?::timestamptz, '1 day') AS day", start, end)
+	// months formatted as PriceTrendMonth
+	month := "to_char(days.day AT TIME ZONE 'UTC', 'YYYY-MM')"
+	during := "prices.effective < days.day + interval '1 day' AND (prices.until IS NULL OR prices.until >= days.day + interval '1 day')"
+	if db.IsSQLite() {
+		// days are julian day numbers
+		days = db.DB.Raw("WITH RECURSIVE series(day) AS (SELECT julianday(?) UNION ALL SELECT day + 1 FROM series WHERE day + 1 <= julianday(?)) SELECT day FROM series", start, end)
+		month = "strftime('%Y-%m', days.day)"
+		during = "julianday(prices.effective) < days.day + 1 AND (prices.until IS NULL OR julianday(prices.until) >= days.day + 1)"
+	}
+
+	var rows []struct {
+		Month   string
+		Group   string
+		Average float64
+		Rentals uint32
+	}
+	err := db.DB.Table("(?) AS days", days).
+		Select(month+" AS month, COALESCE("+priceTrendGroups[groupBy]+", '') AS \"group\", "+
+			"AVG(prices.price_per_day) AS average, COUNT(DISTINCT prices.rental_id) AS rentals").
+		Joins("JOIN (?) AS prices ON "+during, prices).
+		Joins("JOIN rentals ON rentals.id = prices.rental_id").
+		Group("1, 2").
+		Order("1, 2").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	trends := make([]PriceTrend, len(rows))
+	for i, row := range rows {
+		trends[i] = PriceTrend{
+			Month:        row.Month,
+			Group:        row.Group,
+			AveragePrice: PriceReponse{Day: int64(math.Round(row.Average))},
+			Rentals:      row.Rentals,
+		}
+	}
+
+	return trends, nil
+}
@@ -0,0 +1,271 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/notifications"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// price alerts are given up on after this many failed deliveries
+	priceAlertMaxAttempts = 5
+	// delay before retrying a failed delivery, doubled on every attempt
+	priceAlertRetryDelay = time.Minute
+	// price alerts delivered per run
+	priceAlertBatchSize = 100
+	// claims of runs that stopped while sending expire after this delay
+	priceAlertClaimTimeout = 10 * time.Minute
+)
+
+// PriceWatch model, a user waiting for the price of a rental to drop
+type PriceWatch struct {
+	// uses serial integer column in the database which will use 32 bits at most
+	ID       uint32 `gorm:"primary_key;autoincrement;column:id"`
+	UserId   uint32 `gorm:"column:user_id"`
+	RentalId uint32 `gorm:"column:rental_id"`
+	// notify when the price goes below this price
+	Below *int64 `gorm:"column:below"`
+	// notify when the price drops by this percentage from the baseline
+	DropPercent *int32 `gorm:"column:drop_percent"`
+	// price the drop is measured from, reset when a drop is notified
+	BaselinePrice int64     `gorm:"column:baseline_price_per_day"`
+	Created       time.Time `gorm:"column:created;autoCreateTime"`
+}
+
+type PriceWatchResponse struct {
+	ID            uint32       `json:"id"`
+	RentalId      uint32       `json:"rental_id"`
+	Below         *int64       `json:"below"`
+	DropPercent   *int32       `json:"drop_percent"`
+	BaselinePrice PriceReponse `json:"baseline_price"`
+	Created       time.Time    `json:"created"`
+}
+
+// Custom JSON format for the response
+func (watch PriceWatch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&PriceWatchResponse{
+		ID:            watch.ID,
+		RentalId:      watch.RentalId,
+		Below:         watch.Below,
+		DropPercent:   watch.DropPercent,
+		BaselinePrice: PriceReponse{Day: watch.BaselinePrice},
+		Created:       watch.Created,
+	})
+}
+
+// PriceAlert model, a price drop waiting to be delivered to a user, alerts are
+// unique per watch and price change so a drop is never notified twice
+type PriceAlert struct {
+	ID           uint32 `gorm:"primary_key;autoincrement;column:id"`
+	PriceWatchId uint32 `gorm:"column:price_watch_id"`
+	UserId       uint32 `gorm:"column:user_id"`
+	RentalId     uint32 `gorm:"column:rental_id"`
+	// price change recorded in rental_prices
+	RentalPriceId uint32    `gorm:"column:rental_price_id"`
+	OldPrice      int64     `gorm:"column:old_price_per_day"`
+	NewPrice      int64     `gorm:"column:new_price_per_day"`
+	Attempts      int32     `gorm:"column:attempts"`
+	NextAttempt   time.Time `gorm:"column:next_attempt"`
+	LastError     string    `gorm:"column:last_error"`
+	// time a delivery claimed the alert, NULL when not being sent
+	Sending   *time.Time `gorm:"column:sending"`
+	Delivered *time.Time `gorm:"column:delivered"`
+	Created   time.Time  `gorm:"column:created;autoCreateTime"`
+}
+
+// Summary of a price alert delivery
+type PriceAlertReport struct {
+	Delivered int `json:"delivered"`
+	Retried   int `json:"retried"`
+	Abandoned int `json:"abandoned"`
+}
+
+// Returns true when going from the old to the new price should notify the watch
+func (watch *PriceWatch) Triggered(oldPrice int64, newPrice int64) bool {
+	if newPrice >= oldPrice {
+		return false
+	}
+
+	// only when crossing the threshold, not on every drop below it
+	if watch.Below != nil && newPrice < *watch.Below && oldPrice >= *watch.Below {
+		return true
+	}
+	if watch.DropPercent != nil && watch.BaselinePrice > 0 {
+		drop := (watch.BaselinePrice - newPrice) * 100
+		if drop >= int64(*watch.DropPercent)*watch.BaselinePrice {
+			return true
+		}
+	}
+
+	return false
+}
+
+func queuePriceAlerts(tx *gorm.DB, rentalId uint32, oldPrice int64, newPrice int64) error {
+	var watches []PriceWatch
+	if err := tx.Where("rental_id = ?", rentalId).Find(&watches).Error; err != nil || len(watches) == 0 {
+		return err
+	}
+
+	// the price change the rentals_price trigger just recorded
+	var priceId uint32
+	err := tx.Model(&RentalPrice{}).Select("id").Where("rental_id = ?", rentalId).Order("id DESC").Limit(1).Scan(&priceId).Error
+	if err != nil {
+		return err
+	}
+
+	for _, watch := range watches {
+		if !watch.Triggered(oldPrice, newPrice) {
+			continue
+		}
+
+		alert := PriceAlert{
+			PriceWatchId:  watch.ID,
+			UserId:        watch.UserId,
+			RentalId:      rentalId,
+			RentalPriceId: priceId,
+			OldPrice:      oldPrice,
+			NewPrice:      newPrice,
+			NextAttempt:   time.Now(),
+		}
+		// the same price change is only queued once per watch
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert).Error; err != nil {
+			return err
+		}
+
+		// later drops are measured from the notified price
+		if err := tx.Model(&watch).Update("baseline_price_per_day", newPrice).Error; err != nil {
+			return err
+		}
+		log.Log.Debug(fmt.Sprintf("Price alert queued for watch %d of rental %d", watch.ID, rentalId))
+	}
+
+	return nil
+}
+
+// Find the price watches of a user, oldest first
+func FindPriceWatches(userId uint32) ([]PriceWatch, error) {
+	watches := make([]PriceWatch, 0)
+	err := db.DB.Where("user_id = ?", userId).Order("created, id").Find(&watches).Error
+
+	return watches, err
+}
+
+// Deliver the queued price alerts that are due, failed deliveries are retried
+// with an exponential backoff. Alerts are claimed before being sent so the
+// notifier is called outside of any transaction
+func DeliverPriceAlerts(ctx context.Context, notifier notifications.Notifier) (*PriceAlertReport, error) {
+	report := &PriceAlertReport{}
+
+	alerts, err := claimPriceAlerts(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	for i := range alerts {
+		// keep what was delivered so far, the rest waits for the next run
+		if ctx.Err() != nil {
+			return report, releasePriceAlerts(alerts[i:])
+		}
+		if err := deliverPriceAlert(ctx, notifier, &alerts[i], report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// Claim the alerts that are due by marking them as sending
+func claimPriceAlerts(ctx context.Context) ([]PriceAlert, error) {
+	var alerts []PriceAlert
+
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// concurrent runs (ex: several API instances) skip the alerts being claimed
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("delivered IS NULL AND attempts < ? AND next_attempt <= ?", priceAlertMaxAttempts, now).
+			Where("sending IS NULL OR sending <= ?", now.Add(-priceAlertClaimTimeout)).
+			Order("next_attempt, id").
+			Limit(priceAlertBatchSize).
+			Find(&alerts).Error
+		if err != nil || len(alerts) == 0 {
+			return err
+		}
+
+		ids := make([]uint32, len(alerts))
+		for i := range alerts {
+			ids[i] = alerts[i].ID
+			alerts[i].Sending = &now
+		}
+		return tx.Model(&PriceAlert{}).Where("id IN ?", ids).Update("sending", now).Error
+	})
+
+	return alerts, err
+}
+
+// Release the claim on alerts that were not sent
+func releasePriceAlerts(alerts []PriceAlert) error {
+	ids := make([]uint32, len(alerts))
+	for i := range alerts {
+		ids[i] = alerts[i].ID
+	}
+	return db.DB.Model(&PriceAlert{}).Where("id IN ?", ids).Update("sending", nil).Error
+}
+
+// Send a claimed alert and record the outcome, the outcome is recorded even
+// when the context is cancelled while sending
+func deliverPriceAlert(ctx context.Context, notifier notifications.Notifier, alert *PriceAlert, report *PriceAlertReport) error {
+	notification, err := alert.notification(db.DB.WithContext(ctx))
+	if err == nil {
+		err = notifier.Notify(ctx, notification)
+	}
+
+	if err == nil {
+		now := time.Now()
+		report.Delivered++
+		return db.DB.Model(alert).Updates(map[string]interface{}{"delivered": now, "sending": nil, "attempts": alert.Attempts + 1, "last_error": ""}).Error
+	}
+
+	attempts := alert.Attempts + 1
+	// nothing to retry when the user can't be reached
+	if errors.Is(err, notifications.ErrNoRecipient) {
+		attempts = priceAlertMaxAttempts
+	}
+	if attempts >= priceAlertMaxAttempts {
+		report.Abandoned++
+		log.Log.Warn(fmt.Sprintf("Giving up on price alert %d: %v", alert.ID, err))
+	} else {
+		report.Retried++
+	}
+	nextAttempt := time.Now().Add(priceAlertRetryDelay << (attempts - 1))
+	return db.DB.Model(alert).Updates(map[string]interface{}{"sending": nil, "attempts": attempts, "next_attempt": nextAttempt, "last_error": err.Error()}).Error
+}
+
+// Notification for the price drop
+func (alert *PriceAlert) notification(tx *gorm.DB) (notifications.Notification, error) {
+	var user User
+	if err := tx.First(&user, alert.UserId).Error; err != nil {
+		return notifications.Notification{}, err
+	}
+	var rental Rental
+	if err := tx.Select("id", "name").First(&rental, alert.RentalId).Error; err != nil {
+		return notifications.Notification{}, err
+	}
+
+	name := strings.TrimSpace(rental.Name)
+	return notifications.Notification{
+		UserId:  alert.UserId,
+		To:      user.Email,
+		Subject: fmt.Sprintf("Price drop for \"%s\"", name),
+		Body: fmt.Sprintf("Hi %s,\n\nThe price of \"%s\" dropped from $%.2f to $%.2f per day (/rentals/%d).\n",
+			user.FirstName, name, float64(alert.OldPrice)/100, float64(alert.NewPrice)/100, rental.ID),
+	}, nil
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/notifications"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for price watches
+type PriceWatchTestSuite struct {
+	suite.Suite
+	config *config.Config
+}
+
+func (suite *PriceWatchTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+}
+
+// Fails every notification
+type failingNotifier struct {
+	attempts int
+}
+
+func (notifier *failingNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	notifier.attempts++
+	return errors.New("unavailable")
+}
+
+// Runs a concurrent delivery while sending, ex: another API instance
+type concurrentNotifier struct {
+	concurrent []notifications.Notification
+}
+
+func (notifier *concurrentNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	other := &recordingNotifier{}
+	if _, err := DeliverPriceAlerts(ctx, other); err != nil {
+		return err
+	}
+	notifier.concurrent = append(notifier.concurrent, other.notifications...)
+	return nil
+}
+
+func (suite *PriceWatchTestSuite) TestTriggeredBelow() {
+	below := int64(10000)
+	watch := PriceWatch{Below: &below, BaselinePrice: 12000}
+
+	suite.True(watch.Triggered(12000, 9900))
+	suite.False(watch.Triggered(12000, 10000), "Should only trigger below the threshold")
+	suite.False(watch.Triggered(9900, 9500), "Should only trigger when crossing the threshold")
+	suite.False(watch.Triggered(9500, 9900), "Should not trigger on a price increase")
+}
+
+func (suite *PriceWatchTestSuite) TestTriggeredDropPercent() {
+	dropPercent := int32(10)
+	watch := PriceWatch{DropPercent: &dropPercent, BaselinePrice: 20000}
+
+	suite.True(watch.Triggered(20000, 18000))
+	suite.False(watch.Triggered(20000, 18100))
+	// drops add up from the baseline
+	suite.True(watch.Triggered(19000, 17500))
+}
+
+func (suite *PriceWatchTestSuite) TestPriceDropAlert() {
+	var rental Rental
+	suite.Require().Nil(db.DB.First(&rental, 10).Error)
+	price := rental.Price
+	defer db.DB.Model(&rental).Update("price_per_day", price)
+
+	dropPercent := int32(10)
+	watch := PriceWatch{UserId: 2, RentalId: rental.ID, DropPercent: &dropPercent, BaselinePrice: price}
+	suite.Require().Nil(db.DB.Create(&watch).Error)
+	defer db.DB.Delete(&watch)
+
+	// not enough of a drop
+	suite.Require().Nil(db.DB.Model(&rental).Update("price_per_day", price-price/20).Error)
+	var count int64
+	db.DB.Model(&PriceAlert{}).Where("price_watch_id = ?", watch.ID).Count(&count)
+	suite.Equal(int64(0), count)
+
+	suite.Require().Nil(db.DB.Model(&rental).Update("price_per_day", price/2).Error)
+	db.DB.Model(&PriceAlert{}).Where("price_watch_id = ?", watch.ID).Count(&count)
+	suite.Equal(int64(1), count)
+
+	// failed deliveries are retried later
+	failing := &failingNotifier{}
+	report, err := DeliverPriceAlerts(context.Background(), failing)
+	if suite.Nil(err) {
+		suite.GreaterOrEqual(report.Retried, 1)
+		suite.Equal(0, report.Delivered)
+	}
+	var alert PriceAlert
+	suite.Require().Nil(db.DB.Where("price_watch_id = ?", watch.ID).First(&alert).Error)
+	suite.Equal(int32(1), alert.Attempts)
+	suite.Equal("unavailable", alert.LastError)
+
+	// make the retry due
+	suite.Require().Nil(db.DB.Model(&alert).Update("next_attempt", alert.Created).Error)
+	notifier := &recordingNotifier{}
+	report, err = DeliverPriceAlerts(context.Background(), notifier)
+	if suite.Nil(err) {
+		suite.GreaterOrEqual(report.Delivered, 1)
+	}
+	if suite.Len(notifier.notifications, 1) {
+		suite.Equal("jane.doe@example.com", notifier.notifications[0].To)
+		suite.Contains(notifier.notifications[0].Body, "dropped from")
+	}
+
+	// delivered alerts are not sent again
+	notifier = &recordingNotifier{}
+	_, err = DeliverPriceAlerts(context.Background(), notifier)
+	suite.Nil(err)
+	suite.Empty(notifier.notifications)
+
+	// the baseline moved to the notified price
+	suite.Require().Nil(db.DB.First(&watch, watch.ID).Error)
+	suite.Equal(price/2, watch.BaselinePrice)
+}
+
+func (suite *PriceWatchTestSuite) TestPriceDropAlertAgain() {
+	var rental Rental
+	suite.Require().Nil(db.DB.First(&rental, 13).Error)
+	price := rental.Price
+	defer db.DB.Model(&rental).Update("price_per_day", price)
+
+	below := price - 1
+	watch := PriceWatch{UserId: 2, RentalId: rental.ID, Below: &below, BaselinePrice: price}
+	suite.Require().Nil(db.DB.Create(&watch).Error)
+	defer db.DB.Delete(&watch)
+
+	// the price goes back up and drops to the same price again
+	suite.Require().Nil(db.DB.Model(&rental).Update("price_per_day", price/2).Error)
+	suite.Require().Nil(db.DB.Model(&rental).Update("price_per_day", price).Error)
+	suite.Require().Nil(db.DB.Model(&rental).Update("price_per_day", price/2).Error)
+
+	var alerts []PriceAlert
+	suite.Require().Nil(db.DB.Where("price_watch_id = ?", watch.ID).Order("id").Find(&alerts).Error)
+	if suite.Len(alerts, 2, "Should notify both drops") {
+		suite.Equal(price/2, alerts[1].NewPrice)
+		suite.NotEqual(alerts[0].RentalPriceId, alerts[1].RentalPriceId)
+	}
+}
+
+func (suite *PriceWatchTestSuite) TestPriceAlertClaimed() {
+	var rental Rental
+	suite.Require().Nil(db.DB.First(&rental, 11).Error)
+	price := rental.Price
+	defer db.DB.Model(&rental).Update("price_per_day", price)
+
+	below := price - 1
+	watch := PriceWatch{UserId: 2, RentalId: rental.ID, Below: &below, BaselinePrice: price}
+	suite.Require().Nil(db.DB.Create(&watch).Error)
+	defer db.DB.Delete(&watch)
+	suite.Require().Nil(db.DB.Model(&rental).Update("price_per_day", price/2).Error)
+
+	// the alert is claimed before being sent, concurrent deliveries skip it
+	notifier := &concurrentNotifier{}
+	report, err := DeliverPriceAlerts(context.Background(), notifier)
+	if suite.Nil(err) {
+		suite.GreaterOrEqual(report.Delivered, 1)
+	}
+	suite.Empty(notifier.concurrent)
+
+	var alert PriceAlert
+	suite.Require().Nil(db.DB.Where("price_watch_id = ?", watch.ID).First(&alert).Error)
+	suite.NotNil(alert.Delivered)
+	suite.Nil(alert.Sending)
+}
+
+func (suite *PriceWatchTestSuite) TestPriceAlertClaimExpired() {
+	var rental Rental
+	suite.Require().Nil(db.DB.First(&rental, 12).Error)
+	price := rental.Price
+	defer db.DB.Model(&rental).Update("price_per_day", price)
+
+	below := price - 1
+	watch := PriceWatch{UserId: 2, RentalId: rental.ID, Below: &below, BaselinePrice: price}
+	suite.Require().Nil(db.DB.Create(&watch).Error)
+	defer db.DB.Delete(&watch)
+	suite.Require().Nil(db.DB.Model(&rental).Update("price_per_day", price/2).Error)
+
+	// claimed by a run that is still sending
+	var alert PriceAlert
+	suite.Require().Nil(db.DB.Where("price_watch_id = ?", watch.ID).First(&alert).Error)
+	suite.Require().Nil(db.DB.Model(&alert).Update("sending", time.Now()).Error)
+	notifier := &recordingNotifier{}
+	_, err := DeliverPriceAlerts(context.Background(), notifier)
+	suite.Nil(err)
+	suite.Empty(notifier.notifications)
+
+	// claimed by a run that stopped
+	suite.Require().Nil(db.DB.Model(&alert).Update("sending", time.Now().Add(-priceAlertClaimTimeout)).Error)
+	_, err = DeliverPriceAlerts(context.Background(), notifier)
+	suite.Nil(err)
+	suite.Len(notifier.notifications, 1)
+}
+
+func TestPriceWatchTestSuite(t *testing.T) {
+	suite.Run(t, new(PriceWatchTestSuite))
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/samuelg/rentals/db"
+	"github.com/samuelg/rentals/geo"
+	"gorm.io/gorm"
+)
+
+// Problems reported by the data quality checks
+const (
+	QualityMissingZip         = "missing_zip"
+	QualityInvalidZip         = "invalid_zip"
+	QualityZeroLength         = "zero_length"
+	QualityMakeIsModel        = "make_is_model"
+	QualityOutsideCountry     = "outside_country"
+	QualityDuplicateName      = "duplicate_name"
+	QualityTrailingWhitespace = "trailing_whitespace"
+	QualityZeroPrice          = "zero_price"
+)
+
+// Header of the csv data quality report
+var qualityCsvHeader = []string{"rental_id", "user_id", "name", "check", "field", "detail"}
+
+// A problem found on a rental
+type QualityProblem struct {
+	RentalId uint32 `json:"rental_id"`
+	UserId   uint32 `json:"user_id"`
+	Name     string `json:"name"`
+	Check    string `json:"check"`
+	// column with the problem
+	Field  string `json:"field"`
+	Detail string `json:"detail"`
+}
+
+// Result of the data quality checks
+type QualityReport struct {
+	Checked int `json:"checked"`
+	// check to the number of problems found
+	Counts   map[string]int   `json:"counts"`
+	Problems []QualityProblem `json:"problems"`
+}
+
+// Run the data quality checks on all rentals, problems are ordered by rental
+func CheckRentalQuality() (*QualityReport, error) {
+	report := &QualityReport{Counts: make(map[string]int), Problems: make([]QualityProblem, 0)}
+	// owner and name to the first rental using it
+	names := make(map[string]uint32)
+	var rentals []Rental
+
+	result := db.DB.Order("id").FindInBatches(&rentals, backfillBatchSize, func(tx *gorm.DB, batch int) error {
+		for i := range rentals {
+			rental := &rentals[i]
+			report.Checked++
+
+			for _, problem := range rental.QualityProblems() {
+				report.add(problem)
+			}
+
+			key := fmt.Sprintf("%d:%s", rental.UserId, strings.ToLower(strings.Join(strings.Fields(rental.Name), " ")))
+			if first, ok := names[key]; ok {
+				report.add(rental.qualityProblem(QualityDuplicateName, "name", fmt.Sprintf("same name as rental %d", first)))
+			} else {
+				names[key] = rental.ID
+			}
+		}
+
+		return nil
+	})
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return report, nil
+}
+
+func (report *QualityReport) add(problem QualityProblem) {
+	report.Counts[problem.Check]++
+	report.Problems = append(report.Problems, problem)
+}
+
+// Write the problems as csv, one row per problem
+func (report *QualityReport) WriteCsv(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(qualityCsvHeader); err != nil {
+		return err
+	}
+	for _, problem := range report.Problems {
+		row := []string{
+			strconv.FormatUint(uint64(problem.RentalId), 10),
+			strconv.FormatUint(uint64(problem.UserId), 10),
+			problem.Name,
+			problem.Check,
+			problem.Field,
+			problem.Detail,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// Problems found on the rental alone, duplicate names are checked across rentals
+func (rental *Rental) QualityProblems() []QualityProblem {
+	problems := make([]QualityProblem, 0)
+
+	if strings.TrimSpace(rental.Zip) == "" {
+		problems = append(problems, rental.qualityProblem(QualityMissingZip, "home_zip", ""))
+	} else if !geo.ValidZip(rental.Country, rental.Zip) {
+		problems = append(problems, rental.qualityProblem(QualityInvalidZip, "home_zip", fmt.Sprintf("%q is not a %s postal code", rental.Zip, rental.Country)))
+	}
+
+	if rental.VehicleLength == 0 {
+		problems = append(problems, rental.qualityProblem(QualityZeroLength, "vehicle_length", ""))
+	}
+
+	// saving normalizes the make and model, the problems are in the make and
+	// model as entered. Rentals written before the catalog only have the
+	// canonical make and model
+	makeField, makeInput := "vehicle_make_input", rental.VehicleMakeInput
+	modelField, modelInput := "vehicle_model_input", rental.VehicleModelInput
+	if makeInput == "" && modelInput == "" {
+		makeField, makeInput = "vehicle_make", rental.VehicleMake
+		modelField, modelInput = "vehicle_model", rental.VehicleModel
+	}
+
+	if makeInput != "" && strings.EqualFold(strings.TrimSpace(makeInput), strings.TrimSpace(modelInput)) {
+		problems = append(problems, rental.qualityProblem(QualityMakeIsModel, modelField, modelInput))
+	}
+
+	if rental.Lat != 0 || rental.Lng != 0 {
+		inCountry, known := geo.Default().InCountry(strings.ToUpper(strings.TrimSpace(rental.Country)), float64(rental.Lat), float64(rental.Lng))
+		if known && !inCountry {
+			problems = append(problems, rental.qualityProblem(QualityOutsideCountry, "lat,lng", fmt.Sprintf("%.2f,%.2f is outside %s", rental.Lat, rental.Lng, rental.Country)))
+		}
+	}
+
+	texts := []struct {
+		field string
+		value string
+	}{
+		{"name", rental.Name},
+		{"description", rental.Description},
+		{"home_city", rental.City},
+		{"home_state", rental.State},
+		{"home_zip", rental.Zip},
+		{"home_country", rental.Country},
+		{makeField, makeInput},
+		{modelField, modelInput},
+	}
+	for _, text := range texts {
+		if strings.TrimRightFunc(text.value, unicode.IsSpace) != text.value {
+			problems = append(problems, rental.qualityProblem(QualityTrailingWhitespace, text.field, fmt.Sprintf("%q", text.value)))
+		}
+	}
+
+	if rental.Price == 0 {
+		problems = append(problems, rental.qualityProblem(QualityZeroPrice, "price_per_day", ""))
+	}
+
+	return problems
+}
+
+func (rental *Rental) qualityProblem(check string, field string, detail string) QualityProblem {
+	return QualityProblem{
+		RentalId: rental.ID,
+		UserId:   rental.UserId,
+		Name:     rental.Name,
+		Check:    check,
+		Field:    field,
+		Detail:   detail,
+	}
+}
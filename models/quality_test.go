@@ -0,0 +1,109 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the data quality checks
+type QualityModelTestSuite struct {
+	suite.Suite
+	config *config.Config
+}
+
+func (suite *QualityModelTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+}
+
+func checks(problems []QualityProblem) []string {
+	names := make([]string, len(problems))
+	for i, problem := range problems {
+		names[i] = problem.Check
+	}
+	return names
+}
+
+func (suite *QualityModelTestSuite) TestQualityProblemsValid() {
+	rental := Rental{
+		ID: 1, Name: "Westy", Price: 16900, City: "Costa Mesa", State: "CA", Zip: "92627", Country: "US",
+		VehicleMake: "Volkswagen", VehicleModel: "Bay Window", VehicleLength: 15, Lat: 33.64, Lng: -117.93,
+	}
+
+	suite.Empty(rental.QualityProblems())
+}
+
+func (suite *QualityModelTestSuite) TestQualityProblems() {
+	rental := Rental{
+		ID: 1, Name: "Westy ", Price: 0, City: "Costa Mesa", State: "CA", Zip: "9262", Country: "US",
+		VehicleMake: "Eurovan", VehicleModel: "eurovan", VehicleLength: 0, Lat: 51.5, Lng: -0.12,
+	}
+
+	suite.Equal([]string{
+		QualityInvalidZip,
+		QualityZeroLength,
+		QualityMakeIsModel,
+		QualityOutsideCountry,
+		QualityTrailingWhitespace,
+		QualityZeroPrice,
+	}, checks(rental.QualityProblems()))
+
+	rental.Zip = ""
+	suite.Contains(checks(rental.QualityProblems()), QualityMissingZip)
+}
+
+func (suite *QualityModelTestSuite) TestQualityProblemsSavedRental() {
+	ctx := context.Background()
+	rental := Rental{
+		UserId: 3, Name: "Quality Van", Type: "camper-van", Sleeps: 2, Price: 12000, City: "Denver", State: "CO", Zip: "80202", Country: "US",
+		VehicleMake: "Winnebago ", VehicleModel: "winnebago", VehicleLength: 19,
+	}
+	suite.Require().Nil(CreateRental(ctx, &rental))
+	defer DeleteRental(ctx, &rental)
+
+	// the canonical make and model are normalized when saving
+	var saved Rental
+	suite.Require().Nil(db.DB.First(&saved, rental.ID).Error)
+	suite.Equal("Winnebago ", saved.VehicleMakeInput)
+
+	problems := saved.QualityProblems()
+	suite.Equal([]string{QualityMakeIsModel, QualityTrailingWhitespace}, checks(problems))
+	if suite.Len(problems, 2) {
+		suite.Equal("vehicle_model_input", problems[0].Field)
+		suite.Equal("vehicle_make_input", problems[1].Field)
+	}
+}
+
+func (suite *QualityModelTestSuite) TestWriteCsv() {
+	report := &QualityReport{Problems: []QualityProblem{
+		{RentalId: 5, UserId: 1, Name: "Eurovan, Camper", Check: QualityZeroLength, Field: "vehicle_length"},
+	}}
+	var out bytes.Buffer
+
+	if suite.Nil(report.WriteCsv(&out), "Should not lead to an error") {
+		suite.Equal("rental_id,user_id,name,check,field,detail\n5,1,\"Eurovan, Camper\",zero_length,vehicle_length,\n", out.String())
+	}
+}
+
+func (suite *QualityModelTestSuite) TestCheckRentalQuality() {
+	report, err := CheckRentalQuality()
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Greater(report.Checked, 0)
+		// seeded rental without a zip
+		suite.Greater(report.Counts[QualityMissingZip], 0)
+	}
+}
+
+func TestQualityModelTestSuite(t *testing.T) {
+	suite.Run(t, new(QualityModelTestSuite))
+}
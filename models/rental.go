@@ -1,8 +1,13 @@
 package models
 
 import (
+	"context"
 	"encoding/json"
 	"time"
+
+	"github.com/samuelg/rentals/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Rentals model
@@ -11,25 +16,45 @@ type Rental struct {
 	ID     uint32 `gorm:"primary_key;autoincrement;column:id"`
 	UserId uint32 `gorm:"column:user_id"`
 	// many to one association
-	User            User
-	Name            string    `gorm:"column:name"`
-	Type            string    `gorm:"column:type"`
-	Description     string    `gorm:"column:description"`
-	Sleeps          int32     `gorm:"column:sleeps"`
-	Price           int64     `gorm:"column:price_per_day"`
-	City            string    `gorm:"column:home_city"`
-	State           string    `gorm:"column:home_state"`
-	Zip             string    `gorm:"column:home_zip"`
-	Country         string    `gorm:"column:home_country"`
-	VehicleMake     string    `gorm:"column:vehicle_make"`
-	VehicleModel    string    `gorm:"column:vehicle_model"`
-	VehicleYear     int32     `gorm:"column:vehicle_year"`
-	VehicleLength   float32   `gorm:"column:vehicle_length;precision:4;scale:2"`
-	Created         time.Time `gorm:"column:created"`
-	Updated         time.Time `gorm:"column:updated"`
-	Lat             float32   `gorm:"column:lat"`
-	Lng             float32   `gorm:"column:lng"`
-	PrimaryImageUrl string    `gorm:"column:primary_image_url"`
+	User        User
+	Name        string `gorm:"column:name"`
+	Type        string `gorm:"column:type"`
+	Description string `gorm:"column:description"`
+	Sleeps      int32  `gorm:"column:sleeps"`
+	Price       int64  `gorm:"column:price_per_day"`
+	City        string `gorm:"column:home_city"`
+	State       string `gorm:"column:home_state"`
+	Zip         string `gorm:"column:home_zip"`
+	Country     string `gorm:"column:home_country"`
+	// id of the rental in the system of its owner, set by imports
+	ExternalId *string `gorm:"column:external_id"`
+	// canonical make and model from the vehicles catalog
+	VehicleMake  string `gorm:"column:vehicle_make"`
+	VehicleModel string `gorm:"column:vehicle_model"`
+	// make and model as entered, the canonical ones are derived from them
+	VehicleMakeInput  string    `gorm:"column:vehicle_make_input"`
+	VehicleModelInput string    `gorm:"column:vehicle_model_input"`
+	VehicleYear       int32     `gorm:"column:vehicle_year"`
+	VehicleLength     float32   `gorm:"column:vehicle_length;precision:4;scale:2"`
+	Created           time.Time `gorm:"column:created"`
+	Updated           time.Time `gorm:"column:updated"`
+	Lat               float32   `gorm:"column:lat"`
+	Lng               float32   `gorm:"column:lng"`
+	PrimaryImageUrl   string    `gorm:"column:primary_image_url"`
+	// comma separated geo.Issue values, empty when the location checks out
+	LocationIssues string `gorm:"column:location_issues"`
+	// rating aggregates are maintained when reviews are created, read only here
+	RatingAverage *float64 `gorm:"column:rating_average;->"`
+	ReviewCount   int32    `gorm:"column:review_count;->"`
+	// fuel, transmission and other specifications of the vehicle
+	VehicleSpecs `gorm:"embedded"`
+	// many to many association
+	Amenities []Amenity `gorm:"many2many:rental_amenities"`
+	// one to many association, the photo gallery
+	Images []RentalImage `gorm:"foreignKey:RentalId"`
+	// stored row while an update or delete runs, used for the history and to
+	// detect price drops
+	stored *Rental
 }
 
 // Response for rentals operations
@@ -50,25 +75,36 @@ type UserResponse struct {
 	LastName  string `json:"last_name"`
 }
 type RentalResponse struct {
-	ID              uint32           `json:"id"`
-	Name            string           `json:"name"`
-	Description     string           `json:"description"`
-	Type            string           `json:"type"`
-	Make            string           `json:"make"`
-	Model           string           `json:"model"`
-	Year            int32            `json:"year"`
-	Length          float32          `json:"length"`
-	Sleeps          int32            `json:"sleeps"`
-	PrimaryImageUrl string           `json:"primary_image_url"`
-	Price           PriceReponse     `json:"price"`
-	Location        LocationResponse `json:"location"`
-	User            UserResponse     `json:"user"`
+	ID              uint32                `json:"id"`
+	ExternalId      *string               `json:"external_id,omitempty"`
+	Name            string                `json:"name"`
+	Description     string                `json:"description"`
+	Type            string                `json:"type"`
+	Make            string                `json:"make"`
+	Model           string                `json:"model"`
+	Year            int32                 `json:"year"`
+	Length          float32               `json:"length"`
+	Sleeps          int32                 `json:"sleeps"`
+	PrimaryImageUrl string                `json:"primary_image_url"`
+	Price           PriceReponse          `json:"price"`
+	Location        LocationResponse      `json:"location"`
+	User            UserResponse          `json:"user"`
+	Amenities       []string              `json:"amenities"`
+	Vehicle         VehicleResponse       `json:"vehicle"`
+	RatingAverage   *float64              `json:"rating_average"`
+	ReviewCount     int32                 `json:"review_count"`
+	Images          []RentalImageResponse `json:"images"`
 }
 
 // Custom JSON format for the response
 func (rental Rental) MarshalJSON() ([]byte, error) {
-	return json.Marshal(&RentalResponse{
+	return json.Marshal(rental.response())
+}
+
+func (rental Rental) response() *RentalResponse {
+	return &RentalResponse{
 		ID:              rental.ID,
+		ExternalId:      rental.ExternalId,
 		Name:            rental.Name,
 		Description:     rental.Description,
 		Type:            rental.Type,
@@ -92,5 +128,72 @@ func (rental Rental) MarshalJSON() ([]byte, error) {
 			FirstName: rental.User.FirstName,
 			LastName:  rental.User.LastName,
 		},
+		Amenities:     amenityKeys(rental.Amenities),
+		Vehicle:       rental.VehicleSpecs.response(),
+		RatingAverage: rental.RatingAverage,
+		ReviewCount:   rental.ReviewCount,
+		Images:        rentalImageResponses(rental.Images),
+	}
+}
+
+// Derive the location and the canonical make and model whenever a rental is
+// created or updated
+func (rental *Rental) BeforeSave(tx *gorm.DB) error {
+	rental.saveLocation(tx)
+	rental.saveVehicle(tx)
+
+	return nil
+}
+
+// Create a rental, the location is resolved by the BeforeSave hook
+func CreateRental(ctx context.Context, rental *Rental) error {
+	return createRental(db.DB.WithContext(ctx), rental)
+}
+
+func createRental(tx *gorm.DB, rental *Rental) error {
+	now := time.Now()
+	rental.Created = now
+	rental.Updated = now
+
+	return tx.Omit(clause.Associations).Create(rental).Error
+}
+
+// Save the changes to a rental, associations are updated separately
+func UpdateRental(ctx context.Context, rental *Rental) error {
+	return updateRental(db.DB.WithContext(ctx), rental)
+}
+
+func updateRental(tx *gorm.DB, rental *Rental) error {
+	rental.Updated = time.Now()
+
+	return tx.Omit(clause.Associations).Save(rental).Error
+}
+
+// Remove a rental along with the files of its photo gallery
+func DeleteRental(ctx context.Context, rental *Rental) error {
+	var rentalImages []RentalImage
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		rentalImages, err = deleteRental(tx, rental)
+		return err
 	})
+	if err != nil {
+		return err
+	}
+
+	for i := range rentalImages {
+		deleteImageFiles(ctx, &rentalImages[i])
+	}
+	return nil
+}
+
+// Delete the rental in the transaction, returns its images so their files can
+// be deleted once committed
+func deleteRental(tx *gorm.DB, rental *Rental) ([]RentalImage, error) {
+	var rentalImages []RentalImage
+	if err := tx.Where("rental_id = ?", rental.ID).Find(&rentalImages).Error; err != nil {
+		return nil, err
+	}
+	// images, reviews and watches are removed by the database
+	return rentalImages, tx.Delete(rental).Error
 }
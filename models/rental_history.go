@@ -0,0 +1,254 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/db"
+	"golang.org/x/exp/slices"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Actions recorded in the rental history
+const (
+	RentalCreated = "create"
+	RentalUpdated = "update"
+	RentalDeleted = "delete"
+)
+
+// Columns left out of the history, they change on every update
+var untrackedRentalColumns = []string{"id", "created", "updated"}
+
+// Before and after values of a rental column, nil when the rental didn't exist
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// RentalHistory model, an append only record of a change to a rental, records
+// are kept after the rental is deleted
+type RentalHistory struct {
+	ID       uint32 `gorm:"primary_key;autoincrement;column:id"`
+	RentalId uint32 `gorm:"column:rental_id"`
+	// user making the change, nil for admin commands
+	ActorId *uint32 `gorm:"column:actor_id"`
+	Action  string  `gorm:"column:action"`
+	// column name to its before and after values, only changed columns
+	Changes map[string]FieldChange `gorm:"column:changes;serializer:json"`
+	Created time.Time              `gorm:"column:created;autoCreateTime"`
+}
+
+func (RentalHistory) TableName() string {
+	return "rental_history"
+}
+
+type RentalHistoryResponse struct {
+	ID       uint32                 `json:"id"`
+	RentalId uint32                 `json:"rental_id"`
+	ActorId  *uint32                `json:"actor_id"`
+	Action   string                 `json:"action"`
+	Changes  map[string]FieldChange `json:"changes"`
+	Created  time.Time              `json:"created"`
+}
+
+// Custom JSON format for the response
+func (history RentalHistory) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&RentalHistoryResponse{
+		ID:       history.ID,
+		RentalId: history.RentalId,
+		ActorId:  history.ActorId,
+		Action:   history.Action,
+		Changes:  history.Changes,
+		Created:  history.Created,
+	})
+}
+
+// Record the created rental
+func (rental *Rental) AfterCreate(tx *gorm.DB) error {
+	created, err := loadStoredRental(tx, rental.ID)
+	if err != nil || created == nil {
+		return err
+	}
+
+	return recordRentalChange(tx, rental.ID, RentalCreated, diffRentals(nil, created))
+}
+
+// Remember the stored rental, updates must go through a rental with its id to
+// be recorded
+func (rental *Rental) BeforeUpdate(tx *gorm.DB) (err error) {
+	rental.stored, err = loadStoredRental(tx, rental.ID)
+	return err
+}
+
+// Record the changed columns and queue alerts for the watches triggered by a
+// price change. The history is written by these gorm hooks, not the database:
+// raw UPDATE rentals statements and Model(&Rental{}).Where(...).Updates calls
+// without a primary key leave no record and trigger no alert.
+func (rental *Rental) AfterUpdate(tx *gorm.DB) error {
+	if rental.stored == nil {
+		return nil
+	}
+	stored := rental.stored
+	rental.stored = nil
+
+	// the update may not have set the columns on the struct (ex: map updates)
+	updated, err := loadStoredRental(tx, rental.ID)
+	if err != nil || updated == nil {
+		return err
+	}
+
+	changes := diffRentals(stored, updated)
+	if len(changes) == 0 {
+		return nil
+	}
+	if err := recordRentalChange(tx, rental.ID, RentalUpdated, changes); err != nil {
+		return err
+	}
+
+	if updated.Price != stored.Price {
+		return queuePriceAlerts(tx.Session(&gorm.Session{NewDB: true}), rental.ID, stored.Price, updated.Price)
+	}
+	return nil
+}
+
+// Remember the stored rental
+func (rental *Rental) BeforeDelete(tx *gorm.DB) (err error) {
+	rental.stored, err = loadStoredRental(tx, rental.ID)
+	return err
+}
+
+// Record the deleted rental
+func (rental *Rental) AfterDelete(tx *gorm.DB) error {
+	if rental.stored == nil {
+		return nil
+	}
+	stored := rental.stored
+	rental.stored = nil
+
+	return recordRentalChange(tx, rental.ID, RentalDeleted, diffRentals(stored, nil))
+}
+
+// Load the rental as stored in the database, nil when it doesn't exist
+func loadStoredRental(tx *gorm.DB, rentalId uint32) (*Rental, error) {
+	if rentalId == 0 {
+		return nil, nil
+	}
+
+	var stored Rental
+	err := tx.Session(&gorm.Session{NewDB: true}).Where("id = ?", rentalId).Take(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
+func recordRentalChange(tx *gorm.DB, rentalId uint32, action string, changes map[string]FieldChange) error {
+	history := RentalHistory{
+		RentalId: rentalId,
+		ActorId:  actorId(tx.Statement.Context),
+		Action:   action,
+		Changes:  changes,
+	}
+
+	return tx.Session(&gorm.Session{NewDB: true}).Create(&history).Error
+}
+
+// Id of the user making the change, set on the request context by auth.Identify
+func actorId(ctx context.Context) *uint32 {
+	if userId, ok := auth.ContextUserId(ctx); ok {
+		return &userId
+	}
+	return nil
+}
+
+var (
+	rentalSchemaOnce sync.Once
+	rentalSchema     *schema.Schema
+)
+
+// Writable columns of a rental, read only aggregates are maintained elsewhere
+func rentalFields() []*schema.Field {
+	rentalSchemaOnce.Do(func() {
+		var err error
+		rentalSchema, err = schema.Parse(&Rental{}, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			panic(err)
+		}
+	})
+
+	fields := make([]*schema.Field, 0, len(rentalSchema.DBNames))
+	for _, field := range rentalSchema.Fields {
+		if field.DBName == "" || !field.Creatable || !field.Updatable {
+			continue
+		}
+		if !slices.Contains(untrackedRentalColumns, field.DBName) {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// Field level diff of two versions of a rental keyed by column, a nil version
+// means the rental didn't exist
+func diffRentals(before *Rental, after *Rental) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+	for _, field := range rentalFields() {
+		var beforeValue, afterValue interface{}
+		if before != nil {
+			beforeValue = fieldValue(field, before)
+		}
+		if after != nil {
+			afterValue = fieldValue(field, after)
+		}
+		if !reflect.DeepEqual(beforeValue, afterValue) {
+			changes[field.DBName] = FieldChange{Before: beforeValue, After: afterValue}
+		}
+	}
+
+	return changes
+}
+
+// Value of the column, nil pointers are kept as nil
+func fieldValue(field *schema.Field, rental *Rental) interface{} {
+	value, _ := field.ValueOf(context.Background(), reflect.ValueOf(rental).Elem())
+	if reflected := reflect.ValueOf(value); reflected.Kind() == reflect.Pointer {
+		if reflected.IsNil() {
+			return nil
+		}
+		return reflected.Elem().Interface()
+	}
+	return value
+}
+
+// Find the history of a rental, most recent first
+func FindRentalHistory(rentalId uint32, limit uint8, offset uint32) ([]RentalHistory, uint32, error) {
+	history := make([]RentalHistory, 0)
+	var count int64
+
+	query := db.DB.Model(&RentalHistory{}).Where("rental_id = ?", rentalId)
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := db.DB.Where("rental_id = ?", rentalId).
+		Order("created DESC, id DESC").
+		Limit(int(limit)).
+		Offset(int(offset)).
+		Find(&history).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return history, uint32(count), nil
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samuelg/rentals/auth"
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the rental history
+type RentalHistoryTestSuite struct {
+	suite.Suite
+	config *config.Config
+}
+
+func (suite *RentalHistoryTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+}
+
+func (suite *RentalHistoryTestSuite) TestDiffRentals() {
+	diesel := "diesel"
+	before := &Rental{ID: 1, Name: "Van", Price: 10000, Sleeps: 2}
+	after := &Rental{ID: 1, Name: "Van", Price: 9000, Sleeps: 2, VehicleSpecs: VehicleSpecs{FuelType: &diesel}}
+
+	changes := diffRentals(before, after)
+	suite.Equal(map[string]FieldChange{
+		"price_per_day": {Before: int64(10000), After: int64(9000)},
+		"fuel_type":     {Before: nil, After: "diesel"},
+	}, changes)
+}
+
+func (suite *RentalHistoryTestSuite) TestDiffRentalsSkipsUntracked() {
+	rental := &Rental{ID: 1, Name: "Van", ReviewCount: 3}
+
+	changes := diffRentals(nil, rental)
+	suite.Contains(changes, "name")
+	suite.NotContains(changes, "id")
+	suite.NotContains(changes, "created")
+	// maintained by reviews
+	suite.NotContains(changes, "review_count")
+	suite.Equal(FieldChange{Before: "Van", After: nil}, diffRentals(rental, nil)["name"])
+}
+
+func (suite *RentalHistoryTestSuite) TestRentalLifecycleHistory() {
+	ctx := auth.WithUserId(context.Background(), 3)
+	rental := Rental{UserId: 3, Name: "History Van", Type: "camper-van", Sleeps: 2, Price: 12000}
+	suite.Require().Nil(CreateRental(ctx, &rental))
+
+	rental.Price = 11000
+	rental.Sleeps = 3
+	suite.Require().Nil(UpdateRental(ctx, &rental))
+	// saving without changes is not recorded
+	suite.Require().Nil(UpdateRental(ctx, &rental))
+	// admin commands have no actor
+	suite.Require().Nil(db.DB.Model(&Rental{ID: rental.ID}).Update("name", "Renamed Van").Error)
+	suite.Require().Nil(DeleteRental(ctx, &rental))
+
+	history, count, err := FindRentalHistory(rental.ID, 10, 0)
+	suite.Require().Nil(err)
+	suite.Require().Equal(uint32(4), count)
+
+	// most recent first
+	suite.Equal(RentalDeleted, history[0].Action)
+	suite.Equal("Renamed Van", history[0].Changes["name"].Before)
+	suite.Nil(history[0].Changes["name"].After)
+
+	suite.Equal(RentalUpdated, history[1].Action)
+	suite.Nil(history[1].ActorId)
+	suite.Equal(map[string]FieldChange{"name": {Before: "History Van", After: "Renamed Van"}}, history[1].Changes)
+
+	suite.Equal(RentalUpdated, history[2].Action)
+	if suite.NotNil(history[2].ActorId) {
+		suite.Equal(uint32(3), *history[2].ActorId)
+	}
+	// values are read back from JSON
+	suite.Equal(FieldChange{Before: float64(12000), After: float64(11000)}, history[2].Changes["price_per_day"])
+	suite.Equal(FieldChange{Before: float64(2), After: float64(3)}, history[2].Changes["sleeps"])
+	suite.Len(history[2].Changes, 2)
+
+	suite.Equal(RentalCreated, history[3].Action)
+	suite.Nil(history[3].Changes["name"].Before)
+	suite.Equal("History Van", history[3].Changes["name"].After)
+
+	// history records can't be changed
+	suite.NotNil(db.DB.Model(&history[0]).Update("action", RentalCreated).Error)
+}
+
+func TestRentalHistoryTestSuite(t *testing.T) {
+	suite.Run(t, new(RentalHistoryTestSuite))
+}
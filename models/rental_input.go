@@ -0,0 +1,137 @@
+package models
+
+import (
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Fields to create or update a rental, uses the format of the response. Updates
+// only change the fields that are set
+type RentalInput struct {
+	// id of the rental in the system of its owner, unique per owner
+	ExternalId  *string              `json:"external_id" binding:"omitempty,min=1,max=100"`
+	Name        *string              `json:"name" binding:"omitempty,min=1,max=200"`
+	Description *string              `json:"description"`
+	Type        *string              `json:"type" binding:"omitempty,min=1,max=50"`
+	Make        *string              `json:"make"`
+	Model       *string              `json:"model"`
+	Year        *int32               `json:"year" binding:"omitempty,min=1900,max=2100"`
+	Length      *float32             `json:"length" binding:"omitempty,gt=0,lt=100"`
+	Sleeps      *int32               `json:"sleeps" binding:"omitempty,min=1,max=50"`
+	Price       *RentalPriceInput    `json:"price"`
+	Location    *RentalLocationInput `json:"location"`
+	Vehicle     *RentalVehicleInput  `json:"vehicle"`
+}
+
+type RentalPriceInput struct {
+	Day *int64 `json:"day" binding:"omitempty,min=1"`
+}
+
+type RentalLocationInput struct {
+	City    *string  `json:"city"`
+	State   *string  `json:"state"`
+	Zip     *string  `json:"zip"`
+	Country *string  `json:"country"`
+	Lat     *float32 `json:"lat" binding:"omitempty,min=-90,max=90"`
+	Lng     *float32 `json:"lng" binding:"omitempty,min=-180,max=180"`
+}
+
+type RentalVehicleInput struct {
+	FuelType           *string `json:"fuel_type" binding:"omitempty,oneof=gasoline diesel electric hybrid propane"`
+	Transmission       *string `json:"transmission" binding:"omitempty,oneof=automatic manual"`
+	Drivetrain         *string `json:"drivetrain" binding:"omitempty,oneof=fwd rwd awd 4wd"`
+	Seatbelts          *int32  `json:"seatbelts" binding:"omitempty,min=0"`
+	TowCapacity        *int32  `json:"tow_capacity" binding:"omitempty,min=0"`
+	FreshWaterCapacity *int32  `json:"fresh_water_capacity" binding:"omitempty,min=0"`
+	Generator          *bool   `json:"generator"`
+	EvRange            *int32  `json:"ev_range" binding:"omitempty,min=0"`
+}
+
+// Check the values of the input with the rules of its binding tags, requests
+// binding the input are checked the same way
+func (input *RentalInput) Validate() error {
+	return binding.Validator.ValidateStruct(input)
+}
+
+// Returns the first field required to create a rental that is missing, if any
+func (input *RentalInput) Missing() string {
+	switch {
+	case input.Name == nil:
+		return "name"
+	case input.Type == nil:
+		return "type"
+	case input.Sleeps == nil:
+		return "sleeps"
+	case input.Price == nil || input.Price.Day == nil:
+		return "price.day"
+	case input.Location == nil || input.Location.Lat == nil:
+		return "location.lat"
+	case input.Location.Lng == nil:
+		return "location.lng"
+	}
+	return ""
+}
+
+// Set the fields of the input on the rental
+func (input *RentalInput) Apply(rental *Rental) {
+	if input.ExternalId != nil {
+		rental.ExternalId = input.ExternalId
+	}
+	setIfPresent(&rental.Name, input.Name)
+	setIfPresent(&rental.Description, input.Description)
+	setIfPresent(&rental.Type, input.Type)
+	// the canonical make and model are derived when saving, from the make and
+	// model as entered (rentals written before the catalog only have the former)
+	if rental.VehicleMakeInput == "" && rental.VehicleModelInput == "" {
+		rental.VehicleMakeInput, rental.VehicleModelInput = rental.VehicleMake, rental.VehicleModel
+	}
+	setIfPresent(&rental.VehicleMakeInput, input.Make)
+	setIfPresent(&rental.VehicleModelInput, input.Model)
+	setIfPresent(&rental.VehicleYear, input.Year)
+	setIfPresent(&rental.VehicleLength, input.Length)
+	setIfPresent(&rental.Sleeps, input.Sleeps)
+	if price := input.Price; price != nil {
+		setIfPresent(&rental.Price, price.Day)
+	}
+	if location := input.Location; location != nil {
+		setIfPresent(&rental.City, location.City)
+		setIfPresent(&rental.State, location.State)
+		setIfPresent(&rental.Zip, location.Zip)
+		setIfPresent(&rental.Country, location.Country)
+		setIfPresent(&rental.Lat, location.Lat)
+		setIfPresent(&rental.Lng, location.Lng)
+	}
+	// vehicle specifications can't be unset once known
+	if vehicle := input.Vehicle; vehicle != nil {
+		specs := &rental.VehicleSpecs
+		if vehicle.FuelType != nil {
+			specs.FuelType = vehicle.FuelType
+		}
+		if vehicle.Transmission != nil {
+			specs.Transmission = vehicle.Transmission
+		}
+		if vehicle.Drivetrain != nil {
+			specs.Drivetrain = vehicle.Drivetrain
+		}
+		if vehicle.Seatbelts != nil {
+			specs.Seatbelts = vehicle.Seatbelts
+		}
+		if vehicle.TowCapacity != nil {
+			specs.TowCapacity = vehicle.TowCapacity
+		}
+		if vehicle.FreshWaterCapacity != nil {
+			specs.FreshWaterCapacity = vehicle.FreshWaterCapacity
+		}
+		if vehicle.Generator != nil {
+			specs.Generator = vehicle.Generator
+		}
+		if vehicle.EvRange != nil {
+			specs.EvRange = vehicle.EvRange
+		}
+	}
+}
+
+func setIfPresent[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
@@ -20,6 +20,7 @@ func (suite *RentalModelTestSuite) SetupSuite() {
 	config.Init("test")
 	log.Init("FATAL", config.GetConfig().AppVersion)
 	db.Init()
+	db.Prepare()
 	suite.config = config.GetConfig()
 }
 
@@ -58,7 +59,46 @@ func (suite *RentalModelTestSuite) TestMarshallJson() {
 		`"primary_image_url":"http://images.com/1.png","price":{"day":1000},`+
 		`"location":{"city":"Huntsville","state":"AL","zip":"35758",`+
 		`"country":"US","lat":36.1,"lng":-86.4},"user":{"id":1,`+
-		`"first_name":"Bob","last_name":"Smith"}}`, string(bytes))
+		`"first_name":"Bob","last_name":"Smith"},"amenities":[],`+
+		`"vehicle":{"fuel_type":null,"transmission":null,"drivetrain":null,`+
+		`"seatbelts":null,"tow_capacity":null,"fresh_water_capacity":null,`+
+		`"generator":null,"ev_range":null},"rating_average":null,"review_count":0,"images":[]}`, string(bytes))
+}
+
+func (suite *RentalModelTestSuite) TestMarshallJsonVehicleSpecs() {
+	fuelType := "diesel"
+	seatbelts := int32(4)
+	generator := true
+	rental := Rental{
+		ID:        1,
+		Amenities: []Amenity{{ID: 2, Key: "kitchen", Name: "Kitchen"}},
+		VehicleSpecs: VehicleSpecs{
+			FuelType:  &fuelType,
+			Seatbelts: &seatbelts,
+			Generator: &generator,
+		},
+	}
+
+	bytes, err := json.Marshal(rental)
+	if suite.Nil(err, "Should be able to marshal") {
+		var response RentalResponse
+		suite.Nil(json.Unmarshal(bytes, &response))
+		suite.Equal([]string{"kitchen"}, response.Amenities)
+		suite.Equal("diesel", *response.Vehicle.FuelType)
+		suite.Equal(int32(4), *response.Vehicle.Seatbelts)
+		suite.True(*response.Vehicle.Generator)
+		suite.Nil(response.Vehicle.Transmission)
+	}
+}
+
+func (suite *RentalModelTestSuite) TestNormalizeVehiclesDryRun() {
+	report, err := NormalizeVehicles(true)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Greater(report.Checked, 0)
+		suite.LessOrEqual(report.Updated, report.Checked)
+		suite.NotNil(report.Unknown)
+	}
 }
 
 func TestRentalModelTestSuite(t *testing.T) {
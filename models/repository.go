@@ -0,0 +1,263 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samuelg/rentals/geo"
+	"golang.org/x/exp/slices"
+	"gorm.io/gorm"
+)
+
+// Finds rentals for the read endpoints, lets controllers run against the
+// database or against rentals held in memory
+type RentalRepository interface {
+	// Rentals matching the filter with its pagination and sort, and the count of
+	// all the matching rentals
+	Find(ctx context.Context, filter *Filter) ([]Rental, uint32, error)
+	// Rental with its user, amenities and images, as it was at asOf when set.
+	// Returns gorm.ErrRecordNotFound when there is no such rental
+	Get(ctx context.Context, id uint32, asOf *time.Time) (*Rental, error)
+}
+
+// Repository of the rentals stored in the database
+type GormRentalRepository struct{}
+
+func (r GormRentalRepository) Find(ctx context.Context, filter *Filter) ([]Rental, uint32, error) {
+	return filter.Find(ctx)
+}
+
+func (r GormRentalRepository) Get(ctx context.Context, id uint32, asOf *time.Time) (*Rental, error) {
+	var rental Rental
+	query := RentalsAsOf(ctx, asOf).Joins("User").Preload("Amenities").Preload("Images", OrderImages)
+	if err := query.First(&rental, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &rental, nil
+}
+
+// Repository of rentals held in memory, used by tests and the demo server. It
+// applies the same filters, sort and pagination as the database. There is no
+// history, as_of only hides the rentals created after it
+type MemoryRentalRepository struct {
+	mutex   sync.RWMutex
+	rentals []Rental
+}
+
+// Repository holding a copy of the rentals, their ids must be set
+func NewMemoryRentalRepository(rentals []Rental) *MemoryRentalRepository {
+	return &MemoryRentalRepository{rentals: slices.Clone(rentals)}
+}
+
+// Add a rental or replace the one with the same id
+func (r *MemoryRentalRepository) Save(rental Rental) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	i := slices.IndexFunc(r.rentals, func(stored Rental) bool { return stored.ID == rental.ID })
+	if i == -1 {
+		r.rentals = append(r.rentals, rental)
+	} else {
+		r.rentals[i] = rental
+	}
+}
+
+func (r *MemoryRentalRepository) Find(ctx context.Context, filter *Filter) ([]Rental, uint32, error) {
+	r.mutex.RLock()
+	matches := make([]Rental, 0)
+	for _, rental := range r.rentals {
+		if filter.Matches(&rental) {
+			matches = append(matches, rental)
+		}
+	}
+	r.mutex.RUnlock()
+
+	compare := rentalOrder(filter.Sort)
+	slices.SortFunc(matches, func(a Rental, b Rental) int {
+		// ids break ties so pages are stable
+		return cmp.Or(compare(&a, &b), cmp.Compare(a.ID, b.ID))
+	})
+
+	count := uint32(len(matches))
+	start := min(filter.Offset, count)
+	end := min(start+uint32(filter.Limit), count)
+
+	return matches[start:end], count, nil
+}
+
+func (r *MemoryRentalRepository) Get(ctx context.Context, id uint32, asOf *time.Time) (*Rental, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, rental := range r.rentals {
+		if rental.ID == id && (asOf == nil || !rental.Created.After(*asOf)) {
+			return &rental, nil
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Whether the rental matches the conditions of the filter, mirrors Conditions.
+// Pagination and sort don't apply
+func (filter *Filter) Matches(rental *Rental) bool {
+	if filter.AsOf != nil && rental.Created.After(*filter.AsOf) {
+		return false
+	}
+	if filter.PriceMin != nil && rental.Price < *filter.PriceMin {
+		return false
+	}
+	if filter.PriceMax != nil && rental.Price > *filter.PriceMax {
+		return false
+	}
+	if len(filter.Ids) != 0 && !slices.Contains(filter.Ids, rental.ID) {
+		return false
+	}
+	if len(filter.Near) == 2 {
+		distance := geo.DistanceMiles(float64(filter.Near[0]), float64(filter.Near[1]), float64(rental.Lat), float64(rental.Lng))
+		if distance > nearRadiusMiles {
+			return false
+		}
+	}
+	if filter.SleepsMin != nil && rental.Sleeps < *filter.SleepsMin {
+		return false
+	}
+	if filter.CreatedAfter != nil && !rental.Created.After(*filter.CreatedAfter) {
+		return false
+	}
+	if filter.CreatedBefore != nil && rental.Created.After(*filter.CreatedBefore) {
+		return false
+	}
+	// rentals without reviews never match
+	if filter.RatingMin != nil && (rental.RatingAverage == nil || *rental.RatingAverage < *filter.RatingMin) {
+		return false
+	}
+
+	return filter.matchesVehicle(rental) && filter.matchesAmenities(rental)
+}
+
+// Mirrors vehicleConditions, unknown values never match
+func (filter *Filter) matchesVehicle(rental *Rental) bool {
+	specs := rental.VehicleSpecs
+	enums := []struct {
+		value  *string
+		values []string
+	}{
+		{specs.FuelType, filter.FuelTypes},
+		{specs.Transmission, filter.Transmissions},
+		{specs.Drivetrain, filter.Drivetrains},
+	}
+	for _, enum := range enums {
+		if len(enum.values) != 0 && (enum.value == nil || !slices.Contains(enum.values, *enum.value)) {
+			return false
+		}
+	}
+	if len(filter.Makes) != 0 {
+		known, unknown := filter.splitMakes()
+		if !slices.Contains(known, rental.VehicleMake) && !slices.Contains(unknown, strings.ToLower(rental.VehicleMake)) {
+			return false
+		}
+	}
+
+	ranges := []struct {
+		value *int32
+		min   *int32
+		max   *int32
+	}{
+		{specs.Seatbelts, filter.SeatbeltsMin, filter.SeatbeltsMax},
+		{specs.TowCapacity, filter.TowCapacityMin, filter.TowCapacityMax},
+		{specs.FreshWaterCapacity, filter.FreshWaterCapacityMin, filter.FreshWaterCapacityMax},
+		{specs.EvRange, filter.EvRangeMin, filter.EvRangeMax},
+	}
+	for _, r := range ranges {
+		if (r.min != nil || r.max != nil) && r.value == nil {
+			return false
+		}
+		if r.min != nil && *r.value < *r.min {
+			return false
+		}
+		if r.max != nil && *r.value > *r.max {
+			return false
+		}
+	}
+
+	if filter.Generator != nil && (specs.Generator == nil || *specs.Generator != *filter.Generator) {
+		return false
+	}
+
+	return true
+}
+
+// Mirrors the amenities subquery of Conditions
+func (filter *Filter) matchesAmenities(rental *Rental) bool {
+	if len(filter.Amenities) == 0 {
+		return true
+	}
+
+	matched := 0
+	for _, key := range filter.Amenities {
+		if slices.ContainsFunc(rental.Amenities, func(amenity Amenity) bool { return amenity.Key == key }) {
+			matched++
+		}
+	}
+	if filter.AmenitiesMatch == "any" {
+		return matched > 0
+	}
+	return matched == len(filter.Amenities)
+}
+
+// Compares rentals in the order of getSort. Text is compared byte by byte and
+// may differ from the collation of the database for non ASCII names
+func rentalOrder(sort string) func(a *Rental, b *Rental) int {
+	switch sort {
+	case "name":
+		return func(a *Rental, b *Rental) int { return strings.Compare(a.Name, b.Name) }
+	case "type":
+		return func(a *Rental, b *Rental) int { return strings.Compare(a.Type, b.Type) }
+	case "sleeps":
+		return func(a *Rental, b *Rental) int { return cmp.Compare(a.Sleeps, b.Sleeps) }
+	case "price":
+		return func(a *Rental, b *Rental) int { return cmp.Compare(a.Price, b.Price) }
+	case "city":
+		return func(a *Rental, b *Rental) int { return strings.Compare(a.City, b.City) }
+	case "state":
+		return func(a *Rental, b *Rental) int { return strings.Compare(a.State, b.State) }
+	case "country":
+		return func(a *Rental, b *Rental) int { return strings.Compare(a.Country, b.Country) }
+	case "make":
+		return func(a *Rental, b *Rental) int { return strings.Compare(a.VehicleMake, b.VehicleMake) }
+	case "model":
+		return func(a *Rental, b *Rental) int { return strings.Compare(a.VehicleModel, b.VehicleModel) }
+	case "year":
+		return func(a *Rental, b *Rental) int { return cmp.Compare(a.VehicleYear, b.VehicleYear) }
+	case "length":
+		return func(a *Rental, b *Rental) int { return cmp.Compare(a.VehicleLength, b.VehicleLength) }
+	case "created":
+		return func(a *Rental, b *Rental) int { return a.Created.Compare(b.Created) }
+	case "updated":
+		return func(a *Rental, b *Rental) int { return a.Updated.Compare(b.Updated) }
+	case "rating":
+		// best rated first with unrated rentals last, then the most reviewed
+		return func(a *Rental, b *Rental) int {
+			switch {
+			case a.RatingAverage == nil && b.RatingAverage == nil:
+			case a.RatingAverage == nil:
+				return 1
+			case b.RatingAverage == nil:
+				return -1
+			default:
+				if c := cmp.Compare(*b.RatingAverage, *a.RatingAverage); c != 0 {
+					return c
+				}
+			}
+			return cmp.Compare(b.ReviewCount, a.ReviewCount)
+		}
+	default:
+		// sorted by id
+		return func(a *Rental, b *Rental) int { return 0 }
+	}
+}
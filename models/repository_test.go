@@ -0,0 +1,215 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+// Test suite for the in memory rental repository, no database needed
+type MemoryRepositoryTestSuite struct {
+	suite.Suite
+	repository *MemoryRentalRepository
+}
+
+func (suite *MemoryRepositoryTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+}
+
+func (suite *MemoryRepositoryTestSuite) SetupTest() {
+	created := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	rating := 4.5
+	diesel := "diesel"
+	suite.repository = NewMemoryRentalRepository([]Rental{
+		// Irvine
+		{ID: 1, Name: "Sprinter", Price: 16900, Sleeps: 4, Lat: 33.68, Lng: -117.82, Created: created,
+			RatingAverage: &rating, ReviewCount: 2, VehicleMake: "Mercedes-Benz", VehicleSpecs: VehicleSpecs{FuelType: &diesel},
+			Amenities: []Amenity{{Key: "kitchen"}, {Key: "shower"}}},
+		// San Diego
+		{ID: 2, Name: "Airstream", Price: 9000, Sleeps: 2, Lat: 32.72, Lng: -117.16, Created: created.AddDate(0, 1, 0),
+			Amenities: []Amenity{{Key: "kitchen"}}},
+		// Portland
+		{ID: 3, Name: "Westy", Price: 15000, Sleeps: 4, Lat: 45.52, Lng: -122.68, Created: created.AddDate(0, 2, 0)},
+	})
+}
+
+func (suite *MemoryRepositoryTestSuite) find(query string) ([]uint32, uint32) {
+	filter, err := ParseQueryString(query)
+	suite.Require().Nil(err)
+	rentals, count, err := suite.repository.Find(context.Background(), filter)
+	suite.Require().Nil(err)
+
+	ids := make([]uint32, len(rentals))
+	for i, rental := range rentals {
+		ids[i] = rental.ID
+	}
+	return ids, count
+}
+
+func (suite *MemoryRepositoryTestSuite) TestFindFilters() {
+	ids, _ := suite.find("limit=10&price_min=9000&price_max=15000")
+	suite.Equal([]uint32{2, 3}, ids)
+
+	ids, _ = suite.find("limit=10&sleeps_min=4&ids=1,2,3")
+	suite.Equal([]uint32{1, 3}, ids)
+
+	ids, _ = suite.find("limit=10&fuel_type=diesel")
+	suite.Equal([]uint32{1}, ids)
+
+	// rentals without reviews never match
+	ids, _ = suite.find("limit=10&rating_min=1")
+	suite.Equal([]uint32{1}, ids)
+}
+
+func (suite *MemoryRepositoryTestSuite) TestFindNear() {
+	// San Diego is about 85 miles from Irvine, Portland is too far
+	ids, count := suite.find("limit=10&near=33.68,-117.82")
+
+	suite.Equal([]uint32{1, 2}, ids)
+	suite.Equal(uint32(2), count)
+}
+
+func (suite *MemoryRepositoryTestSuite) TestFindAmenities() {
+	ids, _ := suite.find("limit=10&amenities=kitchen,shower")
+	suite.Equal([]uint32{1}, ids)
+
+	ids, _ = suite.find("limit=10&amenities=kitchen,shower&amenities_match=any")
+	suite.Equal([]uint32{1, 2}, ids)
+}
+
+func (suite *MemoryRepositoryTestSuite) TestFindSortAndPages() {
+	ids, count := suite.find("limit=2&sort=price")
+	suite.Equal([]uint32{2, 3}, ids)
+	suite.Equal(uint32(3), count)
+
+	ids, count = suite.find("limit=2&offset=2&sort=price")
+	suite.Equal([]uint32{1}, ids)
+	suite.Equal(uint32(3), count)
+
+	ids, _ = suite.find("limit=10&offset=5")
+	suite.Empty(ids)
+
+	// unrated rentals last, ties by id
+	ids, _ = suite.find("limit=10&sort=rating")
+	suite.Equal([]uint32{1, 2, 3}, ids)
+}
+
+func (suite *MemoryRepositoryTestSuite) TestGet() {
+	rental, err := suite.repository.Get(context.Background(), 2, nil)
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal("Airstream", rental.Name)
+	}
+
+	_, err = suite.repository.Get(context.Background(), 99, nil)
+	suite.True(errors.Is(err, gorm.ErrRecordNotFound))
+
+	// created later than as_of
+	asOf := time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC)
+	_, err = suite.repository.Get(context.Background(), 2, &asOf)
+	suite.True(errors.Is(err, gorm.ErrRecordNotFound))
+}
+
+func (suite *MemoryRepositoryTestSuite) TestSave() {
+	suite.repository.Save(Rental{ID: 2, Name: "Bambi", Price: 8000})
+	suite.repository.Save(Rental{ID: 4, Name: "Vanagon", Price: 12000})
+
+	ids, count := suite.find("limit=10&sort=price")
+	suite.Equal([]uint32{2, 4, 3, 1}, ids)
+	suite.Equal(uint32(4), count)
+}
+
+// Queries both repositories must answer the same
+var repositoryContractQueries = []string{
+	"limit=100",
+	"limit=5&offset=5",
+	"limit=10&offset=40",
+	"limit=100&price_min=10000&price_max=20000&sort=price",
+	"limit=4&offset=2&sort=price",
+	"limit=100&sleeps_min=4&sort=sleeps",
+	"limit=100&ids=1,2,3,15&sort=price",
+	"limit=100&near=33.64,-117.93",
+	"limit=100&near=39.74,-104.99&sort=price",
+	"limit=100&amenities=kitchen,pets",
+	"limit=100&amenities=kitchen,pets&amenities_match=any&sort=price",
+	"limit=100&fuel_type=gasoline&seatbelts_min=5",
+	"limit=100&make=Ford",
+	"limit=100&make=peugeot,vw",
+	"limit=100&rating_min=4.5",
+	"limit=10&sort=rating",
+	"limit=5&offset=3&sort=created",
+}
+
+// Test suite running the same queries against the database and in memory
+// repositories, the memory repository holds the rentals of the database
+type RentalRepositoryContractTestSuite struct {
+	suite.Suite
+	repositories map[string]RentalRepository
+}
+
+func (suite *RentalRepositoryContractTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+}
+
+func (suite *RentalRepositoryContractTestSuite) SetupTest() {
+	var rentals []Rental
+	suite.Require().Nil(db.DB.Joins("User").Preload("Amenities").Preload("Images", OrderImages).Find(&rentals).Error)
+	suite.repositories = map[string]RentalRepository{
+		"gorm":   GormRentalRepository{},
+		"memory": NewMemoryRentalRepository(rentals),
+	}
+}
+
+func (suite *RentalRepositoryContractTestSuite) TestFind() {
+	for _, query := range repositoryContractQueries {
+		filter, err := ParseQueryString(query)
+		suite.Require().Nil(err, query)
+
+		results := make(map[string][]uint32)
+		counts := make(map[string]uint32)
+		for name, repository := range suite.repositories {
+			rentals, count, err := repository.Find(context.Background(), filter)
+			suite.Require().Nil(err, "%s: %s", name, query)
+			ids := make([]uint32, len(rentals))
+			for i, rental := range rentals {
+				ids[i] = rental.ID
+			}
+			results[name] = ids
+			counts[name] = count
+		}
+
+		suite.Equal(results["gorm"], results["memory"], query)
+		suite.Equal(counts["gorm"], counts["memory"], query)
+	}
+}
+
+func (suite *RentalRepositoryContractTestSuite) TestGet() {
+	for name, repository := range suite.repositories {
+		rental, err := repository.Get(context.Background(), 2, nil)
+		if suite.Nil(err, name) {
+			suite.Equal(uint32(2), rental.ID, name)
+			suite.NotEmpty(rental.Amenities, name)
+		}
+
+		_, err = repository.Get(context.Background(), 1_000_000, nil)
+		suite.ErrorIs(err, gorm.ErrRecordNotFound, name)
+	}
+}
+
+func TestRentalRepositoryContractTestSuite(t *testing.T) {
+	suite.Run(t, new(RentalRepositoryContractTestSuite))
+}
+
+func TestMemoryRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryRepositoryTestSuite))
+}
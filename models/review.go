@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/samuelg/rentals/db"
+	"gorm.io/gorm"
+)
+
+// Review model, a user rating a rental
+type Review struct {
+	// uses serial integer column in the database which will use 32 bits at most
+	ID       uint32 `gorm:"primary_key;autoincrement;column:id"`
+	RentalId uint32 `gorm:"column:rental_id"`
+	UserId   uint32 `gorm:"column:user_id"`
+	// many to one association
+	User User
+	// sub-scores from 1 to 5 stars
+	Cleanliness   int16     `gorm:"column:cleanliness"`
+	Accuracy      int16     `gorm:"column:accuracy"`
+	Communication int16     `gorm:"column:communication"`
+	Text          string    `gorm:"column:text"`
+	Created       time.Time `gorm:"column:created;autoCreateTime"`
+}
+
+type ReviewResponse struct {
+	ID            uint32       `json:"id"`
+	RentalId      uint32       `json:"rental_id"`
+	User          UserResponse `json:"user"`
+	Rating        float64      `json:"rating"`
+	Cleanliness   int16        `json:"cleanliness"`
+	Accuracy      int16        `json:"accuracy"`
+	Communication int16        `json:"communication"`
+	Text          string       `json:"text"`
+	Created       time.Time    `json:"created"`
+}
+
+// Overall rating of the review, the average of the sub-scores
+func (review *Review) Rating() float64 {
+	return float64(review.Cleanliness+review.Accuracy+review.Communication) / 3
+}
+
+// Custom JSON format for the response
+func (review Review) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&ReviewResponse{
+		ID:       review.ID,
+		RentalId: review.RentalId,
+		User: UserResponse{
+			Id:        review.User.ID,
+			FirstName: review.User.FirstName,
+			LastName:  review.User.LastName,
+		},
+		Rating:        review.Rating(),
+		Cleanliness:   review.Cleanliness,
+		Accuracy:      review.Accuracy,
+		Communication: review.Communication,
+		Text:          review.Text,
+		Created:       review.Created,
+	})
+}
+
+// Save a new review and update the rating aggregates of its rental
+func CreateReview(review *Review) error {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("User").Create(review).Error; err != nil {
+			return err
+		}
+
+		// aggregates are maintained incrementally so listing rentals never has to
+		// scan reviews, SET expressions use the values from before the update
+		rating := review.Rating()
+		return tx.Exec(
+			`UPDATE rentals SET
+				review_count = review_count + 1,
+				rating_sum = rating_sum + ?,
+				rating_average = (rating_sum + ?) / (review_count + 1)
+			WHERE id = ?`,
+			rating, rating, review.RentalId,
+		).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// load the author for the response
+	return db.DB.First(&review.User, review.UserId).Error
+}
+
+// Find the reviews of a rental, most recent first
+func FindReviews(rentalId uint32, limit uint8, offset uint32) ([]Review, uint32, error) {
+	reviews := make([]Review, 0)
+	var count int64
+
+	query := db.DB.Model(&Review{}).Where("rental_id = ?", rentalId)
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := db.DB.Joins("User").
+		Where("rental_id = ?", rentalId).
+		Order("reviews.created DESC, reviews.id DESC").
+		Limit(int(limit)).
+		Offset(int(offset)).
+		Find(&reviews).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return reviews, uint32(count), nil
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/notifications"
+)
+
+// Most new matches listed in a notification
+const savedSearchMatchLimit = 20
+
+// SavedSearch model, a rentals filter a user wants to hear about
+type SavedSearch struct {
+	// uses serial integer column in the database which will use 32 bits at most
+	ID     uint32 `gorm:"primary_key;autoincrement;column:id"`
+	UserId uint32 `gorm:"column:user_id"`
+	// many to one association
+	User   User
+	Name   string `gorm:"column:name"`
+	Filter Filter `gorm:"column:filter;serializer:json"`
+	// rentals created up to this time have been notified
+	LastRun time.Time `gorm:"column:last_run"`
+	Created time.Time `gorm:"column:created;autoCreateTime"`
+}
+
+type SavedSearchResponse struct {
+	ID      uint32    `json:"id"`
+	Name    string    `json:"name"`
+	Filter  Filter    `json:"filter"`
+	LastRun time.Time `json:"last_run"`
+	Created time.Time `json:"created"`
+}
+
+// Summary of a saved search evaluation
+type SavedSearchReport struct {
+	Evaluated int `json:"evaluated"`
+	Notified  int `json:"notified"`
+	Failed    int `json:"failed"`
+}
+
+// Custom JSON format for the response
+func (search SavedSearch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&SavedSearchResponse{
+		ID:      search.ID,
+		Name:    search.Name,
+		Filter:  search.Filter,
+		LastRun: search.LastRun,
+		Created: search.Created,
+	})
+}
+
+// Save a search, only rentals created from now on are notified
+func CreateSavedSearch(search *SavedSearch) error {
+	// pagination and sort don't apply to matches
+	search.Filter.Limit = 0
+	search.Filter.Offset = 0
+	search.Filter.Sort = ""
+	search.LastRun = time.Now()
+
+	return db.DB.Omit("User").Create(search).Error
+}
+
+// Find the saved searches of a user, oldest first
+func FindSavedSearches(userId uint32) ([]SavedSearch, error) {
+	searches := make([]SavedSearch, 0)
+	err := db.DB.Where("user_id = ?", userId).Order("created, id").Find(&searches).Error
+
+	return searches, err
+}
+
+// Notify users of the rentals created since their saved searches last ran.
+// Every search is claimed before being evaluated so concurrent runs (ex:
+// several API instances) never notify the same matches
+func EvaluateSavedSearches(ctx context.Context, notifier notifications.Notifier) (*SavedSearchReport, error) {
+	report := &SavedSearchReport{}
+	var searches []SavedSearch
+	if err := db.DB.WithContext(ctx).Joins("User").Order("saved_searches.id").Find(&searches).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range searches {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		search := &searches[i]
+		lastRun := search.LastRun
+		claimed, err := search.claim(ctx)
+		if err != nil {
+			report.Failed++
+			log.Log.Warn(fmt.Sprintf("Failed to claim saved search %d: %v", search.ID, err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		report.Evaluated++
+		notified, err := search.evaluate(ctx, notifier, lastRun)
+		if err != nil {
+			report.Failed++
+			log.Log.Warn(fmt.Sprintf("Failed to evaluate saved search %d: %v", search.ID, err))
+			continue
+		}
+		if notified {
+			report.Notified++
+		}
+	}
+
+	return report, nil
+}
+
+// Move last_run to now unless another run moved it since the search was
+// loaded, returns true when this run claimed the search
+func (search *SavedSearch) claim(ctx context.Context) (bool, error) {
+	// Postgres keeps microseconds, last_run is compared with the stored value
+	now := time.Now().Truncate(time.Microsecond)
+	result := db.DB.WithContext(ctx).Model(search).Where("last_run = ?", search.LastRun).Update("last_run", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	search.LastRun = now
+	return true, nil
+}
+
+// Notify the user of the matches created from lastRun until the claim,
+// returns true when a notification was sent. The claim is released when
+// the evaluation fails so the next run retries it
+func (search *SavedSearch) evaluate(ctx context.Context, notifier notifications.Notifier, lastRun time.Time) (bool, error) {
+	// rentals created while evaluating are picked up by the next run
+	filter := search.Filter
+	filter.CreatedAfter = &lastRun
+	filter.CreatedBefore = &search.LastRun
+	filter.Limit = savedSearchMatchLimit
+	filter.Offset = 0
+	filter.Sort = "created"
+
+	rentals, count, err := filter.Find(ctx)
+	if err != nil {
+		return false, search.release(lastRun, err)
+	}
+
+	notified := false
+	if count > 0 {
+		err := notifier.Notify(ctx, search.notification(rentals, count))
+		// nothing to retry when the user can't be reached
+		if err != nil && !errors.Is(err, notifications.ErrNoRecipient) {
+			return false, search.release(lastRun, err)
+		}
+		notified = err == nil
+	}
+
+	return notified, nil
+}
+
+// Move last_run back unless another run moved it since, returns the error
+// of the evaluation
+func (search *SavedSearch) release(lastRun time.Time, err error) error {
+	// the context may be the reason the evaluation failed
+	result := db.DB.Model(search).Where("last_run = ?", search.LastRun).Update("last_run", lastRun)
+	if result.Error != nil {
+		return errors.Join(err, result.Error)
+	}
+
+	search.LastRun = lastRun
+	return err
+}
+
+// Notification listing the new matches of the search
+func (search *SavedSearch) notification(rentals []Rental, count uint32) notifications.Notification {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hi %s,\n\n", search.User.FirstName)
+	if count == 1 {
+		fmt.Fprintf(&body, "A new rental matches your saved search \"%s\":\n\n", search.Name)
+	} else {
+		fmt.Fprintf(&body, "%d new rentals match your saved search \"%s\":\n\n", count, search.Name)
+	}
+	for _, rental := range rentals {
+		fmt.Fprintf(&body, "- %s in %s, %s: $%.2f per day (/rentals/%d)\n",
+			strings.TrimSpace(rental.Name), rental.City, rental.State, float64(rental.Price)/100, rental.ID)
+	}
+	if count > uint32(len(rentals)) {
+		fmt.Fprintf(&body, "- and %d more\n", count-uint32(len(rentals)))
+	}
+
+	return notifications.Notification{
+		UserId:  search.UserId,
+		To:      search.User.Email,
+		Subject: fmt.Sprintf("New rentals for \"%s\"", search.Name),
+		Body:    body.String(),
+	}
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/notifications"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for saved searches
+type SavedSearchTestSuite struct {
+	suite.Suite
+	config *config.Config
+}
+
+func (suite *SavedSearchTestSuite) SetupSuite() {
+	config.Init("test")
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+	suite.config = config.GetConfig()
+}
+
+// Keeps notifications in memory
+type recordingNotifier struct {
+	mutex         sync.Mutex
+	notifications []notifications.Notification
+}
+
+func (notifier *recordingNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+	notifier.notifications = append(notifier.notifications, notification)
+	return nil
+}
+
+func (suite *SavedSearchTestSuite) TestFilterRoundTrip() {
+	priceMax := int64(15000)
+	sleepsMin := int32(4)
+	createdAfter := time.Now()
+	filter := Filter{
+		PriceMax:     &priceMax,
+		SleepsMin:    &sleepsMin,
+		Near:         []float32{39.74, -104.99},
+		Amenities:    []string{"kitchen"},
+		CreatedAfter: &createdAfter,
+	}
+
+	bytes, err := json.Marshal(filter)
+	suite.Nil(err, "Should be able to marshal")
+	suite.Equal(`{"price_max":15000,"near":[39.74,-104.99],"sleeps_min":4,"amenities":["kitchen"]}`, string(bytes))
+
+	var parsed Filter
+	suite.Nil(json.Unmarshal(bytes, &parsed), "Should be able to unmarshal")
+	suite.Equal(priceMax, *parsed.PriceMax)
+	suite.Equal(sleepsMin, *parsed.SleepsMin)
+	// the creation window is not part of a saved search
+	suite.Nil(parsed.CreatedAfter)
+}
+
+func (suite *SavedSearchTestSuite) TestNotificationBody() {
+	search := SavedSearch{UserId: 2, Name: "Denver", User: User{ID: 2, FirstName: "Jane", Email: "jane.doe@example.com"}}
+	rentals := []Rental{{ID: 28, Name: "Sprinter ", City: "Denver", State: "CO", Price: 14900}}
+
+	notification := search.notification(rentals, 3)
+	suite.Equal(uint32(2), notification.UserId)
+	suite.Equal("jane.doe@example.com", notification.To)
+	suite.Equal(`New rentals for "Denver"`, notification.Subject)
+	suite.Contains(notification.Body, "3 new rentals match")
+	suite.Contains(notification.Body, "- Sprinter in Denver, CO: $149.00 per day (/rentals/28)")
+	suite.Contains(notification.Body, "- and 2 more")
+}
+
+func (suite *SavedSearchTestSuite) TestEvaluateSavedSearches() {
+	// seed rentals were created in 2021
+	sleepsMin := int32(2)
+	search := SavedSearch{
+		UserId:  2,
+		Name:    "Near Denver",
+		Filter:  Filter{Near: []float32{39.74, -104.99}, SleepsMin: &sleepsMin},
+		LastRun: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	suite.Require().Nil(db.DB.Omit("User").Create(&search).Error)
+	defer db.DB.Delete(&search)
+
+	notifier := &recordingNotifier{}
+	report, err := EvaluateSavedSearches(context.Background(), notifier)
+	if suite.Nil(err) {
+		suite.GreaterOrEqual(report.Notified, 1)
+		suite.Equal(0, report.Failed)
+	}
+
+	var found *notifications.Notification
+	for i, notification := range notifier.notifications {
+		if notification.Subject == `New rentals for "Near Denver"` {
+			found = &notifier.notifications[i]
+		}
+	}
+	if suite.NotNil(found) {
+		suite.Equal("jane.doe@example.com", found.To)
+		suite.Contains(found.Body, "Denver, CO")
+	}
+
+	// matches are only notified once
+	notifier = &recordingNotifier{}
+	_, err = EvaluateSavedSearches(context.Background(), notifier)
+	suite.Nil(err)
+	for _, notification := range notifier.notifications {
+		suite.NotEqual(`New rentals for "Near Denver"`, notification.Subject)
+	}
+}
+
+// Fails every notification of the saved search
+type unavailableNotifier struct{}
+
+func (notifier *unavailableNotifier) Notify(ctx context.Context, notification notifications.Notification) error {
+	return errors.New("unavailable")
+}
+
+func (suite *SavedSearchTestSuite) TestEvaluateSavedSearchClaimed() {
+	sleepsMin := int32(2)
+	search := SavedSearch{
+		UserId:  2,
+		Name:    "Claimed near Denver",
+		Filter:  Filter{Near: []float32{39.74, -104.99}, SleepsMin: &sleepsMin},
+		LastRun: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	suite.Require().Nil(db.DB.Omit("User").Create(&search).Error)
+	defer db.DB.Delete(&search)
+
+	// loaded by two runs, only the first to claim it evaluates it
+	var first, second SavedSearch
+	suite.Require().Nil(db.DB.First(&first, search.ID).Error)
+	suite.Require().Nil(db.DB.First(&second, search.ID).Error)
+	claimed, err := first.claim(context.Background())
+	suite.Nil(err)
+	suite.True(claimed)
+	claimed, err = second.claim(context.Background())
+	suite.Nil(err)
+	suite.False(claimed, "Should not claim a search claimed by another run")
+
+	// last_run is recorded with the claim
+	var stored SavedSearch
+	suite.Require().Nil(db.DB.First(&stored, search.ID).Error)
+	suite.True(stored.LastRun.After(search.LastRun))
+}
+
+func (suite *SavedSearchTestSuite) TestEvaluateSavedSearchRetried() {
+	sleepsMin := int32(2)
+	search := SavedSearch{
+		UserId:  2,
+		Name:    "Retried near Denver",
+		Filter:  Filter{Near: []float32{39.74, -104.99}, SleepsMin: &sleepsMin},
+		LastRun: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	suite.Require().Nil(db.DB.Omit("User").Create(&search).Error)
+	defer db.DB.Delete(&search)
+
+	report, err := EvaluateSavedSearches(context.Background(), &unavailableNotifier{})
+	if suite.Nil(err) {
+		suite.GreaterOrEqual(report.Failed, 1)
+	}
+
+	// the claim is released so the next run notifies the matches
+	var stored SavedSearch
+	suite.Require().Nil(db.DB.First(&stored, search.ID).Error)
+	suite.True(stored.LastRun.Equal(search.LastRun))
+}
+
+func TestSavedSearchTestSuite(t *testing.T) {
+	suite.Run(t, new(SavedSearchTestSuite))
+}
@@ -0,0 +1,253 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/vehicles"
+	"golang.org/x/exp/slices"
+	"gorm.io/gorm"
+)
+
+// Known values for the vehicle specifications, also enforced by the database
+var (
+	FuelTypes     = []string{"gasoline", "diesel", "electric", "hybrid", "propane"}
+	Transmissions = []string{"automatic", "manual"}
+	Drivetrains   = []string{"fwd", "rwd", "awd", "4wd"}
+)
+
+// Vehicle specifications of a rental, nil when unknown
+type VehicleSpecs struct {
+	FuelType     *string `gorm:"column:fuel_type"`
+	Transmission *string `gorm:"column:transmission"`
+	Drivetrain   *string `gorm:"column:drivetrain"`
+	Seatbelts    *int32  `gorm:"column:seatbelts"`
+	// in pounds
+	TowCapacity *int32 `gorm:"column:tow_capacity"`
+	// in gallons
+	FreshWaterCapacity *int32 `gorm:"column:fresh_water_capacity"`
+	Generator          *bool  `gorm:"column:generator"`
+	// battery range of electric vehicles in miles
+	EvRange *int32 `gorm:"column:ev_range"`
+}
+
+// Derive the canonical make and model from the make and model as entered
+func (rental *Rental) saveVehicle(tx *gorm.DB) {
+	// nothing to normalize without a make or model (ex: partial updates)
+	if rental.VehicleMake == "" && rental.VehicleModel == "" && rental.VehicleMakeInput == "" && rental.VehicleModelInput == "" {
+		return
+	}
+
+	makeInput, modelInput := rental.VehicleMakeInput, rental.VehicleModelInput
+	// rentals written before the catalog only have the make and model as entered
+	if makeInput == "" && modelInput == "" {
+		makeInput, modelInput = rental.VehicleMake, rental.VehicleModel
+		tx.Statement.SetColumn("VehicleMakeInput", makeInput)
+		tx.Statement.SetColumn("VehicleModelInput", modelInput)
+	}
+
+	result := vehicles.Default().Normalize(makeInput, modelInput)
+	tx.Statement.SetColumn("VehicleMake", result.Make)
+	tx.Statement.SetColumn("VehicleModel", result.Model)
+}
+
+// Summary of a make and model normalization
+type NormalizeReport struct {
+	Checked int `json:"checked"`
+	Updated int `json:"updated"`
+	// rental id to the make missing from the catalog
+	Unknown map[uint32]string `json:"unknown"`
+}
+
+// Normalize the make and model of all existing rentals against the catalog,
+// changes are only saved when dryRun is false
+func NormalizeVehicles(dryRun bool) (*NormalizeReport, error) {
+	report := &NormalizeReport{Unknown: make(map[uint32]string)}
+	var rentals []Rental
+
+	result := db.DB.Order("id").FindInBatches(&rentals, backfillBatchSize, func(tx *gorm.DB, batch int) error {
+		for i := range rentals {
+			rental := &rentals[i]
+			report.Checked++
+
+			if rental.VehicleMakeInput == "" && rental.VehicleModelInput == "" {
+				rental.VehicleMakeInput, rental.VehicleModelInput = rental.VehicleMake, rental.VehicleModel
+			}
+			normalized := vehicles.Default().Normalize(rental.VehicleMakeInput, rental.VehicleModelInput)
+			if !normalized.Known && normalized.Make != "" {
+				report.Unknown[rental.ID] = normalized.Make
+			}
+
+			// rentals already using canonical names are left as they are
+			changed := normalized.Make != rental.VehicleMake || normalized.Model != rental.VehicleModel
+			if !changed {
+				continue
+			}
+
+			report.Updated++
+			log.Log.Debug(fmt.Sprintf("Rental %d vehicle: %s %s -> %s %s", rental.ID, rental.VehicleMake, rental.VehicleModel, normalized.Make, normalized.Model))
+			if dryRun {
+				continue
+			}
+			// the BeforeSave hook applies the canonical make and model
+			err := tx.Select("vehicle_make", "vehicle_model", "vehicle_make_input", "vehicle_model_input").Updates(rental).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return report, nil
+}
+
+type VehicleResponse struct {
+	FuelType           *string `json:"fuel_type"`
+	Transmission       *string `json:"transmission"`
+	Drivetrain         *string `json:"drivetrain"`
+	Seatbelts          *int32  `json:"seatbelts"`
+	TowCapacity        *int32  `json:"tow_capacity"`
+	FreshWaterCapacity *int32  `json:"fresh_water_capacity"`
+	Generator          *bool   `json:"generator"`
+	EvRange            *int32  `json:"ev_range"`
+}
+
+func (specs VehicleSpecs) response() VehicleResponse {
+	return VehicleResponse{
+		FuelType:           specs.FuelType,
+		Transmission:       specs.Transmission,
+		Drivetrain:         specs.Drivetrain,
+		Seatbelts:          specs.Seatbelts,
+		TowCapacity:        specs.TowCapacity,
+		FreshWaterCapacity: specs.FreshWaterCapacity,
+		Generator:          specs.Generator,
+		EvRange:            specs.EvRange,
+	}
+}
+
+// Parse the vehicle specification filters, returns the validation errors
+func (filter *Filter) parseVehicleQuery(c *gin.Context) []string {
+	validationErrors := make([]string, 0)
+
+	filter.FuelTypes = parseEnumQuery(c, "fuel_type", FuelTypes, &validationErrors)
+	filter.Transmissions = parseEnumQuery(c, "transmission", Transmissions, &validationErrors)
+	filter.Drivetrains = parseEnumQuery(c, "drivetrain", Drivetrains, &validationErrors)
+
+	makesRaw := c.Query("make")
+	// parse csv makes, aliases match their canonical make and makes missing
+	// from the catalog match the make as entered
+	if makesRaw != "" {
+		for _, value := range strings.Split(makesRaw, ",") {
+			vehicleMake := vehicles.Default().Make(value)
+			if vehicleMake == "" {
+				log.Log.Trace(fmt.Sprintf("Invalid make: %s", makesRaw))
+				validationErrors = append(validationErrors, "Invalid make")
+				break
+			}
+			if !slices.Contains(filter.Makes, vehicleMake) {
+				filter.Makes = append(filter.Makes, vehicleMake)
+			}
+		}
+	}
+
+	filter.SeatbeltsMin = parseInt32Query(c, "seatbelts_min", &validationErrors)
+	filter.SeatbeltsMax = parseInt32Query(c, "seatbelts_max", &validationErrors)
+	filter.TowCapacityMin = parseInt32Query(c, "tow_capacity_min", &validationErrors)
+	filter.TowCapacityMax = parseInt32Query(c, "tow_capacity_max", &validationErrors)
+	filter.FreshWaterCapacityMin = parseInt32Query(c, "fresh_water_capacity_min", &validationErrors)
+	filter.FreshWaterCapacityMax = parseInt32Query(c, "fresh_water_capacity_max", &validationErrors)
+	filter.EvRangeMin = parseInt32Query(c, "ev_range_min", &validationErrors)
+	filter.EvRangeMax = parseInt32Query(c, "ev_range_max", &validationErrors)
+
+	generatorRaw := c.Query("generator")
+	if generatorRaw != "" {
+		generator, err := strconv.ParseBool(generatorRaw)
+		if err != nil {
+			log.Log.Trace(fmt.Sprintf("Invalid generator: %s", generatorRaw))
+			validationErrors = append(validationErrors, "Invalid generator")
+		} else {
+			filter.Generator = &generator
+		}
+	}
+
+	return validationErrors
+}
+
+// Makes of the make filter in the catalog, and the other ones lowercased.
+// Rentals with a make missing from the catalog keep it as entered, the other
+// makes match it regardless of case
+func (filter *Filter) splitMakes() ([]string, []string) {
+	known, unknown := make([]string, 0), make([]string, 0)
+	for _, vehicleMake := range filter.Makes {
+		if vehicles.Default().HasMake(vehicleMake) {
+			known = append(known, vehicleMake)
+		} else {
+			unknown = append(unknown, strings.ToLower(vehicleMake))
+		}
+	}
+	return known, unknown
+}
+
+// Returns the SQL conditions for the vehicle specification filters
+func (filter *Filter) vehicleConditions() []condition {
+	conditions := make([]condition, 0)
+
+	enums := []struct {
+		column string
+		values []string
+	}{
+		{"fuel_type", filter.FuelTypes},
+		{"transmission", filter.Transmissions},
+		{"drivetrain", filter.Drivetrains},
+	}
+	for _, enum := range enums {
+		if len(enum.values) != 0 {
+			conditions = append(conditions, condition{enum.column + " IN ?", []interface{}{enum.values}})
+		}
+	}
+
+	known, unknown := filter.splitMakes()
+	switch {
+	case len(unknown) == 0 && len(known) != 0:
+		conditions = append(conditions, condition{"vehicle_make IN ?", []interface{}{known}})
+	case len(known) == 0 && len(unknown) != 0:
+		conditions = append(conditions, condition{"LOWER(vehicle_make) IN ?", []interface{}{unknown}})
+	case len(known) != 0:
+		conditions = append(conditions, condition{"(vehicle_make IN ? OR LOWER(vehicle_make) IN ?)", []interface{}{known, unknown}})
+	}
+
+	ranges := []struct {
+		column string
+		min    *int32
+		max    *int32
+	}{
+		{"seatbelts", filter.SeatbeltsMin, filter.SeatbeltsMax},
+		{"tow_capacity", filter.TowCapacityMin, filter.TowCapacityMax},
+		{"fresh_water_capacity", filter.FreshWaterCapacityMin, filter.FreshWaterCapacityMax},
+		{"ev_range", filter.EvRangeMin, filter.EvRangeMax},
+	}
+	for _, r := range ranges {
+		// unknown values (NULL) never match a range
+		if r.min != nil {
+			conditions = append(conditions, condition{r.column + " >= ?", []interface{}{*r.min}})
+		}
+		if r.max != nil {
+			conditions = append(conditions, condition{r.column + " <= ?", []interface{}{*r.max}})
+		}
+	}
+
+	if filter.Generator != nil {
+		conditions = append(conditions, condition{"generator = ?", []interface{}{*filter.Generator}})
+	}
+
+	return conditions
+}
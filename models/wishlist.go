@@ -0,0 +1,191 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/samuelg/rentals/db"
+	"gorm.io/gorm"
+)
+
+// Wishlist model, a named list of rentals saved by a user
+type Wishlist struct {
+	// uses serial integer column in the database which will use 32 bits at most
+	ID     uint32 `gorm:"primary_key;autoincrement;column:id"`
+	UserId uint32 `gorm:"column:user_id"`
+	Name   string `gorm:"column:name"`
+	// set when the list is shared, anyone with the token can read it
+	ShareToken *string   `gorm:"column:share_token"`
+	Created    time.Time `gorm:"column:created;autoCreateTime"`
+	Updated    time.Time `gorm:"column:updated;autoUpdateTime"`
+	// one to many association
+	Items []WishlistItem `gorm:"foreignKey:WishlistId"`
+}
+
+// WishlistItem model, a rental saved in a wishlist
+type WishlistItem struct {
+	// uses serial integer column in the database which will use 32 bits at most
+	ID         uint32 `gorm:"primary_key;autoincrement;column:id"`
+	WishlistId uint32 `gorm:"column:wishlist_id"`
+	// not a foreign key so items outlive the rentals they point to
+	RentalId uint32 `gorm:"column:rental_id"`
+	// name and price of the rental when it was saved
+	Name       string    `gorm:"column:name"`
+	SavedPrice int64     `gorm:"column:saved_price_per_day"`
+	Created    time.Time `gorm:"column:created;autoCreateTime"`
+	// many to one association, nil once the rental is gone
+	Rental *Rental `gorm:"foreignKey:RentalId"`
+}
+
+type WishlistResponse struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"`
+	// only shown to the owner of the list
+	ShareToken *string                `json:"share_token,omitempty"`
+	Items      []WishlistItemResponse `json:"items"`
+	Created    time.Time              `json:"created"`
+	Updated    time.Time              `json:"updated"`
+}
+
+type WishlistItemResponse struct {
+	RentalId uint32 `json:"rental_id"`
+	Name     string `json:"name"`
+	// false once the rental has been removed
+	Active       bool          `json:"active"`
+	SavedPrice   PriceReponse  `json:"saved_price"`
+	CurrentPrice *PriceReponse `json:"current_price"`
+	// current price minus the saved price, negative when it went down
+	PriceChange *int64          `json:"price_change"`
+	Rental      *RentalResponse `json:"rental"`
+	Created     time.Time       `json:"created"`
+}
+
+// Custom JSON format for the response
+func (item WishlistItem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(item.response())
+}
+
+func (item WishlistItem) response() WishlistItemResponse {
+	response := WishlistItemResponse{
+		RentalId:   item.RentalId,
+		Name:       item.Name,
+		Active:     item.Rental != nil,
+		SavedPrice: PriceReponse{Day: item.SavedPrice},
+		Created:    item.Created,
+	}
+	if item.Rental != nil {
+		response.Rental = item.Rental.response()
+		change := item.Rental.Price - item.SavedPrice
+		response.CurrentPrice = &PriceReponse{Day: item.Rental.Price}
+		response.PriceChange = &change
+	}
+
+	return response
+}
+
+// Custom JSON format for the response
+func (wishlist Wishlist) MarshalJSON() ([]byte, error) {
+	items := make([]WishlistItemResponse, len(wishlist.Items))
+	for i, item := range wishlist.Items {
+		items[i] = item.response()
+	}
+
+	return json.Marshal(&WishlistResponse{
+		ID:         wishlist.ID,
+		Name:       wishlist.Name,
+		ShareToken: wishlist.ShareToken,
+		Items:      items,
+		Created:    wishlist.Created,
+		Updated:    wishlist.Updated,
+	})
+}
+
+// Loads the items of wishlists along with their rentals
+func preloadWishlistItems(tx *gorm.DB) *gorm.DB {
+	return tx.Preload("Items", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("created, id")
+	}).
+		Preload("Items.Rental").
+		Preload("Items.Rental.User").
+		Preload("Items.Rental.Amenities").
+		Preload("Items.Rental.Images", OrderImages)
+}
+
+// Find the wishlists of a user, oldest first
+func FindWishlists(userId uint32) ([]Wishlist, error) {
+	wishlists := make([]Wishlist, 0)
+	err := preloadWishlistItems(db.DB).Where("user_id = ?", userId).Order("created, id").Find(&wishlists).Error
+
+	return wishlists, err
+}
+
+// Find a wishlist with its items
+func FindWishlist(id uint32) (*Wishlist, error) {
+	var wishlist Wishlist
+	if err := preloadWishlistItems(db.DB).First(&wishlist, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &wishlist, nil
+}
+
+// Find a shared wishlist by its share token
+func FindSharedWishlist(token string) (*Wishlist, error) {
+	var wishlist Wishlist
+	if err := preloadWishlistItems(db.DB).Where("share_token = ?", token).First(&wishlist).Error; err != nil {
+		return nil, err
+	}
+
+	return &wishlist, nil
+}
+
+// Share the wishlist with a new token, previously shared links stop working
+func (wishlist *Wishlist) Share() error {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+
+	shareToken := hex.EncodeToString(token)
+	if err := db.DB.Model(wishlist).Update("share_token", shareToken).Error; err != nil {
+		return err
+	}
+	wishlist.ShareToken = &shareToken
+
+	return nil
+}
+
+// Stop sharing the wishlist
+func (wishlist *Wishlist) Unshare() error {
+	if err := db.DB.Model(wishlist).Update("share_token", nil).Error; err != nil {
+		return err
+	}
+	wishlist.ShareToken = nil
+
+	return nil
+}
+
+// Save the rental in the wishlist along with its current name and price
+func (wishlist *Wishlist) AddItem(rental *Rental) (*WishlistItem, error) {
+	item := &WishlistItem{
+		WishlistId: wishlist.ID,
+		RentalId:   rental.ID,
+		Name:       rental.Name,
+		SavedPrice: rental.Price,
+	}
+	if err := db.DB.Omit("Rental").Create(item).Error; err != nil {
+		return nil, err
+	}
+	item.Rental = rental
+
+	return item, nil
+}
+
+// Remove the rental from the wishlist, returns false when it wasn't saved
+func (wishlist *Wishlist) RemoveItem(rentalId uint32) (bool, error) {
+	result := db.DB.Where("wishlist_id = ? AND rental_id = ?", wishlist.ID, rentalId).Delete(&WishlistItem{})
+
+	return result.RowsAffected > 0, result.Error
+}
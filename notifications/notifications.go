@@ -0,0 +1,203 @@
+package notifications
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samuelg/rentals/config"
+	log "github.com/samuelg/rentals/logging"
+)
+
+// Returned when a notification has nowhere to go (ex: user without email)
+var ErrNoRecipient = errors.New("no recipient")
+
+// A message for a user
+type Notification struct {
+	UserId uint32 `json:"user_id"`
+	// email address of the user, can be empty
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	Sent    time.Time `json:"sent"`
+}
+
+// Delivers notifications to users
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// Notifier configured for the app
+var Default Notifier
+
+func Init() {
+	switch config.GetConfig().Notifier {
+	case "log":
+		Default = &LogNotifier{}
+	case "file":
+		file, err := os.OpenFile(config.GetConfig().NotifierFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to open notifications file: %v", err))
+		}
+		Default = &LogNotifier{Writer: file}
+	case "smtp":
+		Default = &SMTPNotifier{
+			Addr:     net.JoinHostPort(config.GetConfig().SmtpHost, strconv.Itoa(int(config.GetConfig().SmtpPort))),
+			From:     config.GetConfig().SmtpFrom,
+			Username: config.GetConfig().SmtpUsername,
+			Password: config.GetConfig().SmtpPassword,
+			Timeout:  time.Duration(config.GetConfig().SmtpTimeout) * time.Second,
+		}
+	default:
+		panic(fmt.Sprintf("Unknown notifier: %s", config.GetConfig().Notifier))
+	}
+
+	log.Log.Info(fmt.Sprintf("Sending notifications with the %s notifier", config.GetConfig().Notifier))
+}
+
+// Logs notifications, or appends them as JSON lines when a writer is set
+type LogNotifier struct {
+	Writer io.Writer
+	mutex  sync.Mutex
+}
+
+func (notifier *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	if notification.Sent.IsZero() {
+		notification.Sent = time.Now()
+	}
+
+	if notifier.Writer == nil {
+		log.Log.Info(fmt.Sprintf("Notification for user %d: %s", notification.UserId, notification.Subject))
+		return nil
+	}
+
+	line, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+	_, err = notifier.Writer.Write(append(line, '\n'))
+	return err
+}
+
+// Emails notifications through an SMTP server
+type SMTPNotifier struct {
+	// host:port of the server
+	Addr string
+	From string
+	// plain authentication is used when set
+	Username string
+	Password string
+	// limit of the conversation when the context has no deadline (ex:
+	// scheduled jobs), none when 0
+	Timeout time.Duration
+}
+
+func (notifier *SMTPNotifier) Notify(ctx context.Context, notification Notification) error {
+	if notification.To == "" {
+		return ErrNoRecipient
+	}
+
+	host, _, err := net.SplitHostPort(notifier.Addr)
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok && notifier.Timeout > 0 {
+		deadline, ok = time.Now().Add(notifier.Timeout), true
+	}
+
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", notifier.Addr)
+	if err != nil {
+		return err
+	}
+	// the conversation stops with the context (ex: server shutdown)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err == nil {
+		defer client.Close()
+		err = notifier.send(client, host, notification)
+	} else {
+		conn.Close()
+	}
+	// errors of the closed connection come from the context
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Same steps as smtp.SendMail, which can't be cancelled
+func (notifier *SMTPNotifier) send(client *smtp.Client, host string, notification Notification) error {
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	// plain authentication is refused without TLS, except on localhost
+	if notifier.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp server doesn't support AUTH")
+		}
+		if err := client.Auth(smtp.PlainAuth("", notifier.Username, notifier.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(notifier.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(notification.To); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(notifier.message(notification)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// RFC 5322 message for the notification
+func (notifier *SMTPNotifier) message(notification Notification) []byte {
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", notifier.From)
+	fmt.Fprintf(&message, "To: %s\r\n", notification.To)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(notification.Subject)))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(strings.ReplaceAll(notification.Body, "\n", "\r\n"))
+
+	return []byte(message.String())
+}
+
+// Header values can't span lines
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,221 @@
+package notifications
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the notifiers
+type NotifierTestSuite struct {
+	suite.Suite
+}
+
+// Minimal SMTP server accepting one message per connection
+type stubSMTPServer struct {
+	listener net.Listener
+	messages chan stubMessage
+}
+
+type stubMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func newStubSMTPServer(addr string) (*stubSMTPServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &stubSMTPServer{listener: listener, messages: make(chan stubMessage, 10)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return server, nil
+}
+
+func (server *stubSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	message := stubMessage{}
+
+	text.PrintfLine("220 stub ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			text.PrintfLine("250-stub")
+			text.PrintfLine("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "AUTH"):
+			text.PrintfLine("235 Authenticated")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			message.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			message.to = append(message.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			text.PrintfLine("250 OK")
+		case command == "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			message.data = string(data)
+			server.messages <- message
+			text.PrintfLine("250 OK")
+		case command == "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (suite *NotifierTestSuite) TestLogNotifierWritesJsonLines() {
+	var buffer bytes.Buffer
+	notifier := &LogNotifier{Writer: &buffer}
+
+	suite.Nil(notifier.Notify(context.Background(), Notification{UserId: 1, Subject: "First"}))
+	suite.Nil(notifier.Notify(context.Background(), Notification{UserId: 2, Subject: "Second"}))
+
+	scanner := bufio.NewScanner(&buffer)
+	subjects := make([]string, 0)
+	for scanner.Scan() {
+		var notification Notification
+		if suite.Nil(json.Unmarshal(scanner.Bytes(), &notification)) {
+			subjects = append(subjects, notification.Subject)
+			suite.False(notification.Sent.IsZero())
+		}
+	}
+	suite.Equal([]string{"First", "Second"}, subjects)
+}
+
+func (suite *NotifierTestSuite) TestSMTPNotifierSendsEmail() {
+	server, err := newStubSMTPServer("127.0.0.1:0")
+	suite.Require().Nil(err)
+	defer server.listener.Close()
+
+	notifier := &SMTPNotifier{Addr: server.listener.Addr().String(), From: "rentals@example.com"}
+	err = notifier.Notify(context.Background(), Notification{
+		UserId:  1,
+		To:      "john.smith@example.com",
+		Subject: "New rentals\nfor you",
+		Body:    "Hi John,\nHave a look.",
+	})
+	if suite.Nil(err) {
+		message := <-server.messages
+		suite.Equal("rentals@example.com", message.from)
+		suite.Equal([]string{"john.smith@example.com"}, message.to)
+		suite.Contains(message.data, "Subject: New rentals for you\n")
+		suite.Contains(message.data, "Hi John,\nHave a look.")
+	}
+}
+
+func (suite *NotifierTestSuite) TestSMTPNotifierEncodesSubject() {
+	server, err := newStubSMTPServer("127.0.0.1:0")
+	suite.Require().Nil(err)
+	defer server.listener.Close()
+
+	notifier := &SMTPNotifier{Addr: server.listener.Addr().String(), From: "rentals@example.com"}
+	err = notifier.Notify(context.Background(), Notification{UserId: 1, To: "john.smith@example.com", Subject: "Évasion à Montréal"})
+	if suite.Nil(err) {
+		message := <-server.messages
+		suite.Contains(message.data, "Subject: =?utf-8?q?=C3=89vasion_=C3=A0_Montr=C3=A9al?=\n")
+	}
+}
+
+func (suite *NotifierTestSuite) TestSMTPNotifierIPv6() {
+	server, err := newStubSMTPServer("[::1]:0")
+	suite.Require().Nil(err)
+	defer server.listener.Close()
+
+	// plain authentication checks the host of the server
+	notifier := &SMTPNotifier{Addr: server.listener.Addr().String(), From: "rentals@example.com", Username: "rentals", Password: "secret"}
+	err = notifier.Notify(context.Background(), Notification{UserId: 1, To: "john.smith@example.com", Subject: "Over IPv6"})
+	if suite.Nil(err) {
+		message := <-server.messages
+		suite.Equal([]string{"john.smith@example.com"}, message.to)
+	}
+}
+
+func (suite *NotifierTestSuite) TestSMTPNotifierWithoutRecipient() {
+	notifier := &SMTPNotifier{Addr: "127.0.0.1:1", From: "rentals@example.com"}
+	err := notifier.Notify(context.Background(), Notification{UserId: 5, Subject: "Nothing"})
+	suite.ErrorIs(err, ErrNoRecipient)
+}
+
+func (suite *NotifierTestSuite) TestSMTPNotifierCancelled() {
+	// accepts connections and never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().Nil(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	notifier := &SMTPNotifier{Addr: listener.Addr().String(), From: "rentals@example.com"}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err = notifier.Notify(ctx, Notification{UserId: 1, To: "john.smith@example.com", Subject: "Stuck"})
+
+	suite.ErrorIs(err, context.DeadlineExceeded)
+	suite.Less(time.Since(started), 5*time.Second)
+}
+
+func (suite *NotifierTestSuite) TestSMTPNotifierTimeout() {
+	// accepts connections and never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().Nil(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	notifier := &SMTPNotifier{Addr: listener.Addr().String(), From: "rentals@example.com", Timeout: 100 * time.Millisecond}
+	started := time.Now()
+	// without a deadline, ex: scheduled jobs
+	err = notifier.Notify(context.Background(), Notification{UserId: 1, To: "john.smith@example.com", Subject: "Stuck"})
+
+	suite.ErrorIs(err, os.ErrDeadlineExceeded)
+	suite.Less(time.Since(started), 5*time.Second)
+}
+
+func TestNotifierTestSuite(t *testing.T) {
+	suite.Run(t, new(NotifierTestSuite))
+}
@@ -0,0 +1,74 @@
+package outbound
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Redirects followed by the clients
+const MaxRedirects = 5
+
+// Returned when a URL isn't on a public HTTP(S) address
+var ErrForbiddenAddress = errors.New("address not allowed")
+
+// Shared address space for carrier-grade NAT, not covered by netip.Addr.IsPrivate
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Client for URLs coming from users and partners (ex: source images, feeds),
+// which must not reach the internal network. Only public addresses are
+// connected to unless allowPrivate is set, ex: for local stand-ins
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = publicAddressOnly
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// the dialer checks the address of the URL, not of a proxy
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Transport: transport, Timeout: timeout, CheckRedirect: CheckRedirect}
+}
+
+// Rejects connections to addresses that aren't public, called after DNS
+// resolution so host names resolving to internal addresses are rejected too.
+// Redirects go through the same dialer
+func publicAddressOnly(network string, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	ip = ip.Unmap()
+	// global unicast excludes loopback, link-local, multicast and unspecified addresses
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// Follows a limited number of redirects, to HTTP(S) URLs only
+func CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", MaxRedirects)
+	}
+	return CheckScheme(req.URL)
+}
+
+// Rejects URLs other than HTTP(S), ex: file://
+func CheckScheme(source *url.URL) error {
+	if source.Scheme != "http" && source.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrForbiddenAddress, source.Scheme)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package outbound
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the clients of user supplied URLs
+type OutboundTestSuite struct {
+	suite.Suite
+}
+
+func (suite *OutboundTestSuite) TestPublicAddressOnly() {
+	for _, address := range []string{"127.0.0.1:80", "10.1.2.3:80", "192.168.0.1:443", "169.254.169.254:80", "100.64.0.1:80", "[::1]:80", "[fe80::1]:80", "[fd00::1]:80", "[::ffff:127.0.0.1]:80", "0.0.0.0:80"} {
+		suite.ErrorIs(publicAddressOnly("tcp", address, nil), ErrForbiddenAddress, address)
+	}
+	for _, address := range []string{"93.184.216.34:80", "[2606:2800:220:1::1]:443"} {
+		suite.Nil(publicAddressOnly("tcp", address, nil), address)
+	}
+}
+
+func (suite *OutboundTestSuite) TestNewClient() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// the stand-in listens on a loopback address
+	_, err := NewClient(time.Second, false).Get(server.URL)
+	suite.ErrorIs(err, ErrForbiddenAddress)
+
+	resp, err := NewClient(time.Second, true).Get(server.URL)
+	if suite.Nil(err, "Should connect to private addresses when allowed") {
+		resp.Body.Close()
+	}
+}
+
+func (suite *OutboundTestSuite) TestCheckScheme() {
+	source, _ := url.Parse("file:///etc/passwd")
+	suite.ErrorIs(CheckScheme(source), ErrForbiddenAddress)
+}
+
+func TestOutboundTestSuite(t *testing.T) {
+	suite.Run(t, new(OutboundTestSuite))
+}
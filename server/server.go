@@ -1,12 +1,24 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/auth"
 	"github.com/samuelg/rentals/config"
 	"github.com/samuelg/rentals/controllers"
+	"github.com/samuelg/rentals/db"
 	log "github.com/samuelg/rentals/logging"
 	"github.com/samuelg/rentals/metrics"
+	"github.com/samuelg/rentals/models"
+	"github.com/samuelg/rentals/notifications"
 )
 
 // Create router with routes for rentals
@@ -20,11 +32,106 @@ func NewRouter() *gin.Engine {
 	// configure prometheus metrics
 	metrics.Init(router)
 
+	// uploaded files are served by the API when stored locally
+	if config.GetConfig().StorageDriver == "local" {
+		router.Static(config.GetConfig().StorageBaseUrl, config.GetConfig().StorageDir)
+	}
+
+	// identify the user making the request
+	router.Use(auth.Identify())
+
 	rentalGroup := router.Group("rentals")
 	{
 		rentals := new(controllers.RentalController)
 		rentalGroup.GET("/", rentals.List)
+		rentalGroup.GET("/export", auth.RequireUser(), auth.LimitConcurrentRequests(int(config.GetConfig().MaxConcurrentExports)), rentals.Export)
 		rentalGroup.GET("/:rental_id", rentals.Get)
+		rentalGroup.POST("/", auth.RequireUser(), rentals.Create)
+		rentalGroup.POST("/import", auth.RequireUser(), rentals.Import)
+		rentalGroup.PUT("/:rental_id", auth.RequireUser(), rentals.Update)
+		rentalGroup.DELETE("/:rental_id", auth.RequireUser(), rentals.Delete)
+		rentalGroup.GET("/:rental_id/history", auth.RequireUser(), rentals.History)
+		rentalGroup.PUT("/:rental_id/amenities", auth.RequireUser(), rentals.UpdateAmenities)
+
+		prices := new(controllers.PriceController)
+		rentalGroup.GET("/:rental_id/prices", prices.List)
+		rentalGroup.GET("/stats/price-trends", prices.Trends)
+
+		reviews := new(controllers.ReviewController)
+		rentalGroup.GET("/:rental_id/reviews", reviews.List)
+		rentalGroup.POST("/:rental_id/reviews", auth.RequireUser(), reviews.Create)
+
+		gallery := new(controllers.ImageController)
+		rentalGroup.GET("/:rental_id/images", gallery.List)
+		rentalGroup.POST("/:rental_id/images", auth.RequireUser(), gallery.Create)
+		rentalGroup.PUT("/:rental_id/images/:image_id", auth.RequireUser(), gallery.Update)
+		rentalGroup.DELETE("/:rental_id/images/:image_id", auth.RequireUser(), gallery.Delete)
+	}
+
+	imageGroup := router.Group("images")
+	{
+		proxy := new(controllers.ImageProxyController)
+		imageGroup.GET("/:rental_id/:variant", proxy.Get)
+	}
+
+	wishlistGroup := router.Group("wishlists", auth.RequireUser())
+	{
+		wishlists := new(controllers.WishlistController)
+		wishlistGroup.GET("/", wishlists.List)
+		wishlistGroup.POST("/", wishlists.Create)
+		wishlistGroup.GET("/:wishlist_id", wishlists.Get)
+		wishlistGroup.PUT("/:wishlist_id", wishlists.Update)
+		wishlistGroup.DELETE("/:wishlist_id", wishlists.Delete)
+		wishlistGroup.POST("/:wishlist_id/share", wishlists.Share)
+		wishlistGroup.DELETE("/:wishlist_id/share", wishlists.Unshare)
+		wishlistGroup.POST("/:wishlist_id/items", wishlists.AddItem)
+		wishlistGroup.DELETE("/:wishlist_id/items/:rental_id", wishlists.RemoveItem)
+	}
+	router.GET("/shared/wishlists/:share_token", new(controllers.WishlistController).GetShared)
+
+	savedSearchGroup := router.Group("saved-searches", auth.RequireUser())
+	{
+		savedSearches := new(controllers.SavedSearchController)
+		savedSearchGroup.GET("/", savedSearches.List)
+		savedSearchGroup.POST("/", savedSearches.Create)
+		savedSearchGroup.DELETE("/:saved_search_id", savedSearches.Delete)
+	}
+
+	priceWatchGroup := router.Group("price-watches", auth.RequireUser())
+	{
+		priceWatches := new(controllers.PriceWatchController)
+		priceWatchGroup.GET("/", priceWatches.List)
+		priceWatchGroup.POST("/", priceWatches.Create)
+		priceWatchGroup.DELETE("/:price_watch_id", priceWatches.Delete)
+	}
+
+	amenityGroup := router.Group("amenities")
+	{
+		amenities := new(controllers.AmenityController)
+		amenityGroup.GET("/", amenities.List)
+		amenityGroup.GET("/:amenity_id", amenities.Get)
+		amenityGroup.POST("/", auth.RequireAdmin(), amenities.Create)
+		amenityGroup.PUT("/:amenity_id", auth.RequireAdmin(), amenities.Update)
+		amenityGroup.DELETE("/:amenity_id", auth.RequireAdmin(), amenities.Delete)
+	}
+
+	adminGroup := router.Group("admin", auth.RequireAdmin())
+	{
+		quality := new(controllers.QualityController)
+		adminGroup.GET("/quality-report", quality.Report)
+
+		feeds := new(controllers.FeedController)
+		adminGroup.GET("/feeds", feeds.List)
+		adminGroup.POST("/feeds", feeds.Create)
+		adminGroup.PUT("/feeds/:feed_id", feeds.Update)
+		adminGroup.DELETE("/feeds/:feed_id", feeds.Delete)
+		adminGroup.POST("/feeds/:feed_id/sync", feeds.Sync)
+		adminGroup.GET("/feeds/:feed_id/syncs", feeds.Syncs)
+
+		duplicates := new(controllers.DuplicateController)
+		adminGroup.GET("/rentals/duplicates", duplicates.List)
+		adminGroup.POST("/rentals/duplicates/dismiss", duplicates.Dismiss)
+		adminGroup.POST("/rentals/duplicates/merge", duplicates.Merge)
 	}
 
 	log.Log.Info("Router created")
@@ -32,10 +139,151 @@ func NewRouter() *gin.Engine {
 	return router
 }
 
-func Init() {
+// Run the job every interval until the context is done
+func runEvery(ctx context.Context, interval time.Duration, job func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job(ctx)
+		}
+	}
+}
+
+// Notify users of new rentals matching their saved searches
+func evaluateSavedSearches(ctx context.Context) {
+	report, err := models.EvaluateSavedSearches(ctx, notifications.Default)
+	if err != nil {
+		log.Log.Error(fmt.Sprintf("Failed to evaluate saved searches: %v", err))
+		return
+	}
+	log.Log.Info(fmt.Sprintf("Evaluated %d saved searches, %d notified, %d failed", report.Evaluated, report.Notified, report.Failed))
+}
+
+// Deliver queued price drop alerts
+func deliverPriceAlerts(ctx context.Context) {
+	report, err := models.DeliverPriceAlerts(ctx, notifications.Default)
+	if err != nil {
+		log.Log.Error(fmt.Sprintf("Failed to deliver price alerts: %v", err))
+		return
+	}
+	if report.Delivered+report.Retried+report.Abandoned > 0 {
+		log.Log.Info(fmt.Sprintf("Delivered %d price alerts, %d retried, %d abandoned", report.Delivered, report.Retried, report.Abandoned))
+	}
+}
+
+// Sync the partner feeds
+func syncFeeds(ctx context.Context) {
+	report, err := models.SyncFeeds(ctx)
+	if err != nil {
+		log.Log.Error(fmt.Sprintf("Failed to sync feeds: %v", err))
+		return
+	}
+	log.Log.Info(fmt.Sprintf("Synced feeds, %d applied, %d unchanged, %d failed", report.Applied, report.Unchanged, report.Failed))
+}
+
+// Run the API server and its background jobs until SIGINT or SIGTERM, then
+// drain the requests in flight, stop the jobs and close the database pool
+func Init() error {
 	r := NewRouter()
 
-	listenAddress := fmt.Sprintf("%s:%d", config.GetConfig().Host, config.GetConfig().Port)
-	log.Log.Info(fmt.Sprintf("Listening on %s", listenAddress))
-	log.Log.Fatal(r.Run(listenAddress))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// a job running when the server stops is cancelled, the jobs are safe to
+	// run again
+	var jobs sync.WaitGroup
+	schedule := func(seconds uint32, job func(ctx context.Context)) {
+		if seconds == 0 {
+			return
+		}
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			runEvery(ctx, time.Duration(seconds)*time.Second, job)
+		}()
+	}
+	schedule(config.GetConfig().SavedSearchInterval, evaluateSavedSearches)
+	schedule(config.GetConfig().PriceAlertInterval, deliverPriceAlerts)
+	schedule(config.GetConfig().FeedSyncInterval, syncFeeds)
+
+	err := listen(ctx, r)
+	stop()
+	jobs.Wait()
+
+	if closeErr := db.Close(); closeErr != nil {
+		log.Log.Error(fmt.Sprintf("Failed to close the database: %v", closeErr))
+	}
+	return err
+}
+
+// Create a router with the read only rental routes, served from the repository
+func NewDemoRouter(rentals models.RentalRepository) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Logger())
+	router.Use(gin.Recovery())
+	router.SetTrustedProxies(nil)
+
+	rentalGroup := router.Group("rentals")
+	{
+		controller := &controllers.RentalController{Rentals: rentals}
+		rentalGroup.GET("/", controller.List)
+		rentalGroup.GET("/:rental_id", controller.Get)
+	}
+
+	log.Log.Info("Demo router created")
+
+	return router
+}
+
+// Run the demo server until SIGINT or SIGTERM, no database or background jobs
+// are needed
+func Demo(rentals models.RentalRepository) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return listen(ctx, NewDemoRouter(rentals))
+}
+
+// Serve until the context is done, then stop accepting connections and wait
+// for the requests in flight until shutdown_timeout. Returns an error when the
+// server can't listen or requests were still running at the deadline
+func listen(ctx context.Context, r *gin.Engine) error {
+	conf := config.GetConfig()
+	server := &http.Server{
+		Addr:         fmt.Sprintf("%s:%d", conf.Host, conf.Port),
+		Handler:      r,
+		ReadTimeout:  time.Duration(conf.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(conf.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(conf.IdleTimeout) * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Log.Info(fmt.Sprintf("Listening on %s", server.Addr))
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		// ex: the address is already in use
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Log.Info("Shutting down, draining requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(conf.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// cut off the requests still running
+		server.Close()
+		return fmt.Errorf("drain requests: %w", err)
+	}
+
+	log.Log.Info("Server stopped")
+	return nil
 }
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samuelg/rentals/config"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for running and stopping the server, needs no database
+type ServerTestSuite struct {
+	suite.Suite
+	port int
+}
+
+func (suite *ServerTestSuite) SetupTest() {
+	// a port nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().Nil(err)
+	suite.port = listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	config.InitWithOverrides("test", map[string]interface{}{"host": "127.0.0.1", "port": suite.port})
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	gin.SetMode(gin.TestMode)
+}
+
+func (suite *ServerTestSuite) TearDownTest() {
+	config.Init("test")
+}
+
+// Wait for the server to accept connections
+func (suite *ServerTestSuite) waitListening() {
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(suite.port))
+	suite.Eventually(func() bool {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (suite *ServerTestSuite) TestListenDrainsRequests() {
+	started := make(chan struct{})
+	var finished atomic.Bool
+	router := gin.New()
+	router.GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		finished.Store(true)
+		c.String(http.StatusOK, "done")
+	})
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- listen(ctx, router)
+	}()
+	suite.waitListening()
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get("http://127.0.0.1:" + strconv.Itoa(suite.port) + "/slow")
+		suite.Nil(err)
+		responses <- resp
+	}()
+	<-started
+
+	// the request in flight completes before listen returns
+	stop()
+	suite.Nil(<-stopped)
+	suite.True(finished.Load(), "Should wait for the request in flight")
+
+	resp := <-responses
+	if suite.NotNil(resp) {
+		suite.Equal(http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	}
+
+	// new connections are refused
+	_, err := http.Get("http://127.0.0.1:" + strconv.Itoa(suite.port) + "/slow")
+	suite.NotNil(err)
+}
+
+func (suite *ServerTestSuite) TestRunEveryStopsJobs() {
+	var runs atomic.Int32
+	cancelled := make(chan struct{})
+	job := func(ctx context.Context) {
+		runs.Add(1)
+		// a long job, ex: a feed sync
+		<-ctx.Done()
+		close(cancelled)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runEvery(ctx, 10*time.Millisecond, job)
+		close(done)
+	}()
+	suite.Eventually(func() bool { return runs.Load() == 1 }, 5*time.Second, 5*time.Millisecond)
+
+	stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		suite.Fail("Should stop once the context is cancelled")
+	}
+	// the running job saw the cancellation and no other run started
+	select {
+	case <-cancelled:
+	default:
+		suite.Fail("Should cancel the running job")
+	}
+	suite.Equal(int32(1), runs.Load())
+}
+
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
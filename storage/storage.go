@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/samuelg/rentals/config"
+	log "github.com/samuelg/rentals/logging"
+)
+
+// Returned when a key does not exist in the storage
+var ErrNotFound = errors.New("file not found")
+
+// Where uploaded files (ex: rental images) are kept
+type Storage interface {
+	// Store the content under the key, replaces existing content
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open the content stored under the key, ErrNotFound when missing
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete the content stored under the key, missing keys are ignored
+	Delete(ctx context.Context, key string) error
+	// Public URL of the content stored under the key
+	URL(key string) string
+	// Key of the content served at the URL, false when the URL is not ours
+	Key(url string) (string, bool)
+}
+
+// Storage configured for the app
+var Default Storage
+
+func Init() {
+	switch config.GetConfig().StorageDriver {
+	case "local":
+		local, err := NewLocal(config.GetConfig().StorageDir, config.GetConfig().StorageBaseUrl)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to initialize storage: %v", err))
+		}
+		Default = local
+	default:
+		panic(fmt.Sprintf("Unknown storage driver: %s", config.GetConfig().StorageDriver))
+	}
+
+	log.Log.Info(fmt.Sprintf("Using %s storage", config.GetConfig().StorageDriver))
+}
+
+// Stores files in a directory of the local filesystem, the directory is
+// expected to be served by the API under the base URL
+type Local struct {
+	Dir     string
+	BaseUrl string
+}
+
+func NewLocal(dir string, baseUrl string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Local{Dir: dir, BaseUrl: strings.TrimRight(baseUrl, "/")}, nil
+}
+
+func (s *Local) Put(ctx context.Context, key string, r io.Reader) error {
+	filename, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so readers never see partial content
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+func (s *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	filename, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return file, err
+}
+
+func (s *Local) Delete(ctx context.Context, key string) error {
+	filename, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Local) URL(key string) string {
+	return s.BaseUrl + "/" + key
+}
+
+func (s *Local) Key(url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, s.BaseUrl+"/")
+	if !ok || key == "" {
+		return "", false
+	}
+
+	return key, true
+}
+
+// Returns the path of the file for the key, keys can't escape the directory
+func (s *Local) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || cleaned != "/"+key {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+
+	return filepath.Join(s.Dir, filepath.FromSlash(cleaned)), nil
+}
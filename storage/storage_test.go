@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the local storage
+type LocalTestSuite struct {
+	suite.Suite
+	storage *Local
+}
+
+func (suite *LocalTestSuite) SetupTest() {
+	storage, err := NewLocal(suite.T().TempDir(), "/media/")
+	suite.Require().Nil(err)
+	suite.storage = storage
+}
+
+func (suite *LocalTestSuite) TestPutAndOpen() {
+	ctx := context.Background()
+	suite.Nil(suite.storage.Put(ctx, "rentals/1/original.jpg", strings.NewReader("content")))
+
+	file, err := suite.storage.Open(ctx, "rentals/1/original.jpg")
+	if suite.Nil(err) {
+		defer file.Close()
+		content, _ := io.ReadAll(file)
+		suite.Equal("content", string(content))
+	}
+}
+
+func (suite *LocalTestSuite) TestOpenMissing() {
+	_, err := suite.storage.Open(context.Background(), "missing.jpg")
+	suite.ErrorIs(err, ErrNotFound)
+}
+
+func (suite *LocalTestSuite) TestDelete() {
+	ctx := context.Background()
+	suite.Nil(suite.storage.Put(ctx, "file.jpg", strings.NewReader("content")))
+	suite.Nil(suite.storage.Delete(ctx, "file.jpg"))
+
+	_, err := suite.storage.Open(ctx, "file.jpg")
+	suite.ErrorIs(err, ErrNotFound)
+	// deleting again is fine
+	suite.Nil(suite.storage.Delete(ctx, "file.jpg"))
+}
+
+func (suite *LocalTestSuite) TestInvalidKeys() {
+	ctx := context.Background()
+	for _, key := range []string{"", "../escape.jpg", "rentals/../../escape.jpg", "/absolute.jpg"} {
+		suite.NotNil(suite.storage.Put(ctx, key, strings.NewReader("content")), key)
+	}
+}
+
+func (suite *LocalTestSuite) TestURL() {
+	suite.Equal("/media/rentals/1/thumb.webp", suite.storage.URL("rentals/1/thumb.webp"))
+}
+
+func (suite *LocalTestSuite) TestKey() {
+	key, ok := suite.storage.Key("/media/rentals/1/thumb.webp")
+	suite.True(ok)
+	suite.Equal("rentals/1/thumb.webp", key)
+
+	_, ok = suite.storage.Key("https://res.cloudinary.com/outdoorsy/image/upload/v1528586451/p/rentals/4447/images/yxm1aidfmrmwzbd6nfbe.jpg")
+	suite.False(ok)
+}
+
+func TestLocalTestSuite(t *testing.T) {
+	suite.Run(t, new(LocalTestSuite))
+}
@@ -0,0 +1,329 @@
+package synthetic
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/samuelg/rentals/geo"
+	"github.com/samuelg/rentals/models"
+)
+
+// Rentals are created in the years before this time, fixed so a seed always
+// generates the same data
+var generatedUntil = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Most a rental is moved away from its place, in degrees (about 3 miles)
+const maxJitter = 0.05
+
+// A make and model of a rental type
+type vehicle struct {
+	make  string
+	model string
+	// nil for towables
+	fuel *string
+}
+
+// How rentals of a type look, prices are log-normal around the median
+type rentalType struct {
+	name string
+	// relative share of the rentals
+	weight      int
+	medianPrice int64
+	priceSpread float64
+	minSleeps   int32
+	maxSleeps   int32
+	minLength   float32
+	maxLength   float32
+	vehicles    []vehicle
+	// trailers have no engine, transmission or seatbelts
+	towable bool
+}
+
+func fuel(value string) *string {
+	return &value
+}
+
+var rentalTypes = []rentalType{
+	{
+		name: "camper-van", weight: 35, medianPrice: 15000, priceSpread: 0.35,
+		minSleeps: 2, maxSleeps: 4, minLength: 17, maxLength: 22,
+		vehicles: []vehicle{
+			{"Mercedes-Benz", "Sprinter", fuel("diesel")},
+			{"Ford", "Transit", fuel("gasoline")},
+			{"Ram", "ProMaster", fuel("gasoline")},
+			{"Volkswagen", "Vanagon", fuel("gasoline")},
+			{"Volkswagen", "Westfalia", fuel("gasoline")},
+			{"Volkswagen", "ID. Buzz", fuel("electric")},
+			{"Winnebago", "Travato", fuel("gasoline")},
+			{"Winnebago", "Revel", fuel("diesel")},
+			{"Nissan", "NV2500", fuel("gasoline")},
+			{"Toyota", "Hiace", fuel("diesel")},
+		},
+	},
+	{
+		name: "class-b", weight: 10, medianPrice: 20000, priceSpread: 0.3,
+		minSleeps: 2, maxSleeps: 4, minLength: 19, maxLength: 24,
+		vehicles: []vehicle{
+			{"Roadtrek", "Zion", fuel("gasoline")},
+			{"Pleasure-Way", "Plateau", fuel("diesel")},
+			{"Airstream", "Interstate", fuel("diesel")},
+			{"Hymer", "Aktiv", fuel("gasoline")},
+		},
+	},
+	{
+		name: "class-c", weight: 25, medianPrice: 22000, priceSpread: 0.3,
+		minSleeps: 4, maxSleeps: 8, minLength: 22, maxLength: 32,
+		vehicles: []vehicle{
+			{"Thor", "Four Winds", fuel("gasoline")},
+			{"Jayco", "Greyhawk", fuel("gasoline")},
+			{"Forest River", "Sunseeker", fuel("gasoline")},
+			{"Winnebago", "View", fuel("diesel")},
+			{"Coachmen", "Freelander", fuel("gasoline")},
+			{"Leisure Travel Vans", "Unity", fuel("diesel")},
+		},
+	},
+	{
+		name: "class-a", weight: 10, medianPrice: 35000, priceSpread: 0.35,
+		minSleeps: 4, maxSleeps: 8, minLength: 30, maxLength: 42,
+		vehicles: []vehicle{
+			{"Fleetwood", "Bounder", fuel("gasoline")},
+			{"Newmar", "Dutch Star", fuel("diesel")},
+			{"Tiffin", "Allegro", fuel("gasoline")},
+			{"Thor", "Challenger", fuel("gasoline")},
+		},
+	},
+	{
+		name: "travel-trailer", weight: 15, medianPrice: 9000, priceSpread: 0.4,
+		minSleeps: 2, maxSleeps: 8, minLength: 16, maxLength: 32, towable: true,
+		vehicles: []vehicle{
+			{"Airstream", "Flying Cloud", nil},
+			{"Jayco", "Jay Flight", nil},
+			{"Keystone", "Passport", nil},
+			{"Forest River", "Rockwood", nil},
+		},
+	},
+	{
+		name: "truck-camper", weight: 5, medianPrice: 16000, priceSpread: 0.3,
+		minSleeps: 2, maxSleeps: 4, minLength: 18, maxLength: 22,
+		vehicles: []vehicle{
+			{"Toyota", "Tacoma", fuel("gasoline")},
+			{"Ford", "F-250", fuel("diesel")},
+			{"Ram", "2500", fuel("diesel")},
+		},
+	},
+}
+
+var (
+	firstNames = []string{"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David", "Elizabeth",
+		"William", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Carlos", "Karen", "Wei", "Aisha",
+		"Mateo", "Priya", "Liam", "Olivia", "Noah", "Emma", "Hiroshi", "Fatima"}
+	lastNames = []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+		"Hernandez", "Lopez", "Wilson", "Anderson", "Taylor", "Thomas", "Moore", "Jackson", "Martin", "Lee", "Nguyen", "Chen",
+		"Patel", "Kim", "Murphy", "Walsh", "O'Brien", "Tanaka", "Khan", "Silva"}
+	nicknames = []string{"Wanderer", "Basecamp", "Roamer", "Nomad", "Trailblazer", "Sunseeker", "Driftwood", "Juniper",
+		"Pinecone", "Bluebird", "Sagebrush", "Tumbleweed"}
+	sentences = []string{
+		"Perfect for a weekend in the mountains.",
+		"Fully stocked kitchen with a two burner stove.",
+		"Pets are welcome with a small cleaning fee.",
+		"Solar panels keep the fridge running off grid.",
+		"Bedding and towels are included.",
+		"Delivery to nearby campgrounds is available.",
+		"Great fuel economy for long road trips.",
+		"Recently serviced with new tires.",
+		"Roomy enough for the whole family.",
+		"Outdoor shower for rinsing off after the beach.",
+	}
+)
+
+// Deterministic generator of users and rentals, the same seed always generates
+// the same data
+type Generator struct {
+	rand        *rand.Rand
+	places      []geo.Place
+	totalWeight int
+}
+
+func NewGenerator(seed int64) *Generator {
+	generator := &Generator{
+		rand:   rand.New(rand.NewSource(seed)),
+		places: geo.Default().Places(),
+	}
+	for _, t := range rentalTypes {
+		generator.totalWeight += t.weight
+	}
+
+	return generator
+}
+
+// A user, the id is only used to keep emails unique
+func (g *Generator) User(id uint32) models.User {
+	firstName := pick(g.rand, firstNames)
+	lastName := pick(g.rand, lastNames)
+	user := models.User{ID: id, FirstName: firstName, LastName: lastName}
+	// some users never gave an email
+	if g.rand.Float64() >= 0.1 {
+		local := strings.ToLower(strings.ReplaceAll(firstName+"."+lastName, "'", ""))
+		user.Email = fmt.Sprintf("%s.%d@example.com", local, id)
+	}
+
+	return user
+}
+
+// Id of the owner of the next rental, users have ids from firstId
+func (g *Generator) Owner(firstId uint32, users int) uint32 {
+	return firstId + uint32(g.rand.Intn(users))
+}
+
+// A rental owned by the user
+func (g *Generator) Rental(userId uint32) models.Rental {
+	t := g.rentalType()
+	v := t.vehicles[g.rand.Intn(len(t.vehicles))]
+	place := g.places[g.rand.Intn(len(g.places))]
+
+	year := int32(1975 + g.rand.Intn(50))
+	// older vehicles are mostly vintage vans
+	if year < 2000 && t.name != "camper-van" {
+		year += 25
+	}
+	if v.fuel != nil && *v.fuel == "electric" {
+		year = int32(2020 + g.rand.Intn(5))
+	}
+	sleeps := t.minSleeps + int32(g.rand.Intn(int(t.maxSleeps-t.minSleeps)+1))
+	length := t.minLength + float32(g.rand.Intn(int(t.maxLength-t.minLength)+1))
+
+	// log-normal around the median, newer vehicles cost a little more
+	price := float64(t.medianPrice) * math.Exp(g.rand.NormFloat64()*t.priceSpread) * (1 + float64(year-2000)*0.01)
+	// whole dollars, at least $25
+	priceCents := int64(math.Max(25, math.Round(price/100))) * 100
+
+	created := generatedUntil.Add(-time.Duration(g.rand.Int63n(int64(3 * 365 * 24 * time.Hour))))
+	updated := created.Add(time.Duration(g.rand.Int63n(int64(generatedUntil.Sub(created)) + 1)))
+
+	rental := models.Rental{
+		UserId:            userId,
+		Name:              g.name(t, v, year, place, sleeps),
+		Type:              t.name,
+		Description:       g.description(),
+		Sleeps:            sleeps,
+		Price:             priceCents,
+		City:              place.City,
+		State:             place.State,
+		Zip:               place.Zip,
+		Country:           place.Country,
+		VehicleMake:       v.make,
+		VehicleModel:      v.model,
+		VehicleMakeInput:  v.make,
+		VehicleModelInput: v.model,
+		VehicleYear:       year,
+		VehicleLength:     length,
+		Created:           created,
+		Updated:           updated,
+		Lat:               float32(place.Lat + (g.rand.Float64()*2-1)*maxJitter),
+		Lng:               float32(place.Lng + (g.rand.Float64()*2-1)*maxJitter),
+	}
+	rental.VehicleSpecs = g.specs(t, v)
+
+	return rental
+}
+
+// Weighted pick of a rental type
+func (g *Generator) rentalType() rentalType {
+	n := g.rand.Intn(g.totalWeight)
+	for _, t := range rentalTypes {
+		if n < t.weight {
+			return t
+		}
+		n -= t.weight
+	}
+
+	return rentalTypes[len(rentalTypes)-1]
+}
+
+func (g *Generator) name(t rentalType, v vehicle, year int32, place geo.Place, sleeps int32) string {
+	switch g.rand.Intn(4) {
+	case 0:
+		return fmt.Sprintf("%d %s %s", year, v.make, v.model)
+	case 1:
+		return fmt.Sprintf("%s the %s", pick(g.rand, nicknames), v.model)
+	case 2:
+		return fmt.Sprintf("%s %s Getaway", place.City, v.model)
+	default:
+		return fmt.Sprintf("Cozy %s for %d", strings.ReplaceAll(t.name, "-", " "), sleeps)
+	}
+}
+
+func (g *Generator) description() string {
+	count := 2 + g.rand.Intn(3)
+	parts := make([]string, count)
+	for i := range parts {
+		parts[i] = pick(g.rand, sentences)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (g *Generator) specs(t rentalType, v vehicle) models.VehicleSpecs {
+	specs := models.VehicleSpecs{}
+	water := int32(10 + g.rand.Intn(int(t.maxSleeps)*8))
+	specs.FreshWaterCapacity = &water
+	generator := t.name == "class-a" || t.name == "class-c" || g.rand.Float64() < 0.2
+	specs.Generator = &generator
+	if t.towable {
+		return specs
+	}
+
+	specs.FuelType = v.fuel
+	transmission := "automatic"
+	if g.rand.Float64() < 0.15 {
+		transmission = "manual"
+	}
+	specs.Transmission = &transmission
+
+	drivetrain := "rwd"
+	if t.name == "truck-camper" || g.rand.Float64() < 0.1 {
+		drivetrain = "4wd"
+	}
+	specs.Drivetrain = &drivetrain
+
+	seatbelts := t.minSleeps + int32(g.rand.Intn(3))
+	specs.Seatbelts = &seatbelts
+
+	if t.name == "class-a" || t.name == "class-c" || t.name == "truck-camper" {
+		tow := int32(3500 + 500*g.rand.Intn(10))
+		specs.TowCapacity = &tow
+	}
+	if v.fuel != nil && *v.fuel == "electric" {
+		evRange := int32(150 + g.rand.Intn(150))
+		specs.EvRange = &evRange
+	}
+
+	return specs
+}
+
+func pick(r *rand.Rand, values []string) string {
+	return values[r.Intn(len(values))]
+}
+
+// Generate users and their rentals in memory with ids from 1, the same seed
+// generates the same data as Load into an empty database
+func Generate(seed int64, users int, rentals uint) []models.Rental {
+	generator := NewGenerator(seed)
+	owners := make([]models.User, users)
+	for i := range owners {
+		owners[i] = generator.User(uint32(i + 1))
+	}
+
+	generated := make([]models.Rental, rentals)
+	for i := range generated {
+		rental := generator.Rental(generator.Owner(1, users))
+		rental.ID = uint32(i + 1)
+		rental.User = owners[rental.UserId-1]
+		generated[i] = rental
+	}
+
+	return generated
+}
@@ -0,0 +1,104 @@
+package synthetic
+
+import (
+	"testing"
+
+	"github.com/samuelg/rentals/geo"
+	"github.com/samuelg/rentals/models"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the synthetic data generator
+type GeneratorTestSuite struct {
+	suite.Suite
+}
+
+func generate(seed int64, count int) ([]models.User, []models.Rental) {
+	generator := NewGenerator(seed)
+	users := make([]models.User, 10)
+	for i := range users {
+		users[i] = generator.User(uint32(i + 1))
+	}
+	rentals := make([]models.Rental, count)
+	for i := range rentals {
+		rentals[i] = generator.Rental(generator.Owner(1, len(users)))
+	}
+
+	return users, rentals
+}
+
+func (suite *GeneratorTestSuite) TestDeterministic() {
+	users, rentals := generate(42, 100)
+	sameUsers, sameRentals := generate(42, 100)
+	_, otherRentals := generate(43, 100)
+
+	suite.Equal(users, sameUsers)
+	suite.Equal(rentals, sameRentals)
+	suite.NotEqual(rentals, otherRentals)
+}
+
+func (suite *GeneratorTestSuite) TestPlausibleRentals() {
+	_, rentals := generate(7, 2000)
+
+	types := make(map[string]int)
+	for _, rental := range rentals {
+		types[rental.Type]++
+
+		suite.GreaterOrEqual(rental.Price, int64(2500))
+		suite.GreaterOrEqual(rental.UserId, uint32(1))
+		suite.LessOrEqual(rental.UserId, uint32(10))
+		suite.NotEmpty(rental.VehicleMake)
+		suite.False(rental.Updated.Before(rental.Created))
+		suite.False(rental.Updated.After(generatedUntil))
+		suite.Less(rental.VehicleLength, float32(100))
+
+		nearby := geo.Default().Within(float64(rental.Lat), float64(rental.Lng), 10)
+		suite.NotEmpty(nearby, "Should be close to a known place")
+
+		if rental.Type == "travel-trailer" {
+			suite.Nil(rental.FuelType)
+			suite.Nil(rental.Seatbelts)
+		} else {
+			suite.NotNil(rental.FuelType)
+		}
+	}
+
+	// every type is generated, camper vans the most
+	suite.Len(types, len(rentalTypes))
+	for name, count := range types {
+		suite.LessOrEqual(count, types["camper-van"], name)
+	}
+}
+
+func (suite *GeneratorTestSuite) TestPricesByType() {
+	_, rentals := generate(3, 5000)
+
+	totals := make(map[string]int64)
+	counts := make(map[string]int64)
+	for _, rental := range rentals {
+		totals[rental.Type] += rental.Price
+		counts[rental.Type]++
+	}
+
+	average := func(name string) int64 { return totals[name] / counts[name] }
+	suite.Greater(average("class-a"), average("camper-van"))
+	suite.Greater(average("camper-van"), average("travel-trailer"))
+}
+
+func (suite *GeneratorTestSuite) TestGenerate() {
+	users, rentals := generate(42, 100)
+
+	generated := Generate(42, 10, 100)
+
+	if suite.Len(generated, 100) {
+		for i, rental := range generated {
+			suite.Equal(uint32(i+1), rental.ID)
+			suite.Equal(rentals[i].Name, rental.Name)
+			suite.Equal(users[rental.UserId-1], rental.User)
+		}
+	}
+}
+
+func TestGeneratorTestSuite(t *testing.T) {
+	suite.Run(t, new(GeneratorTestSuite))
+}
@@ -0,0 +1,184 @@
+package synthetic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/samuelg/rentals/models"
+	"gorm.io/gorm"
+)
+
+// Number of rows inserted at once on sqlite, which has no COPY
+const insertBatchSize = 500
+
+var userColumns = []string{"id", "first_name", "last_name", "email"}
+
+var rentalColumns = []string{
+	"user_id", "name", "type", "description", "sleeps", "price_per_day",
+	"home_city", "home_state", "home_zip", "home_country",
+	"vehicle_make", "vehicle_model", "vehicle_make_input", "vehicle_model_input", "vehicle_year", "vehicle_length",
+	"fuel_type", "transmission", "drivetrain", "seatbelts", "tow_capacity", "fresh_water_capacity", "generator", "ev_range",
+	"created", "updated", "lat", "lng",
+}
+
+// Summary of a synthetic data load
+type Report struct {
+	Seed    int64   `json:"seed"`
+	Users   int     `json:"users"`
+	Rentals uint    `json:"rentals"`
+	Seconds float64 `json:"seconds"`
+}
+
+// Generate users and their rentals from the seed and bulk insert them with COPY
+// in a single transaction, or in batches on sqlite. Rentals are generated as
+// they are inserted so memory stays constant
+func Load(ctx context.Context, seed int64, users int, rentals uint) (*Report, error) {
+	if users < 1 {
+		return nil, errors.New("at least one user is required")
+	}
+	start := time.Now()
+	generator := NewGenerator(seed)
+
+	var err error
+	if db.IsSQLite() {
+		err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return insertRows(tx, generator, users, rentals)
+		})
+	} else {
+		err = copyAll(ctx, generator, users, rentals)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{Seed: seed, Users: users, Rentals: rentals, Seconds: time.Since(start).Seconds()}, nil
+}
+
+func copyAll(ctx context.Context, generator *Generator, users int, rentals uint) error {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("COPY needs a pgx connection, got %T", driverConn)
+		}
+
+		return pgx.BeginFunc(ctx, stdlibConn.Conn(), func(tx pgx.Tx) error {
+			return copyRows(ctx, tx, generator, users, rentals)
+		})
+	})
+}
+
+func copyRows(ctx context.Context, tx pgx.Tx, generator *Generator, users int, rentals uint) error {
+	// user ids are set here so rentals can reference them, the fixtures insert
+	// users with explicit ids so the sequence can't be trusted
+	if _, err := tx.Exec(ctx, "LOCK TABLE users IN EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+	var firstId uint32
+	if err := tx.QueryRow(ctx, "SELECT COALESCE(MAX(id), 0) + 1 FROM users").Scan(&firstId); err != nil {
+		return err
+	}
+
+	copied, err := tx.CopyFrom(ctx, pgx.Identifier{"users"}, userColumns, pgx.CopyFromSlice(users, func(i int) ([]any, error) {
+		user := generator.User(firstId + uint32(i))
+		var email any
+		if user.Email != "" {
+			email = user.Email
+		}
+		return []any{int32(user.ID), user.FirstName, user.LastName, email}, nil
+	}))
+	if err != nil {
+		return fmt.Errorf("users: %w", err)
+	}
+	log.Log.Info(fmt.Sprintf("Copied %d users", copied))
+
+	if _, err := tx.Exec(ctx, "SELECT setval('users_id_seq', (SELECT MAX(id) FROM users))"); err != nil {
+		return err
+	}
+
+	copied, err = tx.CopyFrom(ctx, pgx.Identifier{"rentals"}, rentalColumns, pgx.CopyFromSlice(int(rentals), func(i int) ([]any, error) {
+		rental := generator.Rental(generator.Owner(firstId, users))
+		specs := rental.VehicleSpecs
+		return []any{
+			int32(rental.UserId), rental.Name, rental.Type, rental.Description, rental.Sleeps, rental.Price,
+			rental.City, rental.State, rental.Zip, rental.Country,
+			rental.VehicleMake, rental.VehicleModel, rental.VehicleMakeInput, rental.VehicleModelInput, rental.VehicleYear, float64(rental.VehicleLength),
+			nullable(specs.FuelType), nullable(specs.Transmission), nullable(specs.Drivetrain), nullable(specs.Seatbelts),
+			nullable(specs.TowCapacity), nullable(specs.FreshWaterCapacity), nullable(specs.Generator), nullable(specs.EvRange),
+			rental.Created, rental.Updated, float64(rental.Lat), float64(rental.Lng),
+		}, nil
+	}))
+	if err != nil {
+		return fmt.Errorf("rentals: %w", err)
+	}
+	log.Log.Info(fmt.Sprintf("Copied %d rentals", copied))
+
+	_, err = tx.Exec(ctx, "ANALYZE users, rentals")
+	return err
+}
+
+// Insert the same rows as copyRows in batches, the transaction holds the lock
+// of the sqlite file so the ids of the users can be set
+func insertRows(tx *gorm.DB, generator *Generator, users int, rentals uint) error {
+	var firstId uint32
+	if err := tx.Raw("SELECT COALESCE(MAX(id), 0) + 1 FROM users").Scan(&firstId).Error; err != nil {
+		return err
+	}
+
+	userRows := make([]map[string]any, 0, insertBatchSize)
+	for i := 0; i < users; i++ {
+		user := generator.User(firstId + uint32(i))
+		var email any
+		if user.Email != "" {
+			email = user.Email
+		}
+		userRows = append(userRows, map[string]any{"id": user.ID, "first_name": user.FirstName, "last_name": user.LastName, "email": email})
+		if len(userRows) == insertBatchSize || i == users-1 {
+			if err := tx.Table("users").Create(userRows).Error; err != nil {
+				return fmt.Errorf("users: %w", err)
+			}
+			userRows = userRows[:0]
+		}
+	}
+	log.Log.Info(fmt.Sprintf("Inserted %d users", users))
+
+	// the generated rentals have their resolved location and canonical vehicle,
+	// the hooks deriving them are skipped like with COPY
+	insert := tx.Session(&gorm.Session{SkipHooks: true}).Select(rentalColumns)
+	batch := make([]models.Rental, 0, insertBatchSize)
+	for i := uint(0); i < rentals; i++ {
+		batch = append(batch, generator.Rental(generator.Owner(firstId, users)))
+		if len(batch) == insertBatchSize || i == rentals-1 {
+			if err := insert.Create(&batch).Error; err != nil {
+				return fmt.Errorf("rentals: %w", err)
+			}
+			batch = batch[:0]
+		}
+	}
+	log.Log.Info(fmt.Sprintf("Inserted %d rentals", rentals))
+
+	return tx.Exec("ANALYZE").Error
+}
+
+// Value of the pointer, or an untyped nil for NULL
+func nullable[T any](value *T) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
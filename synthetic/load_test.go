@@ -0,0 +1,63 @@
+package synthetic
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/samuelg/rentals/config"
+	"github.com/samuelg/rentals/db"
+	log "github.com/samuelg/rentals/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for loading synthetic data, runs on a sqlite database of its own
+// since the rows stay. COPY needs the Postgres database
+type LoadTestSuite struct {
+	suite.Suite
+}
+
+func (suite *LoadTestSuite) SetupTest() {
+	config.InitWithOverrides("test", map[string]interface{}{
+		"db_driver": "sqlite",
+		"db_path":   filepath.Join(suite.T().TempDir(), "synthetic.db"),
+	})
+	log.Init("FATAL", config.GetConfig().AppVersion)
+	db.Init()
+	db.Prepare()
+}
+
+func (suite *LoadTestSuite) TearDownTest() {
+	db.Close()
+	config.Init("test")
+}
+
+func (suite *LoadTestSuite) count(table string) int64 {
+	var count int64
+	suite.Require().Nil(db.DB.Table(table).Count(&count).Error)
+	return count
+}
+
+func (suite *LoadTestSuite) TestLoadSqlite() {
+	users, rentals := suite.count("users"), suite.count("rentals")
+
+	report, err := Load(context.Background(), 42, 3, insertBatchSize+10)
+
+	if suite.Nil(err, "Should not lead to an error") {
+		suite.Equal(uint(insertBatchSize+10), report.Rentals)
+		suite.Equal(users+3, suite.count("users"))
+		suite.Equal(rentals+insertBatchSize+10, suite.count("rentals"))
+
+		// every rental is owned by a new user and has its price history
+		var orphans int64
+		err = db.DB.Table("rentals").Where("user_id NOT IN (SELECT id FROM users)").Count(&orphans).Error
+		if suite.Nil(err) {
+			suite.Zero(orphans)
+		}
+		suite.Equal(suite.count("rentals"), suite.count("rental_prices"))
+	}
+}
+
+func TestLoadTestSuite(t *testing.T) {
+	suite.Run(t, new(LoadTestSuite))
+}
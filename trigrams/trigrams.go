@@ -0,0 +1,50 @@
+package trigrams
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Trigrams of a text, without duplicates
+type Set map[string]struct{}
+
+// Trigrams of the lowercase words of the text, punctuation is ignored. Words
+// are padded with two spaces before and one after like pg_trgm does, so
+// similarities computed in Go and in Postgres are the same
+func Of(text string) Set {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return nil
+	}
+
+	result := make(Set)
+	for _, word := range words {
+		// short words and word boundaries count
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			result[string(runes[i:i+3])] = struct{}{}
+		}
+	}
+	return result
+}
+
+// Jaccard similarity of the trigrams, from 0 to 1. Small edits keep most of
+// the trigrams, texts without words have no similarity
+func Similarity(a Set, b Set) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+
+	shared := 0
+	for trigram := range a {
+		if _, ok := b[trigram]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
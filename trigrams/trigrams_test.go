@@ -0,0 +1,30 @@
+package trigrams
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for trigram similarity
+type TrigramsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *TrigramsTestSuite) TestOf() {
+	suite.Equal(Set{"  w": {}, " we": {}, "wes": {}, "est": {}, "sty": {}, "ty ": {}, "  a": {}, " a ": {}}, Of("Westy, a!"))
+	suite.Nil(Of(" -- "))
+}
+
+func (suite *TrigramsTestSuite) TestSimilarity() {
+	suite.Equal(1.0, Similarity(Of("Westy, the van"), Of("westy the VAN!")))
+	// as pg_trgm: SELECT similarity('word', 'two words') is 4 / 11
+	suite.InDelta(4.0/11, Similarity(Of("word"), Of("two words")), 0.0001)
+	suite.Less(Similarity(Of("Westy the van"), Of("Airstream Bambi")), 0.1)
+	suite.Equal(0.0, Similarity(Of("Westy"), Of("")))
+	suite.Equal(0.0, Similarity(Of(""), Of("")))
+}
+
+func TestTrigramsTestSuite(t *testing.T) {
+	suite.Run(t, new(TrigramsTestSuite))
+}
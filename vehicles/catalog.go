@@ -0,0 +1,228 @@
+package vehicles
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed data/makes.csv
+var makesData []byte
+
+//go:embed data/models.csv
+var modelsData []byte
+
+// Canonical make and model for a vehicle description
+type Result struct {
+	Make  string
+	Model string
+	// false when the make is not in the catalog, the input is kept trimmed
+	Known bool
+}
+
+// A canonical name and the lowercase names it can be written as
+type entry struct {
+	name  string
+	names []string
+}
+
+// Canonical makes and models with their aliases
+type Catalog struct {
+	makes []entry
+	// canonical make to its models
+	models map[string][]entry
+}
+
+var (
+	defaultCatalog *Catalog
+	defaultOnce    sync.Once
+)
+
+// Returns the catalog built from the dataset embedded in the binary
+func Default() *Catalog {
+	defaultOnce.Do(func() {
+		catalog, err := Load(bytes.NewReader(makesData), bytes.NewReader(modelsData))
+		if err != nil {
+			// the embedded dataset is part of the build, it should always parse
+			panic(fmt.Sprintf("invalid embedded vehicle catalog: %v", err))
+		}
+		defaultCatalog = catalog
+	})
+
+	return defaultCatalog
+}
+
+// Load a catalog from csv makes (make, aliases) and models (make, model,
+// aliases), aliases are separated by |
+func Load(makes io.Reader, models io.Reader) (*Catalog, error) {
+	catalog := &Catalog{models: make(map[string][]entry)}
+
+	makeRows, err := readCsv(makes, 2)
+	if err != nil {
+		return nil, fmt.Errorf("makes: %w", err)
+	}
+	for _, row := range makeRows {
+		catalog.makes = append(catalog.makes, newEntry(row[0], row[1]))
+	}
+
+	modelRows, err := readCsv(models, 3)
+	if err != nil {
+		return nil, fmt.Errorf("models: %w", err)
+	}
+	for _, row := range modelRows {
+		vehicleMake, ok := catalog.findMake(row[0])
+		if !ok || vehicleMake != row[0] {
+			return nil, fmt.Errorf("models: unknown make %s", row[0])
+		}
+		catalog.models[vehicleMake] = append(catalog.models[vehicleMake], newEntry(row[1], row[2]))
+	}
+
+	return catalog, nil
+}
+
+func newEntry(name string, aliases string) entry {
+	names := []string{strings.ToLower(name)}
+	if aliases != "" {
+		for _, alias := range strings.Split(aliases, "|") {
+			names = append(names, strings.ToLower(strings.TrimSpace(alias)))
+		}
+	}
+	// longest first so prefixes match as much as possible
+	sort.SliceStable(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+
+	return entry{name: name, names: names}
+}
+
+// Canonical name of a make, matched on its name or aliases regardless of case
+func (c *Catalog) findMake(value string) (string, bool) {
+	normalized := normalizeSpaces(strings.ToLower(value))
+	for _, e := range c.makes {
+		for _, name := range e.names {
+			if name == normalized {
+				return e.name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Canonical make for a make filter value, the trimmed value when unknown
+func (c *Catalog) Make(value string) string {
+	if vehicleMake, ok := c.findMake(value); ok {
+		return vehicleMake
+	}
+	return normalizeSpaces(value)
+}
+
+// Returns true when the value is a make of the catalog or one of its aliases
+func (c *Catalog) HasMake(value string) bool {
+	_, ok := c.findMake(value)
+	return ok
+}
+
+// Normalize a make and model as entered. The make can also hold the start of the
+// model (ex: "Winnebago Eurovan Camper"), and the model can repeat the make
+func (c *Catalog) Normalize(vehicleMake string, model string) Result {
+	vehicleMake = normalizeSpaces(vehicleMake)
+	model = normalizeSpaces(model)
+
+	canonical, known := c.findMake(vehicleMake)
+	if !known {
+		// the make followed by more words
+		bestLength := 0
+		rest := ""
+		for _, e := range c.makes {
+			if name, remainder, ok := longestPrefix(e.names, vehicleMake); ok && len(name) > bestLength {
+				canonical = e.name
+				bestLength = len(name)
+				rest = remainder
+			}
+		}
+		if canonical == "" {
+			return Result{Make: vehicleMake, Model: model}
+		}
+		if model == "" {
+			model = rest
+		}
+	}
+
+	// the model repeats the make (ex: "Subaru Impreza")
+	for _, e := range c.makes {
+		if e.name == canonical {
+			if _, remainder, ok := longestPrefix(e.names, model); ok && remainder != "" {
+				model = remainder
+			}
+		}
+	}
+
+	return Result{Make: canonical, Model: c.normalizeModel(canonical, model), Known: true}
+}
+
+// Canonical model of a known make, the start of the model is matched so
+// trims and other details are kept (ex: "TRANSIT 350" is "Transit 350")
+func (c *Catalog) normalizeModel(vehicleMake string, model string) string {
+	best := ""
+	bestLength := 0
+	rest := model
+	for _, e := range c.models[vehicleMake] {
+		if name, remainder, ok := longestPrefix(e.names, model); ok && len(name) > bestLength {
+			best = e.name
+			bestLength = len(name)
+			rest = remainder
+		}
+	}
+
+	if best == "" {
+		return model
+	}
+	if rest == "" {
+		return best
+	}
+	return best + " " + rest
+}
+
+// Longest of the lowercase names starting the value, whole words only. Returns
+// the matched name and the rest of the value
+func longestPrefix(names []string, value string) (string, string, bool) {
+	lower := strings.ToLower(value)
+	for _, name := range names {
+		if lower == name {
+			return name, "", true
+		}
+		if strings.HasPrefix(lower, name+" ") {
+			return name, strings.TrimSpace(value[len(name):]), true
+		}
+	}
+	return "", "", false
+}
+
+// Trim and collapse whitespace
+func normalizeSpaces(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+func readCsv(r io.Reader, columns int) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = columns
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("missing header")
+	}
+
+	for _, row := range rows {
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+	}
+
+	return rows[1:], nil
+}
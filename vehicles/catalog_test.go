@@ -0,0 +1,80 @@
+package vehicles
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Test suite for the make / model catalog
+type CatalogTestSuite struct {
+	suite.Suite
+	catalog *Catalog
+}
+
+func (suite *CatalogTestSuite) SetupSuite() {
+	suite.catalog = Default()
+}
+
+func (suite *CatalogTestSuite) TestNormalizeAlias() {
+	result := suite.catalog.Normalize("VW", "Eurovan Weekender Westfalia")
+
+	suite.True(result.Known)
+	suite.Equal("Volkswagen", result.Make)
+	suite.Equal("Eurovan Weekender Westfalia", result.Model)
+}
+
+func (suite *CatalogTestSuite) TestNormalizeCase() {
+	result := suite.catalog.Normalize(" toyota ", "4RUNNER")
+
+	suite.Equal(Result{Make: "Toyota", Model: "4Runner", Known: true}, result)
+}
+
+func (suite *CatalogTestSuite) TestNormalizeModelInMake() {
+	result := suite.catalog.Normalize("Winnebago Eurovan Camper", "Eurovan Camper")
+	suite.Equal(Result{Make: "Winnebago", Model: "Eurovan Camper", Known: true}, result)
+
+	result = suite.catalog.Normalize("Winnebago Travato", "")
+	suite.Equal(Result{Make: "Winnebago", Model: "Travato", Known: true}, result)
+}
+
+func (suite *CatalogTestSuite) TestNormalizeMakeInModel() {
+	result := suite.catalog.Normalize("SUBARU IMPREZA 4WD", "SUBARU IMPREZA 4WD")
+
+	suite.Equal(Result{Make: "Subaru", Model: "Impreza 4WD", Known: true}, result)
+}
+
+func (suite *CatalogTestSuite) TestNormalizeKeepsTrim() {
+	suite.Equal("Transit 350", suite.catalog.Normalize("Ford", "TRANSIT  350").Model)
+	suite.Equal("Econoline 250s", suite.catalog.Normalize("Ford", "Econolline 250s").Model)
+	// only whole words match
+	suite.Equal("Transitional", suite.catalog.Normalize("Ford", "Transitional").Model)
+}
+
+func (suite *CatalogTestSuite) TestNormalizeUnknown() {
+	result := suite.catalog.Normalize("  Homemade ", "Box  Truck")
+
+	suite.Equal(Result{Make: "Homemade", Model: "Box Truck", Known: false}, result)
+}
+
+func (suite *CatalogTestSuite) TestMake() {
+	suite.Equal("Mercedes-Benz", suite.catalog.Make("mercedes benz"))
+	suite.Equal("Homemade", suite.catalog.Make(" Homemade"))
+}
+
+func (suite *CatalogTestSuite) TestHasMake() {
+	suite.True(suite.catalog.HasMake("vw"))
+	suite.True(suite.catalog.HasMake("Ford"))
+	suite.False(suite.catalog.HasMake("Peugeot"))
+}
+
+func (suite *CatalogTestSuite) TestLoadUnknownMake() {
+	_, err := Load(strings.NewReader("make,aliases\nFord,\n"), strings.NewReader("make,model,aliases\nFrod,Transit,\n"))
+
+	suite.NotNil(err)
+}
+
+func TestCatalogTestSuite(t *testing.T) {
+	suite.Run(t, new(CatalogTestSuite))
+}